@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/billmal071/bookdl/internal/config"
 )
 
 // APIClient uses the Anna's Archive API with an API key
@@ -24,7 +26,8 @@ func NewAPIClient(apiKey, baseURL string) *APIClient {
 		apiKey:  apiKey,
 		baseURL: baseURL,
 		http: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: config.ProxyTransport(),
 		},
 	}
 }
@@ -68,41 +71,107 @@ func (c *APIClient) SearchPage(ctx context.Context, query string, limit int, pag
 
 // GetDownloadInfo retrieves download information for a book
 func (c *APIClient) GetDownloadInfo(ctx context.Context, md5Hash string) (*DownloadInfo, error) {
+	return fastDownloadInfo(ctx, c.http, c.baseURL, c.apiKey, md5Hash)
+}
+
+// fastDownloadInfo asks the member fast_download API for a direct,
+// authenticated download URL (no countdown or browser needed), along with
+// the file's name and size
+func fastDownloadInfo(ctx context.Context, client *http.Client, baseURL, apiKey, md5Hash string) (*DownloadInfo, error) {
+	result, err := fetchFastDownload(ctx, client, baseURL, apiKey, md5Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// download_url is the link to use; older answers only list download_links
+	directURL := result.DownloadURL
+	if directURL == "" && len(result.DownloadLinks) > 0 {
+		directURL = result.DownloadLinks[0]
+	}
+	if directURL == "" {
+		return nil, fmt.Errorf("fast download response has no download URL: %w", ErrNoPublicMirrors)
+	}
+
+	info := &DownloadInfo{
+		DirectURL:  directURL,
+		MirrorURLs: []string{directURL},
+		Filename:   result.Filename,
+		FileSize:   result.FileSize,
+	}
+	for _, link := range result.DownloadLinks {
+		if link != "" && link != directURL {
+			info.MirrorURLs = append(info.MirrorURLs, link)
+		}
+	}
+	return info, nil
+}
+
+// withFastDownload puts the links from the fast_download API ahead of the
+// ones scraped from the book page. The API's filename and size win over
+// the page's.
+func withFastDownload(page, fast *DownloadInfo) *DownloadInfo {
+	seen := make(map[string]bool, len(fast.MirrorURLs))
+	for _, u := range fast.MirrorURLs {
+		seen[u] = true
+	}
+	mirrors := append([]string{}, fast.MirrorURLs...)
+	for _, u := range page.MirrorURLs {
+		if !seen[u] {
+			mirrors = append(mirrors, u)
+		}
+	}
+
+	page.DirectURL = fast.DirectURL
+	page.MirrorURLs = mirrors
+	if fast.Filename != "" {
+		page.Filename = fast.Filename
+	}
+	if fast.FileSize > 0 {
+		page.FileSize = fast.FileSize
+	}
+	return page
+}
+
+// fastDownloadResponse is the member fast_download API's answer for a book
+type fastDownloadResponse struct {
+	DownloadURL   string   `json:"download_url"`
+	DownloadLinks []string `json:"download_links"`
+	Filename      string   `json:"filename"`
+	FileSize      int64    `json:"filesize"`
+	Error         string   `json:"error"`
+}
+
+// fetchFastDownload calls the member fast_download API for md5Hash. The
+// API's own error message is preferred over the bare HTTP status.
+func fetchFastDownload(ctx context.Context, client *http.Client, baseURL, apiKey, md5Hash string) (*fastDownloadResponse, error) {
 	url := fmt.Sprintf("https://%s/dyn/api/fast_download.json?md5=%s&key=%s",
-		c.baseURL, md5Hash, c.apiKey)
+		baseURL, md5Hash, apiKey)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.http.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: %s", resp.Status)
-	}
-
-	var result struct {
-		DownloadLinks []string `json:"download_links"`
-		Filename      string   `json:"filename"`
-		FileSize      int64    `json:"filesize"`
-	}
+	var result fastDownloadResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("API error: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("invalid fast download response: %w", err)
 	}
 
-	info := &DownloadInfo{
-		Filename:   result.Filename,
-		FileSize:   result.FileSize,
-		MirrorURLs: result.DownloadLinks,
+	if result.Error != "" {
+		return nil, fmt.Errorf("fast download error: %s", result.Error)
 	}
-	if len(result.DownloadLinks) > 0 {
-		info.DirectURL = result.DownloadLinks[0]
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error: %s", resp.Status)
 	}
 
-	return info, nil
+	return &result, nil
 }
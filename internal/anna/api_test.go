@@ -0,0 +1,142 @@
+package anna
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fastDownloadServer answers fast_download API calls with status and body
+func fastDownloadServer(t *testing.T, status int, body string) (*httptest.Server, string) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dyn/api/fast_download.json" || r.URL.Query().Get("key") != "secret" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, strings.TrimPrefix(srv.URL, "https://")
+}
+
+func TestFastDownloadInfo(t *testing.T) {
+	const hash = "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    *DownloadInfo
+		wantErr string
+	}{
+		{
+			name:   "download_url",
+			status: http.StatusOK,
+			body:   `{"download_url": "https://fast.example/a.epub", "download_links": ["https://fast.example/b.epub"], "filename": "Dune.epub", "filesize": 1234}`,
+			want: &DownloadInfo{
+				DirectURL:  "https://fast.example/a.epub",
+				MirrorURLs: []string{"https://fast.example/a.epub", "https://fast.example/b.epub"},
+				Filename:   "Dune.epub",
+				FileSize:   1234,
+			},
+		},
+		{
+			name:   "download_links only",
+			status: http.StatusOK,
+			body:   `{"download_links": ["https://fast.example/b.epub"], "filename": "Dune.epub"}`,
+			want: &DownloadInfo{
+				DirectURL:  "https://fast.example/b.epub",
+				MirrorURLs: []string{"https://fast.example/b.epub"},
+				Filename:   "Dune.epub",
+			},
+		},
+		{
+			name:    "API error",
+			status:  http.StatusForbidden,
+			body:    `{"error": "Invalid secret key"}`,
+			wantErr: "Invalid secret key",
+		},
+		{
+			name:    "missing URL",
+			status:  http.StatusOK,
+			body:    `{"filename": "Dune.epub"}`,
+			wantErr: "no download URL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, host := fastDownloadServer(t, tt.status, tt.body)
+			got, err := fastDownloadInfo(context.Background(), srv.Client(), host, "secret", hash)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFastDownloadInfoMissingURLIsNoMirrors(t *testing.T) {
+	srv, host := fastDownloadServer(t, http.StatusOK, `{}`)
+	_, err := fastDownloadInfo(context.Background(), srv.Client(), host, "secret", "0123456789abcdef0123456789abcdef")
+	if !errors.Is(err, ErrNoPublicMirrors) {
+		t.Errorf("err = %v, want ErrNoPublicMirrors", err)
+	}
+}
+
+func TestAPIClientGetDownloadInfo(t *testing.T) {
+	srv, host := fastDownloadServer(t, http.StatusOK, `{"download_url": "https://fast.example/a.epub", "filename": "Dune.epub", "filesize": 99}`)
+	c := NewAPIClient("secret", host)
+	c.http = srv.Client()
+
+	info, err := c.GetDownloadInfo(context.Background(), "0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.DirectURL != "https://fast.example/a.epub" || info.Filename != "Dune.epub" || info.FileSize != 99 {
+		t.Errorf("got %+v", info)
+	}
+}
+
+func TestWithFastDownload(t *testing.T) {
+	page := &DownloadInfo{
+		DirectURL:  "https://annas-archive.li/slow_download/x/0/0",
+		MirrorURLs: []string{"https://annas-archive.li/slow_download/x/0/0", "https://fast.example/a.epub"},
+		Filename:   "page name.epub",
+		FileSize:   50,
+		SHA1:       "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+		Volumes:    []Volume{{MD5Hash: "fedcba9876543210fedcba9876543210", Title: "Vol. 2"}},
+	}
+	fast := &DownloadInfo{
+		DirectURL:  "https://fast.example/a.epub",
+		MirrorURLs: []string{"https://fast.example/a.epub"},
+		Filename:   "Dune.epub",
+	}
+
+	got := withFastDownload(page, fast)
+	if got.DirectURL != fast.DirectURL {
+		t.Errorf("DirectURL = %q", got.DirectURL)
+	}
+	if want := []string{"https://fast.example/a.epub", "https://annas-archive.li/slow_download/x/0/0"}; !reflect.DeepEqual(got.MirrorURLs, want) {
+		t.Errorf("MirrorURLs = %q, want %q", got.MirrorURLs, want)
+	}
+	if got.Filename != "Dune.epub" || got.FileSize != 50 {
+		t.Errorf("Filename, FileSize = %q, %d; want the API's name and the page's size", got.Filename, got.FileSize)
+	}
+	if got.SHA1 == "" || len(got.Volumes) != 1 {
+		t.Error("the page's checksum or volumes were lost")
+	}
+}
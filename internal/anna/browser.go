@@ -2,11 +2,12 @@ package anna
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/url"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -14,45 +15,53 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/chromedp/chromedp"
 	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/log"
 )
 
-// silentLogger discards all log output
-var silentLogger = log.New(io.Discard, "", 0)
-
-// browserPool manages a shared browser instance for reuse
+// browserPool holds one headless Chrome shared by every browser operation
+// in the process. It's started on first use; each operation gets its own
+// tab, so a batch resolving many links pays Chrome's startup cost once.
 type browserPool struct {
 	mu          sync.Mutex
-	allocCtx    context.Context
 	allocCancel context.CancelFunc
 	browserCtx  context.Context
 	cancelFunc  context.CancelFunc
-	inUse       bool
 }
 
 var sharedBrowserPool = &browserPool{}
 
-// getBrowserContext returns a reusable browser context
+// getBrowserContext returns a new tab in the shared browser, starting it if
+// needed. The tab is closed when parentCtx is done or the returned cancel
+// func is called, so per-operation timeouts and cancellation still apply.
 func (p *browserPool) getBrowserContext(parentCtx context.Context) (context.Context, context.CancelFunc, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Check if existing browser is still valid
-	if p.browserCtx != nil {
-		select {
-		case <-p.browserCtx.Done():
-			// Browser context was cancelled, need to recreate
-			p.cleanup()
-		default:
-			// Browser is still valid, create a new tab context
-			tabCtx, tabCancel := chromedp.NewContext(p.browserCtx,
-				chromedp.WithLogf(silentLogger.Printf),
-				chromedp.WithErrorf(silentLogger.Printf),
-			)
-			return tabCtx, tabCancel, nil
+	// Chrome may have exited since the last operation
+	if p.browserCtx != nil && p.browserCtx.Err() != nil {
+		p.cleanup()
+	}
+	if p.browserCtx == nil {
+		if err := p.start(); err != nil {
+			return nil, nil, err
 		}
 	}
 
-	// Create new browser instance
+	tabCtx, tabCancel := chromedp.NewContext(p.browserCtx,
+		chromedp.WithLogf(log.Debugf),
+		chromedp.WithErrorf(log.Debugf),
+	)
+	stop := context.AfterFunc(parentCtx, tabCancel)
+
+	return tabCtx, func() {
+		stop()
+		tabCancel()
+	}, nil
+}
+
+// start launches Chrome. Tabs only share a browser that's already running,
+// otherwise each would launch (and on cancel, kill) its own.
+func (p *browserPool) start() error {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("headless", true),
 		chromedp.Flag("disable-gpu", true),
@@ -60,22 +69,38 @@ func (p *browserPool) getBrowserContext(parentCtx context.Context) (context.Cont
 		chromedp.Flag("disable-dev-shm-usage", true),
 		chromedp.Flag("disable-background-networking", true),
 		chromedp.Flag("disable-extensions", true),
-		chromedp.UserAgent("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
+		chromedp.UserAgent(config.RandomUserAgent()),
 	)
+	proxy, err := config.ProxyURL()
+	if err != nil {
+		return err
+	}
+	if proxy != nil {
+		// Chrome only knows socks5, which already resolves names through the proxy
+		if proxy.Scheme == "socks5h" {
+			proxy.Scheme = "socks5"
+		}
+		opts = append(opts, chromedp.ProxyServer(proxy.String()))
+	}
 
-	p.allocCtx, p.allocCancel = chromedp.NewExecAllocator(context.Background(), opts...)
-	p.browserCtx, p.cancelFunc = chromedp.NewContext(p.allocCtx,
-		chromedp.WithLogf(silentLogger.Printf),
-		chromedp.WithErrorf(silentLogger.Printf),
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	browserCtx, cancel := chromedp.NewContext(allocCtx,
+		chromedp.WithLogf(log.Debugf),
+		chromedp.WithErrorf(log.Debugf),
 	)
 
-	// Create a tab context for this request
-	tabCtx, tabCancel := chromedp.NewContext(p.browserCtx,
-		chromedp.WithLogf(silentLogger.Printf),
-		chromedp.WithErrorf(silentLogger.Printf),
-	)
+	// An empty Run allocates the browser and ties it to browserCtx
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancel()
+		allocCancel()
+		return fmt.Errorf("failed to start browser: %w", err)
+	}
+	log.Debug("browser started")
 
-	return tabCtx, tabCancel, nil
+	p.allocCancel = allocCancel
+	p.browserCtx = browserCtx
+	p.cancelFunc = cancel
+	return nil
 }
 
 // cleanup releases browser resources
@@ -89,7 +114,24 @@ func (p *browserPool) cleanup() {
 		p.allocCancel = nil
 	}
 	p.browserCtx = nil
-	p.allocCtx = nil
+}
+
+// ErrBrowserDisabled is returned instead of starting the headless browser
+// when it's turned off with --no-browser or browser.enabled
+var ErrBrowserDisabled = errors.New("browser disabled")
+
+// browserOff is set by DisableBrowser
+var browserOff bool
+
+// DisableBrowser turns off the headless browser for this run, whatever
+// browser.enabled says
+func DisableBrowser() {
+	browserOff = true
+}
+
+// BrowserEnabled reports whether the headless browser may be used
+func BrowserEnabled() bool {
+	return !browserOff && config.Get().Browser.Enabled
 }
 
 // CloseBrowser closes the shared browser instance
@@ -100,11 +142,17 @@ func CloseBrowser() {
 }
 
 // BrowserClient uses a headless browser to access Anna's Archive
-// This is used as a fallback when Cloudflare blocks regular HTTP requests
+// This is used as a fallback when Cloudflare blocks regular HTTP requests.
+// All clients share one browser, started on first use.
 type BrowserClient struct {
 	baseURL string
 }
 
+// Close shuts down the shared browser. It's started again if needed.
+func (c *BrowserClient) Close() {
+	CloseBrowser()
+}
+
 // NewBrowserClient creates a new browser client
 func NewBrowserClient(baseURL string) *BrowserClient {
 	if baseURL == "" {
@@ -120,6 +168,10 @@ func (c *BrowserClient) Search(ctx context.Context, query string, limit int) ([]
 
 // SearchPage searches for books with pagination using a headless browser
 func (c *BrowserClient) SearchPage(ctx context.Context, query string, limit int, page int) ([]*Book, error) {
+	if !BrowserEnabled() {
+		return nil, ErrBrowserDisabled
+	}
+
 	// Get a browser context from the shared pool
 	browserCtx, cancel, err := sharedBrowserPool.getBrowserContext(ctx)
 	if err != nil {
@@ -139,6 +191,7 @@ func (c *BrowserClient) SearchPage(ctx context.Context, query string, limit int,
 
 	var htmlContent string
 	err = chromedp.Run(browserCtx,
+		setMemberCookies(searchURL),
 		chromedp.Navigate(searchURL),
 		// Wait for page to load (Cloudflare challenge should resolve)
 		chromedp.Sleep(5*time.Second),
@@ -162,6 +215,10 @@ func (c *BrowserClient) SearchPage(ctx context.Context, query string, limit int,
 
 // GetDownloadInfo retrieves download links using a headless browser
 func (c *BrowserClient) GetDownloadInfo(ctx context.Context, md5Hash string) (*DownloadInfo, error) {
+	if !BrowserEnabled() {
+		return nil, ErrBrowserDisabled
+	}
+
 	// Get a browser context from the shared pool
 	browserCtx, cancel, err := sharedBrowserPool.getBrowserContext(ctx)
 	if err != nil {
@@ -176,6 +233,7 @@ func (c *BrowserClient) GetDownloadInfo(ctx context.Context, md5Hash string) (*D
 
 	var htmlContent string
 	err = chromedp.Run(browserCtx,
+		setMemberCookies(pageURL),
 		chromedp.Navigate(pageURL),
 		chromedp.Sleep(5*time.Second),
 		chromedp.OuterHTML("html", &htmlContent),
@@ -184,11 +242,20 @@ func (c *BrowserClient) GetDownloadInfo(ctx context.Context, md5Hash string) (*D
 		return nil, fmt.Errorf("browser page load failed: %w", err)
 	}
 
-	return parseDownloadPageHTML(htmlContent, c.baseURL)
+	info, err := parseDownloadPageHTML(htmlContent, c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	preferFastDownload(info)
+	return info, nil
 }
 
 // ResolveDownloadURL navigates to a slow_download page and extracts the actual download URL
 func (c *BrowserClient) ResolveDownloadURL(ctx context.Context, slowDownloadURL string) (string, error) {
+	if !BrowserEnabled() {
+		return "", ErrBrowserDisabled
+	}
+
 	cfg := config.Get()
 
 	// Get a browser context from the shared pool
@@ -205,34 +272,39 @@ func (c *BrowserClient) ResolveDownloadURL(ctx context.Context, slowDownloadURL
 	var htmlContent string
 	var downloadURL string
 
-	if cfg.Browser.VerboseLogging {
-		fmt.Printf("[Browser] Navigating to: %s\n", slowDownloadURL)
-	}
+	log.Debug("browser navigating", "url", slowDownloadURL)
 
-	// Navigate to slow_download page and wait for download link to appear
-	err = chromedp.Run(browserCtx,
+	// Navigate to slow_download page, then wait out any anti-bot challenge
+	loadCtx, loadCancel := context.WithTimeout(browserCtx, cfg.Browser.PageLoadTimeout)
+	err = chromedp.Run(loadCtx,
+		setMemberCookies(slowDownloadURL),
 		chromedp.Navigate(slowDownloadURL),
-		// Wait for anti-bot challenge to resolve (longer wait for Cloudflare)
-		chromedp.Sleep(8*time.Second),
 	)
+	if err == nil {
+		err = waitForChallenge(loadCtx, cfg.Browser.PollInterval)
+	}
+	loadCancel()
 	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("operation cancelled")
+		}
 		return "", fmt.Errorf("browser navigation failed: %w", err)
 	}
 
-	if cfg.Browser.VerboseLogging {
-		fmt.Println("[Browser] Page loaded, waiting for download link...")
-	}
+	log.Debug("browser page loaded, waiting for download link")
 
-	// Calculate polling parameters
 	pollInterval := cfg.Browser.PollInterval
 	maxWait := cfg.Browser.MaxCountdownWait
-	maxPolls := int(maxWait / pollInterval)
 
-	fmt.Printf("Waiting for download link (max %v)...\n", maxWait)
+	// Progress goes to stderr, clear of machine-readable output on stdout
+	fmt.Fprintf(os.Stderr, "Waiting for download link (max %v)...\n", maxWait)
 
-	// Poll for the download link to appear with progress feedback
+	// Check for the download link, sleeping out the page's countdown when it
+	// shows one and polling otherwise
 	startTime := time.Now()
-	for i := 0; i < maxPolls; i++ {
+	lastProgress := startTime
+	announced := false
+	for time.Since(startTime) < maxWait {
 		select {
 		case <-ctx.Done():
 			return "", fmt.Errorf("operation cancelled")
@@ -251,18 +323,11 @@ func (c *BrowserClient) ResolveDownloadURL(ctx context.Context, slowDownloadURL
 		downloadURL = extractDownloadURL(htmlContent, c.baseURL)
 		if downloadURL != "" {
 			elapsed := time.Since(startTime)
-			fmt.Printf("Download link found after %v\n", elapsed.Round(time.Second))
-			if cfg.Browser.VerboseLogging {
-				fmt.Printf("[Browser] Resolved URL: %s\n", downloadURL)
-			}
+			fmt.Fprintf(os.Stderr, "Download link found after %v\n", elapsed.Round(time.Second))
+			log.Debug("browser resolved download URL", "url", downloadURL)
 			break
 		}
 
-		// Check if there's a countdown timer
-		hasCountdown := strings.Contains(htmlContent, "Please wait") ||
-			strings.Contains(htmlContent, "countdown") ||
-			strings.Contains(htmlContent, "seconds")
-
 		// Check if page shows an error or no files available
 		hasError := strings.Contains(htmlContent, "No files available") ||
 			strings.Contains(htmlContent, "File not found") ||
@@ -270,28 +335,41 @@ func (c *BrowserClient) ResolveDownloadURL(ctx context.Context, slowDownloadURL
 			strings.Contains(htmlContent, "Error 403")
 
 		if hasError {
-			if cfg.Browser.VerboseLogging {
-				fmt.Println("[Browser] Error page detected")
-			}
+			log.Warn("error page while waiting for download link", "url", slowDownloadURL)
 			break
 		}
 
-		// Show progress every 5 polls (15 seconds by default)
-		if i > 0 && i%5 == 0 {
-			elapsed := time.Since(startTime)
-			remaining := maxWait - elapsed
-			if hasCountdown {
-				fmt.Printf("Still waiting for countdown... (%v elapsed, %v remaining)\n",
-					elapsed.Round(time.Second), remaining.Round(time.Second))
+		wait := pollInterval
+		countdown, hasCountdown := parseCountdown(htmlContent)
+		if hasCountdown {
+			// The link appears when the countdown ends; the extra poll
+			// interval covers the page's own timer lagging ours
+			wait = countdown + pollInterval
+			if !announced {
+				fmt.Fprintf(os.Stderr, "Countdown: %v\n", countdown)
+				announced = true
 			}
+			log.Debug("countdown detected, waiting", "countdown", countdown)
 		}
 
-		if cfg.Browser.VerboseLogging && hasCountdown {
-			fmt.Printf("[Browser] Poll %d/%d: Countdown detected, waiting...\n", i+1, maxPolls)
+		elapsed := time.Since(startTime)
+		remaining := maxWait - elapsed
+		if remaining <= 0 {
+			break
+		}
+		if wait > remaining {
+			wait = remaining
+		}
+
+		// Show progress every 15 seconds while blind polling
+		if !hasCountdown && time.Since(lastProgress) >= 15*time.Second {
+			fmt.Fprintf(os.Stderr, "Still waiting for download link... (%v elapsed, %v remaining)\n",
+				elapsed.Round(time.Second), remaining.Round(time.Second))
+			lastProgress = time.Now()
 		}
 
 		// Wait before checking again
-		err = chromedp.Run(browserCtx, chromedp.Sleep(pollInterval))
+		err = chromedp.Run(browserCtx, chromedp.Sleep(wait))
 		if err != nil {
 			return "", fmt.Errorf("polling interrupted: %w", err)
 		}
@@ -306,6 +384,53 @@ func (c *BrowserClient) ResolveDownloadURL(ctx context.Context, slowDownloadURL
 	return downloadURL, nil
 }
 
+// waitForChallenge polls the current page until it's no longer a Cloudflare
+// challenge, or ctx ends
+func waitForChallenge(ctx context.Context, pollInterval time.Duration) error {
+	for {
+		var html string
+		if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &html)); err != nil {
+			return err
+		}
+		if !isChallengePage(html) {
+			return nil
+		}
+		log.Debug("cloudflare challenge in browser, waiting")
+		if err := chromedp.Run(ctx, chromedp.Sleep(pollInterval)); err != nil {
+			return fmt.Errorf("cloudflare challenge didn't clear: %w", err)
+		}
+	}
+}
+
+// isChallengePage reports whether html is a Cloudflare challenge page
+func isChallengePage(html string) bool {
+	return strings.Contains(html, "cf-browser-verification") ||
+		strings.Contains(html, "Just a moment...") ||
+		strings.Contains(html, "_cf_chl")
+}
+
+// countdownPattern matches the wait Anna's Archive shows on slow_download
+// pages: "Please wait 45 seconds"
+var countdownPattern = regexp.MustCompile(`(?i)wait\s+(?:<[^>]*>\s*)*(\d{1,4})\s*(?:<[^>]*>\s*)*sec`)
+
+// parseCountdown returns the countdown shown on a slow_download page, if any.
+// The js-partner-countdown element holds the live value; the page text is
+// the fallback.
+func parseCountdown(html string) (time.Duration, bool) {
+	if doc, err := goquery.NewDocumentFromReader(strings.NewReader(html)); err == nil {
+		text := strings.TrimSpace(doc.Find(".js-partner-countdown").First().Text())
+		if n, err := strconv.Atoi(text); err == nil && n > 0 {
+			return time.Duration(n) * time.Second, true
+		}
+	}
+	if m := countdownPattern.FindStringSubmatch(html); len(m) == 2 {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > 0 {
+			return time.Duration(n) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
 // extractDownloadURL parses HTML and finds the best download URL
 func extractDownloadURL(html string, baseURL string) string {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
@@ -316,13 +441,6 @@ func extractDownloadURL(html string, baseURL string) string {
 	var downloadURL string
 	var fallbackURL string
 
-	// IPFS gateway patterns (comprehensive list)
-	ipfsGateways := []string{
-		"ipfs.io", "dweb.link", "cloudflare-ipfs", "gateway.pinata", "w3s.link",
-		"ipfs.eth", "cf-ipfs", "gateway.ipfs", "ipfs.fleek", "ipfs.infura",
-		"nftstorage.link", "4everland.io", "ipfs-gateway", "hardbin.com",
-	}
-
 	// Other trusted download sources
 	trustedSources := []string{
 		"libgen.li", "libgen.is", "libgen.rs", "libgen.st", "library.lol",
@@ -354,11 +472,9 @@ func extractDownloadURL(html string, baseURL string) string {
 		}
 
 		// Priority 1: IPFS gateways (actual file downloads)
-		for _, gateway := range ipfsGateways {
-			if strings.Contains(hrefLower, gateway) {
-				downloadURL = href
-				return
-			}
+		if onIPFSGateway(href) {
+			downloadURL = href
+			return
 		}
 
 		// Priority 2: Direct file links with known extensions
@@ -457,6 +573,7 @@ func parseSearchResultsHTML(html string, limit int, baseURL string) ([]*Book, er
 			MD5Hash: strings.ToLower(matches[1]),
 			PageURL: fmt.Sprintf("https://%s/md5/%s", baseURL, matches[1]),
 		}
+		book.CoverURL = findCoverURL(s, book.MD5Hash, baseURL)
 
 		// Extract title
 		if title := s.Find("h3").First().Text(); title != "" {
@@ -499,15 +616,13 @@ func parseSearchResultsHTML(html string, limit int, baseURL string) ([]*Book, er
 		// Size detection (e.g., "5.2MB", "1.1 GB")
 		if sizeMatch := regexp.MustCompile(`(\d+\.?\d*)\s*(KB|MB|GB)`).FindStringSubmatch(metaText); len(sizeMatch) > 0 {
 			book.Size = sizeMatch[0]
+			book.SizeBytes = ParseSizeToBytes(book.Size)
 		}
 
 		// Language detection
-		for _, lang := range []string{"english", "russian", "german", "french", "spanish", "chinese", "japanese", "portuguese", "italian"} {
-			if strings.Contains(metaText, lang) {
-				book.Language = strings.Title(lang)
-				break
-			}
-		}
+		book.Language = detectLanguage(metaText)
+
+		parseResultDetails(s, book)
 
 		if book.Title != "" && book.MD5Hash != "" && !seenMD5[book.MD5Hash] {
 			seenMD5[book.MD5Hash] = true
@@ -592,15 +707,12 @@ func parseDownloadPageHTML(html string, baseURL string) (*DownloadInfo, error) {
 		info.DirectURL = info.MirrorURLs[0]
 	}
 
-	// Try to extract filename from page
-	doc.Find("h1, h2, .title").Each(func(_ int, s *goquery.Selection) {
-		if info.Filename == "" {
-			info.Filename = strings.TrimSpace(s.Text())
-		}
-	})
+	info.Filename, info.FileSize = parsePageDetails(doc.Selection)
+
+	info.SHA1, info.SHA256 = parseHashes(doc.Text())
 
 	if info.DirectURL == "" && len(info.MirrorURLs) == 0 {
-		return nil, fmt.Errorf("no download links found")
+		return nil, classifyMissingLinks(html)
 	}
 
 	return info, nil
@@ -1,6 +1,8 @@
 package anna
 
 import (
+	"strings"
+
 	"github.com/billmal071/bookdl/internal/config"
 )
 
@@ -16,6 +18,25 @@ func NewClient() Client {
 	return NewScraperClient(cfg.Anna.BaseURL)
 }
 
+// Mirrors returns the Anna's Archive domains to try, in order: anna.base_url
+// followed by anna.base_urls, without duplicates
+func Mirrors() []string {
+	cfg := config.Get()
+	seen := make(map[string]bool)
+	var mirrors []string
+	for _, m := range append([]string{cfg.Anna.BaseURL}, cfg.Anna.BaseURLs...) {
+		m = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(m), "https://"), "/")
+		if m != "" && !seen[m] {
+			seen[m] = true
+			mirrors = append(mirrors, m)
+		}
+	}
+	if len(mirrors) == 0 {
+		mirrors = []string{"annas-archive.li"}
+	}
+	return mirrors
+}
+
 // GetBaseURL returns the configured base URL
 func GetBaseURL() string {
 	cfg := config.Get()
@@ -24,3 +45,9 @@ func GetBaseURL() string {
 	}
 	return "annas-archive.li"
 }
+
+// PhraseQuery wraps query in quotes so Anna's Archive matches it as a
+// phrase rather than as loose keywords. Quotes inside query are dropped.
+func PhraseQuery(query string) string {
+	return `"` + strings.ReplaceAll(strings.TrimSpace(query), `"`, "") + `"`
+}
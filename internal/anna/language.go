@@ -0,0 +1,93 @@
+package anna
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// languages maps ISO 639 codes to the English names Anna's Archive shows,
+// e.g. "Dutch [nl]"
+var languages = map[string]string{
+	"af": "Afrikaans", "am": "Amharic", "ar": "Arabic", "az": "Azerbaijani",
+	"be": "Belarusian", "bg": "Bulgarian", "bn": "Bengali", "bo": "Tibetan",
+	"bs": "Bosnian", "ca": "Catalan", "cs": "Czech", "cy": "Welsh",
+	"da": "Danish", "de": "German", "el": "Greek", "en": "English",
+	"eo": "Esperanto", "es": "Spanish", "et": "Estonian", "eu": "Basque",
+	"fa": "Persian", "fi": "Finnish", "fr": "French", "ga": "Irish",
+	"gl": "Galician", "gu": "Gujarati", "he": "Hebrew", "hi": "Hindi",
+	"hr": "Croatian", "hu": "Hungarian", "hy": "Armenian", "id": "Indonesian",
+	"is": "Icelandic", "it": "Italian", "ja": "Japanese", "ka": "Georgian",
+	"kk": "Kazakh", "km": "Khmer", "kn": "Kannada", "ko": "Korean",
+	"ku": "Kurdish", "ky": "Kyrgyz", "la": "Latin", "lo": "Lao",
+	"lt": "Lithuanian", "lv": "Latvian", "mk": "Macedonian", "ml": "Malayalam",
+	"mn": "Mongolian", "mr": "Marathi", "ms": "Malay", "my": "Burmese",
+	"nb": "Norwegian", "ne": "Nepali", "nl": "Dutch", "nn": "Norwegian",
+	"no": "Norwegian", "pa": "Punjabi", "pl": "Polish", "ps": "Pashto",
+	"pt": "Portuguese", "ro": "Romanian", "ru": "Russian", "sa": "Sanskrit",
+	"si": "Sinhala", "sk": "Slovak", "sl": "Slovenian", "sq": "Albanian",
+	"sr": "Serbian", "sv": "Swedish", "sw": "Swahili", "ta": "Tamil",
+	"te": "Telugu", "tg": "Tajik", "th": "Thai", "tl": "Tagalog",
+	"tr": "Turkish", "tt": "Tatar", "uk": "Ukrainian", "ur": "Urdu",
+	"uz": "Uzbek", "vi": "Vietnamese", "yi": "Yiddish", "zh": "Chinese",
+	"grc": "Ancient Greek", "ang": "Old English", "chu": "Church Slavonic",
+}
+
+// languageNames maps each lowercased name in languages back to its
+// canonical form
+var languageNames = func() map[string]string {
+	names := make(map[string]string, len(languages))
+	for _, name := range languages {
+		names[strings.ToLower(name)] = name
+	}
+	return names
+}()
+
+// languageTag matches the ISO code Anna's Archive puts after a language
+// name: "[nl]", "[zh-Hant]"
+var languageTag = regexp.MustCompile(`\[([a-zA-Z]{2,3})(?:-[a-zA-Z0-9]+)?\]`)
+
+// detectLanguage returns the canonical name of the first language in a
+// search result's metadata text. Anna's Archive's own "[code]" tags are
+// trusted first; otherwise a language name has to appear as whole words,
+// so "Brazilian Portuguese" is Portuguese while "Englishman" isn't English.
+// Returns "" if no language is found.
+func detectLanguage(metaText string) string {
+	for _, m := range languageTag.FindAllStringSubmatch(metaText, -1) {
+		if name, ok := languages[strings.ToLower(m[1])]; ok {
+			return name
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(metaText), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for i, word := range words {
+		// Two-word names ("ancient greek") before one-word ones ("greek")
+		if i+1 < len(words) {
+			if name, ok := languageNames[word+" "+words[i+1]]; ok {
+				return name
+			}
+		}
+		if name, ok := languageNames[word]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// NormalizeLanguage returns the canonical name for a language given by
+// name or ISO code, ignoring case ("de", "GERMAN" and "de-AT" are all
+// "German"). Unknown languages are returned trimmed but otherwise as given.
+func NormalizeLanguage(language string) string {
+	language = strings.TrimSpace(language)
+	lower := strings.ToLower(language)
+	if name, ok := languageNames[lower]; ok {
+		return name
+	}
+	code, _, _ := strings.Cut(strings.ReplaceAll(lower, "_", "-"), "-")
+	if name, ok := languages[code]; ok {
+		return name
+	}
+	return language
+}
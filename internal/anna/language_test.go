@@ -0,0 +1,52 @@
+package anna
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		meta string
+		want string
+	}{
+		{"tagged", "Dutch [nl], .epub, 1.2MB", "Dutch"},
+		{"multilingual keeps the first", "English [en], French [fr], .pdf, 10MB", "English"},
+		{"tag with a script", "Chinese [zh-Hant], .pdf, 5MB", "Chinese"},
+		{"three-letter code", "Ancient Greek [grc], .djvu", "Ancient Greek"},
+		{"tag wins over an earlier name", "Spanish translation, German [de], .epub", "German"},
+		{"unknown tag falls back to names", "Klingon [tlh], Russian, .fb2", "Russian"},
+		{"name only", "Brazilian Portuguese, .epub", "Portuguese"},
+		{"two-word name before one word", "ancient greek, .pdf", "Ancient Greek"},
+		{"part of a word", "The Englishman's Guide, .pdf", ""},
+		{"none", ".cbz, 40MB, Comic book", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectLanguage(tt.meta); got != tt.want {
+				t.Errorf("detectLanguage(%q) = %q, want %q", tt.meta, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"de", "German"},
+		{"GERMAN", "German"},
+		{"de-AT", "German"},
+		{"pt_BR", "Portuguese"},
+		{" nb ", "Norwegian"},
+		{"nn", "Norwegian"},
+		{"ancient greek", "Ancient Greek"},
+		{"zh-Hant", "Chinese"},
+		{"Klingon", "Klingon"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeLanguage(tt.in); got != tt.want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
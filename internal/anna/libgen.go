@@ -0,0 +1,143 @@
+package anna
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/billmal071/bookdl/internal/config"
+)
+
+// libgenMaxHops bounds how many intermediate pages ResolveLibgenURL follows:
+// libgen.li goes file.php -> ads.php -> get.php
+const libgenMaxHops = 3
+
+// IsLibgenPage reports whether rawURL is a LibGen page that leads to the
+// file rather than the file itself: libgen.li's file.php and ads.php, and
+// library.lol's /main/ and /fiction/ pages. get.php links are the file.
+func IsLibgenPage(rawURL string) bool {
+	if ClassifyMirror(rawURL) != MirrorLibgen {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	path := strings.ToLower(u.Path)
+	switch {
+	case strings.HasSuffix(path, "/get.php"):
+		return false
+	case strings.HasSuffix(path, "/file.php"), strings.HasSuffix(path, "/ads.php"):
+		return true
+	}
+	// Files are served from download.library.lol under the same paths
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	return host == "library.lol" && (strings.HasPrefix(path, "/main/") || strings.HasPrefix(path, "/fiction/"))
+}
+
+// IsDownloadPage reports whether rawURL is a page that leads to the file
+// rather than the file itself: a slow_download or fast_download page, or a
+// LibGen page
+func IsDownloadPage(rawURL string) bool {
+	return strings.Contains(rawURL, "/slow_download/") ||
+		strings.Contains(rawURL, "/fast_download/") ||
+		IsLibgenPage(rawURL)
+}
+
+// ResolveLibgenURL follows a LibGen page to the file's URL. The pages are
+// plain HTML, so unlike slow_download links no browser is needed; a page
+// that turns out to be the file is returned as is.
+func ResolveLibgenURL(ctx context.Context, pageURL string) (string, error) {
+	client := &http.Client{Timeout: 30 * time.Second, Transport: config.ProxyTransport()}
+
+	current := pageURL
+	for hop := 0; hop < libgenMaxHops; hop++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", current, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("User-Agent", config.RandomUserAgent())
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return "", fmt.Errorf("libgen page returned %s", resp.Status)
+		}
+
+		// Redirected to the file itself
+		if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+			resp.Body.Close()
+			return resp.Request.URL.String(), nil
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 2*1024*1024))
+		resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+
+		next, final := extractLibgenLink(string(body), resp.Request.URL)
+		if next == "" {
+			return "", fmt.Errorf("no download link on the libgen page %s", resp.Request.URL)
+		}
+		if final {
+			return next, nil
+		}
+		current = next
+	}
+	return "", fmt.Errorf("too many libgen pages before the file, starting at %s", pageURL)
+}
+
+// extractLibgenLink finds the way on from a LibGen page, resolved against
+// base. final is true for a link to the file: a get.php link or library.lol's
+// GET button. Otherwise the link is to another page (libgen.li's ads.php).
+func extractLibgenLink(html string, base *url.URL) (link string, final bool) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return "", false
+	}
+
+	var getLink, buttonLink, adsLink string
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		abs, err := base.Parse(strings.TrimSpace(href))
+		if err != nil || (abs.Scheme != "http" && abs.Scheme != "https") {
+			return
+		}
+		path := strings.ToLower(abs.Path)
+		switch {
+		case getLink == "" && strings.HasSuffix(path, "/get.php"):
+			getLink = abs.String()
+		case adsLink == "" && strings.HasSuffix(path, "/ads.php") && abs.Query().Get("md5") != "":
+			adsLink = abs.String()
+		case buttonLink == "" && strings.EqualFold(strings.TrimSpace(s.Text()), "GET"):
+			buttonLink = abs.String()
+		}
+	})
+
+	// library.lol puts its main link in #download; the other links there
+	// are mirrors of the same file
+	if first, ok := doc.Find("#download a[href]").First().Attr("href"); ok && buttonLink == "" {
+		if abs, err := base.Parse(strings.TrimSpace(first)); err == nil {
+			buttonLink = abs.String()
+		}
+	}
+
+	switch {
+	case getLink != "":
+		return getLink, true
+	case buttonLink != "":
+		return buttonLink, true
+	case adsLink != "":
+		return adsLink, false
+	}
+	return "", false
+}
@@ -0,0 +1,110 @@
+package anna
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsLibgenPage(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://libgen.li/file.php?id=12345", true},
+		{"https://libgen.li/ads.php?md5=0123456789abcdef0123456789abcdef", true},
+		{"https://libgen.rs/ADS.PHP?md5=0123456789abcdef0123456789abcdef", true},
+		{"https://libgen.li/get.php?md5=0123456789abcdef0123456789abcdef&key=ABCD", false},
+		{"https://library.lol/main/0123456789ABCDEF0123456789ABCDEF", true},
+		{"https://www.library.lol/fiction/0123456789abcdef0123456789abcdef", true},
+		{"https://download.library.lol/main/3314000/0123456789abcdef/Dune.epub", false},
+		{"https://libgen.li/index.php?req=dune", false},
+		{"https://annas-archive.li/slow_download/0123456789abcdef0123456789abcdef/0/0", false},
+		{"https://example.com/ads.php?md5=0123456789abcdef0123456789abcdef", false},
+	}
+	for _, tt := range tests {
+		if got := IsLibgenPage(tt.url); got != tt.want {
+			t.Errorf("IsLibgenPage(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestExtractLibgenLink(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		base      string
+		wantLink  string
+		wantFinal bool
+	}{
+		{
+			// The book's page links to its ads.php page, which has the file
+			fixture:   "libgen_file.html",
+			base:      "https://libgen.li/file.php?id=12345",
+			wantLink:  "https://libgen.li/ads.php?md5=0123456789ABCDEF0123456789ABCDEF",
+			wantFinal: false,
+		},
+		{
+			fixture:   "libgen_ads.html",
+			base:      "https://libgen.li/ads.php?md5=0123456789abcdef0123456789abcdef",
+			wantLink:  "https://libgen.li/get.php?md5=0123456789abcdef0123456789abcdef&key=ABCD1234",
+			wantFinal: true,
+		},
+		{
+			// The first #download link is the file; the rest are mirrors
+			fixture:   "library_lol.html",
+			base:      "https://library.lol/main/0123456789ABCDEF0123456789ABCDEF",
+			wantLink:  "https://download.library.lol/main/3314000/0123456789abcdef0123456789abcdef/Frank%20Herbert%20-%20Dune.epub",
+			wantFinal: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			html, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			base, _ := url.Parse(tt.base)
+			link, final := extractLibgenLink(string(html), base)
+			if link != tt.wantLink || final != tt.wantFinal {
+				t.Errorf("got %q, %v; want %q, %v", link, final, tt.wantLink, tt.wantFinal)
+			}
+		})
+	}
+}
+
+func TestExtractLibgenLinkNone(t *testing.T) {
+	base, _ := url.Parse("https://libgen.li/ads.php?md5=0123456789abcdef0123456789abcdef")
+	if link, _ := extractLibgenLink(`<html><a href="javascript:void(0)">GET</a><a href="/">Home</a></html>`, base); link != "" {
+		t.Errorf("got %q, want no link", link)
+	}
+}
+
+func TestResolveLibgenURL(t *testing.T) {
+	// file.php -> ads.php -> get.php, which sends the file
+	ads, _ := os.ReadFile(filepath.Join("testdata", "libgen_ads.html"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file.php":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/ads.php?md5=0123456789abcdef0123456789abcdef">Libgen</a>`))
+		case "/ads.php":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write(ads)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	got, err := ResolveLibgenURL(context.Background(), srv.URL+"/file.php?id=12345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/get.php?md5=0123456789abcdef0123456789abcdef&key=ABCD1234"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
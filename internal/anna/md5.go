@@ -0,0 +1,28 @@
+package anna
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// md5Hash matches a bare MD5 hash
+var md5Hash = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// md5Link matches the hash in an Anna's Archive book or download link on
+// any mirror domain: /md5/<hash>, /slow_download/<hash>/..., /fast_download/<hash>/...
+var md5Link = regexp.MustCompile(`/(?:md5|slow_download|fast_download)/([0-9a-fA-F]{32})(?:[/?#]|$)`)
+
+// ExtractMD5 returns the lowercase MD5 hash in input, which is either a
+// bare hash or an Anna's Archive link such as
+// https://annas-archive.se/md5/<hash>, with or without the scheme
+func ExtractMD5(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if md5Hash.MatchString(input) {
+		return strings.ToLower(input), nil
+	}
+	if m := md5Link.FindStringSubmatch(input); m != nil {
+		return strings.ToLower(m[1]), nil
+	}
+	return "", fmt.Errorf("invalid MD5: %q is neither a 32 character hash nor an Anna's Archive /md5/ link", input)
+}
@@ -0,0 +1,35 @@
+package anna
+
+import "testing"
+
+func TestExtractMD5(t *testing.T) {
+	const hash = "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name  string
+		in    string
+		want  string
+		valid bool
+	}{
+		{"bare", hash, hash, true},
+		{"uppercase", "0123456789ABCDEF0123456789ABCDEF", hash, true},
+		{"spaces", "  " + hash + "\n", hash, true},
+		{"book link", "https://annas-archive.li/md5/" + hash, hash, true},
+		{"no scheme", "annas-archive.se/md5/" + hash + "?tab=downloads", hash, true},
+		{"slow download link", "https://annas-archive.org/slow_download/" + hash + "/0/2", hash, true},
+		{"short", hash[:31], "", false},
+		{"not hex", "0123456789abcdef0123456789abcdeg", "", false},
+		{"longer link segment", "https://annas-archive.li/md5/" + hash + "0", "", false},
+		{"numeric ID", "42", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractMD5(tt.in)
+			if (err == nil) != tt.valid {
+				t.Fatalf("ExtractMD5(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractMD5(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
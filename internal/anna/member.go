@@ -0,0 +1,121 @@
+package anna
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/gocolly/colly/v2"
+	"github.com/billmal071/bookdl/internal/config"
+)
+
+// withMemberCookie sends the anna.member_cookie session with the
+// collector's requests, if one is set
+func withMemberCookie(collector *colly.Collector) {
+	cookie := config.MemberCookieHeader()
+	if cookie == "" {
+		return
+	}
+	collector.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("Cookie", cookie)
+	})
+}
+
+// setMemberCookies is a browser action that stores the anna.member_cookie
+// session for rawURL's domain, so the pages loaded after it are logged in
+func setMemberCookies(rawURL string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		cookies := config.MemberCookies()
+		if len(cookies) == 0 {
+			return nil
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		for _, c := range cookies {
+			err := network.SetCookie(c.Name, c.Value).
+				WithDomain(u.Hostname()).
+				WithPath("/").
+				WithSecure(true).
+				Do(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to set member cookie: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// preferFastDownload moves fast_download links to the front when a member
+// session is set, since they skip the slow_download countdown
+func preferFastDownload(info *DownloadInfo) {
+	if info == nil || config.MemberCookieHeader() == "" {
+		return
+	}
+
+	var fast, rest []string
+	for _, u := range info.MirrorURLs {
+		if strings.Contains(u, "/fast_download/") {
+			fast = append(fast, u)
+		} else {
+			rest = append(rest, u)
+		}
+	}
+	if len(fast) == 0 {
+		return
+	}
+	info.MirrorURLs = append(fast, rest...)
+	info.DirectURL = fast[0]
+}
+
+// ResolveFastDownload follows a fast_download link with the member session
+// and returns the file's URL. Anna's Archive either redirects straight to
+// the file or shows a page linking to it; no countdown or browser needed.
+func ResolveFastDownload(ctx context.Context, fastURL string) (string, error) {
+	cookie := config.MemberCookieHeader()
+	if cookie == "" {
+		return "", fmt.Errorf("%w: set anna.member_cookie to use fast downloads", ErrMembershipRequired)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fastURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", config.RandomUserAgent())
+	req.Header.Set("Cookie", cookie)
+
+	client := &http.Client{Timeout: 30 * time.Second, Transport: config.ProxyTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fast download returned %s", resp.Status)
+	}
+
+	// Redirected to the file itself
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return resp.Request.URL.String(), nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 2*1024*1024))
+	if err != nil {
+		return "", err
+	}
+	if downloadURL := extractDownloadURL(string(body), resp.Request.URL.Host); downloadURL != "" {
+		return downloadURL, nil
+	}
+	if classifyMissingLinks(string(body)) == ErrMembershipRequired {
+		return "", fmt.Errorf("%w: anna.member_cookie was not accepted, it may have expired", ErrMembershipRequired)
+	}
+	return "", fmt.Errorf("no download link on the fast download page")
+}
@@ -0,0 +1,150 @@
+package anna
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ipfsGateways are known IPFS gateway hosts, matched along with their
+// subdomains. Links on any of them are recognized as IPFS; the rewrite ones
+// are public gateways an IPFS link can be moved to when the gateway it
+// points at is down or rate-limited, tried in this order.
+var ipfsGateways = []struct {
+	host    string
+	rewrite bool
+}{
+	{"ipfs.io", true},
+	{"dweb.link", true},
+	{"w3s.link", true},
+	{"gateway.pinata.cloud", true},
+	{"nftstorage.link", true},
+	{"cloudflare-ipfs.com", false},
+	{"cf-ipfs.com", false},
+	{"ipfs.eth.aragon.network", false},
+	{"ipfs.fleek.co", false},
+	{"ipfs.infura.io", false},
+	{"4everland.io", false},
+	{"ipfs-gateway.cloud", false},
+	{"hardbin.com", false},
+}
+
+// onIPFSGateway reports whether a link points at a known IPFS gateway
+func onIPFSGateway(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, gateway := range ipfsGateways {
+		if onHost(host, gateway.host) {
+			return true
+		}
+	}
+	return false
+}
+
+// onHost reports whether host is domain or one of its subdomains
+func onHost(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// MirrorKind is the kind of site a download link points to
+type MirrorKind string
+
+const (
+	MirrorFastDownload MirrorKind = "fast download"
+	MirrorSlowDownload MirrorKind = "slow download"
+	MirrorIPFS         MirrorKind = "IPFS gateway"
+	MirrorLibgen       MirrorKind = "LibGen"
+	MirrorDirect       MirrorKind = "direct"
+)
+
+// ClassifyMirror tells what kind of site a download link points to
+func ClassifyMirror(rawURL string) MirrorKind {
+	lower := strings.ToLower(rawURL)
+	switch {
+	case strings.Contains(lower, "/fast_download/"):
+		return MirrorFastDownload
+	case strings.Contains(lower, "/slow_download/"):
+		return MirrorSlowDownload
+	case strings.Contains(lower, "/ipfs/"):
+		return MirrorIPFS
+	case strings.Contains(lower, "libgen") || strings.Contains(lower, "library.lol"):
+		return MirrorLibgen
+	case onIPFSGateway(rawURL):
+		return MirrorIPFS
+	}
+	return MirrorDirect
+}
+
+// MirrorHost returns the host a download link points to, or the link
+// itself if it can't be parsed
+func MirrorHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host
+}
+
+// ipfsContent returns the CID and path an IPFS gateway URL points at, as
+// "<cid>/<path>", from either a path gateway (host/ipfs/<cid>/...) or a
+// subdomain gateway (<cid>.ipfs.host/...)
+func ipfsContent(u *url.URL) (string, bool) {
+	if i := strings.Index(u.Path, "/ipfs/"); i >= 0 {
+		content := u.Path[i+len("/ipfs/"):]
+		if content == "" {
+			return "", false
+		}
+		return content, true
+	}
+	if cid, rest, ok := strings.Cut(u.Hostname(), ".ipfs."); ok && cid != "" && rest != "" {
+		return cid + u.Path, true
+	}
+	return "", false
+}
+
+// RewriteIPFSGateway moves an IPFS gateway URL onto newGateway, keeping the
+// CID, path and query. It reports false if rawURL isn't an IPFS URL.
+func RewriteIPFSGateway(rawURL, newGateway string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	content, ok := ipfsContent(u)
+	if !ok {
+		return "", false
+	}
+
+	rewritten := url.URL{
+		Scheme:   "https",
+		Host:     newGateway,
+		Path:     "/ipfs/" + content,
+		RawQuery: u.RawQuery,
+	}
+	return rewritten.String(), true
+}
+
+// IPFSAlternatives returns rawURL on up to n gateways other than its own,
+// or nil if it isn't an IPFS URL
+func IPFSAlternatives(rawURL string, n int) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+
+	var alternatives []string
+	for _, gateway := range ipfsGateways {
+		if len(alternatives) >= n {
+			break
+		}
+		if !gateway.rewrite || onHost(host, gateway.host) {
+			continue
+		}
+		if rewritten, ok := RewriteIPFSGateway(rawURL, gateway.host); ok {
+			alternatives = append(alternatives, rewritten)
+		}
+	}
+	return alternatives
+}
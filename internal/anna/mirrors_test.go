@@ -0,0 +1,50 @@
+package anna
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyMirror(t *testing.T) {
+	tests := []struct {
+		url  string
+		want MirrorKind
+	}{
+		{"https://annas-archive.li/fast_download/abc/0/0", MirrorFastDownload},
+		{"https://annas-archive.li/slow_download/abc/0/0", MirrorSlowDownload},
+		{"https://example.com/ipfs/bafy123", MirrorIPFS},
+		{"https://bafy123.ipfs.dweb.link/book.epub", MirrorIPFS},
+		{"https://gateway.pinata.cloud/some/book.epub", MirrorIPFS},
+		{"https://CF-IPFS.com/x", MirrorIPFS},
+		{"https://libgen.li/ads.php?md5=abc", MirrorLibgen},
+		// Gateway names elsewhere in the link don't count
+		{"https://files.example.com/ipfs.io/book.epub", MirrorDirect},
+		{"https://notipfs.io/book.epub", MirrorDirect},
+	}
+	for _, tt := range tests {
+		if got := ClassifyMirror(tt.url); got != tt.want {
+			t.Errorf("ClassifyMirror(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestIPFSAlternatives(t *testing.T) {
+	got := IPFSAlternatives("https://bafy123.ipfs.dweb.link/book.epub?filename=x", 3)
+	want := []string{
+		"https://ipfs.io/ipfs/bafy123/book.epub?filename=x",
+		"https://w3s.link/ipfs/bafy123/book.epub?filename=x",
+		"https://gateway.pinata.cloud/ipfs/bafy123/book.epub?filename=x",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Gateways that aren't rewrite targets are never suggested
+	if n := len(IPFSAlternatives("https://ipfs.io/ipfs/bafy123", len(ipfsGateways))); n != 4 {
+		t.Errorf("got %d alternatives, want the 4 other rewrite targets", n)
+	}
+
+	if got := IPFSAlternatives("https://example.com/book.epub", 3); got != nil {
+		t.Errorf("got %q for a non-IPFS link", got)
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/log"
 )
 
 var (
@@ -18,42 +22,133 @@ var (
 	ErrCloudflareBlocked = errors.New("cloudflare challenge detected")
 	// ErrNoResults indicates no search results found
 	ErrNoResults = errors.New("no results found")
+	// ErrMembershipRequired indicates the file is only offered to members
+	ErrMembershipRequired = errors.New("this file requires a membership")
+	// ErrFileRemoved indicates the file was taken down or is no longer available
+	ErrFileRemoved = errors.New("this file has been removed")
+	// ErrNoPublicMirrors indicates the page lists no usable download links
+	ErrNoPublicMirrors = errors.New("no public mirrors available")
 )
 
+// classifyMissingLinks explains why a book page had no download links,
+// based on the page text
+func classifyMissingLinks(pageText string) error {
+	text := strings.ToLower(pageText)
+
+	switch {
+	case strings.Contains(text, "cf-browser-verification") ||
+		strings.Contains(text, "just a moment..."):
+		return ErrCloudflareBlocked
+	case strings.Contains(text, "file removed") ||
+		strings.Contains(text, "has been removed") ||
+		strings.Contains(text, "no longer available") ||
+		strings.Contains(text, "dmca") ||
+		strings.Contains(text, "copyright claim"):
+		return ErrFileRemoved
+	case strings.Contains(text, "become a member") ||
+		strings.Contains(text, "members only") ||
+		strings.Contains(text, "only available to members") ||
+		strings.Contains(text, "membership required"):
+		return ErrMembershipRequired
+	}
+	return ErrNoPublicMirrors
+}
+
 // ScraperClient scrapes Anna's Archive website
 type ScraperClient struct {
-	baseURL string
+	baseURL string   // mirror currently in use
+	mirrors []string // domains tried in order when searching
+	apiKey  string   // Member key, used for fast_download links when set
 	browser *BrowserClient
 }
 
-// NewScraperClient creates a new scraper client
+// NewScraperClient creates a new scraper client. baseURL is tried first,
+// followed by the other configured mirrors.
 func NewScraperClient(baseURL string) *ScraperClient {
 	if baseURL == "" {
 		baseURL = "annas-archive.li"
 	}
+	mirrors := []string{baseURL}
+	for _, m := range Mirrors() {
+		if m != baseURL {
+			mirrors = append(mirrors, m)
+		}
+	}
 	return &ScraperClient{
 		baseURL: baseURL,
+		mirrors: mirrors,
+		apiKey:  config.Get().Anna.APIKey,
 		browser: NewBrowserClient(baseURL),
 	}
 }
 
+// useMirror switches later requests to domain
+func (c *ScraperClient) useMirror(domain string) {
+	c.baseURL = domain
+	c.browser = NewBrowserClient(domain)
+}
+
 // Search searches for books by scraping the website
 func (c *ScraperClient) Search(ctx context.Context, query string, limit int) ([]*Book, error) {
 	return c.SearchPage(ctx, query, limit, 1)
 }
 
-// SearchPage searches for books with pagination support
+// SearchPage searches for books with pagination support, trying each mirror
+// domain in turn. The first mirror that returns results is used for later
+// requests. If every mirror is blocked or unreachable, the headless browser
+// is tried instead, unless it's disabled.
 func (c *ScraperClient) SearchPage(ctx context.Context, query string, limit int, page int) ([]*Book, error) {
+	reachable := false
+	var lastErr error
+	for _, domain := range c.mirrors {
+		books, err := c.searchMirror(domain, query, limit, page)
+		if err == nil {
+			if domain != c.baseURL {
+				log.Info("using mirror", "domain", domain)
+				c.useMirror(domain)
+			}
+			return books, nil
+		}
+		log.Info("mirror failed, trying the next one", "domain", domain, "err", err)
+		if lastErr == nil || errors.Is(err, ErrCloudflareBlocked) {
+			lastErr = err
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, ErrNoResults) {
+			reachable = true
+		}
+	}
+
+	if reachable {
+		return nil, ErrNoResults
+	}
+
+	if !BrowserEnabled() {
+		return nil, lastErr
+	}
+
+	// Fall back to headless browser
+	log.Info("no mirror reachable, searching with the headless browser")
+	return c.browser.SearchPage(ctx, query, limit, page)
+}
+
+// searchMirror scrapes one page of search results from a single mirror
+func (c *ScraperClient) searchMirror(domain string, query string, limit int, page int) ([]*Book, error) {
 	var books []*Book
 	var cloudflareDetected bool
 	var scrapeErr error
 
 	collector := colly.NewCollector(
-		colly.AllowedDomains(c.baseURL),
-		colly.UserAgent("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
+		colly.AllowedDomains(domain),
+		colly.UserAgent(config.RandomUserAgent()),
 	)
 
 	collector.SetRequestTimeout(30 * time.Second)
+	collector.WithTransport(config.ProxyTransport())
+	withMemberCookie(collector)
 
 	// Detect Cloudflare challenge
 	collector.OnResponse(func(r *colly.Response) {
@@ -75,7 +170,7 @@ func (c *ScraperClient) SearchPage(ctx context.Context, query string, limit int,
 			return
 		}
 
-		book := parseBookElement(e, c.baseURL)
+		book := parseBookElement(e, domain)
 		if book != nil && book.MD5Hash != "" && !seenMD5[book.MD5Hash] {
 			seenMD5[book.MD5Hash] = true
 			books = append(books, book)
@@ -84,25 +179,29 @@ func (c *ScraperClient) SearchPage(ctx context.Context, query string, limit int,
 
 	collector.OnError(func(r *colly.Response, err error) {
 		scrapeErr = err
+		if isChallengeStatus(r) {
+			cloudflareDetected = true
+		}
 	})
 
 	// Build search URL with pagination
-	searchURL := fmt.Sprintf("https://%s/search?q=%s", c.baseURL, url.QueryEscape(query))
+	searchURL := fmt.Sprintf("https://%s/search?q=%s", domain, url.QueryEscape(query))
 	if page > 1 {
 		searchURL = fmt.Sprintf("%s&page=%d", searchURL, page)
 	}
 
-	err := collector.Visit(searchURL)
-	if err != nil {
-		// Try browser fallback
-		return c.browser.SearchPage(ctx, query, limit, page)
+	if err := collector.Visit(searchURL); err != nil {
+		if cloudflareDetected {
+			return nil, ErrCloudflareBlocked
+		}
+		return nil, err
 	}
 
 	collector.Wait()
 
 	if cloudflareDetected {
-		// Fall back to headless browser
-		return c.browser.SearchPage(ctx, query, limit, page)
+		log.Warn("cloudflare challenge detected", "domain", domain)
+		return nil, ErrCloudflareBlocked
 	}
 
 	if scrapeErr != nil {
@@ -122,16 +221,48 @@ func (c *ScraperClient) SearchPage(ctx context.Context, query string, limit int,
 }
 
 // GetDownloadInfo retrieves download links for a book
+// With a member API key, an authenticated fast_download link is put first,
+// which skips the slow_download countdown; the page is still scraped for
+// its mirrors, checksums and volumes, but a failed scrape doesn't lose the
+// fast link. With a member cookie, the page's fast_download links are put
+// first.
 func (c *ScraperClient) GetDownloadInfo(ctx context.Context, md5Hash string) (*DownloadInfo, error) {
+	var fast *DownloadInfo
+	if c.apiKey != "" {
+		httpClient := &http.Client{Timeout: 30 * time.Second, Transport: config.ProxyTransport()}
+		var err error
+		fast, err = fastDownloadInfo(ctx, httpClient, c.baseURL, c.apiKey, md5Hash)
+		if err != nil {
+			// Fall back to the public download links
+			log.Info("fast download link unavailable, using the public links", "err", err)
+		}
+	}
+
+	info, err := c.scrapeDownloadInfo(ctx, md5Hash)
+	if fast == nil {
+		return info, err
+	}
+	if err != nil {
+		log.Info("book page unavailable, using the fast download link alone", "err", err)
+		return fast, nil
+	}
+	return withFastDownload(info, fast), nil
+}
+
+// scrapeDownloadInfo reads the download links, checksums and volumes off
+// a book's page
+func (c *ScraperClient) scrapeDownloadInfo(ctx context.Context, md5Hash string) (*DownloadInfo, error) {
 	var info *DownloadInfo
 	var cloudflareDetected bool
 
 	collector := colly.NewCollector(
 		colly.AllowedDomains(c.baseURL),
-		colly.UserAgent("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
+		colly.UserAgent(config.RandomUserAgent()),
 	)
 
 	collector.SetRequestTimeout(30 * time.Second)
+	collector.WithTransport(config.ProxyTransport())
+	withMemberCookie(collector)
 
 	collector.OnResponse(func(r *colly.Response) {
 		body := string(r.Body)
@@ -141,8 +272,17 @@ func (c *ScraperClient) GetDownloadInfo(ctx context.Context, md5Hash string) (*D
 		}
 	})
 
+	// Error statuses skip OnResponse
+	collector.OnError(func(r *colly.Response, err error) {
+		if isChallengeStatus(r) {
+			cloudflareDetected = true
+		}
+	})
+
+	var pageText string
 	collector.OnHTML("body", func(e *colly.HTMLElement) {
 		info = &DownloadInfo{}
+		pageText = e.Text
 
 		// First priority: slow download links (these lead to IPFS downloads)
 		// These are the best option for direct HTTP downloads
@@ -193,39 +333,177 @@ func (c *ScraperClient) GetDownloadInfo(ctx context.Context, md5Hash string) (*D
 		if info.DirectURL == "" && len(info.MirrorURLs) > 0 {
 			info.DirectURL = info.MirrorURLs[0]
 		}
+
+		info.Volumes = parseVolumes(e, md5Hash)
+		info.SHA1, info.SHA256 = parseHashes(e.Text)
+		info.Filename, info.FileSize = parsePageDetails(e.DOM.Parent())
+
+		// Torrents are kept separate from the HTTP mirrors, they're handed
+		// off to a torrent client rather than downloaded directly
+		e.ForEach("a[href^='magnet:'], a[href$='.torrent']", func(_ int, el *colly.HTMLElement) {
+			href := el.Attr("href")
+			if info.TorrentURL != "" || href == "" {
+				return
+			}
+			if strings.HasPrefix(href, "/") {
+				href = fmt.Sprintf("https://%s%s", c.baseURL, href)
+			}
+			info.TorrentURL = href
+		})
 	})
 
 	pageURL := fmt.Sprintf("https://%s/md5/%s", c.baseURL, md5Hash)
 	err := collector.Visit(pageURL)
 	if err != nil {
+		if !BrowserEnabled() {
+			if cloudflareDetected {
+				return nil, ErrCloudflareBlocked
+			}
+			return nil, err
+		}
+		log.Info("book page request failed, using the headless browser", "url", pageURL, "err", err)
 		return c.browser.GetDownloadInfo(ctx, md5Hash)
 	}
 
 	collector.Wait()
 
 	if cloudflareDetected {
+		if !BrowserEnabled() {
+			return nil, ErrCloudflareBlocked
+		}
+		log.Warn("cloudflare challenge detected, loading the book page with the headless browser", "domain", c.baseURL)
 		return c.browser.GetDownloadInfo(ctx, md5Hash)
 	}
 
-	if info == nil || (info.DirectURL == "" && len(info.MirrorURLs) == 0) {
+	if info == nil || (info.DirectURL == "" && len(info.MirrorURLs) == 0 && info.TorrentURL == "") {
+		if !BrowserEnabled() {
+			return nil, classifyMissingLinks(pageText)
+		}
+		log.Info("no download links found, loading the book page with the headless browser", "md5", md5Hash)
 		return c.browser.GetDownloadInfo(ctx, md5Hash)
 	}
 
+	preferFastDownload(info)
 	return info, nil
 }
 
+// isChallengeStatus reports whether r has a status Cloudflare answers
+// challenges with
+func isChallengeStatus(r *colly.Response) bool {
+	return r != nil && (r.StatusCode == http.StatusForbidden || r.StatusCode == http.StatusServiceUnavailable)
+}
+
+var (
+	sha1Pattern   = regexp.MustCompile(`(?i)sha-?1\W{0,20}([0-9a-f]{40})\b`)
+	sha256Pattern = regexp.MustCompile(`(?i)sha-?256\W{0,20}([0-9a-f]{64})\b`)
+)
+
+// parseHashes extracts the SHA-1 and SHA-256 listed on a book page, if any
+func parseHashes(pageText string) (sha1, sha256 string) {
+	if m := sha1Pattern.FindStringSubmatch(pageText); len(m) == 2 {
+		sha1 = strings.ToLower(m[1])
+	}
+	if m := sha256Pattern.FindStringSubmatch(pageText); len(m) == 2 {
+		sha256 = strings.ToLower(m[1])
+	}
+	return sha1, sha256
+}
+
+// volumePattern matches link text naming one volume/part of a multi-file book:
+// the word followed by a number or a roman numeral up to XXXIX
+var volumePattern = regexp.MustCompile(`(?i)\b(?:vol(?:ume)?|part|tome|book)(?:\.?\s*\d+|(?:\.\s*|\s+)(?:x{0,3}(?:ix|iv|v?i{1,3}|v)|x{1,3}))\b`)
+
+// md5HrefPattern finds the MD5 hash in a link to a book page
+var md5HrefPattern = regexp.MustCompile(`/md5/([a-fA-F0-9]{32})`)
+
+// parseVolumes finds links to the other volumes of a multi-file book.
+// These are different files with their own MD5 (not mirrors of this one),
+// so they're only returned when the page's own title names a volume, and
+// only links to the same series count: a bare "Vol. 2", or a title
+// starting like the page's before its volume marker. Links to unrelated books that happen
+// to be a "Book 2" are ignored.
+func parseVolumes(e *colly.HTMLElement, md5Hash string) []Volume {
+	title := pageTitle(e.DOM.Parent())
+	if !volumePattern.MatchString(title) {
+		return nil
+	}
+	series := volumeSeries(title)
+
+	var volumes []Volume
+	seen := map[string]bool{strings.ToLower(md5Hash): true}
+
+	e.ForEach("a[href*='/md5/']", func(_ int, el *colly.HTMLElement) {
+		match := md5HrefPattern.FindStringSubmatch(el.Attr("href"))
+		if len(match) < 2 {
+			return
+		}
+		hash := strings.ToLower(match[1])
+		title := strings.Join(strings.Fields(el.Text), " ")
+		if seen[hash] || !volumePattern.MatchString(title) {
+			return
+		}
+		if s := volumeSeries(title); s != "" && s != series {
+			return
+		}
+		seen[hash] = true
+		volumes = append(volumes, Volume{MD5Hash: hash, Title: title})
+	})
+
+	return volumes
+}
+
+// volumeSeries is the part of a volume's title before the volume marker,
+// lowercased and without punctuation, e.g. "the stormlight archive" for
+// "The Stormlight Archive, Vol. 2: Words of Radiance"
+func volumeSeries(title string) string {
+	if loc := volumePattern.FindStringIndex(title); loc != nil {
+		title = title[:loc[0]]
+	}
+	return strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// findCoverURL finds the cover image of a search result. The cover sits in
+// its own link to the book page next to the title link, so the title's
+// ancestors are searched for an image linking to the same MD5.
+func findCoverURL(link *goquery.Selection, md5Hash, baseURL string) string {
+	selector := fmt.Sprintf("a[href*='/md5/%s'] img[src], a[href*='/md5/%s'] img[data-src]", md5Hash, strings.ToUpper(md5Hash))
+	for parent, depth := link.Parent(), 0; parent.Length() > 0 && depth < 4; parent, depth = parent.Parent(), depth+1 {
+		img := parent.Find(selector).First()
+		if img.Length() == 0 {
+			continue
+		}
+		src := img.AttrOr("src", "")
+		if src == "" || strings.HasPrefix(src, "data:") {
+			src = img.AttrOr("data-src", "")
+		}
+		switch {
+		case strings.HasPrefix(src, "//"):
+			return "https:" + src
+		case strings.HasPrefix(src, "/"):
+			return fmt.Sprintf("https://%s%s", baseURL, src)
+		case strings.HasPrefix(src, "http"):
+			return src
+		}
+		return ""
+	}
+	return ""
+}
+
 // parseBookElement extracts book information from an HTML element
 func parseBookElement(e *colly.HTMLElement, baseURL string) *Book {
 	book := &Book{}
 
 	// Extract MD5 hash from href
 	href := e.Attr("href")
-	md5Match := regexp.MustCompile(`/md5/([a-fA-F0-9]{32})`).FindStringSubmatch(href)
+	md5Match := md5HrefPattern.FindStringSubmatch(href)
 	if len(md5Match) < 2 {
 		return nil
 	}
 	book.MD5Hash = strings.ToLower(md5Match[1])
 	book.PageURL = fmt.Sprintf("https://%s/md5/%s", baseURL, book.MD5Hash)
+	book.CoverURL = findCoverURL(e.DOM, book.MD5Hash, baseURL)
 
 	// The title is the text content of this anchor tag
 	book.Title = strings.TrimSpace(e.Text)
@@ -264,18 +542,84 @@ func parseBookElement(e *colly.HTMLElement, baseURL string) *Book {
 		}
 
 		// Size detection (e.g., "5.2MB", "1.1 GB")
-		if sizeMatch := regexp.MustCompile(`(\d+\.?\d*)\s*(KB|MB|GB)`).FindStringSubmatch(metaText); len(sizeMatch) > 0 {
-			book.Size = sizeMatch[0]
+		if size := pageSizePattern.FindString(metaText); size != "" {
+			book.Size = strings.ToUpper(size)
+			book.SizeBytes = ParseSizeToBytes(book.Size)
 		}
 
 		// Language detection
-		for _, lang := range []string{"english", "russian", "german", "french", "spanish", "chinese", "japanese", "portuguese", "italian"} {
-			if strings.Contains(metaText, lang) {
-				book.Language = strings.Title(lang)
-				break
+		book.Language = detectLanguage(metaText)
+
+		parseResultDetails(e.DOM, book)
+	}
+
+	return book
+}
+
+// yearPattern matches a plausible publication year
+var yearPattern = regexp.MustCompile(`\b(1[5-9][0-9]{2}|20[0-9]{2})\b`)
+
+// parseResultDetails fills in authors, publisher and year from a search
+// result row. link is the result's title anchor. Authors are shown as an
+// italic line (older layout) or a link with a user icon; the publisher line
+// holds the publisher and year, e.g. "O'Reilly Media, 2019".
+func parseResultDetails(link *goquery.Selection, book *Book) {
+	row := link.Parent()
+	// Only widen to the grandparent when it still holds just this result
+	if gp := row.Parent(); gp.Length() > 0 && gp.Find("a.js-vim-focus[href*='/md5/']").Length() == 1 {
+		row = gp
+	}
+
+	lineText := func(s *goquery.Selection) string {
+		return strings.Join(strings.Fields(s.Text()), " ")
+	}
+
+	if a := row.Find("a:has(span[class*='user-edit'])").First(); a.Length() > 0 {
+		book.Authors = lineText(a)
+	} else if div := row.Find("div.italic").First(); div.Length() > 0 {
+		book.Authors = lineText(div)
+	}
+
+	var pubLine string
+	if a := row.Find("a:has(span[class*='company'])").First(); a.Length() > 0 {
+		pubLine = lineText(a)
+	} else {
+		// Older layout: publisher is the plain small line after the title
+		row.Find("div.truncate.text-sm").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			if s.HasClass("italic") || s.HasClass("text-gray-500") {
+				return true
+			}
+			pubLine = lineText(s)
+			return false
+		})
+	}
+
+	if pubLine != "" {
+		var parts []string
+		for _, part := range strings.Split(pubLine, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
 			}
+			if book.Year == "" {
+				if y := yearPattern.FindString(part); y != "" && len(part) <= 12 {
+					// A short part holding the year, e.g. "2019" or "2019-05"
+					book.Year = y
+					continue
+				}
+			}
+			parts = append(parts, part)
+		}
+		if len(parts) > 0 {
+			book.Publisher = parts[0]
 		}
 	}
 
-	return book
+	// Fall back to any year in the metadata line
+	if book.Year == "" {
+		row.Find("div.text-gray-500, div.text-gray-800").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			book.Year = yearPattern.FindString(s.Text())
+			return book.Year == ""
+		})
+	}
 }
@@ -0,0 +1,167 @@
+package anna
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+func TestVolumePattern(t *testing.T) {
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{"Vol. 2", true},
+		{"Volume 12: The Return", true},
+		{"vol3", true},
+		{"Part II", true},
+		{"Book IV - Winter", true},
+		{"Tome XXIII", true},
+		{"Part ix", true},
+		{"Book Civil Procedure", false},
+		{"Part Vision", false},
+		{"Book Club Guide", false},
+		{"Bookkeeping 101", false},
+		{"Departure 2", false},
+		{"Book", false},
+	}
+	for _, tt := range tests {
+		if got := volumePattern.MatchString(tt.title); got != tt.want {
+			t.Errorf("volumePattern.MatchString(%q) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestParseBookElement(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "search_results.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var books []*Book
+	resp := &colly.Response{Request: &colly.Request{}}
+	doc.Find("a.js-vim-focus[href*='/md5/']").Each(func(i int, s *goquery.Selection) {
+		e := colly.NewHTMLElementFromSelectionNode(resp, s, s.Get(0), i)
+		if book := parseBookElement(e, "annas-archive.li"); book != nil {
+			books = append(books, book)
+		}
+	})
+
+	want := []Book{
+		{
+			MD5Hash:   "0123456789abcdef0123456789abcdef",
+			Title:     "The Pragmatic Programmer",
+			Authors:   "David Thomas, Andrew Hunt",
+			Publisher: "Addison-Wesley Professional",
+			Year:      "2019",
+			Language:  "English",
+			Format:    "EPUB",
+			Size:      "2.1MB",
+			CoverURL:  "https://covers.example.org/pragmatic.jpg",
+		},
+		{
+			MD5Hash:   "fedcba9876543210fedcba9876543210",
+			Title:     "Der Prozess",
+			Authors:   "Franz Kafka",
+			Publisher: "Die Schmiede",
+			Language:  "German",
+			Year:      "1925",
+			Format:    "PDF",
+			Size:      "850KB",
+		},
+		{
+			MD5Hash: "aaaabbbbccccddddeeeeffff00001111",
+			Title:   "Untitled Scan",
+			Format:  "DJVU",
+			Size:    "1.1 GB",
+		},
+	}
+	if len(books) != len(want) {
+		t.Fatalf("parsed %d books, want %d", len(books), len(want))
+	}
+	for i, w := range want {
+		got := books[i]
+		check := func(field, got, want string) {
+			if got != want {
+				t.Errorf("book %d %s = %q, want %q", i, field, got, want)
+			}
+		}
+		check("MD5Hash", got.MD5Hash, w.MD5Hash)
+		check("Title", got.Title, w.Title)
+		check("Authors", got.Authors, w.Authors)
+		check("Publisher", got.Publisher, w.Publisher)
+		check("Year", got.Year, w.Year)
+		check("Format", got.Format, w.Format)
+		check("Size", got.Size, w.Size)
+		check("CoverURL", got.CoverURL, w.CoverURL)
+		if w.Language != "" {
+			check("Language", got.Language, w.Language)
+		}
+		if got.PageURL != "https://annas-archive.li/md5/"+w.MD5Hash {
+			t.Errorf("book %d PageURL = %q", i, got.PageURL)
+		}
+	}
+}
+
+// bodyElement loads a book page fixture as the body element the scraper sees
+func bodyElement(t *testing.T, name string) *colly.HTMLElement {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := doc.Find("body")
+	return colly.NewHTMLElementFromSelectionNode(&colly.Response{Request: &colly.Request{}}, body, body.Get(0), 0)
+}
+
+func TestParseVolumes(t *testing.T) {
+	volumes := parseVolumes(bodyElement(t, "volume_page.html"), "0123456789ABCDEF0123456789ABCDEF")
+	want := []Volume{
+		{MD5Hash: "11111111111111111111111111111111", Title: "The Stormlight Archive, Vol. 2: Words of Radiance"},
+		{MD5Hash: "22222222222222222222222222222222", Title: "Vol. 3"},
+	}
+	if len(volumes) != len(want) {
+		t.Fatalf("got %+v, want %+v", volumes, want)
+	}
+	for i := range want {
+		if volumes[i] != want[i] {
+			t.Errorf("volume %d = %+v, want %+v", i, volumes[i], want[i])
+		}
+	}
+}
+
+func TestParseVolumesIgnoresUnrelatedBooks(t *testing.T) {
+	// Dune isn't a volume, so the "Book 2" recommendations aren't its volumes
+	if volumes := parseVolumes(bodyElement(t, "single_page.html"), "0123456789abcdef0123456789abcdef"); len(volumes) != 0 {
+		t.Errorf("got %+v for a single-file book", volumes)
+	}
+}
+
+func TestVolumeSeries(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"The Stormlight Archive, Vol. 2: Words of Radiance", "the stormlight archive"},
+		{"The Stormlight Archive Volume 1", "the stormlight archive"},
+		{"Vol. 3", ""},
+		{"Harry Potter Book 2", "harry potter"},
+	}
+	for _, tt := range tests {
+		if got := volumeSeries(tt.title); got != tt.want {
+			t.Errorf("volumeSeries(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package anna
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// sizePattern matches a size such as "5.2MB", "1.1 GB" or "800 kb"
+var sizePattern = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*(B|KB|KIB|MB|MIB|GB|GIB|TB|TIB)?$`)
+
+// sizeMultipliers are the binary multiples Anna's Archive uses for sizes
+var sizeMultipliers = map[string]float64{
+	"B":  1,
+	"KB": 1024,
+	"MB": 1024 * 1024,
+	"GB": 1024 * 1024 * 1024,
+	"TB": 1024 * 1024 * 1024 * 1024,
+}
+
+// ParseSizeToBytes parses a size string like "10MB" or "1.5 GB" to bytes.
+// Plain numbers are bytes; it returns 0 if s isn't a size.
+func ParseSizeToBytes(s string) int64 {
+	match := sizePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0
+	}
+
+	unit := strings.Replace(strings.ToUpper(match[2]), "I", "", 1)
+	if unit == "" {
+		unit = "B"
+	}
+	return int64(value * sizeMultipliers[unit])
+}
+
+// pageSizePattern finds a file size within a book page's details line
+var pageSizePattern = regexp.MustCompile(`(?i)\b\d+(?:\.\d+)?\s*(?:KB|MB|GB|TB)\b`)
+
+// pageFormatPattern finds the file extension within a book page's details line
+var pageFormatPattern = regexp.MustCompile(`(?i)(?:^|[\s,·])\.?(epub|pdf|mobi|azw3|djvu|fb2|cbr|cbz|txt|rtf|doc|docx)\b`)
+
+// pageTitle returns the title shown on a book page
+func pageTitle(doc *goquery.Selection) string {
+	title := strings.TrimSpace(doc.Find("div.text-3xl.font-bold").First().Text())
+	if title == "" {
+		title, _ = doc.Find("meta[property='og:title']").First().Attr("content")
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(title), "🔍"))
+}
+
+// parsePageDetails reads the file size and a filename from the header of a
+// book page: the title, and the details line below it, which reads like
+// "English [en], .epub, 1.2MB, 📘 Book (non-fiction)". The filename is only
+// set when both the title and the format are found.
+func parsePageDetails(doc *goquery.Selection) (filename string, size int64) {
+	title := pageTitle(doc)
+
+	var format string
+	doc.Find("div.text-sm.text-gray-500, div.text-gray-800").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		text := s.Text()
+		sizeText := pageSizePattern.FindString(text)
+		if sizeText == "" {
+			return true
+		}
+		size = ParseSizeToBytes(sizeText)
+		if m := pageFormatPattern.FindStringSubmatch(text); m != nil {
+			format = strings.ToLower(m[1])
+		}
+		return false
+	})
+
+	if title != "" && format != "" {
+		filename = title + "." + format
+	}
+	return filename, size
+}
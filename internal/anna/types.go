@@ -14,6 +14,8 @@ type Book struct {
 	Size      string `json:"size"`
 	SizeBytes int64  `json:"size_bytes"`
 	PageURL   string `json:"page_url"`
+	CoverURL  string `json:"cover_url,omitempty"`
+	Editions  int    `json:"editions,omitempty"` // results merged into this one by search --dedupe
 }
 
 // SearchResult contains search results with metadata
@@ -24,11 +26,23 @@ type SearchResult struct {
 }
 
 // DownloadInfo contains information needed to download a book
+// MirrorURLs are alternative sources for the same file, while Volumes are
+// other files (volumes/parts) of the same title, each with its own MD5
 type DownloadInfo struct {
 	DirectURL  string `json:"direct_url"`
 	MirrorURLs []string `json:"mirror_urls"`
 	Filename   string `json:"filename"`
 	FileSize   int64  `json:"file_size"`
+	Volumes    []Volume `json:"volumes,omitempty"`
+	TorrentURL string   `json:"torrent_url,omitempty"` // magnet link or .torrent URL, if offered
+	SHA1       string   `json:"sha1,omitempty"`
+	SHA256     string   `json:"sha256,omitempty"`
+}
+
+// Volume is one file of a book split across several files
+type Volume struct {
+	MD5Hash string `json:"md5"`
+	Title   string `json:"title"`
 }
 
 // Client defines the interface for Anna's Archive access
@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+// defaultArchiveDays is used by 'bookdl archive' when neither --days nor
+// files.archive_after_days is set
+const defaultArchiveDays = 90
+
+// autoArchiveKey is the meta row recording the last automatic archive run
+const autoArchiveKey = "auto_archived_at"
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Move old completed downloads to the archive",
+	Long: `Move downloads completed more than N days ago into the archive directory
+(files.archive_dir, default: <downloads.path>/archive).
+
+Archived downloads are hidden from 'bookdl list'; use 'bookdl list --archived'
+to see them. Set files.archive_after_days to archive automatically (checked
+at most once a day).
+
+Examples:
+  bookdl archive              Archive downloads older than 90 days
+  bookdl archive --days 30    Archive downloads older than 30 days
+  bookdl archive --dry-run    Show what would be archived`,
+	Args: cobra.NoArgs,
+	RunE: runArchive,
+}
+
+func init() {
+	archiveCmd.Flags().Int("days", 0, "archive downloads completed more than this many days ago")
+	archiveCmd.Flags().Bool("dry-run", false, "show what would be archived without moving anything")
+}
+
+func runArchive(cmd *cobra.Command, args []string) error {
+	days, _ := cmd.Flags().GetInt("days")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	if days <= 0 {
+		days = config.Get().Files.ArchiveAfterDays
+	}
+	if days <= 0 {
+		days = defaultArchiveDays
+	}
+
+	archived, err := archiveOlderThan(days, dryRun)
+	if err != nil {
+		return err
+	}
+
+	if archived == 0 {
+		fmt.Printf("Nothing completed more than %d days ago.\n", days)
+	} else if dryRun {
+		fmt.Printf("\n%d download(s) would be archived.\n", archived)
+	} else {
+		Successf("Archived %d download(s) to %s", archived, archiveDir())
+	}
+	return nil
+}
+
+// autoArchive archives old downloads when files.archive_after_days is set,
+// at most once a day
+func autoArchive() {
+	days := config.Get().Files.ArchiveAfterDays
+	if days <= 0 {
+		return
+	}
+	if due, err := db.IsDue(autoArchiveKey, 24*time.Hour); err != nil || !due {
+		return
+	}
+	if n, err := archiveOlderThan(days, false); err != nil {
+		Printf("Auto-archive failed: %v\n", err)
+	} else if n > 0 {
+		Printf("Archived %d old download(s)\n", n)
+	}
+	db.MarkDone(autoArchiveKey)
+}
+
+// archiveOlderThan moves downloads completed more than days ago into the
+// archive directory and returns how many were (or would be) archived
+func archiveOlderThan(days int, dryRun bool) (int, error) {
+	cutoff := time.Now().AddDate(0, 0, -days)
+	downloads, err := db.ListArchivable(cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list downloads: %w", err)
+	}
+
+	archived := 0
+	for _, d := range downloads {
+		dest := archivePath(d.FilePath)
+		if dryRun {
+			fmt.Printf("Would archive: %s\n", d.Title)
+			archived++
+			continue
+		}
+
+		if _, err := os.Stat(d.FilePath); err == nil {
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				Errorf("failed to archive %s: %v", d.Title, err)
+				continue
+			}
+			if err := moveFile(d.FilePath, dest); err != nil {
+				Errorf("failed to archive %s: %v", d.Title, err)
+				continue
+			}
+			// Keep the metadata sidecar with its book
+			if _, err := os.Stat(metadataPath(d.FilePath)); err == nil {
+				moveFile(metadataPath(d.FilePath), metadataPath(dest))
+			}
+		} else {
+			// File is already gone; just record it as archived
+			dest = d.FilePath
+		}
+
+		if err := db.MarkArchived(d.ID, dest); err != nil {
+			Errorf("failed to mark %s archived: %v", d.Title, err)
+			continue
+		}
+		Printf("Archived: %s\n", d.Title)
+		archived++
+	}
+	return archived, nil
+}
+
+// archiveDir returns the configured archive directory
+func archiveDir() string {
+	cfg := config.Get()
+	if cfg.Files.ArchiveDir != "" {
+		return cfg.Files.ArchiveDir
+	}
+	return filepath.Join(cfg.Downloads.Path, "archive")
+}
+
+// archivePath maps a library file to its place in the archive, keeping its
+// path relative to the downloads directory
+func archivePath(filePath string) string {
+	base := config.Get().Downloads.Path
+	if rel, err := filepath.Rel(base, filePath); err == nil && isWithinDir(base, filePath) {
+		return filepath.Join(archiveDir(), rel)
+	}
+	return filepath.Join(archiveDir(), filepath.Base(filePath))
+}
+
+// moveFile renames src to dst, falling back to copy and delete when they
+// are on different devices
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
@@ -2,9 +2,10 @@ package cli
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/billmal071/bookdl/internal/anna"
@@ -18,12 +19,18 @@ var bookmarkCmd = &cobra.Command{
 	Long: `Save a book to your bookmarks for later download.
 
 Use without arguments to list all bookmarks.
-Use with an MD5 hash to add a new bookmark.
+Use with an MD5 hash (or a book's Anna's Archive link) to add a new bookmark.
+
+Bookmarks double as a reading list: each one is to_read, reading or read.
+New bookmarks are to_read; --status sets another status, adding the
+bookmark first if needed. Bookmarks are never downloaded automatically.
 
 Examples:
   bookdl bookmark                    List all bookmarks
   bookdl bookmark abc123def456...    Add book to bookmarks
+  bookdl bookmark https://annas-archive.se/md5/abc123...  Same, from a pasted link
   bookdl bookmark -d abc123...       Remove from bookmarks
+  bookdl bookmark --status reading abc123...  Mark a book as being read
   bookdl bookmark --download         Download all bookmarks`,
 	RunE: runBookmark,
 }
@@ -35,7 +42,10 @@ var bookmarksCmd = &cobra.Command{
 
 Examples:
   bookdl bookmarks              List all bookmarks
-  bookdl bookmarks --download   Download all bookmarks`,
+  bookdl bookmarks --tag study  List bookmarks tagged "study"
+  bookdl bookmarks --filter to_read   List books you haven't started
+  bookdl bookmarks --download   Download all bookmarks
+  bookdl bookmarks --filter to_read --download`,
 	RunE: runBookmarkList,
 }
 
@@ -43,18 +53,41 @@ func init() {
 	bookmarkCmd.Flags().BoolP("delete", "d", false, "remove bookmark")
 	bookmarkCmd.Flags().Bool("download", false, "download all bookmarks")
 	bookmarkCmd.Flags().StringP("note", "n", "", "add a note to the bookmark")
+	bookmarkCmd.Flags().String("status", "", "set the reading status: to_read, reading, read")
+
+	bookmarksCmd.Flags().Bool("download", false, "download all bookmarks (with --filter, only those)")
+	bookmarksCmd.Flags().StringP("tag", "t", "", "show bookmarks with this tag")
+	bookmarksCmd.Flags().String("filter", "", "show bookmarks with this reading status: to_read, reading, read")
+}
+
+// parseBookmarkStatus validates a --status or --filter value
+func parseBookmarkStatus(value string) (db.BookmarkStatus, error) {
+	status := db.BookmarkStatus(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(value)), "-", "_"))
+	if status == "" || status.Valid() {
+		return status, nil
+	}
+	return "", fmt.Errorf("invalid reading status %q (use to_read, reading or read)", value)
+}
 
-	bookmarksCmd.Flags().Bool("download", false, "download all bookmarks")
+// statusLabel is how a bookmark status is shown
+func statusLabel(status db.BookmarkStatus) string {
+	return strings.ReplaceAll(string(status), "_", " ")
 }
 
 func runBookmark(cmd *cobra.Command, args []string) error {
 	deleteMode, _ := cmd.Flags().GetBool("delete")
 	downloadAll, _ := cmd.Flags().GetBool("download")
 	note, _ := cmd.Flags().GetString("note")
+	statusFlag, _ := cmd.Flags().GetString("status")
+
+	status, err := parseBookmarkStatus(statusFlag)
+	if err != nil {
+		return err
+	}
 
 	// Download all bookmarks
 	if downloadAll {
-		return downloadBookmarks(cmd.Context())
+		return downloadBookmarks(cmd.Context(), "")
 	}
 
 	// List bookmarks if no args
@@ -62,29 +95,92 @@ func runBookmark(cmd *cobra.Command, args []string) error {
 		return runBookmarkList(cmd, args)
 	}
 
-	md5Hash := strings.ToLower(args[0])
+	md5Hash, err := anna.ExtractMD5(args[0])
+	if err != nil {
+		return err
+	}
 
 	// Delete mode
 	if deleteMode {
 		return removeBookmark(md5Hash)
 	}
 
+	if status != "" {
+		return setBookmarkStatus(cmd.Context(), md5Hash, note, status)
+	}
+
 	// Add bookmark
-	return addBookmark(cmd.Context(), md5Hash, note)
+	return addBookmark(cmd.Context(), md5Hash, note, "")
+}
+
+// setBookmarkStatus moves a bookmark along the reading list, bookmarking
+// the book first if it isn't yet
+func setBookmarkStatus(ctx context.Context, md5Hash, note string, status db.BookmarkStatus) error {
+	bookmark, err := db.GetBookmarkByHash(md5Hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return addBookmark(ctx, md5Hash, note, status)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get bookmark: %w", err)
+	}
+
+	if err := db.UpdateBookmarkStatus(md5Hash, status); err != nil {
+		return fmt.Errorf("failed to update bookmark: %w", err)
+	}
+	if note != "" {
+		if err := db.UpdateBookmarkNotes(bookmark.ID, note); err != nil {
+			return fmt.Errorf("failed to update note: %w", err)
+		}
+	}
+
+	Successf("Marked as %s: %s", statusLabel(status), bookmark.Title)
+	return nil
 }
 
 func runBookmarkList(cmd *cobra.Command, args []string) error {
 	downloadAll, _ := cmd.Flags().GetBool("download")
+	filter, _ := cmd.Flags().GetString("filter")
+
+	status, err := parseBookmarkStatus(filter)
+	if err != nil {
+		return err
+	}
 
 	if downloadAll {
-		return downloadBookmarks(cmd.Context())
+		return downloadBookmarks(cmd.Context(), status)
 	}
 
-	bookmarks, err := db.ListBookmarks()
+	tag, _ := cmd.Flags().GetString("tag")
+
+	var bookmarks []*db.Bookmark
+	if tag != "" {
+		bookmarks, err = db.ListBookmarksByTag(tag)
+		if err == nil && status != "" {
+			var filtered []*db.Bookmark
+			for _, b := range bookmarks {
+				if b.Status == status {
+					filtered = append(filtered, b)
+				}
+			}
+			bookmarks = filtered
+		}
+	} else {
+		bookmarks, err = db.ListBookmarks(status)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to list bookmarks: %w", err)
 	}
 
+	if len(bookmarks) == 0 && tag != "" {
+		fmt.Printf("No bookmarks tagged '%s'.\n", tag)
+		return nil
+	}
+
+	if len(bookmarks) == 0 && status != "" {
+		fmt.Printf("No bookmarks marked %s.\n", statusLabel(status))
+		return nil
+	}
+
 	if len(bookmarks) == 0 {
 		fmt.Println("No bookmarks saved.")
 		fmt.Println("\nTo bookmark a book:")
@@ -122,11 +218,16 @@ func runBookmarkList(cmd *cobra.Command, args []string) error {
 		}
 
 		fmt.Printf("     MD5: %s\n", b.MD5Hash)
+		fmt.Printf("     Status: %s\n", statusLabel(b.Status))
 
 		if b.Notes != "" {
 			fmt.Printf("     Note: %s\n", b.Notes)
 		}
 
+		if tags := formatTags(b.MD5Hash); tags != "" {
+			fmt.Printf("     Tags: %s\n", tags)
+		}
+
 		fmt.Printf("     Added: %s\n", b.CreatedAt.Format("2006-01-02"))
 		fmt.Println()
 	}
@@ -135,7 +236,7 @@ func runBookmarkList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func addBookmark(ctx context.Context, md5Hash string, note string) error {
+func addBookmark(ctx context.Context, md5Hash string, note string, status db.BookmarkStatus) error {
 	// Check if already bookmarked
 	if db.BookmarkExists(md5Hash) {
 		fmt.Println("Book is already bookmarked.")
@@ -146,7 +247,7 @@ func addBookmark(ctx context.Context, md5Hash string, note string) error {
 	fmt.Println("Fetching book info...")
 
 	client := anna.NewClient()
-	searchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	searchCtx, cancel := withTimeout(ctx, searchTimeout())
 	defer cancel()
 
 	// Try to get book info from the page
@@ -157,6 +258,7 @@ func addBookmark(ctx context.Context, md5Hash string, note string) error {
 			MD5Hash: md5Hash,
 			Title:   "Unknown (MD5: " + md5Hash[:16] + "...)",
 			Notes:   note,
+			Status:  status,
 		}
 		if err := db.CreateBookmark(bookmark); err != nil {
 			return fmt.Errorf("failed to create bookmark: %w", err)
@@ -172,6 +274,7 @@ func addBookmark(ctx context.Context, md5Hash string, note string) error {
 		Title:    info.Filename,
 		PageURL:  fmt.Sprintf("https://%s/md5/%s", anna.GetBaseURL(), md5Hash),
 		Notes:    note,
+		Status:   status,
 	}
 
 	// If filename is empty, use MD5
@@ -201,8 +304,10 @@ func removeBookmark(md5Hash string) error {
 	return nil
 }
 
-func downloadBookmarks(ctx context.Context) error {
-	bookmarks, err := db.ListBookmarks()
+// downloadBookmarks downloads the bookmarks with a status, or all of them
+// if status is empty
+func downloadBookmarks(ctx context.Context, status db.BookmarkStatus) error {
+	bookmarks, err := db.ListBookmarks(status)
 	if err != nil {
 		return fmt.Errorf("failed to list bookmarks: %w", err)
 	}
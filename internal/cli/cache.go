@@ -2,6 +2,8 @@ package cli
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/billmal071/bookdl/internal/config"
@@ -16,6 +18,8 @@ var cacheCmd = &cobra.Command{
 Examples:
   bookdl cache stats    # Show cache statistics
   bookdl cache clear    # Clear all cached results
+  bookdl cache clean    # Remove expired entries (--all for everything)
+  bookdl cache ttl 6h   # Keep results for 6 hours
   bookdl cache enable   # Enable caching
   bookdl cache disable  # Disable caching`,
 }
@@ -38,6 +42,11 @@ var cacheStatsCmd = &cobra.Command{
 		fmt.Printf("Expired entries: %d\n", expired)
 		fmt.Printf("Valid entries: %d\n", total-expired)
 		fmt.Printf("Cache TTL: %v\n", cfg.Cache.TTL)
+		if cfg.Cache.MaxEntries > 0 {
+			fmt.Printf("Max entries: %d\n", cfg.Cache.MaxEntries)
+		} else {
+			fmt.Println("Max entries: unlimited")
+		}
 
 		if expired > 0 {
 			fmt.Println("\nTip: Run 'bookdl cache clean' to remove expired entries")
@@ -62,7 +71,15 @@ var cacheClearCmd = &cobra.Command{
 var cacheCleanCmd = &cobra.Command{
 	Use:   "clean",
 	Short: "Remove expired cache entries",
+	Long:  `Remove expired cache entries, or every entry with --all.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			if err := db.ClearSearchCache(); err != nil {
+				return fmt.Errorf("failed to clean cache: %w", err)
+			}
+			Successf("All cache entries removed")
+			return nil
+		}
 		if err := db.CleanExpiredCache(); err != nil {
 			return fmt.Errorf("failed to clean cache: %w", err)
 		}
@@ -95,17 +112,42 @@ var cacheDisableCmd = &cobra.Command{
 	},
 }
 
+var cacheTTLCmd = &cobra.Command{
+	Use:   "ttl <duration>",
+	Short: "Set how long search results are cached",
+	Long: `Set how long search results stay in the cache, e.g. 30m, 6h or 72h.
+Entries already cached keep their original expiry.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ttl, err := time.ParseDuration(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", args[0], err)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("TTL must be positive")
+		}
+		if err := config.Set("cache.ttl", ttl.String()); err != nil {
+			return fmt.Errorf("failed to set cache TTL: %w", err)
+		}
+		Successf("Cache TTL set to %v", ttl)
+		return nil
+	},
+}
+
 func init() {
+	cacheCleanCmd.Flags().Bool("all", false, "remove every entry, not just expired ones")
+
 	cacheCmd.AddCommand(cacheStatsCmd)
 	cacheCmd.AddCommand(cacheClearCmd)
 	cacheCmd.AddCommand(cacheCleanCmd)
 	cacheCmd.AddCommand(cacheEnableCmd)
 	cacheCmd.AddCommand(cacheDisableCmd)
+	cacheCmd.AddCommand(cacheTTLCmd)
 }
 
 func enabledStatus(enabled bool) string {
 	if enabled {
-		return "enabled ✓"
+		return "enabled " + strings.TrimSpace(glyph("✓"))
 	}
 	return "disabled"
 }
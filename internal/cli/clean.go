@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+)
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove stale partial downloads and dead records",
+	Long: `Tidy up the download directory and database:
+
+  - failed or paused downloads whose .part file hasn't been written to for
+    --days days are deleted, file and record
+  - bookdl's .part files in the download directory (a book's name followed
+    by .part) that no download refers to are deleted once they're as old
+  - chunk records left behind by deleted or completed downloads are removed
+  - completed downloads whose file no longer exists are listed, and their
+    records removed with --missing
+
+You are asked to confirm before partial downloads are deleted; --force
+skips the question.
+
+Examples:
+  bookdl clean --dry-run     Show what would be removed
+  bookdl clean               Remove partial downloads untouched for 7 days
+  bookdl clean --days 1 --missing --force`,
+	Args: cobra.NoArgs,
+	RunE: runClean,
+}
+
+func init() {
+	cleanCmd.Flags().Int("days", 7, "treat partial downloads untouched for this many days as stale")
+	cleanCmd.Flags().Bool("missing", false, "also remove records of completed downloads whose file is gone")
+	cleanCmd.Flags().Bool("dry-run", false, "show what would be removed without removing anything")
+	cleanCmd.Flags().BoolP("force", "f", false, "don't ask for confirmation")
+}
+
+func runClean(cmd *cobra.Command, args []string) error {
+	days, _ := cmd.Flags().GetInt("days")
+	removeMissing, _ := cmd.Flags().GetBool("missing")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	force, _ := cmd.Flags().GetBool("force")
+	if days < 0 {
+		return fmt.Errorf("--days can't be negative")
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	verb := "Deleted"
+	if dryRun {
+		verb = "Would delete"
+	}
+	removed := 0
+
+	// Failed and paused downloads nobody has come back to, and .part files
+	// no download refers to any more
+	stale, err := staleDownloads(cutoff)
+	if err != nil {
+		return err
+	}
+	orphans, err := orphanedPartFiles(config.Get().Downloads.Path, cutoff)
+	if err != nil {
+		return err
+	}
+	if (len(stale) > 0 || len(orphans) > 0) && !dryRun && !force {
+		for _, d := range stale {
+			fmt.Printf("  [%d] %s (%s, %s)\n", d.ID, d.Title, d.Status, d.TempPath)
+		}
+		for _, path := range orphans {
+			fmt.Printf("  %s\n", path)
+		}
+		if !interactive() {
+			return fmt.Errorf("refusing to delete partial downloads without confirmation; use --force")
+		}
+		if !confirm(fmt.Sprintf("Delete %d stale download(s) and %d orphaned partial file(s)?", len(stale), len(orphans))) {
+			fmt.Println("Keeping partial downloads.")
+			stale, orphans = nil, nil
+		}
+	}
+
+	for _, d := range stale {
+		if !dryRun {
+			if err := os.Remove(d.TempPath); err != nil && !os.IsNotExist(err) {
+				Errorf("failed to delete %s: %v", d.TempPath, err)
+				continue
+			}
+			if err := db.DeleteDownload(d.ID); err != nil {
+				Errorf("failed to remove #%d: %v", d.ID, err)
+				continue
+			}
+		}
+		fmt.Printf("%s stale %s download: [%d] %s (%s)\n", verb, d.Status, d.ID, d.Title, d.TempPath)
+		removed++
+	}
+
+	for _, path := range orphans {
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				Errorf("failed to delete %s: %v", path, err)
+				continue
+			}
+		}
+		fmt.Printf("%s orphaned partial file: %s\n", verb, path)
+		removed++
+	}
+
+	// Chunk rows of downloads that are gone or done
+	chunks, err := db.FindOrphanedChunks()
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned chunks: %w", err)
+	}
+	if len(chunks) > 0 {
+		if !dryRun {
+			seen := make(map[int64]bool)
+			for _, c := range chunks {
+				if seen[c.DownloadID] {
+					continue
+				}
+				seen[c.DownloadID] = true
+				if err := db.DeleteChunks(c.DownloadID); err != nil {
+					return fmt.Errorf("failed to delete chunks: %w", err)
+				}
+			}
+		}
+		fmt.Printf("%s %d orphaned chunk record(s)\n", verb, len(chunks))
+		removed++
+	}
+
+	// Completed downloads whose file was deleted or moved outside bookdl
+	missing, err := missingFiles()
+	if err != nil {
+		return err
+	}
+	for _, d := range missing {
+		if !removeMissing {
+			fmt.Printf("File missing: [%d] %s (%s)\n", d.ID, d.Title, d.FilePath)
+			continue
+		}
+		if !dryRun {
+			if err := db.DeleteDownload(d.ID); err != nil {
+				Errorf("failed to remove #%d: %v", d.ID, err)
+				continue
+			}
+		}
+		fmt.Printf("%s record with missing file: [%d] %s (%s)\n", verb, d.ID, d.Title, d.FilePath)
+		removed++
+	}
+	if len(missing) > 0 && !removeMissing {
+		fmt.Println("Use 'bookdl clean --missing' to remove these records, or 'bookdl move' if the files were moved.")
+	}
+
+	if removed == 0 && len(missing) == 0 {
+		fmt.Println("Nothing to clean.")
+	} else if removed > 0 && !dryRun {
+		Successf("Cleaned up %d item(s).", removed)
+	}
+	return nil
+}
+
+// staleDownloads returns failed and paused downloads whose partial file
+// exists but hasn't been written to since cutoff
+func staleDownloads(cutoff time.Time) ([]*db.Download, error) {
+	var stale []*db.Download
+	for _, status := range []db.DownloadStatus{db.StatusFailed, db.StatusPaused} {
+		downloads, err := db.ListDownloads(status, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list downloads: %w", err)
+		}
+		for _, d := range downloads {
+			if d.TempPath == "" {
+				continue
+			}
+			if fi, err := os.Stat(d.TempPath); err == nil && fi.ModTime().Before(cutoff) {
+				stale = append(stale, d)
+			}
+		}
+	}
+	return stale, nil
+}
+
+// orphanedPartFiles returns bookdl's .part files under dir, last written
+// before cutoff, that no download record refers to. Other programs' .part
+// files are left alone.
+func orphanedPartFiles(dir string, cutoff time.Time) ([]string, error) {
+	downloads, err := db.ListDownloads("", true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list downloads: %w", err)
+	}
+	inUse := make(map[string]bool)
+	for _, d := range downloads {
+		if d.TempPath != "" {
+			inUse[filepath.Clean(d.TempPath)] = true
+		}
+	}
+
+	var orphans []string
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		// Unreadable entries, or a download directory that doesn't exist
+		// yet, just have nothing to clean
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() || !downloader.IsTempPath(entry.Name()) || inUse[filepath.Clean(path)] {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+			orphans = append(orphans, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return orphans, nil
+}
+
+// missingFiles returns completed downloads, archived or not, whose file no
+// longer exists
+func missingFiles() ([]*db.Download, error) {
+	completed, err := db.ListDownloads(db.StatusCompleted, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list downloads: %w", err)
+	}
+	archived, err := db.ListArchivedDownloads()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived downloads: %w", err)
+	}
+
+	var missing []*db.Download
+	for _, d := range append(completed, archived...) {
+		if d.FilePath == "" {
+			continue
+		}
+		if _, err := os.Stat(d.FilePath); os.IsNotExist(err) {
+			missing = append(missing, d)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+func TestOrphanedPartFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	write := func(name string, modTime time.Time) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("partial"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	orphan := write("Author/Dune.epub.part", old)
+	write("Recent.pdf.part", time.Now()) // too new
+	write("video.mkv.part", old)         // another program's
+	write("notes.part", old)             // another program's
+	write("Finished.epub", old)          // not partial
+	inUse := write("Emma.pdf.part", old) // a download still refers to it
+
+	download := &db.Download{MD5Hash: "0123456789abcdef0123456789abcdef", Title: "Emma", TempPath: inUse, Status: db.StatusPaused}
+	if err := db.CreateDownload(download); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DeleteDownload(download.ID) })
+
+	got, err := orphanedPartFiles(dir, time.Now().Add(-7*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{orphan}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/billmal071/bookdl/internal/downloader"
+	"github.com/billmal071/bookdl/internal/tui"
+)
+
+// colorMode is the --color flag value: auto, always, or never
+var colorMode string
+
+// applyColorMode resolves --color (and the NO_COLOR convention) and turns
+// ANSI styling on or off for the TUI and progress bars
+func applyColorMode() error {
+	var enabled bool
+	switch strings.ToLower(colorMode) {
+	case "always":
+		enabled = true
+	case "never":
+		enabled = false
+	case "", "auto":
+		_, noColor := os.LookupEnv("NO_COLOR")
+		enabled = !noColor && isatty.IsTerminal(os.Stdout.Fd())
+	default:
+		return fmt.Errorf("invalid --color value %q (use auto, always, or never)", colorMode)
+	}
+
+	colorEnabled = enabled
+	tui.SetColorEnabled(enabled)
+	downloader.SetColorEnabled(enabled)
+	return nil
+}
+
+// colorEnabled is whether styled output is on, as resolved by applyColorMode
+var colorEnabled = true
+
+// plainGlyphs are the plain-text stand-ins for status symbols when color
+// is off
+var plainGlyphs = map[string]string{
+	"✓":  "OK",
+	"✗":  "X",
+	"✅":  "OK",
+	"❌":  "X",
+	"⚠️": "!",
+	"⬇️": ">",
+	"⏸️": "||",
+	"⏳":  "..",
+	"🔍":  "?",
+	"🔄":  "~",
+}
+
+// glyph returns a status symbol followed by its spacing, or its plain-text
+// stand-in under --color never, NO_COLOR or when output isn't a terminal
+func glyph(symbol string) string {
+	if !colorEnabled {
+		return plainGlyphs[symbol] + " "
+	}
+	// Symbols drawn as emoji with a variation selector render two columns
+	// wide but count as one, so they get an extra space
+	if strings.HasSuffix(symbol, "️") {
+		return symbol + "  "
+	}
+	return symbol + " "
+}
@@ -1,10 +1,17 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/notify"
+	"github.com/billmal071/bookdl/internal/tui"
 )
 
 var configCmd = &cobra.Command{
@@ -17,7 +24,10 @@ Configuration is stored in ~/.config/bookdl/config.yaml
 Examples:
   bookdl config get anna.api_key
   bookdl config set anna.api_key YOUR_API_KEY
-  bookdl config set downloads.path ~/Books`,
+  bookdl config set downloads.path ~/Books
+  bookdl config set notifications.webhook_url https://ntfy.sh/my-books
+  bookdl config edit
+  bookdl config validate`,
 }
 
 var configGetCmd = &cobra.Command{
@@ -63,6 +73,93 @@ var configPathCmd = &cobra.Command{
 	},
 }
 
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the configuration file in your editor",
+	Long: `Open the configuration file in $EDITOR (or $VISUAL).
+
+If the config file does not exist yet, it is created with the default
+settings first. After the editor exits, the file is reloaded and any
+errors are reported.
+
+With --form, settings are edited in a form instead: pick a setting, type
+its new value (checked against the setting's type) and press s to save.
+
+Examples:
+  bookdl config edit
+  EDITOR=nano bookdl config edit
+  bookdl config edit --form`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if form, _ := cmd.Flags().GetBool("form"); form {
+			return runConfigForm()
+		}
+
+		path, err := config.EnsureConfigFile()
+		if err != nil {
+			return fmt.Errorf("failed to create config file: %w", err)
+		}
+
+		editor := findEditor()
+		Printf("Opening %s with %s\n", path, editor)
+
+		// Run the editor attached to the terminal ($EDITOR may include arguments, e.g. "code -w")
+		parts := strings.Fields(editor)
+		editCmd := exec.Command(parts[0], append(parts[1:], path)...)
+		editCmd.Stdin = os.Stdin
+		editCmd.Stdout = os.Stdout
+		editCmd.Stderr = os.Stderr
+		if err := editCmd.Run(); err != nil {
+			return fmt.Errorf("editor exited with error: %w", err)
+		}
+
+		// Reload and validate the edited file
+		if err := config.Reload(); err != nil {
+			return fmt.Errorf("config file has errors: %w\nRun 'bookdl config edit' again to fix them", err)
+		}
+
+		Successf("Config saved: %s", path)
+		return nil
+	},
+}
+
+// runConfigForm edits the config in the TUI form and saves what changed
+func runConfigForm() error {
+	if !interactive() {
+		return fmt.Errorf("--form needs a terminal; use 'bookdl config set' instead")
+	}
+
+	changed, err := tui.RunConfigEditor(config.Fields())
+	if err != nil {
+		return fmt.Errorf("config editor error: %w", err)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	for _, field := range changed {
+		if err := config.Set(field.Key, field.Value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", field.Key, err)
+		}
+		Successf("Set %s = %s", field.Key, field.Value)
+	}
+	fmt.Printf("Config saved to: %s\n", config.GetConfigPath())
+	return nil
+}
+
+// findEditor returns the user's preferred editor, falling back to a platform default
+func findEditor() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(env); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
 var configOrganizeCmd = &cobra.Command{
 	Use:   "organize [mode]",
 	Short: "Set file organization mode",
@@ -84,15 +181,7 @@ Examples:
 		mode := args[0]
 
 		// Validate mode
-		validModes := []string{"flat", "author", "format", "year", "custom"}
-		valid := false
-		for _, m := range validModes {
-			if mode == m {
-				valid = true
-				break
-			}
-		}
-		if !valid {
+		if !validOrganizeMode(mode) {
 			return fmt.Errorf("invalid mode: %s (use flat, author, format, year, or custom)", mode)
 		}
 
@@ -136,13 +225,26 @@ var configNotifyCmd = &cobra.Command{
 	Short: "Enable or disable desktop notifications",
 	Long: `Enable or disable desktop notifications for download events.
 
+Webhook notifications are sent whenever notifications.webhook_url is set,
+whether or not desktop notifications are on. Use --test to send a test
+event to it.
+
 Examples:
   bookdl config notify on          Enable notifications
   bookdl config notify off         Disable notifications
   bookdl config notify             Show current setting
-  bookdl config notify on --sound  Enable notifications with sound`,
+  bookdl config notify on --sound  Enable notifications with sound
+  bookdl config set notifications.webhook_url https://ntfy.sh/my-books
+  bookdl config notify --test      Send a test event to the webhook`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if test, _ := cmd.Flags().GetBool("test"); test {
+			if len(args) > 0 {
+				return fmt.Errorf("--test doesn't take on/off")
+			}
+			return sendTestWebhook(cmd.Context())
+		}
+
 		if len(args) == 0 {
 			// Show current setting
 			cfg := config.Get()
@@ -156,6 +258,11 @@ Examples:
 			} else {
 				fmt.Println("Notification sounds: disabled")
 			}
+			if cfg.Notifications.WebhookURL != "" {
+				fmt.Printf("Webhook: %s\n", cfg.Notifications.WebhookURL)
+			} else {
+				fmt.Println("Webhook: not set (notifications.webhook_url)")
+			}
 			return nil
 		}
 
@@ -246,12 +353,35 @@ func init() {
 	configOrganizeCmd.Flags().StringP("pattern", "p", "", "custom organization pattern (for custom mode)")
 	configOrganizeCmd.Flags().Bool("rename", false, "rename files based on metadata")
 
+	configEditCmd.Flags().Bool("form", false, "edit settings in an interactive form instead of $EDITOR")
+
 	configNotifyCmd.Flags().Bool("sound", false, "also enable/disable notification sounds")
+	configNotifyCmd.Flags().Bool("test", false, "send a test event to notifications.webhook_url")
 
 	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configEditCmd)
 	configCmd.AddCommand(configOrganizeCmd)
 	configCmd.AddCommand(configNotifyCmd)
 	configCmd.AddCommand(configSoundCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
+
+// sendTestWebhook posts a test event to the configured webhook and waits
+// for the answer
+func sendTestWebhook(ctx context.Context) error {
+	url := config.Get().Notifications.WebhookURL
+	if url == "" {
+		return fmt.Errorf("no webhook set; use 'bookdl config set notifications.webhook_url <url>'")
+	}
+	err := notify.PostWebhook(ctx, notify.EventTest, notify.Payload{
+		Title:   "bookdl",
+		Message: "Test notification from bookdl",
+	})
+	if err != nil {
+		return fmt.Errorf("webhook test failed: %w", err)
+	}
+	Successf("Test event sent to %s", url)
+	return nil
 }
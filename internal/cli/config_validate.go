@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/downloader"
+)
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for mistakes",
+	Long: `Load the configuration and check it for settings that would only fail
+later, mid-download or not at all: unwritable directories, zero or
+negative limits and durations, unknown organize modes or placeholders,
+sizes that don't parse, and implausible mirror hosts.
+
+Every problem is reported at once, each with a suggested fix. The exit
+status is non-zero if any were found.
+
+Examples:
+  bookdl config validate
+  bookdl --config ./test.yaml config validate`,
+	Args: cobra.NoArgs,
+	RunE: runConfigValidate,
+}
+
+// configProblem is one setting that failed validation
+type configProblem struct {
+	key     string
+	problem string
+	hint    string
+}
+
+// configChecker collects problems as settings are checked
+type configChecker struct {
+	problems []configProblem
+}
+
+func (c *configChecker) add(key, problem, hint string) {
+	c.problems = append(c.problems, configProblem{key: key, problem: problem, hint: hint})
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	path := config.ConfigFileUsed()
+	if _, err := os.Stat(path); err == nil {
+		// A file that doesn't parse leaves every setting at its default
+		if err := config.Reload(); err != nil {
+			return fmt.Errorf("%s has errors: %w\nRun 'bookdl config edit' to fix them", path, err)
+		}
+		fmt.Printf("Checking %s\n\n", path)
+	} else {
+		fmt.Printf("No config file at %s, checking the defaults\n\n", path)
+	}
+
+	problems := validateConfig(config.Get())
+	if len(problems) == 0 {
+		Successf("No problems found")
+		return nil
+	}
+
+	for _, p := range problems {
+		fmt.Printf("%s%s: %s\n", glyph("✗"), p.key, p.problem)
+		if p.hint != "" {
+			fmt.Printf("    %s\n", p.hint)
+		}
+	}
+	fmt.Println()
+	return fmt.Errorf("%d problem(s) found", len(problems))
+}
+
+// validateConfig checks every setting and returns all problems found
+func validateConfig(cfg *config.Config) []configProblem {
+	c := &configChecker{}
+
+	// anna
+	c.checkHost("anna.base_url", cfg.Anna.BaseURL)
+	for _, host := range cfg.Anna.BaseURLs {
+		c.checkHost("anna.base_urls", host)
+	}
+
+	// downloads
+	c.checkDir("downloads.path", cfg.Downloads.Path, true)
+	c.checkDir("downloads.torrent_watch_dir", cfg.Downloads.TorrentWatchDir, false)
+	if err := downloader.ValidateChunkSize(cfg.Downloads.ChunkSize); err != nil {
+		c.add("downloads.chunk_size", err.Error(),
+			fmt.Sprintf("try 'bookdl config set downloads.chunk_size %d' (5MB)", downloader.DefaultChunkSize))
+	}
+	if cfg.Downloads.MaxConcurrent < 1 {
+		c.add("downloads.max_concurrent", fmt.Sprintf("must be at least 1, got %d", cfg.Downloads.MaxConcurrent),
+			"try 'bookdl config set downloads.max_concurrent 2'")
+	}
+	if cfg.Downloads.ParallelChunks < 1 {
+		c.add("downloads.parallel_chunks", fmt.Sprintf("must be at least 1, got %d", cfg.Downloads.ParallelChunks),
+			"try 'bookdl config set downloads.parallel_chunks 4'")
+	}
+	c.checkDuration("downloads.timeout", cfg.Downloads.Timeout, true)
+	if cfg.Downloads.MaxDownloadRetries < 0 {
+		c.add("downloads.max_download_retries", "can't be negative", "use 0 to retry failed downloads forever")
+	}
+	switch cfg.Downloads.Order {
+	case orderPriority, orderSmallestFirst, orderLargestFirst:
+	default:
+		c.add("downloads.order", fmt.Sprintf("unknown order %q", cfg.Downloads.Order),
+			"use priority, smallest_first or largest_first")
+	}
+	c.checkRate("downloads.max_speed", cfg.Downloads.MaxSpeed)
+	c.checkRate("downloads.max_rate", cfg.Downloads.MaxRate)
+	c.checkSize("downloads.min_file_size", cfg.Downloads.MinFileSize)
+	c.checkSize("downloads.min_free_space", cfg.Downloads.MinFreeSpace)
+
+	// files
+	if !validOrganizeMode(cfg.Files.OrganizeMode) {
+		c.add("files.organize_mode", fmt.Sprintf("unknown mode %q", cfg.Files.OrganizeMode),
+			"use "+strings.Join(organizeModes, ", "))
+	}
+	if cfg.Files.OrganizeMode == "custom" {
+		c.checkPattern("files.organize_pattern", cfg.Files.OrganizePattern)
+	}
+	c.checkDir("files.archive_dir", cfg.Files.ArchiveDir, false)
+	for format, dir := range cfg.Files.FormatDirs {
+		c.checkDir("files.format_dirs."+format, dir, false)
+	}
+	if cfg.Files.ArchiveAfterDays < 0 {
+		c.add("files.archive_after_days", "can't be negative", "use 0 to turn auto-archiving off")
+	}
+
+	// network
+	c.checkDuration("network.timeout", cfg.Network.Timeout, false)
+	c.checkDuration("network.search_timeout", cfg.Network.SearchTimeout, true)
+	c.checkDuration("network.retry_base_delay", cfg.Network.RetryBaseDelay, false)
+	c.checkDuration("network.retry_max_delay", cfg.Network.RetryMaxDelay, false)
+	if cfg.Network.RetryMaxDelay > 0 && cfg.Network.RetryMaxDelay < cfg.Network.RetryBaseDelay {
+		c.add("network.retry_max_delay", fmt.Sprintf("%s is shorter than network.retry_base_delay (%s)",
+			cfg.Network.RetryMaxDelay, cfg.Network.RetryBaseDelay), "raise it or lower retry_base_delay")
+	}
+	if cfg.Network.RetryAttempts < 0 {
+		c.add("network.retry_attempts", "can't be negative", "use 0 to turn retries off")
+	}
+	if cfg.Network.RetryMultiplier < 1 {
+		c.add("network.retry_multiplier", fmt.Sprintf("must be at least 1, got %g", cfg.Network.RetryMultiplier),
+			"try 'bookdl config set network.retry_multiplier 2'")
+	}
+	if _, err := config.ProxyURL(); err != nil {
+		c.add("network.proxy", err.Error(), "e.g. socks5://127.0.0.1:9050, or empty for no proxy")
+	}
+
+	// browser
+	c.checkDuration("browser.page_load_timeout", cfg.Browser.PageLoadTimeout, false)
+	c.checkDuration("browser.max_countdown_wait", cfg.Browser.MaxCountdownWait, false)
+	c.checkDuration("browser.poll_interval", cfg.Browser.PollInterval, false)
+
+	// cache
+	if cfg.Cache.Enabled {
+		c.checkDuration("cache.ttl", cfg.Cache.TTL, false)
+	}
+	if cfg.Cache.MaxEntries < 0 {
+		c.add("cache.max_entries", "can't be negative", "use 0 for no limit")
+	}
+
+	// notifications
+	if cfg.Notifications.WebhookURL != "" {
+		c.checkDuration("notifications.webhook_timeout", cfg.Notifications.WebhookTimeout, false)
+		if !strings.HasPrefix(cfg.Notifications.WebhookURL, "http://") && !strings.HasPrefix(cfg.Notifications.WebhookURL, "https://") {
+			c.add("notifications.webhook_url", "must be an http:// or https:// URL", "")
+		}
+	}
+
+	return c.problems
+}
+
+// hostPattern matches a plausible domain name, optionally with a port
+var hostPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)+(:\d+)?$`)
+
+// checkHost checks an Anna's Archive domain, given the way anna.Mirrors
+// reads it: a bare host, optionally with https:// and a trailing slash
+func (c *configChecker) checkHost(key, value string) {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "https://"), "/")
+	if host == "" {
+		return
+	}
+	if strings.HasPrefix(host, "http://") {
+		c.add(key, fmt.Sprintf("%q uses http://", value), "use the bare domain, e.g. annas-archive.li")
+		return
+	}
+	if !hostPattern.MatchString(host) {
+		c.add(key, fmt.Sprintf("%q doesn't look like a domain", value), "use the bare domain, e.g. annas-archive.li")
+	}
+}
+
+// checkDir checks that dir exists and is writable, or could be created.
+// Nothing is created; an empty dir is only a problem if required.
+func (c *configChecker) checkDir(key, dir string, required bool) {
+	if dir == "" {
+		if required {
+			c.add(key, "is empty", "set it to a directory, e.g. ~/Downloads/books")
+		}
+		return
+	}
+
+	// Walk up to the closest directory that exists; it's where the rest
+	// would be created
+	existing := dir
+	for {
+		fi, err := os.Stat(existing)
+		if err == nil {
+			if !fi.IsDir() {
+				c.add(key, fmt.Sprintf("%s is a file, not a directory", existing), "point it at a directory")
+				return
+			}
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			c.add(key, fmt.Sprintf("no part of %s exists", dir), "check the path for typos")
+			return
+		}
+		existing = parent
+	}
+
+	probe, err := os.CreateTemp(existing, ".bookdl-validate-*")
+	if err != nil {
+		hint := "check its permissions"
+		if existing != dir {
+			hint = fmt.Sprintf("it would be created in %s; check that directory's permissions", existing)
+		}
+		c.add(key, fmt.Sprintf("%s is not writable", existing), hint)
+		return
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+}
+
+// checkDuration checks that d is positive, or zero where zero means no limit
+func (c *configChecker) checkDuration(key string, d time.Duration, zeroOK bool) {
+	switch {
+	case d < 0:
+		c.add(key, fmt.Sprintf("can't be negative, got %s", d), "use a duration like 30s, 5m or 1h")
+	case d == 0 && !zeroOK:
+		c.add(key, "must be longer than 0", "use a duration like 30s, 5m or 1h")
+	}
+}
+
+// checkSize checks that a size setting parses
+func (c *configChecker) checkSize(key, value string) {
+	if _, err := downloader.ParseSize(value); err != nil {
+		c.add(key, fmt.Sprintf("%q isn't a size", value), "use a size like 500KB, 10MB or 1GB, or leave it empty")
+	}
+}
+
+// checkRate checks that a rate setting parses
+func (c *configChecker) checkRate(key, value string) {
+	if _, err := downloader.ParseRate(value); err != nil {
+		c.add(key, fmt.Sprintf("%q isn't a rate", value), "use a rate like 500KB or 2MB, or 0 or empty for unlimited")
+	}
+}
+
+// placeholderPattern finds {placeholders} in an organize pattern
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// checkPattern checks that an organize pattern only uses known placeholders
+func (c *configChecker) checkPattern(key, pattern string) {
+	if strings.TrimSpace(pattern) == "" {
+		c.add(key, "is empty but files.organize_mode is custom", "e.g. {author}/{title}")
+		return
+	}
+	known := make(map[string]bool, len(patternPlaceholders))
+	for _, p := range patternPlaceholders {
+		known[p] = true
+	}
+	for _, p := range placeholderPattern.FindAllString(pattern, -1) {
+		if !known[p] {
+			hint := "use " + strings.Join(patternPlaceholders, ", ")
+			if lower := strings.ToLower(p); known[lower] {
+				hint = fmt.Sprintf("did you mean %s?", lower)
+			}
+			c.add(key, fmt.Sprintf("unknown placeholder %s", p), hint)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/billmal071/bookdl/internal/config"
+)
+
+func TestCheckSizeAndRate(t *testing.T) {
+	for _, rate := range []string{"", "0", "2M", "500KB", "1MB/s", "1.5 mb/s"} {
+		c := &configChecker{}
+		c.checkRate("downloads.max_rate", rate)
+		if len(c.problems) > 0 {
+			t.Errorf("rate %q: %v", rate, c.problems)
+		}
+	}
+	for _, size := range []string{"", "0", "8M", "100MB", "1GB"} {
+		c := &configChecker{}
+		c.checkSize("downloads.min_free_space", size)
+		if len(c.problems) > 0 {
+			t.Errorf("size %q: %v", size, c.problems)
+		}
+	}
+
+	c := &configChecker{}
+	c.checkRate("downloads.max_rate", "fast")
+	c.checkSize("downloads.min_file_size", "-1KB")
+	c.checkSize("downloads.min_file_size", "1MB/s")
+	if len(c.problems) != 3 {
+		t.Errorf("got %d problems for invalid values, want 3", len(c.problems))
+	}
+}
+
+func TestValidateConfigDefaults(t *testing.T) {
+	for _, p := range validateConfig(config.Get()) {
+		t.Errorf("default %s: %s", p.key, p.problem)
+	}
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/config"
+)
+
+// maxCoverSize caps how much of a cover image is saved
+const maxCoverSize = 10 * 1024 * 1024
+
+// coverExtensions are the image types kept as they are named
+var coverExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true}
+
+// saveCover downloads the book's cover next to the book file, named after
+// it (Dune.epub gets Dune.jpg), and returns the cover's path
+func saveCover(ctx context.Context, book *anna.Book, bookPath string) (string, error) {
+	if book == nil || book.CoverURL == "" {
+		return "", fmt.Errorf("no cover found for this book")
+	}
+
+	ctx, cancel := withTimeout(ctx, config.Get().Network.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", book.CoverURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", config.RandomUserAgent())
+
+	client := &http.Client{Transport: config.ProxyTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cover request returned %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.HasPrefix(contentType, "image/") {
+		return "", fmt.Errorf("cover is %s, not an image", contentType)
+	}
+
+	coverPath := strings.TrimSuffix(bookPath, filepath.Ext(bookPath)) + coverExtension(book.CoverURL, contentType)
+	if coverPath == bookPath {
+		return "", fmt.Errorf("cover would overwrite the book")
+	}
+
+	file, err := os.Create(coverPath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(file, io.LimitReader(resp.Body, maxCoverSize)); err != nil {
+		file.Close()
+		os.Remove(coverPath)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(coverPath)
+		return "", err
+	}
+	return coverPath, nil
+}
+
+// coverExtension picks the cover's file extension from its URL, then its
+// content type, defaulting to .jpg
+func coverExtension(coverURL, contentType string) string {
+	if i := strings.IndexAny(coverURL, "?#"); i >= 0 {
+		coverURL = coverURL[:i]
+	}
+	if ext := strings.ToLower(path.Ext(coverURL)); coverExtensions[ext] {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(contentType); err == nil {
+		for _, ext := range exts {
+			if coverExtensions[ext] {
+				return ext
+			}
+		}
+	}
+	return ".jpg"
+}
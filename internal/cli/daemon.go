@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+	"github.com/billmal071/bookdl/internal/notify"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep processing the download queue in the foreground",
+	Long: `Run until interrupted, starting queued downloads as they are added.
+
+The queue is polled every --interval and due downloads are started, up to
+downloads.max_concurrent at a time. Paused downloads are resumed when the
+daemon starts; downloads you pause while it runs stay paused. Failed
+downloads are left alone (use 'bookdl restart').
+
+On Ctrl+C (or SIGTERM) active downloads are paused, keeping their progress
+for the next run.
+
+Examples:
+  bookdl daemon
+  bookdl daemon --interval 1m
+  bookdl queue add <md5>     From another terminal; picked up on the next poll`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+func init() {
+	daemonCmd.Flags().Duration("interval", 30*time.Second, "how often to check the queue")
+	daemonCmd.Flags().StringVar(&limitRate, "limit-rate", "", "cap each download's speed, e.g. 500KB or 2MB")
+	daemonCmd.Flags().BoolVar(&verifyAfter, "verify-after", false, "fail downloads whose checksum doesn't match")
+}
+
+// daemon dispatches queued downloads and tracks the ones in flight
+type daemon struct {
+	mgr   *downloader.Manager
+	wg    sync.WaitGroup
+	slots chan struct{} // one per download in flight, up to max_concurrent
+
+	mu     sync.Mutex
+	active map[int64]*db.Download // holding a slot until their outcome is recorded
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	interval, _ := cmd.Flags().GetDuration("interval")
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	mgr, err := newDownloadManager()
+	if err != nil {
+		return err
+	}
+	d := &daemon{
+		mgr:    mgr,
+		slots:  make(chan struct{}, mgr.GetMaxConcurrent()),
+		active: make(map[int64]*db.Download),
+	}
+
+	// Execute cancels this on Ctrl+C or SIGTERM, which pauses the running
+	// downloads; a second Ctrl+C exits at once
+	ctx := cmd.Context()
+
+	fmt.Printf("bookdl daemon: checking the queue every %s (max %d concurrent). Press Ctrl+C to stop.\n\n",
+		interval, mgr.GetMaxConcurrent())
+
+	// Pick up where the last run left off
+	if paused, err := db.ListDownloads(db.StatusPaused, false); err == nil {
+		d.dispatch(ctx, paused)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if due, err := db.ListDueDownloads(time.Now()); err != nil {
+			Errorf("failed to check queue: %v", err)
+		} else {
+			d.dispatch(ctx, due)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			fmt.Println("\nStopping, pausing active downloads...")
+			d.wg.Wait()
+			fmt.Println("Stopped. Run 'bookdl daemon' or 'bookdl resume all' to continue.")
+			return nil
+		}
+	}
+}
+
+// dispatch starts as many of downloads as there are free slots, skipping
+// ones already running. Each download frees its slot as soon as it ends.
+func (d *daemon) dispatch(ctx context.Context, downloads []*db.Download) {
+	for _, dl := range downloads {
+		d.mu.Lock()
+		_, running := d.active[dl.ID]
+		d.mu.Unlock()
+		if running {
+			continue
+		}
+
+		select {
+		case d.slots <- struct{}{}:
+		default:
+			return // all slots taken
+		}
+
+		d.mu.Lock()
+		d.active[dl.ID] = dl
+		d.mu.Unlock()
+
+		d.wg.Add(1)
+		go func(dl *db.Download) {
+			defer d.wg.Done()
+			defer func() { <-d.slots }()
+
+			fmt.Printf("%sStarting: %s\n", glyph("⬇️"), dl.Title)
+			d.finish(downloader.DownloadResult{Download: dl, Error: d.mgr.StartDownload(ctx, dl)})
+		}(dl)
+	}
+}
+
+// finish records the outcome of a download and frees its slot
+func (d *daemon) finish(result downloader.DownloadResult) {
+	dl := result.Download
+	defer func() {
+		d.mu.Lock()
+		delete(d.active, dl.ID)
+		d.mu.Unlock()
+	}()
+
+	if result.Error == downloader.ErrPaused {
+		fmt.Printf("%sPaused: %s\n", glyph("⏸️"), dl.Title)
+		return
+	}
+
+	err := result.Error
+	if err == nil {
+		if verr := verifyCompleted(dl); verr != nil {
+			err = fmt.Errorf("verification failed: %w", verr)
+		}
+	}
+	if err == nil {
+		err = db.MarkCompleted(dl.ID, dl.FilePath)
+	}
+	if err != nil {
+		db.MarkFailed(dl.ID, err.Error())
+		fmt.Printf("%sFailed: %s: %v\n", glyph("❌"), dl.Title, err)
+		notify.DownloadFailed(dl.Title, err.Error())
+		return
+	}
+
+	writeMetadataSidecar(dl, nil)
+	fmt.Printf("%sCompleted: %s\n", glyph("✅"), dl.Title)
+	notify.DownloadComplete(dl.Title)
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+	"github.com/billmal071/bookdl/internal/tui"
+)
+
+// runWithDashboard runs downloads like StartConcurrent while showing the
+// download dashboard. Downloads paused from the dashboard can be resumed
+// from it too, so the dashboard stays open while any are paused; detaching
+// closes it but waits for the running downloads.
+func runWithDashboard(ctx context.Context, mgr *downloader.Manager, downloads []*db.Download) ([]downloader.DownloadResult, error) {
+	rows := make([]tui.DashboardRow, len(downloads))
+	byID := make(map[int64]*db.Download, len(downloads))
+	for i, d := range downloads {
+		rows[i] = tui.DashboardRow{ID: d.ID, Title: d.Title, Downloaded: d.DownloadedSize, Total: d.FileSize}
+		byID[d.ID] = d
+	}
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		results    = make(map[int64]downloader.DownloadResult)
+		resumed    = make(map[int64]bool) // restarted from the dashboard; their batch result is stale
+		running    = len(downloads)
+		pausedHere = make(map[int64]bool) // paused from the dashboard, may be resumed
+		dash       *tui.Dashboard
+	)
+
+	// finished updates a download's row once it stops and closes the
+	// dashboard when nothing is left running or waiting to be resumed
+	finished := func(id int64, status string) {
+		mu.Lock()
+		running--
+		done := running == 0 && len(pausedHere) == 0
+		mu.Unlock()
+
+		dash.SetStatus(id, status)
+		if done {
+			dash.Done()
+		}
+	}
+
+	dash = tui.NewDashboard(rows, tui.DashboardActions{
+		Pause: func(id int64) error {
+			mu.Lock()
+			pausedHere[id] = true
+			mu.Unlock()
+			return mgr.PauseDownload(id)
+		},
+		Resume: func(id int64) error {
+			mu.Lock()
+			delete(pausedHere, id)
+			resumed[id] = true
+			running++
+			mu.Unlock()
+
+			d := byID[id]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := mgr.StartDownload(ctx, d)
+				mu.Lock()
+				results[id] = downloader.DownloadResult{Download: d, Error: err}
+				mu.Unlock()
+
+				switch {
+				case err == downloader.ErrPaused:
+					finished(id, tui.DownloadPaused)
+				case err != nil:
+					finished(id, tui.DownloadFailed)
+				default:
+					finished(id, tui.DownloadCompleted)
+				}
+			}()
+			return nil
+		},
+	})
+
+	downloader.SetProgressBars(false)
+	mgr.SetProgressListener(dash.Progress)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		batch := mgr.StartConcurrent(ctx, downloads, func(id int64, status string, progress float64) {
+			switch status {
+			case "starting":
+				dash.SetStatus(id, tui.DownloadRunning)
+			case "paused":
+				finished(id, tui.DownloadPaused)
+			case "failed":
+				finished(id, tui.DownloadFailed)
+			case "completed":
+				finished(id, tui.DownloadCompleted)
+			}
+		})
+		mu.Lock()
+		for _, result := range batch {
+			if !resumed[result.Download.ID] {
+				results[result.Download.ID] = result
+			}
+		}
+		mu.Unlock()
+	}()
+
+	detached, err := dash.Run()
+	if err != nil {
+		return nil, fmt.Errorf("dashboard failed: %w", err)
+	}
+	if detached {
+		mu.Lock()
+		left := running
+		mu.Unlock()
+		if left > 0 {
+			fmt.Printf("Waiting for %d running download(s) to finish...\n", left)
+		}
+	}
+	wg.Wait()
+
+	ordered := make([]downloader.DownloadResult, 0, len(downloads))
+	for _, d := range downloads {
+		if result, ok := results[d.ID]; ok {
+			ordered = append(ordered, result)
+		}
+	}
+	return ordered, nil
+}
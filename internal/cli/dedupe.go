@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Find books downloaded more than once",
+	Long: `List completed downloads that look like the same book, e.g. the same
+title downloaded as both EPUB and PDF. Books match on their title (ignoring
+case, punctuation and subtitles) and first author.
+
+Use --delete-keep to keep one copy of each and delete the others' files
+and records. Give a format to keep that format (groups without a copy in
+it are left alone), or "preferred" to follow files.preferred_formats.
+
+Examples:
+  bookdl dedupe                          List likely duplicates
+  bookdl dedupe --delete-keep epub       Keep the EPUBs, delete the rest
+  bookdl dedupe --delete-keep preferred --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runDedupe,
+}
+
+func init() {
+	dedupeCmd.Flags().String("delete-keep", "", "keep this format (or \"preferred\") and delete the other copies")
+	dedupeCmd.Flags().Bool("dry-run", false, "show what would be deleted")
+}
+
+func runDedupe(cmd *cobra.Command, args []string) error {
+	keepFormat := strings.ToLower(getString(cmd, "delete-keep"))
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	clusters, err := db.FindDuplicateDownloads()
+	if err != nil {
+		return fmt.Errorf("failed to find duplicates: %w", err)
+	}
+	if len(clusters) == 0 {
+		fmt.Println("No duplicates found.")
+		return nil
+	}
+
+	if keepFormat == "" {
+		fmt.Printf("Likely duplicates (%d):\n\n", len(clusters))
+		for _, cluster := range clusters {
+			fmt.Println(cluster[0].Title)
+			for _, d := range cluster {
+				fmt.Printf("  [%d] %-5s %8s  %s\n", d.ID, strings.ToUpper(d.Format), downloader.FormatBytes(d.FileSize), d.FilePath)
+			}
+			fmt.Println()
+		}
+		fmt.Println("Use 'bookdl dedupe --delete-keep <format>' to keep one copy of each.")
+		return nil
+	}
+
+	deleted := 0
+	for _, cluster := range clusters {
+		keep := pickKeeper(cluster, keepFormat)
+		if keep == nil {
+			fmt.Printf("Skipping %q: no %s copy\n", cluster[0].Title, strings.ToUpper(keepFormat))
+			continue
+		}
+
+		for _, d := range cluster {
+			if d == keep {
+				continue
+			}
+			if dryRun {
+				fmt.Printf("Would delete: [%d] %s (%s), keeping [%d] (%s)\n", d.ID, d.Title, d.Format, keep.ID, keep.Format)
+				deleted++
+				continue
+			}
+			if err := deleteDownloadFiles(d); err != nil {
+				Errorf("failed to delete #%d: %v", d.ID, err)
+				continue
+			}
+			if err := db.DeleteDownload(d.ID); err != nil {
+				Errorf("failed to remove #%d: %v", d.ID, err)
+				continue
+			}
+			fmt.Printf("Deleted: [%d] %s (%s), keeping [%d] (%s)\n", d.ID, d.Title, d.Format, keep.ID, keep.Format)
+			deleted++
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("\n%d duplicate(s) would be deleted.\n", deleted)
+	} else if deleted > 0 {
+		Successf("Deleted %d duplicate(s).", deleted)
+	}
+	return nil
+}
+
+// pickKeeper returns the copy to keep: the oldest one in format, or with
+// "preferred" the best per files.preferred_formats. nil if no copy fits.
+func pickKeeper(cluster []*db.Download, format string) *db.Download {
+	if format == "preferred" {
+		keep := cluster[0]
+		for _, d := range cluster[1:] {
+			if preferDownload(d, keep) {
+				keep = d
+			}
+		}
+		return keep
+	}
+	for _, d := range cluster {
+		if strings.EqualFold(d.Format, format) {
+			return d
+		}
+	}
+	return nil
+}
+
+// deleteDownloadFiles removes a download's file and its metadata sidecar.
+// A file that's already gone isn't an error.
+func deleteDownloadFiles(d *db.Download) error {
+	if d.FilePath != "" {
+		if err := os.Remove(d.FilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		os.Remove(metadataPath(d.FilePath))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete [id...]",
+	Short: "Remove downloads from the library",
+	Long: `Remove download records by ID. The downloaded files are kept unless
+--with-file is given, which also deletes the book, its metadata sidecar and
+any partial .part file.
+
+You are asked to confirm before anything is deleted; --force skips the
+question, and is required when there is no terminal to ask on.
+
+Examples:
+  bookdl delete 12                   Forget download #12, keep the file
+  bookdl delete 12 15 --with-file    Delete the records and their files
+  bookdl delete --all-failed --force Remove every failed download`,
+	RunE: runDelete,
+}
+
+func init() {
+	deleteCmd.Flags().Bool("with-file", false, "also delete the downloaded file and any partial file")
+	deleteCmd.Flags().Bool("all-failed", false, "delete every failed download")
+	deleteCmd.Flags().BoolP("force", "f", false, "don't ask for confirmation")
+}
+
+func runDelete(cmd *cobra.Command, args []string) error {
+	withFile, _ := cmd.Flags().GetBool("with-file")
+	allFailed, _ := cmd.Flags().GetBool("all-failed")
+	force, _ := cmd.Flags().GetBool("force")
+
+	if allFailed && len(args) > 0 {
+		return fmt.Errorf("give download IDs or --all-failed, not both")
+	}
+	if !allFailed && len(args) == 0 {
+		return fmt.Errorf("no downloads given; pass IDs or --all-failed")
+	}
+
+	var downloads []*db.Download
+	if allFailed {
+		failed, err := db.ListDownloads(db.StatusFailed, false)
+		if err != nil {
+			return fmt.Errorf("failed to list downloads: %w", err)
+		}
+		downloads = failed
+	} else {
+		for _, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				Errorf("invalid ID: %s", arg)
+				continue
+			}
+			download, err := db.GetDownload(id)
+			if err != nil {
+				Errorf("download #%d not found", id)
+				continue
+			}
+			if download.Status == db.StatusDownloading {
+				Errorf("download #%d is in progress; pause it first", id)
+				continue
+			}
+			downloads = append(downloads, download)
+		}
+	}
+
+	if len(downloads) == 0 {
+		fmt.Println("Nothing to delete.")
+		return nil
+	}
+
+	if !force {
+		for _, d := range downloads {
+			fmt.Printf("  [%d] %s (%s)\n", d.ID, d.Title, d.Status)
+		}
+		question := fmt.Sprintf("Delete %d download record(s)?", len(downloads))
+		if withFile {
+			question = fmt.Sprintf("Delete %d download(s) and their files?", len(downloads))
+		}
+		if !interactive() {
+			return fmt.Errorf("refusing to delete without confirmation; use --force")
+		}
+		if !confirm(question) {
+			fmt.Println("Nothing deleted.")
+			return nil
+		}
+	}
+
+	deleted := 0
+	for _, d := range downloads {
+		if withFile {
+			if err := deleteDownloadFiles(d); err != nil {
+				Errorf("failed to delete %s: %v", d.FilePath, err)
+				continue
+			}
+			if d.TempPath != "" {
+				if err := os.Remove(d.TempPath); err != nil && !os.IsNotExist(err) {
+					Errorf("failed to delete %s: %v", d.TempPath, err)
+					continue
+				}
+			}
+		}
+		if err := db.DeleteDownload(d.ID); err != nil {
+			Errorf("failed to remove #%d: %v", d.ID, err)
+			continue
+		}
+		Printf("Deleted: [%d] %s\n", d.ID, d.Title)
+		deleted++
+	}
+
+	if deleted > 0 {
+		Successf("Deleted %d download(s).", deleted)
+	}
+	return nil
+}
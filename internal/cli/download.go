@@ -1,19 +1,26 @@
 package cli
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/spf13/cobra"
 	"github.com/billmal071/bookdl/internal/anna"
 	"github.com/billmal071/bookdl/internal/config"
 	"github.com/billmal071/bookdl/internal/db"
 	"github.com/billmal071/bookdl/internal/downloader"
+	"github.com/billmal071/bookdl/internal/log"
 	"github.com/billmal071/bookdl/internal/notify"
+	"github.com/billmal071/bookdl/internal/tui"
 )
 
 var downloadCmd = &cobra.Command{
@@ -21,30 +28,239 @@ var downloadCmd = &cobra.Command{
 	Short: "Download a book by MD5 hash",
 	Long: `Download a book from Anna's Archive using its MD5 hash.
 
-The MD5 hash can be obtained from the search results.
+The MD5 hash can be obtained from the search results, or pasted as part
+of the book's link (https://annas-archive.se/md5/<hash>, any mirror).
+Pass - to read hashes from stdin, one per line. --from-file queues the
+hashes in a file and downloads them concurrently (downloads.max_concurrent).
 
 Examples:
   bookdl download abc123def456789...
-  bookdl download -o ~/Books abc123def456789...`,
-	Args: cobra.ExactArgs(1),
+  bookdl download https://annas-archive.se/md5/abc123def456789...
+  bookdl download -o ~/Books abc123def456789...
+  bookdl download --limit-rate 500KB abc123def456789...
+  cat hashes.txt | bookdl download -
+  bookdl download --from-file hashes.txt
+  bookdl download --volumes abc123def456789...   Fetch every volume of a multi-file book
+  bookdl download --cover abc123def456789...     Also save the cover image next to the book
+  bookdl download --torrent abc123def456789...   Hand the torrent to downloads.torrent_handler
+  bookdl download --at 02:00 abc123def456789...  Queue it to start at 2am ('bookdl resume all')
+  bookdl download --after 3h abc123def456789...`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if fromFile != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if scheduleAt != "" && scheduleAfter != "" {
+			return fmt.Errorf("--at and --after can't be used together")
+		}
 		outputDir, _ := cmd.Flags().GetString("output")
+		if fromFile != "" {
+			if scheduleAt != "" || scheduleAfter != "" {
+				return fmt.Errorf("--from-file can't be combined with --at or --after")
+			}
+			return runDownloadFromFile(cmd.Context(), fromFile, outputDir)
+		}
+		if args[0] == "-" {
+			return runDownloadHashes(cmd.Context(), os.Stdin, outputDir)
+		}
 		return runDownloadByHash(cmd.Context(), args[0], outputDir, nil)
 	},
 }
 
+// verifyAfter makes a checksum mismatch fail the download instead of warning
+var verifyAfter bool
+
+// limitRate overrides downloads.max_rate for this run
+var limitRate string
+
+// chunkSize overrides downloads.chunk_size for this run
+var chunkSize string
+
+// downloadVolumes fetches every volume of a multi-file book
+var downloadVolumes bool
+
+// raceMirrors probes all mirrors concurrently and starts with the fastest
+var raceMirrors bool
+
+// chooseMirror lets the user pick which mirror to start with
+var chooseMirror bool
+
+// downloadCover also saves the book's cover image next to it
+var downloadCover bool
+
+// scheduleAt and scheduleAfter queue the download to start later
+// instead of now (--at 02:00, --after 3h)
+var scheduleAt, scheduleAfter string
+
+// volumeKey marks a context as belonging to one volume of a volume set,
+// so the volumes themselves aren't expanded again
+type volumeKey struct{}
+
 func init() {
 	downloadCmd.Flags().StringP("output", "o", "", "output directory (default: ~/Downloads/books)")
+	downloadCmd.Flags().BoolVar(&verifyAfter, "verify-after", false, "fail the download if the checksum doesn't match (tries the next mirror)")
+	downloadCmd.Flags().StringVar(&limitRate, "limit-rate", "", "cap each download's speed, e.g. 500KB or 2MB")
+	downloadCmd.Flags().StringVar(&chunkSize, "chunk-size", "", "size of each download chunk, 256KB to 256MB (default from downloads.chunk_size)")
+	downloadCmd.Flags().BoolVar(&downloadVolumes, "volumes", false, "download all volumes of a multi-file book into one directory")
+	downloadCmd.Flags().BoolVar(&raceMirrors, "race-mirrors", false, "probe the direct mirror links at once and start with the first to send data")
+	downloadCmd.Flags().BoolVar(&chooseMirror, "choose-mirror", false, "pick which mirror to start with from a list")
+	downloadCmd.Flags().BoolVar(&downloadCover, "cover", false, "also save the cover image next to the book")
+	downloadCmd.Flags().StringVar(&fromFile, "from-file", "", "queue the MD5 hashes in a file (one per line) and download them concurrently")
+	downloadCmd.Flags().StringVar(&scheduleAt, "at", "", "queue the download to start at a time (15:04 or \"2006-01-02 15:04\")")
+	downloadCmd.Flags().StringVar(&scheduleAfter, "after", "", "queue the download to start after a delay, e.g. 3h or 90m")
+}
+
+// scheduledStart resolves --at/--after to a start time, or nil to start now.
+// A bare --at time that has already passed today means tomorrow.
+func scheduledStart(now time.Time) (*time.Time, error) {
+	switch {
+	case scheduleAfter != "":
+		delay, err := time.ParseDuration(scheduleAfter)
+		if err != nil || delay < 0 {
+			return nil, fmt.Errorf("invalid --after %q (use e.g. 3h or 90m)", scheduleAfter)
+		}
+		at := now.Add(delay)
+		return &at, nil
+	case scheduleAt != "":
+		if at, err := time.ParseInLocation("2006-01-02 15:04", scheduleAt, time.Local); err == nil {
+			return &at, nil
+		}
+		clock, err := time.ParseInLocation("15:04", scheduleAt, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --at %q (use 15:04 or \"2006-01-02 15:04\")", scheduleAt)
+		}
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return &at, nil
+	}
+	return nil, nil
+}
+
+// newDownloadManager creates a download manager, applying --limit-rate,
+// --chunk-size and --verify-resume
+func newDownloadManager() (*downloader.Manager, error) {
+	mgr := downloader.NewManager()
+	mgr.SetVerifyResume(verifyResume)
+	mgr.SetFilenameSanitizer(sanitizeFilename)
+	if limitRate != "" {
+		rate, err := downloader.ParseRate(limitRate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --limit-rate: %w", err)
+		}
+		mgr.SetMaxRate(rate)
+	}
+	if chunkSize != "" {
+		size, err := downloader.ParseSize(chunkSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --chunk-size: %w", err)
+		}
+		if err := mgr.SetChunkSize(size); err != nil {
+			return nil, fmt.Errorf("invalid --chunk-size: %w", err)
+		}
+	}
+	if rate := mgr.MaxRate(); rate > 0 {
+		Printf("Rate limit: %s/s per download\n", downloader.FormatBytes(rate))
+	}
+	// Without a terminal there are no progress bars; print plain lines
+	// instead, unless --quiet asked for none at all
+	if !quiet && !isTerminal(os.Stdout) {
+		mgr.SetProgressListener(plainProgressPrinter(os.Stdout))
+	}
+	return mgr, nil
+}
+
+// verifyAfterEnabled returns whether strict post-download verification is on
+func verifyAfterEnabled() bool {
+	return verifyAfter || config.Get().Downloads.VerifyAfter
+}
+
+// verifyCompleted verifies a finished download's checksum.
+// A mismatch is only returned as an error when strict verification is enabled;
+// otherwise it is reported as a warning.
+func verifyCompleted(download *db.Download) error {
+	err := downloader.VerifyAndMark(download)
+	if err == nil {
+		return nil
+	}
+	if verifyAfterEnabled() {
+		return err
+	}
+	fmt.Printf("%sWarning: Checksum verification failed for %s: %v\n", glyph("⚠️"), download.Title, err)
+	return nil
+}
+
+// readHashes reads MD5 hashes one per line. Blank lines and # comments are
+// ignored, and only the first field of a line is used so tab-separated
+// lists (e.g. 'queue export --format text') work. Invalid lines are counted
+// in skipped.
+func readHashes(r io.Reader) (hashes []string, skipped int, err error) {
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, err := anna.ExtractMD5(strings.Fields(line)[0])
+		if err != nil {
+			Errorf("skipping %v", err)
+			skipped++
+			continue
+		}
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		hashes = append(hashes, hash)
+	}
+	return hashes, skipped, scanner.Err()
+}
+
+// runDownloadHashes downloads every hash read from r, one after another
+func runDownloadHashes(ctx context.Context, r io.Reader, outputDir string) error {
+	hashes, skipped, err := readHashes(r)
+	if err != nil {
+		return fmt.Errorf("failed to read hashes: %w", err)
+	}
+	if len(hashes) == 0 {
+		return fmt.Errorf("no valid MD5 hashes given")
+	}
+
+	failed := 0
+	for i, hash := range hashes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Printf("[%d/%d] %s\n", i+1, len(hashes), hash)
+		if err := runDownloadByHash(ctx, hash, outputDir, nil); err != nil {
+			Errorf("%s: %v", hash, err)
+			failed++
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Summary: %d downloaded, %d failed, %d skipped\n", len(hashes)-failed, failed, skipped)
+	if failed > 0 {
+		return fmt.Errorf("%d download(s) failed", failed)
+	}
+	return nil
 }
 
 // runDownloadByHash downloads a book by its MD5 hash
 func runDownloadByHash(ctx context.Context, md5Hash string, outputDir string, bookInfo *anna.Book) error {
-	// Normalize hash
-	md5Hash = strings.ToLower(strings.TrimSpace(md5Hash))
+	// Accept a bare hash or a pasted book link
+	md5Hash, err := anna.ExtractMD5(md5Hash)
+	if err != nil {
+		return err
+	}
 
-	// Validate hash format
-	if len(md5Hash) != 32 {
-		return fmt.Errorf("invalid MD5 hash: must be 32 characters")
+	startAt, err := scheduledStart(time.Now())
+	if err != nil {
+		return err
 	}
 
 	// Set output directory
@@ -93,15 +309,27 @@ func runDownloadByHash(ctx context.Context, md5Hash string, outputDir string, bo
 	fmt.Printf("Getting download links...\n")
 	dlInfo, err := client.GetDownloadInfo(ctx, md5Hash)
 	if err != nil {
-		return fmt.Errorf("failed to get download info: %w", err)
+		return fmt.Errorf("failed to get download info: %w%s", err, downloadInfoHint(err))
+	}
+
+	// Torrents are only used when asked for, or when nothing else is offered
+	if dlInfo.TorrentURL != "" && (useTorrent || (dlInfo.DirectURL == "" && len(dlInfo.MirrorURLs) == 0)) {
+		return handleTorrent(ctx, dlInfo.TorrentURL, getTitle(bookInfo, md5Hash))
 	}
 
 	if dlInfo.DirectURL == "" && len(dlInfo.MirrorURLs) == 0 {
-		return fmt.Errorf("no download links found")
+		return fmt.Errorf("no download links found: %w%s", anna.ErrNoPublicMirrors, downloadInfoHint(anna.ErrNoPublicMirrors))
+	}
+
+	if len(dlInfo.Volumes) > 0 && ctx.Value(volumeKey{}) == nil {
+		if downloadVolumes {
+			return runDownloadVolumes(ctx, md5Hash, outputDir, bookInfo, dlInfo.Volumes)
+		}
+		fmt.Printf("This book has %d other volume(s). Use --volumes to download them all.\n", len(dlInfo.Volumes))
 	}
 
 	// Determine filename
-	filename := dlInfo.Filename
+	filename := sanitizeFilename(dlInfo.Filename)
 	if filename == "" && bookInfo != nil {
 		// Create filename from book info
 		safeName := sanitizeFilename(bookInfo.Title)
@@ -123,20 +351,27 @@ func runDownloadByHash(ctx context.Context, md5Hash string, outputDir string, bo
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	tempPath := filePath + ".part"
+	tempPath := downloader.TempPath(filePath)
 
 	// Create download record
 	download := &db.Download{
 		MD5Hash:   md5Hash,
 		Title:     getTitle(bookInfo, md5Hash),
 		Authors:   getAuthors(bookInfo),
+		Publisher: getPublisher(bookInfo),
+		Language:  getLanguage(bookInfo),
 		Format:    getFormat(bookInfo),
+		FileSize:  dlInfo.FileSize,
 		SourceURL: fmt.Sprintf("https://%s/md5/%s", anna.GetBaseURL(), md5Hash),
 		FilePath:  filePath,
 		TempPath:  tempPath,
 		Status:    db.StatusPending,
 	}
 
+	if download.FileSize == 0 && bookInfo != nil {
+		download.FileSize = bookInfo.SizeBytes
+	}
+
 	// Get the primary download URL
 	downloadURL := dlInfo.DirectURL
 	if downloadURL == "" && len(dlInfo.MirrorURLs) > 0 {
@@ -154,32 +389,74 @@ func runDownloadByHash(ctx context.Context, md5Hash string, outputDir string, bo
 		}
 	}
 
+	// Keep any extra hashes from the page for 'verify --algo'
+	if dlInfo.SHA1 != "" || dlInfo.SHA256 != "" {
+		if err := db.SetHashes(download.ID, dlInfo.SHA1, dlInfo.SHA256); err != nil {
+			log.Warn("failed to save SHA-1/SHA-256 hashes", "id", download.ID, "error", err)
+		}
+		download.SHA1, download.SHA256 = dlInfo.SHA1, dlInfo.SHA256
+	}
+
+	if startAt != nil {
+		if err := db.SetScheduledAt(download.ID, startAt); err != nil {
+			return fmt.Errorf("failed to schedule download: %w", err)
+		}
+		Successf("Scheduled for %s: %s", startAt.Format("2006-01-02 15:04"), download.Title)
+		fmt.Println("Run 'bookdl resume all' after then (e.g. from cron) to start it.")
+		return nil
+	}
+
 	fmt.Printf("Downloading: %s\n", download.Title)
 	fmt.Printf("Destination: %s\n", download.FilePath)
 	fmt.Println()
 
 	// Create download manager and start download
-	mgr := downloader.NewManager()
-
-	// Create context with configurable timeout
-	timeout := config.Get().Downloads.Timeout
-	if timeout == 0 {
-		timeout = 30 * time.Minute
+	mgr, err := newDownloadManager()
+	if err != nil {
+		return err
 	}
-	dlCtx, cancel := context.WithTimeout(ctx, timeout)
+
+	// Limit the download to --timeout or downloads.timeout
+	dlCtx, cancel := withTimeout(ctx, downloadTimeout())
 	defer cancel()
 
-	// Collect all possible URLs to try
-	urlsToTry := []string{downloadURL}
-	for _, mirror := range dlInfo.MirrorURLs {
-		if mirror != downloadURL {
-			urlsToTry = append(urlsToTry, mirror)
+	// Collect all possible URLs to try, each mirror once
+	urlsToTry := dedupeURLs(append([]string{downloadURL}, dlInfo.MirrorURLs...))
+	urlsToTry = expandIPFSGateways(urlsToTry)
+
+	// Start with whichever direct link answers first; the rest stay as
+	// fallbacks. Pages (slow_download, LibGen) aren't raced: they answer
+	// quickly but are only resolved if they're reached in the loop.
+	var direct []string
+	for _, u := range urlsToTry {
+		if !anna.IsDownloadPage(u) {
+			direct = append(direct, u)
+		}
+	}
+	if raceMirrors && len(urlsToTry) > 1 && len(direct) > 0 {
+		Printf("Racing %d mirrors...\n", len(direct))
+		if fastest, err := mgr.PickFastestMirror(dlCtx, direct); err == nil {
+			Printf("Fastest mirror: %s\n", fastest)
+			urlsToTry = moveToFront(urlsToTry, fastest)
+		} else {
+			Printf("Mirror race failed (%v), trying mirrors in order\n", err)
+		}
+	}
+
+	// The chosen mirror goes first; the others stay as fallbacks
+	if chooseMirror && len(urlsToTry) > 1 {
+		if interactive() {
+			urlsToTry = promptMirror(urlsToTry)
+		} else {
+			Printf("--choose-mirror needs a terminal, trying mirrors in order\n")
 		}
 	}
 
 	var lastErr error
-	for i, tryURL := range urlsToTry {
-		// For slow_download/fast_download URLs, resolve them via browser
+	for i := 0; i < len(urlsToTry) && !downloader.Interrupted(ctx); i++ {
+		tryURL := urlsToTry[i]
+
+		// slow_download/fast_download URLs are pages, not files; resolve them first
 		if strings.Contains(tryURL, "/slow_download/") || strings.Contains(tryURL, "/fast_download/") {
 			if i > 0 {
 				fmt.Printf("Trying mirror %d: resolving download link...\n", i+1)
@@ -187,7 +464,7 @@ func runDownloadByHash(ctx context.Context, md5Hash string, outputDir string, bo
 				fmt.Printf("Resolving download link...\n")
 			}
 			// Use dlCtx which respects the configured timeout
-			resolvedURL, err := anna.NewBrowserClient(anna.GetBaseURL()).ResolveDownloadURL(dlCtx, tryURL)
+			resolvedURL, err := resolveDownloadLink(dlCtx, tryURL)
 			if err != nil {
 				// Check if it's a timeout error
 				if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "context deadline exceeded") {
@@ -200,27 +477,81 @@ func runDownloadByHash(ctx context.Context, md5Hash string, outputDir string, bo
 				continue
 			}
 			tryURL = resolvedURL
+
+			// Slow downloads usually lead to an IPFS gateway; if it fails,
+			// try the same file on other gateways before the next mirror
+			if alternatives := newURLs(urlsToTry, anna.IPFSAlternatives(tryURL, ipfsAlternates)); len(alternatives) > 0 {
+				urlsToTry = insertAfter(urlsToTry, i, alternatives)
+			}
+		}
+
+		// LibGen file.php/library.lol links are pages leading to the file
+		if anna.IsLibgenPage(tryURL) {
+			Printf("Resolving LibGen link...\n")
+			resolvedURL, err := anna.ResolveLibgenURL(dlCtx, tryURL)
+			if err != nil {
+				lastErr = fmt.Errorf("failed to resolve libgen link: %w", err)
+				if i < len(urlsToTry)-1 {
+					fmt.Printf("Trying next mirror...\n")
+				}
+				continue
+			}
+			tryURL = resolvedURL
+		}
+
+		// A host that kept failing for an earlier mirror is skipped for a while
+		if mgr.HostDown(tryURL) {
+			Printf("Skipping mirror %d: its host is down\n", i+1)
+			lastErr = fmt.Errorf("%w: %s", downloader.ErrHostDown, tryURL)
+			continue
 		}
 
 		download.DownloadURL = tryURL
 
 		err := mgr.StartDownload(dlCtx, download)
+		if err == downloader.ErrPaused {
+			fmt.Printf("Paused. Use 'bookdl resume %d' to continue.\n", download.ID)
+			return nil
+		}
 		if err == nil {
-			// Success! Mark as completed
-			if err := db.MarkCompleted(download.ID, download.FilePath); err != nil {
-				return fmt.Errorf("failed to mark download complete: %w", err)
-			}
-
 			// Verify checksum
 			fmt.Println("Verifying checksum...")
 			if err := downloader.VerifyAndMark(download); err != nil {
-				fmt.Printf("⚠️  Warning: Checksum verification failed: %v\n", err)
+				if verifyAfterEnabled() {
+					// Discard the bad file and start over with the next mirror
+					fmt.Printf("%sChecksum verification failed: %v\n", glyph("❌"), err)
+					os.Remove(download.FilePath)
+					db.DeleteChunks(download.ID)
+					lastErr = err
+					if i < len(urlsToTry)-1 {
+						fmt.Printf("Trying next mirror...\n")
+					}
+					continue
+				}
+				fmt.Printf("%sWarning: Checksum verification failed: %v\n", glyph("⚠️"), err)
 				fmt.Printf("   File may be corrupted. Consider re-downloading.\n")
 			} else {
-				fmt.Println("✓ Checksum verified")
+				fmt.Println(glyph("✓") + "Checksum verified")
+			}
+
+			// The file's own metadata usually beats the scraped guess
+			renameFromEmbedded(download, bookInfo)
+
+			// Success! Mark as completed
+			if err := db.MarkCompleted(download.ID, download.FilePath); err != nil {
+				return fmt.Errorf("failed to mark download complete: %w", err)
 			}
 
+			writeMetadataSidecar(download, bookInfo)
+
 			Successf("Downloaded: %s", download.FilePath)
+			if downloadCover {
+				if coverPath, err := saveCover(ctx, bookInfo, download.FilePath); err != nil {
+					Errorf("failed to save cover: %v", err)
+				} else {
+					Printf("Saved cover: %s\n", coverPath)
+				}
+			}
 			notify.DownloadComplete(download.Title)
 			return nil
 		}
@@ -232,6 +563,19 @@ func runDownloadByHash(ctx context.Context, md5Hash string, outputDir string, bo
 			continue
 		}
 
+		if errors.Is(err, downloader.ErrFileTooSmall) {
+			fmt.Printf("Skipping mirror: %v\n", err)
+			lastErr = err
+			continue
+		}
+
+		// Another mirror won't make the file fit
+		if errors.Is(err, downloader.ErrInsufficientSpace) {
+			db.MarkFailed(download.ID, err.Error())
+			notify.DownloadFailed(download.Title, err.Error())
+			return err
+		}
+
 		// For other errors, also try next mirror
 		lastErr = err
 		if i < len(urlsToTry)-1 {
@@ -239,11 +583,193 @@ func runDownloadByHash(ctx context.Context, md5Hash string, outputDir string, bo
 		}
 	}
 
-	db.UpdateStatus(download.ID, db.StatusFailed, lastErr.Error())
+	// Ctrl-C while a link was being resolved; nothing was lost
+	if downloader.Interrupted(ctx) {
+		db.UpdateStatus(download.ID, db.StatusPaused, "")
+		fmt.Printf("Paused. Use 'bookdl resume %d' to continue.\n", download.ID)
+		return nil
+	}
+
+	db.MarkFailed(download.ID, lastErr.Error())
 	notify.DownloadFailed(download.Title, lastErr.Error())
 	return fmt.Errorf("download failed after trying all mirrors: %w", lastErr)
 }
 
+// promptMirror shows the mirror selector and returns urls with the chosen
+// one first, or unchanged if the user cancels
+func promptMirror(urls []string) []string {
+	options := make([]tui.MirrorOption, len(urls))
+	for i, u := range urls {
+		options[i] = tui.MirrorOption{
+			URL:   u,
+			Host:  anna.MirrorHost(u),
+			Label: string(anna.ClassifyMirror(u)),
+		}
+	}
+
+	choice, err := tui.RunMirrorSelector(options)
+	if err != nil {
+		Errorf("mirror selection failed: %v", err)
+		return urls
+	}
+	if choice < 0 {
+		return urls
+	}
+	return moveToFront(urls, urls[choice])
+}
+
+// ipfsAlternates is how many other gateways an IPFS link is tried on
+const ipfsAlternates = 3
+
+// expandIPFSGateways follows each IPFS link with the same file on other
+// gateways, so a failing gateway is retried elsewhere before moving on to
+// a different mirror
+func expandIPFSGateways(urls []string) []string {
+	expanded := urls
+	for i := len(urls) - 1; i >= 0; i-- {
+		if alternatives := newURLs(expanded, anna.IPFSAlternatives(urls[i], ipfsAlternates)); len(alternatives) > 0 {
+			expanded = insertAfter(expanded, i, alternatives)
+		}
+	}
+	return expanded
+}
+
+// newURLs returns the candidates not already in urls
+func newURLs(urls, candidates []string) []string {
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		seen[u] = true
+	}
+	var fresh []string
+	for _, c := range candidates {
+		if !seen[c] {
+			seen[c] = true
+			fresh = append(fresh, c)
+		}
+	}
+	return fresh
+}
+
+// dedupeURLs drops links that lead to the same place as an earlier one,
+// keeping the order. Links are compared ignoring http vs https, the case
+// of the host, a default port and a trailing slash; of two such links the
+// https one is kept, in the place of the first.
+func dedupeURLs(urls []string) []string {
+	index := make(map[string]int, len(urls))
+	var out []string
+	for _, raw := range urls {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		key := canonicalURL(raw)
+		if i, ok := index[key]; ok {
+			if strings.HasPrefix(out[i], "http://") && strings.HasPrefix(raw, "https://") {
+				out[i] = raw
+			}
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, raw)
+	}
+	return out
+}
+
+// canonicalURL is the form dedupeURLs compares links in, or raw itself if
+// it doesn't parse as an http(s) URL
+func canonicalURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return raw
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+	key := "//" + host + path
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
+
+// insertAfter returns urls with extra inserted after index i
+func insertAfter(urls []string, i int, extra []string) []string {
+	out := make([]string, 0, len(urls)+len(extra))
+	out = append(out, urls[:i+1]...)
+	out = append(out, extra...)
+	return append(out, urls[i+1:]...)
+}
+
+// moveToFront returns urls with first moved to the front
+func moveToFront(urls []string, first string) []string {
+	ordered := []string{first}
+	for _, u := range urls {
+		if u != first {
+			ordered = append(ordered, u)
+		}
+	}
+	return ordered
+}
+
+// runDownloadVolumes downloads a book and its other volumes into a shared
+// subdirectory named after the title. A failed volume doesn't stop the rest.
+func runDownloadVolumes(ctx context.Context, md5Hash string, outputDir string, bookInfo *anna.Book, volumes []anna.Volume) error {
+	dirName := sanitizePathComponent(getTitle(bookInfo, md5Hash))
+	if dirName == "" {
+		dirName = md5Hash
+	}
+	volumeDir := filepath.Join(outputDir, dirName)
+	volumeCtx := context.WithValue(ctx, volumeKey{}, true)
+
+	fmt.Printf("Downloading %d volumes into %s\n\n", len(volumes)+1, volumeDir)
+
+	var failed []string
+	if err := runDownloadByHash(volumeCtx, md5Hash, volumeDir, bookInfo); err != nil {
+		Errorf("%s: %v", getTitle(bookInfo, md5Hash), err)
+		failed = append(failed, md5Hash)
+	}
+
+	for _, vol := range volumes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		volInfo := &anna.Book{
+			MD5Hash: vol.MD5Hash,
+			Title:   vol.Title,
+			Format:  getFormat(bookInfo),
+		}
+		fmt.Println()
+		if err := runDownloadByHash(volumeCtx, vol.MD5Hash, volumeDir, volInfo); err != nil {
+			Errorf("%s: %v", vol.Title, err)
+			failed = append(failed, vol.MD5Hash)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d volumes failed", len(failed), len(volumes)+1)
+	}
+	return nil
+}
+
+// downloadInfoHint suggests what to do about a missing-links error
+func downloadInfoHint(err error) string {
+	switch {
+	case errors.Is(err, anna.ErrMembershipRequired):
+		return "\n  Set a member key with 'bookdl config set anna.api_key <key>' to use fast downloads."
+	case errors.Is(err, anna.ErrCloudflareBlocked) && !anna.BrowserEnabled():
+		return "\n  The page was blocked. Wait a while and try again, or drop --no-browser (browser.enabled) to use the browser fallback."
+	case errors.Is(err, anna.ErrCloudflareBlocked):
+		return "\n  The page was blocked. Wait a while and try again, or install Chrome/Chromium for the browser fallback."
+	case errors.Is(err, anna.ErrFileRemoved):
+		return "\n  The file is no longer available. Search for another edition."
+	case errors.Is(err, anna.ErrNoPublicMirrors):
+		return "\n  Try again later, or search for another edition."
+	}
+	return ""
+}
+
 // sanitizeFilename removes invalid characters from filename
 func sanitizeFilename(name string) string {
 	// Remove or replace invalid characters
@@ -253,14 +779,37 @@ func sanitizeFilename(name string) string {
 	}
 
 	// Trim whitespace and limit length
-	name = strings.TrimSpace(name)
-	if len(name) > 100 {
-		name = name[:100]
+	name = truncateFilename(strings.TrimSpace(name), maxFilenameLen)
+
+	// Reject "." and ".." style names that would refer to a directory
+	if isDotsOnly(name) {
+		return ""
 	}
 
 	return name
 }
 
+// maxFilenameLen is the longest name sanitizeFilename returns, in bytes
+const maxFilenameLen = 100
+
+// truncateFilename shortens name to at most max bytes, cutting the stem on
+// a rune boundary and keeping a short extension
+func truncateFilename(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > 10 || strings.ContainsAny(ext, " ") {
+		ext = ""
+	}
+	stem := name[:len(name)-len(ext)]
+	limit := max - len(ext)
+	for limit > 0 && !utf8.RuneStart(stem[limit]) {
+		limit--
+	}
+	return strings.TrimSpace(stem[:limit]) + ext
+}
+
 func getTitle(book *anna.Book, fallback string) string {
 	if book != nil && book.Title != "" {
 		return book.Title
@@ -275,6 +824,20 @@ func getAuthors(book *anna.Book) string {
 	return ""
 }
 
+func getPublisher(book *anna.Book) string {
+	if book != nil {
+		return book.Publisher
+	}
+	return ""
+}
+
+func getLanguage(book *anna.Book) string {
+	if book != nil {
+		return book.Language
+	}
+	return ""
+}
+
 func getFormat(book *anna.Book) string {
 	if book != nil && book.Format != "" {
 		return book.Format
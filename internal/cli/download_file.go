@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+)
+
+// fromFile is a file of MD5 hashes to queue and download concurrently
+var fromFile string
+
+// runDownloadFromFile queues every hash in path and downloads them
+// concurrently, up to downloads.max_concurrent at a time
+func runDownloadFromFile(ctx context.Context, path, outputDir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open hash file: %w", err)
+	}
+	hashes, skipped, err := readHashes(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read hashes: %w", err)
+	}
+	if len(hashes) == 0 {
+		return fmt.Errorf("no valid MD5 hashes in %s", path)
+	}
+
+	if outputDir == "" {
+		outputDir = config.Get().Downloads.Path
+	}
+
+	client := anna.NewClient()
+	results := make(map[string]string, len(hashes))
+	var downloads []*db.Download
+	for i, hash := range hashes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Printf("[%d/%d] Queueing %s\n", i+1, len(hashes), hash)
+		download, note, err := queueHash(ctx, client, hash, outputDir)
+		switch {
+		case err != nil:
+			Errorf("%s: %v", hash, err)
+			results[hash] = "failed: " + err.Error()
+		case download == nil:
+			results[hash] = note
+		default:
+			downloads = append(downloads, download)
+		}
+	}
+	fmt.Println()
+
+	if len(downloads) > 0 {
+		if err := runBatch(ctx, downloads, "", "Downloading"); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	// Report how each hash ended up, in file order
+	fmt.Println("Results:")
+	failed := 0
+	for _, hash := range hashes {
+		result, ok := results[hash]
+		if !ok {
+			result = batchResult(hash)
+		}
+		if strings.HasPrefix(result, "failed") {
+			failed++
+		}
+		fmt.Printf("  %s  %s\n", hash, result)
+	}
+	if skipped > 0 {
+		fmt.Printf("%d invalid line(s) skipped\n", skipped)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d download(s) failed", failed)
+	}
+	return nil
+}
+
+// queueHash makes sure hash has a download record that's ready to start,
+// adding it to the queue if needed. It returns a nil download with a note
+// when there is nothing to do.
+func queueHash(ctx context.Context, client anna.Client, hash, outputDir string) (*db.Download, string, error) {
+	existing, _ := db.GetDownloadByHash(hash)
+	if existing != nil {
+		switch existing.Status {
+		case db.StatusCompleted:
+			return nil, "already downloaded", nil
+		case db.StatusDownloading:
+			return nil, fmt.Sprintf("already downloading (ID: %d)", existing.ID), nil
+		}
+	}
+
+	var book *anna.Book
+	if existing == nil {
+		book = lookupBook(ctx, client, hash)
+		if err := addToQueue(book); err != nil {
+			return nil, "", err
+		}
+		var err error
+		if existing, err = db.GetDownloadByHash(hash); err != nil {
+			return nil, "", fmt.Errorf("failed to load queued download: %w", err)
+		}
+	}
+
+	// Queued downloads have no link or destination yet
+	if existing.DownloadURL == "" || existing.FilePath == "" {
+		if book == nil {
+			book = lookupBook(ctx, client, hash)
+		}
+		if err := prepareQueued(ctx, client, existing, book, outputDir); err != nil {
+			db.MarkFailed(existing.ID, err.Error())
+			return nil, "", err
+		}
+	}
+	return existing, "", nil
+}
+
+// prepareQueued fills in the download link and file paths of a queued
+// download so the manager can start it
+func prepareQueued(ctx context.Context, client anna.Client, download *db.Download, book *anna.Book, outputDir string) error {
+	searchCtx, cancel := withTimeout(ctx, searchTimeout())
+	defer cancel()
+
+	url, err := freshDownloadURL(searchCtx, client, download.MD5Hash, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get download link: %w%s", err, downloadInfoHint(err))
+	}
+
+	filename := sanitizeFilename(download.Title)
+	if filename == "" || download.Title == download.MD5Hash {
+		filename = download.MD5Hash
+	}
+	ext := strings.ToLower(download.Format)
+	if ext == "" {
+		ext = "epub"
+	}
+	filePath := OrganizedPath(outputDir, book, filename+"."+ext)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tempPath := downloader.TempPath(filePath)
+
+	if err := db.UpdateDownloadURL(download.ID, url); err != nil {
+		return fmt.Errorf("failed to save download link: %w", err)
+	}
+	if err := db.UpdatePaths(download.ID, filePath, tempPath, download.Format); err != nil {
+		return fmt.Errorf("failed to save paths: %w", err)
+	}
+	download.DownloadURL = url
+	download.FilePath = filePath
+	download.TempPath = tempPath
+	return nil
+}
+
+// batchResult describes how a batch download of hash ended
+func batchResult(hash string) string {
+	download, err := db.GetDownloadByHash(hash)
+	if err != nil || download == nil {
+		return "failed: no download record"
+	}
+	switch download.Status {
+	case db.StatusCompleted:
+		return "downloaded: " + download.FilePath
+	case db.StatusFailed:
+		return "failed: " + download.ErrorMessage
+	default:
+		return string(download.Status)
+	}
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+)
+
+// downloadJSON is a download as printed by list --json and queue --json
+type downloadJSON struct {
+	ID              int64    `json:"id"`
+	MD5             string   `json:"md5"`
+	Title           string   `json:"title"`
+	Authors         string   `json:"authors,omitempty"`
+	Format          string   `json:"format,omitempty"`
+	Status          string   `json:"status"`
+	Size            int64    `json:"size"`
+	SizeHuman       string   `json:"size_human,omitempty"`
+	Downloaded      int64    `json:"downloaded"`
+	DownloadedHuman string   `json:"downloaded_human"`
+	ProgressPercent float64  `json:"progress_percent"`
+	Speed           float64  `json:"speed,omitempty"`     // bytes per second, while downloading
+	AvgSpeed        float64  `json:"avg_speed,omitempty"` // bytes per second, once completed
+	Priority        int      `json:"priority"`
+	RetryCount      int      `json:"retry_count"`
+	Verified        bool     `json:"verified"`
+	Archived        bool     `json:"archived,omitempty"`
+	FilePath        string   `json:"file_path,omitempty"`
+	Error           string   `json:"error,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	CreatedAt       string   `json:"created_at"`
+	UpdatedAt       string   `json:"updated_at"`
+	CompletedAt     string   `json:"completed_at,omitempty"`
+	ScheduledAt     string   `json:"scheduled_at,omitempty"`
+}
+
+// toDownloadJSON converts a download for JSON output
+func toDownloadJSON(d *db.Download) downloadJSON {
+	out := downloadJSON{
+		ID:              d.ID,
+		MD5:             d.MD5Hash,
+		Title:           d.Title,
+		Authors:         d.Authors,
+		Format:          d.Format,
+		Status:          string(d.Status),
+		Size:            d.FileSize,
+		Downloaded:      d.DownloadedSize,
+		DownloadedHuman: downloader.FormatBytes(d.DownloadedSize),
+		AvgSpeed:        d.AvgSpeed,
+		Priority:        d.Priority,
+		RetryCount:      d.RetryCount,
+		Verified:        d.Verified,
+		Archived:        d.Archived,
+		FilePath:        d.FilePath,
+		Error:           d.ErrorMessage,
+		CreatedAt:       jsonTime(&d.CreatedAt),
+		UpdatedAt:       jsonTime(&d.UpdatedAt),
+		CompletedAt:     jsonTime(d.CompletedAt),
+		ScheduledAt:     jsonTime(d.ScheduledAt),
+	}
+	if d.FileSize > 0 {
+		out.SizeHuman = downloader.FormatBytes(d.FileSize)
+		percent := float64(d.DownloadedSize) / float64(d.FileSize) * 100
+		if percent > 100 {
+			percent = 100
+		}
+		// Round to one decimal, as the table shows
+		out.ProgressPercent = float64(int64(percent*10+0.5)) / 10
+	} else if d.Status == db.StatusCompleted {
+		out.ProgressPercent = 100
+	}
+	if d.Status == db.StatusDownloading {
+		out.Speed = d.Speed
+	}
+	if tags, err := db.ListTags(d.MD5Hash); err == nil {
+		out.Tags = tags
+	}
+	return out
+}
+
+// jsonTime formats t as RFC3339, or "" if unset
+func jsonTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// printDownloadsJSON writes downloads to stdout as a JSON array, which is
+// empty rather than null when there are none
+func printDownloadsJSON(downloads []*db.Download) error {
+	out := make([]downloadJSON, 0, len(downloads))
+	for _, d := range downloads {
+		out = append(out, toDownloadJSON(d))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
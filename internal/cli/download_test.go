@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Book Title.epub", "Book Title.epub"},
+		{"separators", "../../etc/passwd", ".._.._etc_passwd"},
+		{"windows separators", `..\..\boot.ini`, ".._.._boot.ini"},
+		{"reserved characters", `a:b*c?"d"<e>|f.pdf`, "a_b_c__d__e__f.pdf"},
+		{"dots only", "..", ""},
+		{"dots and spaces", " . ", ""},
+		{"whitespace", "  spaced  ", "spaced"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFilename(tt.in); got != tt.want {
+				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameTruncates(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantExt string
+	}{
+		{"ascii", strings.Repeat("a", 300) + ".epub", ".epub"},
+		{"multi-byte", strings.Repeat("日本語", 60) + ".pdf", ".pdf"},
+		{"emoji", strings.Repeat("📚", 50) + ".mobi", ".mobi"},
+		{"no extension", strings.Repeat("ü", 120), ""},
+		{"long extension dropped", strings.Repeat("a", 120) + "." + strings.Repeat("b", 20), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeFilename(tt.in)
+			if len(got) > maxFilenameLen {
+				t.Errorf("len = %d, want at most %d", len(got), maxFilenameLen)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("%q is not valid UTF-8", got)
+			}
+			if tt.wantExt != "" && !strings.HasSuffix(got, tt.wantExt) {
+				t.Errorf("%q lost its extension %s", got, tt.wantExt)
+			}
+			if got == "" {
+				t.Error("name truncated to nothing")
+			}
+		})
+	}
+}
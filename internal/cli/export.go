@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export your library to CSV or JSON",
+	Long: `Export downloads to CSV or JSON.
+
+Completed downloads are exported by default; use --status to export
+others (e.g. pending for the queue, or all). Output goes to stdout unless
+--output is given.
+
+Examples:
+  bookdl export > library.csv
+  bookdl export --format json --output library.json
+  bookdl export --status pending`,
+	Args: cobra.NoArgs,
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().String("format", "csv", "output format (csv, json)")
+	exportCmd.Flags().StringP("output", "o", "", "write to this file instead of stdout")
+	exportCmd.Flags().StringP("status", "s", string(db.StatusCompleted), "export downloads with this status (pending, downloading, paused, completed, failed, all)")
+}
+
+// exportRecord is one exported download
+type exportRecord struct {
+	MD5         string     `json:"md5"`
+	Title       string     `json:"title"`
+	Authors     string     `json:"authors"`
+	Format      string     `json:"format"`
+	Size        int64      `json:"size"`
+	FilePath    string     `json:"file_path"`
+	Status      string     `json:"status"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+var exportColumns = []string{"md5", "title", "authors", "format", "size", "file_path", "status", "completed_at"}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	status, _ := cmd.Flags().GetString("status")
+
+	format = strings.ToLower(format)
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("invalid format %q (use csv or json)", format)
+	}
+
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status != "all" && !db.DownloadStatus(status).Valid() {
+		return fmt.Errorf("invalid status %q (use pending, downloading, paused, completed, failed or all)", status)
+	}
+
+	var downloads []*db.Download
+	var err error
+	if status == "all" {
+		downloads, err = db.ListDownloads("", true)
+	} else {
+		downloads, err = db.ListDownloads(db.DownloadStatus(status), false)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list downloads: %w", err)
+	}
+
+	records := make([]exportRecord, 0, len(downloads))
+	for _, d := range downloads {
+		records = append(records, exportRecord{
+			MD5:         d.MD5Hash,
+			Title:       d.Title,
+			Authors:     d.Authors,
+			Format:      d.Format,
+			Size:        d.FileSize,
+			FilePath:    d.FilePath,
+			Status:      string(d.Status),
+			CompletedAt: d.CompletedAt,
+		})
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", output, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(records)
+	} else {
+		err = writeExportCSV(w, records)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	if output != "" {
+		Successf("Exported %d download(s) to %s", len(records), output)
+	}
+	return nil
+}
+
+func writeExportCSV(w io.Writer, records []exportRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return err
+	}
+	for _, r := range records {
+		completedAt := ""
+		if r.CompletedAt != nil {
+			completedAt = r.CompletedAt.Format(time.RFC3339)
+		}
+		row := []string{r.MD5, r.Title, r.Authors, r.Format, strconv.FormatInt(r.Size, 10), r.FilePath, r.Status, completedAt}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
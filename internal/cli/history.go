@@ -15,6 +15,8 @@ var historyCmd = &cobra.Command{
 
 Examples:
   bookdl history              List recent searches
+  bookdl history select       Pick a past search and run it again
+  bookdl history search rust  Find past searches mentioning "rust"
   bookdl history clear        Clear all search history`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return showSearchHistory()
@@ -42,11 +44,60 @@ var historyListCmd = &cobra.Command{
 	},
 }
 
+var historySelectCmd = &cobra.Command{
+	Use:   "select",
+	Short: "Pick a past search and run it again",
+	Long: `Pick one of your recent searches and run it again with the filters it
+used. Flags such as --download, --queue and --sort apply to the new run.
+
+Examples:
+  bookdl history select
+  bookdl history select -d      Download the book you pick from the results
+  bookdl history select -n 20   Show more results`,
+	Args: cobra.NoArgs,
+	RunE: showSearchHistoryInteractive,
+}
+
+var historySearchCmd = &cobra.Command{
+	Use:   "search [term]",
+	Short: "Find past searches containing a term",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		term := strings.Join(args, " ")
+		history, err := db.SearchHistoryByQuery(term)
+		if err != nil {
+			return fmt.Errorf("failed to search history: %w", err)
+		}
+
+		if len(history) == 0 {
+			fmt.Printf("No past searches match %q.\n", term)
+			return nil
+		}
+
+		fmt.Printf("Searches matching %q (%d):\n\n", term, len(history))
+		printHistory(history)
+		return nil
+	},
+}
+
 func init() {
 	historyListCmd.Flags().IntP("limit", "n", 20, "number of entries to show")
 
+	// The same result options as search, for the re-run
+	historySelectCmd.Flags().IntP("limit", "n", 5, "number of results to show")
+	historySelectCmd.Flags().BoolP("download", "d", false, "immediately download selected book")
+	historySelectCmd.Flags().BoolP("queue", "q", false, "multi-select mode: add multiple books to download queue")
+	historySelectCmd.Flags().Bool("prefer", false, "show each title once, in the best format from files.preferred_formats")
+	historySelectCmd.Flags().Bool("dedupe", false, "merge near-identical titles by the same author into one result")
+	historySelectCmd.Flags().Bool("exact", false, "match the query as a phrase; titles must contain it")
+	historySelectCmd.Flags().Bool("new", false, "hide books already downloaded, queued or bookmarked")
+	historySelectCmd.Flags().String("sort", "", "sort results by size, year, title or format")
+	historySelectCmd.Flags().Bool("sort-desc", false, "sort in descending order")
+
 	historyCmd.AddCommand(historyClearCmd)
 	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historySelectCmd)
+	historyCmd.AddCommand(historySearchCmd)
 }
 
 // showSearchHistoryWithLimit shows history with a custom limit
@@ -63,7 +114,12 @@ func showSearchHistoryWithLimit(limit int) error {
 	}
 
 	fmt.Printf("Recent Searches (%d):\n\n", len(history))
+	printHistory(history)
+	return nil
+}
 
+// printHistory prints numbered history entries with their filters and date
+func printHistory(history []*db.SearchHistory) {
 	for i, h := range history {
 		fmt.Printf("  %d. \"%s\" (%d results)\n", i+1, h.Query, h.ResultCount)
 
@@ -80,12 +136,19 @@ func showSearchHistoryWithLimit(limit int) error {
 		if h.Filters.MaxSize != "" {
 			filterParts = append(filterParts, "max-size="+h.Filters.MaxSize)
 		}
+		if h.Filters.Author != "" {
+			filterParts = append(filterParts, "author="+h.Filters.Author)
+		}
+		if h.Filters.Publisher != "" {
+			filterParts = append(filterParts, "publisher="+h.Filters.Publisher)
+		}
+		if h.Filters.Exact {
+			filterParts = append(filterParts, "exact")
+		}
 		if len(filterParts) > 0 {
 			fmt.Printf("     Filters: %s\n", strings.Join(filterParts, ", "))
 		}
 
 		fmt.Printf("     %s\n\n", h.CreatedAt.Format("2006-01-02 15:04"))
 	}
-
-	return nil
 }
@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/meta"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <dir>",
+	Short: "Add an existing folder of books to the library",
+	Long: `Walk a directory and record every book file in it as a completed
+download, so list, verify, stats, export and serve cover books you already
+had. Files stay where they are.
+
+Each file is identified by its MD5, as on Anna's Archive. Title and
+authors come from the metadata embedded in EPUBs and PDFs, or else from the
+file name ("Author - Title (Year).epub", as bookdl names downloads).
+Files already in the library, by path or by MD5, are skipped.
+
+Examples:
+  bookdl import ~/Books
+  bookdl import ~/Books --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+func init() {
+	importCmd.Flags().Bool("dry-run", false, "show what would be imported")
+}
+
+// importExtensions are the book formats import picks up
+var importExtensions = map[string]bool{
+	".epub": true, ".pdf": true, ".mobi": true, ".azw": true, ".azw3": true,
+	".djvu": true, ".fb2": true, ".cbr": true, ".cbz": true,
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(dir); err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	knownPaths, knownHashes, err := libraryIndex()
+	if err != nil {
+		return err
+	}
+
+	verb := "Imported"
+	if dryRun {
+		verb = "Would import"
+	}
+	imported, skipped := 0, 0
+
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			Errorf("skipping %s: %v", path, err)
+			return nil
+		}
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !importExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		if knownPaths[filepath.Clean(path)] {
+			skipped++
+			return nil
+		}
+
+		hash, size, err := fileMD5(path)
+		if err != nil {
+			Errorf("failed to read %s: %v", path, err)
+			return nil
+		}
+		if knownHashes[hash] {
+			Printf("Already in library: %s\n", path)
+			skipped++
+			return nil
+		}
+
+		download := importedDownload(path, hash, size)
+		if !dryRun {
+			if err := db.ImportDownload(download); err != nil {
+				Errorf("failed to import %s: %v", path, err)
+				return nil
+			}
+		}
+		knownHashes[hash] = true
+		fmt.Printf("%s: %s (%s)\n", verb, download.Title, path)
+		imported++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	if skipped > 0 {
+		fmt.Printf("Skipped %d file(s) already in the library.\n", skipped)
+	}
+	if imported == 0 {
+		fmt.Println("No new books found.")
+	} else if !dryRun {
+		Successf("Imported %d book(s).", imported)
+	}
+	return nil
+}
+
+// libraryIndex returns the file paths and MD5s of every download, archived
+// or not
+func libraryIndex() (paths, hashes map[string]bool, err error) {
+	downloads, err := db.ListDownloads("", true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list downloads: %w", err)
+	}
+	archived, err := db.ListArchivedDownloads()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list archived downloads: %w", err)
+	}
+
+	paths = make(map[string]bool)
+	hashes = make(map[string]bool)
+	for _, d := range append(downloads, archived...) {
+		if d.FilePath != "" {
+			paths[filepath.Clean(d.FilePath)] = true
+		}
+		hashes[strings.ToLower(d.MD5Hash)] = true
+	}
+	return paths, hashes, nil
+}
+
+// fileMD5 returns the hex MD5 and size of the file at path
+func fileMD5(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// importedDownload builds the download record for a book file, preferring
+// its embedded metadata over what the file name suggests
+func importedDownload(path, hash string, size int64) *db.Download {
+	ext := filepath.Ext(path)
+	title, authors := parseBookFilename(strings.TrimSuffix(filepath.Base(path), ext))
+	if title == "" {
+		title = filepath.Base(path)
+	}
+
+	download := &db.Download{
+		MD5Hash:   hash,
+		Title:     title,
+		Authors:   authors,
+		Format:    strings.ToLower(strings.TrimPrefix(ext, ".")),
+		FileSize:  size,
+		SourceURL: fmt.Sprintf("https://%s/md5/%s", anna.GetBaseURL(), hash),
+		FilePath:  path,
+	}
+
+	if embedded, err := meta.Extract(path); err == nil {
+		if embedded.Title != "" {
+			download.Title = embedded.Title
+		}
+		if embedded.Authors != "" {
+			download.Authors = embedded.Authors
+		}
+		download.Publisher = embedded.Publisher
+		download.Language = anna.NormalizeLanguage(embedded.Language)
+	}
+	return download
+}
+
+// trailingYear matches the " (2019)" bookdl appends to file names
+var trailingYear = regexp.MustCompile(`\s*\(\d{4}\)$`)
+
+// parseBookFilename guesses a book's title and authors from a file name
+// without its extension, in the "Author - Title (Year)" form that
+// buildFilename produces. A name without " - " is all title.
+func parseBookFilename(stem string) (title, authors string) {
+	stem = strings.TrimSpace(strings.ReplaceAll(stem, "_", " "))
+	stem = trailingYear.ReplaceAllString(stem, "")
+
+	if author, rest, ok := strings.Cut(stem, " - "); ok && strings.TrimSpace(rest) != "" {
+		return strings.TrimSpace(rest), strings.TrimSpace(author)
+	}
+	return stem, ""
+}
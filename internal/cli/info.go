@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info <md5>",
+	Short: "Show book details without downloading",
+	Long: `Show a book's metadata and download links without downloading it.
+
+The book is given by its MD5 hash or its Anna's Archive link. Also shows
+whether the book is already downloaded, queued or bookmarked.
+
+Examples:
+  bookdl info abc123def456...
+  bookdl info https://annas-archive.se/md5/abc123def456...
+  bookdl info abc123def456... --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInfo,
+}
+
+func init() {
+	infoCmd.Flags().Bool("json", false, "output book and download info as JSON")
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	md5Hash, err := anna.ExtractMD5(args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	client := anna.NewClient()
+
+	Printf("Fetching book information...\n")
+	book := lookupBook(ctx, client, md5Hash)
+	if book.Title == md5Hash {
+		// Not found by search; don't show lookupBook's guessed format
+		book.Format = ""
+	}
+
+	Printf("Getting download links...\n")
+	dlInfo, infoErr := client.GetDownloadInfo(ctx, md5Hash)
+
+	if asJSON {
+		out := struct {
+			Book         *anna.Book         `json:"book"`
+			DownloadInfo *anna.DownloadInfo `json:"download_info"`
+			Error        string             `json:"error,omitempty"`
+		}{Book: book, DownloadInfo: dlInfo}
+		if infoErr != nil {
+			out.Error = infoErr.Error()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	printField := func(label, value string) {
+		if value != "" {
+			fmt.Printf("  %-11s %s\n", label+":", value)
+		}
+	}
+
+	fmt.Println(book.Title)
+	fmt.Println()
+	printField("Authors", book.Authors)
+	printField("Publisher", book.Publisher)
+	printField("Year", book.Year)
+	printField("Language", book.Language)
+	printField("Format", strings.ToUpper(book.Format))
+	size := book.Size
+	if dlInfo != nil && dlInfo.FileSize > 0 {
+		size = downloader.FormatBytes(dlInfo.FileSize)
+	}
+	printField("Size", size)
+	printField("MD5", md5Hash)
+	if dlInfo != nil {
+		printField("SHA-1", dlInfo.SHA1)
+		printField("SHA-256", dlInfo.SHA256)
+	}
+	printField("Page", book.PageURL)
+	printField("Cover", book.CoverURL)
+
+	fmt.Println()
+	fmt.Printf("  %-11s %s\n", "Status:", libraryStatus(md5Hash))
+	printField("Tags", formatTags(md5Hash))
+
+	fmt.Println()
+	if infoErr != nil {
+		fmt.Printf("Download links unavailable: %v%s\n", infoErr, downloadInfoHint(infoErr))
+		return nil
+	}
+
+	if len(dlInfo.MirrorURLs) == 0 && dlInfo.DirectURL != "" {
+		dlInfo.MirrorURLs = []string{dlInfo.DirectURL}
+	}
+	fmt.Printf("Mirrors (%d):\n", len(dlInfo.MirrorURLs))
+	for i, u := range dlInfo.MirrorURLs {
+		fmt.Printf("  %d. %s\n", i+1, u)
+	}
+	if dlInfo.TorrentURL != "" {
+		fmt.Printf("Torrent: %s\n", dlInfo.TorrentURL)
+	}
+	if len(dlInfo.Volumes) > 0 {
+		fmt.Printf("Other volumes (%d):\n", len(dlInfo.Volumes))
+		for _, v := range dlInfo.Volumes {
+			fmt.Printf("  %s  %s\n", v.MD5Hash, v.Title)
+		}
+	}
+
+	return nil
+}
+
+// libraryStatus describes what bookdl already knows about a book
+func libraryStatus(md5Hash string) string {
+	var status []string
+	if d, err := db.GetDownloadByHash(md5Hash); err == nil && d != nil {
+		switch d.Status {
+		case db.StatusCompleted:
+			status = append(status, fmt.Sprintf("downloaded (#%d, %s)", d.ID, d.FilePath))
+		case db.StatusPending:
+			status = append(status, fmt.Sprintf("queued (#%d)", d.ID))
+		default:
+			status = append(status, fmt.Sprintf("%s (#%d)", d.Status, d.ID))
+		}
+	}
+	if db.BookmarkExists(md5Hash) {
+		status = append(status, "bookmarked")
+	}
+	if len(status) == 0 {
+		return "not in library"
+	}
+	return strings.Join(status, ", ")
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// noInput disables every prompt and TUI, for scripts, cron and containers
+var noInput bool
+
+// interactive reports whether prompts and the TUI may be used: --no-input
+// is not set and both stdin and stdout are terminals
+func interactive() bool {
+	if noInput {
+		return false
+	}
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// confirm asks a yes/no question on the terminal; anything but y or yes,
+// including no terminal to ask on, is a no
+func confirm(question string) bool {
+	if !interactive() {
+		return false
+	}
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
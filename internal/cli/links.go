@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+	"github.com/billmal071/bookdl/internal/log"
+)
+
+// linkExpired reports whether a download failed because its stored link no
+// longer leads to the file: mirrors answer expired links with an HTML page
+// or a 403/404
+func linkExpired(err error) bool {
+	return errors.Is(err, downloader.ErrHTMLContent) || errors.Is(err, downloader.ErrLinkExpired)
+}
+
+// needsNewLink reports whether a download failed in a way another link
+// might fix: the link expired, or its host is down
+func needsNewLink(err error) bool {
+	return linkExpired(err) || errors.Is(err, downloader.ErrHostDown)
+}
+
+// refreshDownloadURL replaces a download's link with a freshly scraped one
+// on a host mgr isn't skipping, reporting what it does to out. cause is
+// the error that made the old link useless.
+func refreshDownloadURL(ctx context.Context, mgr *downloader.Manager, download *db.Download, cause error, out io.Writer) error {
+	if errors.Is(cause, downloader.ErrHostDown) {
+		fmt.Fprintf(out, "Mirror for %s is down, looking for another one...\n", download.Title)
+	} else {
+		fmt.Fprintf(out, "Download link for %s has expired, fetching a new one...\n", download.Title)
+	}
+	url, err := freshDownloadURL(ctx, anna.NewClient(), download.MD5Hash, mgr.HostDown)
+	if err != nil {
+		fmt.Fprintf(out, "Could not refresh the link for #%d: %v\n", download.ID, err)
+		return err
+	}
+	if err := db.UpdateDownloadURL(download.ID, url); err != nil {
+		return err
+	}
+	download.DownloadURL = url
+	return nil
+}
+
+// resolveDownloadLink turns a slow_download, fast_download or LibGen page
+// into the file's URL. LibGen pages, and fast_download pages with
+// anna.member_cookie set, are fetched directly; everything else waits out
+// the countdown in the browser.
+func resolveDownloadLink(ctx context.Context, pageURL string) (string, error) {
+	if anna.IsLibgenPage(pageURL) {
+		return anna.ResolveLibgenURL(ctx, pageURL)
+	}
+	if strings.Contains(pageURL, "/fast_download/") && config.MemberCookieHeader() != "" {
+		resolved, err := anna.ResolveFastDownload(ctx, pageURL)
+		if err == nil {
+			return resolved, nil
+		}
+		log.Info("fast download link failed, trying the browser", "url", pageURL, "err", err)
+	}
+	if !anna.BrowserEnabled() {
+		kind := "slow_download"
+		if strings.Contains(pageURL, "/fast_download/") {
+			kind = "fast_download"
+		}
+		return "", fmt.Errorf("%w; cannot resolve %s link", anna.ErrBrowserDisabled, kind)
+	}
+	return anna.NewBrowserClient(anna.GetBaseURL()).ResolveDownloadURL(ctx, pageURL)
+}
+
+// freshDownloadURL scrapes the book page again for a current download link,
+// resolving slow_download pages through the browser. Stored links expire,
+// so this is used before starting a download over. Links avoid reports
+// true for are passed over, if avoid is set.
+func freshDownloadURL(ctx context.Context, client anna.Client, md5Hash string, avoid func(string) bool) (string, error) {
+	info, err := client.GetDownloadInfo(ctx, md5Hash)
+	if err != nil {
+		return "", err
+	}
+
+	urls := info.MirrorURLs
+	if info.DirectURL != "" {
+		urls = append([]string{info.DirectURL}, urls...)
+	}
+	urls = dedupeURLs(urls)
+	if len(urls) == 0 {
+		return "", anna.ErrNoPublicMirrors
+	}
+
+	usable := func(u string) bool {
+		return avoid == nil || !avoid(u)
+	}
+
+	lastErr := downloader.ErrHostDown
+	for _, u := range urls {
+		if !strings.Contains(u, "/slow_download/") && !strings.Contains(u, "/fast_download/") && !anna.IsLibgenPage(u) {
+			if usable(u) {
+				return u, nil
+			}
+			continue
+		}
+		resolved, err := resolveDownloadLink(ctx, u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if usable(resolved) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("failed to resolve download link: %w", lastErr)
+}
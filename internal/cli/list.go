@@ -3,9 +3,12 @@ package cli
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/config"
 	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
 )
 
 var listCmd = &cobra.Command{
@@ -19,31 +22,65 @@ Examples:
   bookdl list                  List active downloads
   bookdl list -a               List all downloads
   bookdl list -s paused        List paused downloads
-  bookdl list -s failed        List failed downloads`,
+  bookdl list -s failed        List failed downloads
+  bookdl list --archived       List archived downloads
+  bookdl list --tag work       List downloads tagged "work"
+  bookdl list -a --json        Print all downloads as JSON`,
 	RunE: runList,
 }
 
 func init() {
 	listCmd.Flags().StringP("status", "s", "", "filter by status (pending, downloading, paused, completed, failed)")
 	listCmd.Flags().BoolP("all", "a", false, "show all downloads including completed")
+	listCmd.Flags().Bool("archived", false, "show archived downloads")
+	listCmd.Flags().StringP("tag", "t", "", "show downloads with this tag")
+	listCmd.Flags().Bool("json", false, "output downloads as JSON")
 }
 
 func runList(cmd *cobra.Command, args []string) error {
 	statusFilter, _ := cmd.Flags().GetString("status")
 	showAll, _ := cmd.Flags().GetBool("all")
+	showArchived, _ := cmd.Flags().GetBool("archived")
+	tag, _ := cmd.Flags().GetString("tag")
+	asJSON, _ := cmd.Flags().GetBool("json")
 
 	var status db.DownloadStatus
 	if statusFilter != "" {
 		status = db.DownloadStatus(strings.ToLower(statusFilter))
 	}
 
-	downloads, err := db.ListDownloads(status, showAll)
+	var downloads []*db.Download
+	var err error
+	if showArchived {
+		downloads, err = db.ListArchivedDownloads()
+	} else if tag != "" {
+		downloads, err = db.ListDownloadsByTag(tag)
+		if err == nil && status != "" {
+			var filtered []*db.Download
+			for _, d := range downloads {
+				if d.Status == status {
+					filtered = append(filtered, d)
+				}
+			}
+			downloads = filtered
+		}
+	} else {
+		downloads, err = db.ListUnarchivedDownloads(status, showAll)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to list downloads: %w", err)
 	}
 
+	if asJSON {
+		return printDownloadsJSON(downloads)
+	}
+
 	if len(downloads) == 0 {
-		if statusFilter != "" {
+		if showArchived {
+			fmt.Println("No archived downloads.")
+		} else if tag != "" {
+			fmt.Printf("No downloads tagged '%s'.\n", tag)
+		} else if statusFilter != "" {
 			fmt.Printf("No downloads with status '%s'.\n", statusFilter)
 		} else {
 			fmt.Println("No active downloads.")
@@ -65,17 +102,17 @@ func printDownload(d *db.Download) {
 	var statusIcon string
 	switch d.Status {
 	case db.StatusPending:
-		statusIcon = "⏳"
+		statusIcon = glyph("⏳")
 	case db.StatusDownloading:
-		statusIcon = "⬇️ "
+		statusIcon = glyph("⬇️")
 	case db.StatusPaused:
-		statusIcon = "⏸️ "
+		statusIcon = glyph("⏸️")
 	case db.StatusCompleted:
-		statusIcon = "✅"
+		statusIcon = glyph("✅")
 	case db.StatusFailed:
-		statusIcon = "❌"
+		statusIcon = glyph("❌")
 	default:
-		statusIcon = "  "
+		statusIcon = "   "
 	}
 
 	// Title (truncate if too long)
@@ -84,15 +121,27 @@ func printDownload(d *db.Download) {
 		title = title[:47] + "..."
 	}
 
-	fmt.Printf("%s [%d] %s\n", statusIcon, d.ID, title)
+	fmt.Printf("%s[%d] %s\n", statusIcon, d.ID, title)
 
 	// Progress
 	if d.FileSize > 0 {
 		progress := float64(d.DownloadedSize) / float64(d.FileSize) * 100
 		fmt.Printf("   Progress: %.1f%% (%s / %s)\n",
 			progress,
-			formatBytes(d.DownloadedSize),
-			formatBytes(d.FileSize))
+			downloader.FormatBytes(d.DownloadedSize),
+			downloader.FormatBytes(d.FileSize))
+	}
+
+	// Speed: the recent average while downloading, the overall one after
+	if d.Status == db.StatusDownloading && d.Speed > 0 {
+		fmt.Printf("   Speed: %s/s", downloader.FormatBytes(int64(d.Speed)))
+		if d.FileSize > d.DownloadedSize {
+			remaining := time.Duration(float64(d.FileSize-d.DownloadedSize) / d.Speed * float64(time.Second))
+			fmt.Printf(", ETA %s", formatETA(remaining))
+		}
+		fmt.Println()
+	} else if d.Status == db.StatusCompleted && d.AvgSpeed > 0 {
+		fmt.Printf("   Average speed: %s/s\n", downloader.FormatBytes(int64(d.AvgSpeed)))
 	}
 
 	// Status details
@@ -102,13 +151,25 @@ func printDownload(d *db.Download) {
 	}
 	if d.Status == db.StatusCompleted {
 		if d.Verified {
-			fmt.Printf(" (✓ verified)")
+			fmt.Printf(" (%sverified)", glyph("✓"))
 		} else {
-			fmt.Printf(" (⚠️  not verified)")
+			fmt.Printf(" (%snot verified)", glyph("⚠️"))
 		}
 	}
 	fmt.Println()
 
+	if d.RetryCount > 0 && d.Status != db.StatusCompleted {
+		if max := config.Get().Downloads.MaxDownloadRetries; max > 0 {
+			fmt.Printf("   Retries: %d/%d", d.RetryCount, max)
+			if retriesExhausted(d) {
+				fmt.Printf(" (gave up; 'bookdl restart --reset-retries %d' to retry)", d.ID)
+			}
+			fmt.Println()
+		} else {
+			fmt.Printf("   Retries: %d\n", d.RetryCount)
+		}
+	}
+
 	// File info
 	if d.FilePath != "" {
 		fmt.Printf("   File: %s\n", d.FilePath)
@@ -117,18 +178,22 @@ func printDownload(d *db.Download) {
 	// MD5
 	fmt.Printf("   MD5: %s\n", d.MD5Hash)
 
+	if tags := formatTags(d.MD5Hash); tags != "" {
+		fmt.Printf("   Tags: %s\n", tags)
+	}
+
 	fmt.Println()
 }
 
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+// formatETA formats a remaining time coarsely: "45s", "3m", "1h5m"
+func formatETA(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return d.Round(time.Second).String()
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Round(time.Minute).Minutes()))
+	default:
+		d = d.Round(time.Minute)
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
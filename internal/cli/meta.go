@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/meta"
+)
+
+var metaCmd = &cobra.Command{
+	Use:   "meta <file>",
+	Short: "Show the metadata embedded in an EPUB or PDF",
+	Long: `Show the title, authors and year a book file carries itself.
+
+With files.rename_files enabled, downloads are renamed using this embedded
+metadata when they finish, since it's usually more accurate than search
+results.
+
+Examples:
+  bookdl meta ~/Books/book.epub
+  bookdl meta paper.pdf --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMeta,
+}
+
+func init() {
+	metaCmd.Flags().Bool("json", false, "output metadata as JSON")
+}
+
+func runMeta(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	m, err := meta.Extract(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	}
+
+	if *m == (meta.Metadata{}) {
+		fmt.Println("No embedded metadata found.")
+		return nil
+	}
+
+	printField := func(label, value string) {
+		if value != "" {
+			fmt.Printf("  %-11s %s\n", label+":", value)
+		}
+	}
+	printField("Title", m.Title)
+	printField("Authors", m.Authors)
+	printField("Year", m.Year)
+	printField("Publisher", m.Publisher)
+	printField("Language", m.Language)
+	return nil
+}
+
+// renameFromEmbedded renames a finished download after the metadata
+// embedded in the file when files.rename_files is on, falling back to the
+// scraped values for anything the file doesn't say. The file stays in its
+// directory and existing files are never overwritten.
+func renameFromEmbedded(download *db.Download, book *anna.Book) {
+	if !config.Get().Files.RenameFiles {
+		return
+	}
+
+	embedded, err := meta.Extract(download.FilePath)
+	if err != nil || embedded.Empty() {
+		return
+	}
+
+	named := anna.Book{
+		Title:   download.Title,
+		Authors: download.Authors,
+		Format:  strings.TrimPrefix(filepath.Ext(download.FilePath), "."),
+	}
+	if book != nil {
+		named.Year = book.Year
+	}
+	if embedded.Title != "" {
+		named.Title = embedded.Title
+	}
+	if embedded.Authors != "" {
+		named.Authors = embedded.Authors
+	}
+	if embedded.Year != "" {
+		named.Year = embedded.Year
+	}
+
+	newPath := filepath.Join(filepath.Dir(download.FilePath), buildFilename(&named))
+	if newPath == download.FilePath {
+		return
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		Printf("Not renaming to %s: file exists\n", filepath.Base(newPath))
+		return
+	}
+	if err := os.Rename(download.FilePath, newPath); err != nil {
+		Errorf("failed to rename from embedded metadata: %v", err)
+		return
+	}
+	Printf("Renamed from embedded metadata: %s\n", filepath.Base(newPath))
+	download.FilePath = newPath
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+// bookMetadata is the content of a metadata sidecar file
+type bookMetadata struct {
+	Title     string `json:"title"`
+	Authors   string `json:"authors,omitempty"`
+	Publisher string `json:"publisher,omitempty"`
+	Year      string `json:"year,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Format    string `json:"format,omitempty"`
+	FileSize  int64  `json:"file_size,omitempty"`
+	MD5       string `json:"md5"`
+	SourceURL string `json:"source_url"`
+}
+
+// metadataPath returns the sidecar path for a downloaded file
+func metadataPath(filePath string) string {
+	return filePath + ".json"
+}
+
+// writeMetadataSidecar writes a JSON sidecar next to a completed download
+// when files.write_metadata is enabled. Failures are reported as warnings,
+// a missing sidecar never fails the download.
+func writeMetadataSidecar(download *db.Download, book *anna.Book) {
+	if !config.Get().Files.WriteMetadata {
+		return
+	}
+
+	meta := bookMetadata{
+		Title:     download.Title,
+		Authors:   download.Authors,
+		Publisher: download.Publisher,
+		Language:  download.Language,
+		Format:    download.Format,
+		FileSize:  download.FileSize,
+		MD5:       download.MD5Hash,
+		SourceURL: download.SourceURL,
+	}
+	if book != nil {
+		if meta.Publisher == "" {
+			meta.Publisher = book.Publisher
+		}
+		if meta.Language == "" {
+			meta.Language = book.Language
+		}
+		meta.Year = book.Year
+	}
+	if info, err := os.Stat(download.FilePath); err == nil {
+		meta.FileSize = info.Size()
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		Errorf("failed to encode metadata: %v", err)
+		return
+	}
+	if err := os.WriteFile(metadataPath(download.FilePath), append(data, '\n'), 0644); err != nil {
+		Errorf("failed to write metadata sidecar: %v", err)
+	}
+}
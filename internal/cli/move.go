@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+var moveCmd = &cobra.Command{
+	Use:   "move <download-id|md5> <new-path>",
+	Short: "Move a downloaded book and keep track of it",
+	Long: `Move a completed download's file and record its new location, so
+'verify', 'open' and 'serve' keep working after you reorganize your library.
+
+If the new path is an existing directory (or ends with a slash), the file
+keeps its name. Missing parent directories are created, and an existing
+file is never overwritten. The metadata sidecar, if any, moves along.
+
+Examples:
+  bookdl move 1 ~/Books/Fiction/
+  bookdl move 1 ~/Books/dune.epub
+  bookdl move abc123def456... /mnt/nas/books/`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMove,
+}
+
+func runMove(cmd *cobra.Command, args []string) error {
+	download, err := findDownload(args[0])
+	if err != nil {
+		return err
+	}
+
+	if download.Status != db.StatusCompleted {
+		return fmt.Errorf("download #%d is not completed (status: %s)", download.ID, download.Status)
+	}
+	if _, err := os.Stat(download.FilePath); err != nil {
+		return fmt.Errorf("file not found: %s", download.FilePath)
+	}
+
+	dest, err := moveDestination(download.FilePath, args[1])
+	if err != nil {
+		return err
+	}
+	if dest == download.FilePath {
+		fmt.Println("File is already there.")
+		return nil
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("destination already exists: %s", dest)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := moveFile(download.FilePath, dest); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+	// Keep the metadata sidecar with its book
+	if _, err := os.Stat(metadataPath(download.FilePath)); err == nil {
+		if err := moveFile(metadataPath(download.FilePath), metadataPath(dest)); err != nil {
+			Errorf("failed to move metadata sidecar: %v", err)
+		}
+	}
+
+	if err := db.UpdateFilePath(download.ID, dest); err != nil {
+		return fmt.Errorf("file moved to %s but the database could not be updated: %w", dest, err)
+	}
+
+	Successf("Moved: %s", dest)
+	return nil
+}
+
+// moveDestination resolves the target of a move: a directory target keeps
+// the file's name
+func moveDestination(src, target string) (string, error) {
+	if target == "" {
+		return "", fmt.Errorf("no destination given")
+	}
+	isDir := os.IsPathSeparator(target[len(target)-1])
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		isDir = true
+	}
+	if isDir {
+		return filepath.Join(target, filepath.Base(src)), nil
+	}
+	return target, nil
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/open"
+)
+
+var openCmd = &cobra.Command{
+	Use:   "open <download-id|md5>",
+	Short: "Open a downloaded book in your default reader",
+	Long: `Open a completed download with the system's default application.
+
+Examples:
+  bookdl open 1
+  bookdl open abc123def456...`,
+	Args: cobra.ExactArgs(1),
+	RunE: runOpen,
+}
+
+func runOpen(cmd *cobra.Command, args []string) error {
+	download, err := findDownload(args[0])
+	if err != nil {
+		return err
+	}
+
+	if download.Status != db.StatusCompleted {
+		return fmt.Errorf("download #%d is not completed (status: %s)", download.ID, download.Status)
+	}
+	if _, err := os.Stat(download.FilePath); err != nil {
+		return fmt.Errorf("file not found: %s", download.FilePath)
+	}
+
+	if err := open.Start(download.FilePath); err != nil {
+		return fmt.Errorf("failed to open %s: %w", download.FilePath, err)
+	}
+	Printf("Opened: %s\n", download.FilePath)
+	return nil
+}
+
+// findDownload looks up a download by ID or MD5 hash
+func findDownload(arg string) (*db.Download, error) {
+	if md5Hash, err := anna.ExtractMD5(arg); err == nil {
+		download, err := db.GetDownloadByHash(md5Hash)
+		if err != nil {
+			return nil, fmt.Errorf("download not found: %s", arg)
+		}
+		return download, nil
+	}
+
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid download ID or MD5: %s", arg)
+	}
+	download, err := db.GetDownload(id)
+	if err != nil {
+		return nil, fmt.Errorf("download not found: %d", id)
+	}
+	return download, nil
+}
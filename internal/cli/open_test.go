@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+func TestFindDownload(t *testing.T) {
+	const hash = "fedcba9876543210fedcba9876543210"
+	download := &db.Download{MD5Hash: hash, Title: "Dune", Status: db.StatusCompleted}
+	if err := db.CreateDownload(download); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DeleteDownload(download.ID) })
+
+	for _, arg := range []string{
+		strconv.FormatInt(download.ID, 10),
+		hash,
+		"FEDCBA9876543210FEDCBA9876543210",
+		"https://annas-archive.li/md5/" + hash,
+	} {
+		got, err := findDownload(arg)
+		if err != nil {
+			t.Errorf("findDownload(%q): %v", arg, err)
+			continue
+		}
+		if got.ID != download.ID {
+			t.Errorf("findDownload(%q) found download %d, want %d", arg, got.ID, download.ID)
+		}
+	}
+
+	if _, err := findDownload("not-a-book"); err == nil {
+		t.Error("findDownload accepted an argument that's neither an ID nor an MD5")
+	}
+}
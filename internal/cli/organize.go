@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -9,11 +10,43 @@ import (
 	"github.com/billmal071/bookdl/internal/config"
 )
 
-// OrganizedPath returns the organized file path based on config and book metadata
+// organizeModes are the values files.organize_mode accepts
+var organizeModes = []string{"flat", "author", "format", "year", "custom"}
+
+// validOrganizeMode reports whether mode is one of organizeModes
+func validOrganizeMode(mode string) bool {
+	for _, m := range organizeModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// patternPlaceholders are the placeholders expandPattern fills in
+var patternPlaceholders = []string{"{author}", "{title}", "{year}", "{format}", "{language}", "{publisher}"}
+
+// OrganizedPath returns the organized file path based on config and book
+// metadata. Downloads into the default directory are rooted in the
+// files.format_dirs entry for their format, if there is one, before the
+// organize mode is applied.
 func OrganizedPath(baseDir string, book *anna.Book, filename string) string {
 	cfg := config.Get()
 	mode := cfg.Files.OrganizeMode
 
+	// Never let the filename itself escape the base directory
+	filename = filepath.Base(filename)
+	if isDotsOnly(filename) {
+		filename = "book"
+	}
+
+	// An explicit --output directory wins over the per-format ones
+	if filepath.Clean(baseDir) == filepath.Clean(cfg.Downloads.Path) {
+		if dir := formatDir(book, filename); dir != "" {
+			baseDir = dir
+		}
+	}
+
 	// If flat mode or no book info, just return base path
 	if mode == "flat" || mode == "" || book == nil {
 		return filepath.Join(baseDir, filename)
@@ -30,14 +63,14 @@ func OrganizedPath(baseDir string, book *anna.Book, filename string) string {
 		subDir = author
 
 	case "format":
-		format := strings.ToUpper(book.Format)
+		format := sanitizePathComponent(strings.ToUpper(book.Format))
 		if format == "" {
 			format = "Other"
 		}
 		subDir = format
 
 	case "year":
-		year := book.Year
+		year := sanitizePathComponent(book.Year)
 		if year == "" {
 			year = "Unknown Year"
 		}
@@ -56,7 +89,50 @@ func OrganizedPath(baseDir string, book *anna.Book, filename string) string {
 		filename = buildFilename(book)
 	}
 
-	return filepath.Join(baseDir, subDir, filename)
+	path := filepath.Join(baseDir, subDir, filename)
+
+	// Metadata or a custom pattern could still produce a path outside the
+	// base directory (e.g. "{author}/../.."); fall back to flat in that case
+	if !isWithinDir(baseDir, path) {
+		return filepath.Join(baseDir, filename)
+	}
+
+	return path
+}
+
+// formatDir returns the files.format_dirs directory for the book's format,
+// taken from the filename's extension when the format is unknown, or ""
+func formatDir(book *anna.Book, filename string) string {
+	dirs := config.Get().Files.FormatDirs
+	if len(dirs) == 0 {
+		return ""
+	}
+
+	format := ""
+	if book != nil {
+		format = book.Format
+	}
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(filename), ".")
+	}
+	return dirs[strings.ToLower(format)]
+}
+
+// isWithinDir reports whether path is located inside dir after cleaning both
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	if rel == "." || filepath.IsAbs(rel) {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// isDotsOnly reports whether s consists only of dots (".", "..", "...")
+func isDotsOnly(s string) bool {
+	return s != "" && strings.Trim(s, ".") == ""
 }
 
 // expandPattern expands a custom pattern with book metadata
@@ -68,9 +144,9 @@ func expandPattern(pattern string, book *anna.Book) string {
 	replacements := map[string]string{
 		"{author}":    sanitizePathComponent(book.Authors),
 		"{title}":     sanitizePathComponent(book.Title),
-		"{year}":      book.Year,
-		"{format}":    strings.ToUpper(book.Format),
-		"{language}":  book.Language,
+		"{year}":      sanitizePathComponent(book.Year),
+		"{format}":    sanitizePathComponent(strings.ToUpper(book.Format)),
+		"{language}":  sanitizePathComponent(book.Language),
 		"{publisher}": sanitizePathComponent(book.Publisher),
 	}
 
@@ -146,13 +222,20 @@ func sanitizePathComponent(s string) string {
 	// Replace multiple spaces/underscores with single
 	s = regexp.MustCompile(`[\s_]+`).ReplaceAllString(s, " ")
 
-	// Trim whitespace
+	// Trim whitespace and leading separators/dots so a component can't be
+	// absolute, hidden, or a "." / ".." reference
 	s = strings.TrimSpace(s)
+	s = strings.TrimLeft(s, `/\. `)
 
 	// Limit length
 	if len(s) > 80 {
 		s = s[:80]
 	}
 
+	s = strings.TrimSpace(s)
+	if isDotsOnly(s) {
+		return ""
+	}
+
 	return s
 }
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/config"
+)
+
+// setFiles replaces the files settings for the rest of the test
+func setFiles(t *testing.T, files config.FileConfig) {
+	t.Helper()
+	cfg := config.Get()
+	saved := cfg.Files
+	cfg.Files = files
+	t.Cleanup(func() { cfg.Files = saved })
+}
+
+func TestOrganizedPathPrecedence(t *testing.T) {
+	base := config.Get().Downloads.Path
+	papers := filepath.Join(t.TempDir(), "Papers")
+	book := &anna.Book{Title: "Dune", Authors: "Frank Herbert", Year: "1965", Format: "pdf"}
+	formatDirs := map[string]string{"pdf": papers}
+
+	tests := []struct {
+		name    string
+		files   config.FileConfig
+		baseDir string
+		want    string
+	}{
+		{
+			name:    "flat",
+			files:   config.FileConfig{OrganizeMode: "flat"},
+			baseDir: base,
+			want:    filepath.Join(base, "dune.pdf"),
+		},
+		{
+			name:    "format dir replaces the default directory",
+			files:   config.FileConfig{OrganizeMode: "flat", FormatDirs: formatDirs},
+			baseDir: base,
+			want:    filepath.Join(papers, "dune.pdf"),
+		},
+		{
+			name:    "explicit output directory wins over format dirs",
+			files:   config.FileConfig{OrganizeMode: "flat", FormatDirs: formatDirs},
+			baseDir: "/srv/books",
+			want:    filepath.Join("/srv/books", "dune.pdf"),
+		},
+		{
+			name:    "organize mode applies inside the format dir",
+			files:   config.FileConfig{OrganizeMode: "author", FormatDirs: formatDirs},
+			baseDir: base,
+			want:    filepath.Join(papers, "Frank Herbert", "dune.pdf"),
+		},
+		{
+			name:    "rename applies with an organize mode",
+			files:   config.FileConfig{OrganizeMode: "year", RenameFiles: true, FormatDirs: formatDirs},
+			baseDir: base,
+			want:    filepath.Join(papers, "1965", buildFilename(book)),
+		},
+		{
+			name:    "rename is ignored in flat mode",
+			files:   config.FileConfig{OrganizeMode: "flat", RenameFiles: true},
+			baseDir: base,
+			want:    filepath.Join(base, "dune.pdf"),
+		},
+		{
+			name:    "format dir for another format is ignored",
+			files:   config.FileConfig{OrganizeMode: "format", FormatDirs: map[string]string{"epub": papers}},
+			baseDir: base,
+			want:    filepath.Join(base, "PDF", "dune.pdf"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFiles(t, tt.files)
+			if got := OrganizedPath(tt.baseDir, book, "dune.pdf"); got != tt.want {
+				t.Errorf("OrganizedPath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrganizedPathUnknownFormatUsesExtension(t *testing.T) {
+	papers := filepath.Join(t.TempDir(), "Papers")
+	setFiles(t, config.FileConfig{OrganizeMode: "flat", FormatDirs: map[string]string{"pdf": papers}})
+
+	got := OrganizedPath(config.Get().Downloads.Path, &anna.Book{Title: "Dune"}, "dune.pdf")
+	if want := filepath.Join(papers, "dune.pdf"); got != want {
+		t.Errorf("OrganizedPath = %q, want %q", got, want)
+	}
+}
@@ -17,6 +17,10 @@ var pauseCmd = &cobra.Command{
 
 Use 'all' to pause all active downloads.
 
+Downloads running in another bookdl process (e.g. a 'bookdl resume all'
+in a different terminal) are paused too: the running process picks up the
+pause request within a second and saves its progress before stopping.
+
 Examples:
   bookdl pause 1      Pause download #1
   bookdl pause all    Pause all active downloads`,
@@ -84,7 +88,7 @@ func pauseAll() error {
 			Errorf("Failed to pause #%d: %s", d.ID, err)
 		} else {
 			paused++
-			fmt.Printf("⏸️  Paused: %s (ID: %d)\n", d.Title, d.ID)
+			fmt.Printf("%sPaused: %s (ID: %d)\n", glyph("⏸️"), d.Title, d.ID)
 		}
 	}
 
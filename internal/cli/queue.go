@@ -1,11 +1,20 @@
 package cli
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/config"
 	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+	"github.com/billmal071/bookdl/internal/fuzzy"
 )
 
 var queueCmd = &cobra.Command{
@@ -19,8 +28,13 @@ Use subcommands to list, clear, or reorder the queue.
 Examples:
   bookdl queue              List queued downloads
   bookdl queue list         List queued downloads
+  bookdl queue --json       List queued downloads as JSON
+  bookdl queue add <md5>    Add books to the queue by MD5
   bookdl queue clear        Clear all pending downloads
-  bookdl queue remove 1 2 3 Remove specific items from queue`,
+  bookdl queue remove 1 2 3 Remove specific items from queue
+  bookdl queue export q.json Export the queue to a file
+  bookdl queue import q.json Import a queue from a file
+  bookdl queue dedupe       Remove duplicate titles from the queue`,
 	RunE: runQueueList,
 }
 
@@ -31,6 +45,19 @@ var queueListCmd = &cobra.Command{
 	RunE:  runQueueList,
 }
 
+var queueAddCmd = &cobra.Command{
+	Use:   "add [md5-hash...]",
+	Short: "Add books to the queue by MD5 hash",
+	Long: `Add books to the download queue by MD5 hash.
+Pass - to read hashes from stdin, one per line.
+
+Examples:
+  bookdl queue add abc123def456789...
+  grep -o '[0-9a-f]\{32\}' links.txt | bookdl queue add -`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runQueueAdd,
+}
+
 var queueClearCmd = &cobra.Command{
 	Use:   "clear",
 	Short: "Clear the download queue",
@@ -63,19 +90,89 @@ Examples:
 	RunE: runQueuePriority,
 }
 
+var queueExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export the queue to a file",
+	Long: `Export pending downloads (MD5, title, and priority) so the queue can be
+shared or moved to another machine. Writes to stdout if no file is given.
+
+Examples:
+  bookdl queue export queue.json
+  bookdl queue export --format text > hashes.txt`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runQueueExport,
+}
+
+var queueImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import a queue from a file",
+	Long: `Import pending downloads from a file created with 'bookdl queue export'.
+
+Both the JSON format and the text format (one MD5 per line, optionally
+followed by a tab and the title) are accepted. Books that are already
+queued or downloaded are skipped.
+
+Examples:
+  bookdl queue import queue.json
+  bookdl queue import hashes.txt`,
+	Args: cobra.ExactArgs(1),
+	RunE: runQueueImport,
+}
+
+var queueDedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Remove duplicate titles from the queue",
+	Long: `Remove queued downloads that duplicate another queued book with the same
+title, e.g. the same book queued in several formats.
+
+For each title the copy in the most preferred format (files.preferred_formats)
+is kept, then the one with the highest priority, then the oldest.
+
+Examples:
+  bookdl queue dedupe            Remove duplicates
+  bookdl queue dedupe --dry-run  Show what would be removed`,
+	Args: cobra.NoArgs,
+	RunE: runQueueDedupe,
+}
+
+// queueEntry is the portable representation of a queued download
+type queueEntry struct {
+	MD5      string `json:"md5"`
+	Title    string `json:"title"`
+	Authors  string `json:"authors,omitempty"`
+	Format   string `json:"format,omitempty"`
+	FileSize int64  `json:"file_size,omitempty"`
+	Priority int    `json:"priority"`
+}
+
 func init() {
+	queueCmd.Flags().Bool("json", false, "output the queue as JSON")
+	queueListCmd.Flags().Bool("json", false, "output the queue as JSON")
+	queueExportCmd.Flags().String("format", "json", "export format (json, text)")
+	queueDedupeCmd.Flags().Bool("dry-run", false, "show duplicates without removing them")
+
 	queueCmd.AddCommand(queueListCmd)
+	queueCmd.AddCommand(queueAddCmd)
 	queueCmd.AddCommand(queueClearCmd)
 	queueCmd.AddCommand(queueRemoveCmd)
 	queueCmd.AddCommand(queuePriorityCmd)
+	queueCmd.AddCommand(queueExportCmd)
+	queueCmd.AddCommand(queueImportCmd)
+	queueCmd.AddCommand(queueDedupeCmd)
 }
 
 func runQueueList(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+
 	downloads, err := db.ListDownloads(db.StatusPending, true)
 	if err != nil {
 		return fmt.Errorf("failed to list queue: %w", err)
 	}
 
+	if asJSON {
+		return printDownloadsJSON(downloads)
+	}
+
 	if len(downloads) == 0 {
 		fmt.Println("Queue is empty.")
 		return nil
@@ -103,7 +200,7 @@ func runQueueList(cmd *cobra.Command, args []string) error {
 			details = append(details, d.Format)
 		}
 		if d.FileSize > 0 {
-			details = append(details, formatBytes(d.FileSize))
+			details = append(details, downloader.FormatBytes(d.FileSize))
 		}
 		if d.Authors != "" {
 			authors := d.Authors
@@ -112,6 +209,9 @@ func runQueueList(cmd *cobra.Command, args []string) error {
 			}
 			details = append(details, authors)
 		}
+		if d.ScheduledAt != nil {
+			details = append(details, "scheduled "+d.ScheduledAt.Local().Format("2006-01-02 15:04"))
+		}
 		if len(details) > 0 {
 			fmt.Printf("     %s\n", strings.Join(details, " | "))
 		}
@@ -123,6 +223,69 @@ func runQueueList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runQueueAdd(cmd *cobra.Command, args []string) error {
+	var hashes []string
+	skipped := 0
+	for _, arg := range args {
+		if arg == "-" {
+			read, invalid, err := readHashes(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read hashes: %w", err)
+			}
+			hashes = append(hashes, read...)
+			skipped += invalid
+			continue
+		}
+		hash, err := anna.ExtractMD5(arg)
+		if err != nil {
+			Errorf("skipping %v", err)
+			skipped++
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+
+	client := anna.NewClient()
+	added := 0
+	for _, hash := range hashes {
+		if existing, _ := db.GetDownloadByHash(hash); existing != nil {
+			Printf("Skipping %s: already tracked (status: %s)\n", hash, existing.Status)
+			skipped++
+			continue
+		}
+
+		book := lookupBook(cmd.Context(), client, hash)
+		if err := addToQueue(book); err != nil {
+			Errorf("failed to queue %s: %v", hash, err)
+			skipped++
+			continue
+		}
+		fmt.Printf("Queued: %s\n", book.Title)
+		added++
+	}
+
+	Successf("Added %d book(s) to the queue (%d skipped).", added, skipped)
+	return nil
+}
+
+// lookupBook fetches a book's details by MD5, falling back to a
+// placeholder with just the hash when the search fails
+func lookupBook(ctx context.Context, client anna.Client, md5Hash string) *anna.Book {
+	searchCtx, cancel := withTimeout(ctx, searchTimeout())
+	defer cancel()
+
+	books, err := client.Search(searchCtx, md5Hash, 1)
+	if err == nil && len(books) > 0 && books[0].MD5Hash == md5Hash {
+		return books[0]
+	}
+	return &anna.Book{
+		MD5Hash: md5Hash,
+		Title:   md5Hash,
+		Format:  "EPUB",
+		PageURL: fmt.Sprintf("https://%s/md5/%s", anna.GetBaseURL(), md5Hash),
+	}
+}
+
 func runQueueClear(cmd *cobra.Command, args []string) error {
 	downloads, err := db.ListDownloads(db.StatusPending, true)
 	if err != nil {
@@ -227,3 +390,244 @@ func runQueuePriority(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runQueueExport(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	format = strings.ToLower(format)
+	if format != "json" && format != "text" {
+		return fmt.Errorf("invalid format: %s (use json or text)", format)
+	}
+
+	downloads, err := db.ListDownloads(db.StatusPending, true)
+	if err != nil {
+		return fmt.Errorf("failed to list queue: %w", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if len(args) == 1 {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	entries := make([]queueEntry, 0, len(downloads))
+	for _, d := range downloads {
+		entries = append(entries, queueEntry{
+			MD5:      d.MD5Hash,
+			Title:    d.Title,
+			Authors:  d.Authors,
+			Format:   d.Format,
+			FileSize: d.FileSize,
+			Priority: d.Priority,
+		})
+	}
+
+	if format == "text" {
+		for _, e := range entries {
+			fmt.Fprintf(out, "%s\t%s\n", e.MD5, e.Title)
+		}
+	} else {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			return fmt.Errorf("failed to write queue: %w", err)
+		}
+	}
+
+	// Only print status when writing to a file so stdout stays pipeable
+	if len(args) == 1 {
+		Successf("Exported %d item(s) to %s", len(entries), args[0])
+	}
+	return nil
+}
+
+func runQueueImport(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	entries, err := parseQueueEntries(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", args[0], err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No entries to import.")
+		return nil
+	}
+
+	imported, skipped, failed := 0, 0, 0
+	for _, e := range entries {
+		md5Hash, err := anna.ExtractMD5(e.MD5)
+		if err != nil {
+			Errorf("%v", err)
+			failed++
+			continue
+		}
+
+		// Skip anything we already know about (queued, downloaded, paused, ...)
+		if existing, err := db.GetDownloadByHash(md5Hash); err == nil && existing != nil {
+			Printf("Skipped (%s): %s\n", existing.Status, existing.Title)
+			skipped++
+			continue
+		}
+
+		title := e.Title
+		if title == "" {
+			title = md5Hash
+		}
+
+		book := &anna.Book{
+			MD5Hash:   md5Hash,
+			Title:     title,
+			Authors:   e.Authors,
+			Format:    e.Format,
+			SizeBytes: e.FileSize,
+			PageURL:   fmt.Sprintf("https://%s/md5/%s", anna.GetBaseURL(), md5Hash),
+		}
+		if err := addToQueue(book); err != nil {
+			Errorf("failed to queue %s: %v", title, err)
+			failed++
+			continue
+		}
+
+		// Restore the exported priority
+		if e.Priority != 0 {
+			d, err := db.GetDownloadByHash(md5Hash)
+			if err != nil {
+				return fmt.Errorf("failed to set priority of %s: %w", title, err)
+			}
+			if err := db.UpdatePriority(d.ID, e.Priority); err != nil {
+				return fmt.Errorf("failed to set priority of %s: %w", title, err)
+			}
+		}
+
+		imported++
+		Printf("Queued: %s\n", title)
+	}
+
+	Successf("Imported %d item(s), skipped %d, failed %d.", imported, skipped, failed)
+	return nil
+}
+
+// parseQueueEntries parses either the JSON export format or a plain text
+// list with one MD5 per line (optionally followed by a tab and title)
+func parseQueueEntries(data []byte) ([]queueEntry, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var entries []queueEntry
+		if err := json.Unmarshal([]byte(trimmed), &entries); err != nil {
+			return nil, err
+		}
+		return entries, nil
+	}
+
+	var entries []queueEntry
+	scanner := bufio.NewScanner(strings.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry := queueEntry{MD5: line}
+		if idx := strings.IndexAny(line, "\t "); idx > 0 {
+			entry.MD5 = line[:idx]
+			entry.Title = strings.TrimSpace(line[idx+1:])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func runQueueDedupe(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	downloads, err := db.ListDownloads(db.StatusPending, true)
+	if err != nil {
+		return fmt.Errorf("failed to list queue: %w", err)
+	}
+
+	// Group by normalized title, keeping queue order within each group
+	groups := make(map[string][]*db.Download)
+	var keys []string
+	for _, d := range downloads {
+		key := fuzzy.Normalize(d.Title)
+		if key == "" {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], d)
+	}
+
+	removed := 0
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+
+		keep := group[0]
+		for _, d := range group[1:] {
+			if preferDownload(d, keep) {
+				keep = d
+			}
+		}
+
+		for _, d := range group {
+			if d == keep {
+				continue
+			}
+			if dryRun {
+				fmt.Printf("Would remove: [%d] %s (%s), keeping [%d] (%s)\n", d.ID, d.Title, d.Format, keep.ID, keep.Format)
+				removed++
+				continue
+			}
+			if err := db.DeleteDownload(d.ID); err != nil {
+				Errorf("failed to remove #%d: %v", d.ID, err)
+				continue
+			}
+			fmt.Printf("Removed: [%d] %s (%s), keeping [%d] (%s)\n", d.ID, d.Title, d.Format, keep.ID, keep.Format)
+			removed++
+		}
+	}
+
+	if removed == 0 {
+		fmt.Println("No duplicates found.")
+	} else if dryRun {
+		fmt.Printf("\n%d duplicate(s) would be removed.\n", removed)
+	} else {
+		Successf("Removed %d duplicate(s) from the queue.", removed)
+	}
+	return nil
+}
+
+// preferDownload reports whether a should be kept over b when both are the
+// same book: preferred format first, then priority, then the older entry
+func preferDownload(a, b *db.Download) bool {
+	prefs := config.Get().Files.PreferredFormats
+	ra, rb := formatRank(a.Format, prefs), formatRank(b.Format, prefs)
+	if ra != rb {
+		return ra < rb
+	}
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.ID < b.ID
+}
+
+// formatRank returns the position of format in prefs, or len(prefs) if
+// it isn't listed
+func formatRank(format string, prefs []string) int {
+	for i, f := range prefs {
+		if strings.EqualFold(f, format) {
+			return i
+		}
+	}
+	return len(prefs)
+}
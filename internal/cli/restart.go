@@ -3,28 +3,67 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/anna"
 	"github.com/billmal071/bookdl/internal/db"
 	"github.com/billmal071/bookdl/internal/downloader"
+	"github.com/billmal071/bookdl/internal/notify"
 )
 
 var restartCmd = &cobra.Command{
-	Use:   "restart [download-id]",
+	Use:   "restart [download-id|all]",
 	Short: "Restart a download from scratch",
 	Long: `Restart a download from the beginning, discarding any partial progress.
 
 This is useful when a download is corrupted or you want to start fresh.
+The download link is fetched again first, since old ones expire.
+
+Use 'all' to restart every paused and failed download, or --failed for
+just the failed ones. They run concurrently like 'resume all'.
+
+'resume all' gives up on a download after downloads.max_download_retries
+failed attempts. Restarting clears the count; --reset-retries clears just
+the count, so the next 'resume all' tries the download again where it
+left off.
 
 Examples:
-  bookdl restart 1    Restart download #1 from scratch`,
-	Args: cobra.ExactArgs(1),
+  bookdl restart 1          Restart download #1 from scratch
+  bookdl restart all        Restart all paused and failed downloads
+  bookdl restart --failed   Restart all failed downloads
+  bookdl restart --reset-retries 1    Let 'resume all' retry #1 again
+  bookdl restart --reset-retries all  ...and every other failed download`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runRestart,
 }
 
+func init() {
+	restartCmd.Flags().Bool("failed", false, "restart all failed downloads")
+	restartCmd.Flags().Bool("reset-retries", false, "only clear the retry count of a download (or all) without restarting it")
+}
+
 func runRestart(cmd *cobra.Command, args []string) error {
+	if resetRetries, _ := cmd.Flags().GetBool("reset-retries"); resetRetries {
+		return runResetRetries(args)
+	}
+
+	failedOnly, _ := cmd.Flags().GetBool("failed")
+	if failedOnly {
+		if len(args) > 0 {
+			return fmt.Errorf("--failed restarts all failed downloads and takes no arguments")
+		}
+		return restartAll(cmd.Context(), true)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("specify a download ID, 'all' or --failed")
+	}
+	if strings.ToLower(args[0]) == "all" {
+		return restartAll(cmd.Context(), false)
+	}
+
 	id, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
 		return fmt.Errorf("invalid download ID: %s", args[0])
@@ -38,10 +77,18 @@ func runRestart(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Restarting: %s\n", download.Title)
 
 	// Reset download state
-	if err := db.ResetDownload(id); err != nil {
+	if err := resetDownload(download); err != nil {
 		return fmt.Errorf("failed to reset download: %w", err)
 	}
 
+	// A reset keeps the old link, which may have expired
+	fmt.Println("Fetching a fresh download link...")
+	if url, err := freshDownloadURL(cmd.Context(), anna.NewClient(), download.MD5Hash, nil); err != nil {
+		Printf("Keeping the stored link: %v\n", err)
+	} else if err := db.UpdateDownloadURL(id, url); err != nil {
+		Errorf("failed to save the new link: %v", err)
+	}
+
 	// Re-fetch download record
 	download, err = db.GetDownload(id)
 	if err != nil {
@@ -49,20 +96,135 @@ func runRestart(cmd *cobra.Command, args []string) error {
 	}
 
 	// Start fresh download
-	mgr := downloader.NewManager()
+	mgr, err := newDownloadManager()
+	if err != nil {
+		return err
+	}
 
-	dlCtx, cancel := context.WithTimeout(cmd.Context(), 30*time.Minute)
+	dlCtx, cancel := withTimeout(cmd.Context(), downloadTimeout())
 	defer cancel()
 
 	if err := mgr.StartDownload(dlCtx, download); err != nil {
-		db.UpdateStatus(download.ID, db.StatusFailed, err.Error())
+		if err == downloader.ErrPaused {
+			fmt.Printf("Paused. Use 'bookdl resume %d' to continue.\n", download.ID)
+			return nil
+		}
+		db.MarkFailed(download.ID, err.Error())
+		notify.DownloadFailed(download.Title, err.Error())
 		return fmt.Errorf("download failed: %w", err)
 	}
 
+	if err := verifyCompleted(download); err != nil {
+		db.MarkFailed(download.ID, err.Error())
+		notify.DownloadFailed(download.Title, err.Error())
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
 	if err := db.MarkCompleted(download.ID, download.FilePath); err != nil {
 		return fmt.Errorf("failed to mark complete: %w", err)
 	}
+	writeMetadataSidecar(download, nil)
 
 	Successf("Downloaded: %s", download.FilePath)
+	notify.DownloadComplete(download.Title)
 	return nil
 }
+
+// runResetRetries clears the retry count of one download, or of every
+// failed download for "all"
+func runResetRetries(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("specify a download ID or 'all' with --reset-retries")
+	}
+	if strings.ToLower(args[0]) == "all" {
+		n, err := db.ResetAllRetries()
+		if err != nil {
+			return fmt.Errorf("failed to reset retries: %w", err)
+		}
+		Successf("Reset the retry count of %d failed download(s)", n)
+		return nil
+	}
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid download ID: %s", args[0])
+	}
+	download, err := db.GetDownload(id)
+	if err != nil {
+		return fmt.Errorf("download not found: %w", err)
+	}
+	if err := db.ResetRetries(id); err != nil {
+		return fmt.Errorf("failed to reset retries: %w", err)
+	}
+	Successf("Reset the retry count of #%d (%s)", id, download.Title)
+	return nil
+}
+
+// resetDownload clears a download's progress, including the partial file
+// a simple download would otherwise continue from
+func resetDownload(download *db.Download) error {
+	if err := db.ResetDownload(download.ID); err != nil {
+		return err
+	}
+	if download.TempPath != "" {
+		if err := os.Remove(download.TempPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartAll resets the paused and failed downloads, or only the failed
+// ones, and downloads them again concurrently
+func restartAll(ctx context.Context, failedOnly bool) error {
+	downloads, err := db.ListDownloads(db.StatusFailed, false)
+	if err != nil {
+		return fmt.Errorf("failed to list downloads: %w", err)
+	}
+	if !failedOnly {
+		paused, err := db.ListDownloads(db.StatusPaused, false)
+		if err != nil {
+			return fmt.Errorf("failed to list downloads: %w", err)
+		}
+		downloads = append(paused, downloads...)
+	}
+
+	if len(downloads) == 0 {
+		if failedOnly {
+			fmt.Println("No failed downloads to restart.")
+		} else {
+			fmt.Println("No paused or failed downloads to restart.")
+		}
+		return nil
+	}
+
+	client := anna.NewClient()
+	var fresh []*db.Download
+	for _, d := range downloads {
+		if err := resetDownload(d); err != nil {
+			Errorf("failed to reset #%d: %v", d.ID, err)
+			continue
+		}
+
+		// A reset keeps the old link, which may have expired
+		fmt.Printf("Fetching a fresh download link for %s...\n", d.Title)
+		if url, err := freshDownloadURL(ctx, client, d.MD5Hash, nil); err != nil {
+			Printf("Keeping the stored link for #%d: %v\n", d.ID, err)
+		} else if err := db.UpdateDownloadURL(d.ID, url); err != nil {
+			Errorf("failed to save the new link for #%d: %v", d.ID, err)
+		}
+
+		reset, err := db.GetDownload(d.ID)
+		if err != nil {
+			Errorf("failed to get download: %v", err)
+			continue
+		}
+		fresh = append(fresh, reset)
+	}
+	fmt.Println()
+
+	if len(fresh) == 0 {
+		return fmt.Errorf("no downloads could be reset")
+	}
+	return runBatch(ctx, fresh, "", "Restarting")
+}
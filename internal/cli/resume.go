@@ -2,9 +2,14 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -23,16 +28,132 @@ Use 'all' to resume all paused downloads.
 
 Examples:
   bookdl resume 1      Resume download #1
-  bookdl resume all    Resume all paused downloads`,
+  bookdl resume all    Resume all paused downloads (and queued ones that are due)
+  bookdl resume all --verify-after
+  bookdl resume all --smallest-first   Finish small books first
+  bookdl resume 1 --limit-rate 500KB
+  bookdl resume 1 --verify-resume      Re-check finished chunks first
+  bookdl resume all --progress json    One JSON progress line per update on stdout`,
 	Args: cobra.ExactArgs(1),
 	RunE: runResume,
 }
 
+func init() {
+	resumeCmd.Flags().StringVar(&limitRate, "limit-rate", "", "cap each download's speed, e.g. 500KB or 2MB")
+	resumeCmd.Flags().StringVar(&chunkSize, "chunk-size", "", "chunk size for downloads that haven't started yet (existing chunks are kept)")
+	resumeCmd.Flags().BoolVar(&verifyAfter, "verify-after", false, "fail downloads whose checksum doesn't match")
+	resumeCmd.Flags().Bool("smallest-first", false, "start the smallest downloads first")
+	resumeCmd.Flags().Bool("largest-first", false, "start the largest downloads first")
+	resumeCmd.Flags().StringVar(&progressFormat, "progress", progressAuto, "how 'resume all' shows progress: auto, tui, bar, or json for one JSON line per update")
+	resumeCmd.Flags().BoolVar(&verifyResume, "verify-resume", false, "re-hash completed chunks, and restart if the server's ETag changed (chunks need a checksum: from --verify-resume or downloads.chunk_checksums when downloaded)")
+}
+
+// verifyResume re-checks what was already downloaded before resuming
+var verifyResume bool
+
+// progressFormat is how 'resume all' reports progress (--progress)
+var progressFormat string
+
+const (
+	progressAuto = "auto" // the dashboard on a terminal, bars otherwise
+	progressTUI  = "tui"
+	progressBar  = "bar"
+	progressJSON = "json"
+)
+
+// jsonProgressPrinter returns a progress listener that writes each update
+// to w as a line of JSON
+func jsonProgressPrinter(w io.Writer) func(downloader.ProgressUpdate) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(u downloader.ProgressUpdate) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(u)
+	}
+}
+
+// plainProgressPrinter returns a progress listener that prints a line each
+// time a download passes another 10%, for logs where bars can't be drawn
+func plainProgressPrinter(w io.Writer) func(downloader.ProgressUpdate) {
+	var mu sync.Mutex
+	printed := make(map[int64]int)
+	return func(u downloader.ProgressUpdate) {
+		if u.Total <= 0 {
+			return
+		}
+		step := int(u.Percent()) / 10 * 10
+		mu.Lock()
+		defer mu.Unlock()
+		last, seen := printed[u.ID]
+		if seen && step <= last {
+			return
+		}
+		printed[u.ID] = step
+		fmt.Fprintf(w, "Download #%d: %d%% (%s of %s)\n", u.ID, step, downloader.FormatBytes(u.Downloaded), downloader.FormatBytes(u.Total))
+	}
+}
+
+// Download orders for 'resume all' (downloads.order)
+const (
+	orderPriority      = "priority"
+	orderSmallestFirst = "smallest_first"
+	orderLargestFirst  = "largest_first"
+)
+
+// downloadOrder resolves the queue order from flags, falling back to config
+func downloadOrder(cmd *cobra.Command) string {
+	if smallest, _ := cmd.Flags().GetBool("smallest-first"); smallest {
+		return orderSmallestFirst
+	}
+	if largest, _ := cmd.Flags().GetBool("largest-first"); largest {
+		return orderLargestFirst
+	}
+	return config.Get().Downloads.Order
+}
+
+// orderBySize sorts downloads of the same priority by file size. Unknown
+// sizes are probed with a HEAD request when a download URL is known; anything
+// still unknown goes last.
+func orderBySize(ctx context.Context, mgr *downloader.Manager, downloads []*db.Download, order string) {
+	if order != orderSmallestFirst && order != orderLargestFirst {
+		return
+	}
+
+	for _, d := range downloads {
+		if d.FileSize <= 0 && d.DownloadURL != "" {
+			probeCtx, cancel := withTimeout(ctx, config.Get().Network.Timeout)
+			d.FileSize = mgr.ProbeSize(probeCtx, d.DownloadURL)
+			cancel()
+		}
+	}
+
+	sort.SliceStable(downloads, func(i, j int) bool {
+		if pi, pj := downloads[i].Priority, downloads[j].Priority; pi != pj {
+			return pi > pj
+		}
+		a, b := downloads[i].FileSize, downloads[j].FileSize
+		if a <= 0 || b <= 0 {
+			return a > 0 && b <= 0
+		}
+		if order == orderLargestFirst {
+			return a > b
+		}
+		return a < b
+	})
+}
+
 func runResume(cmd *cobra.Command, args []string) error {
 	arg := strings.ToLower(args[0])
 
+	switch progressFormat {
+	case progressAuto, progressTUI, progressBar, progressJSON:
+	default:
+		return fmt.Errorf("invalid --progress %q (use auto, tui, bar or json)", progressFormat)
+	}
+
 	if arg == "all" {
-		return resumeAll(cmd.Context())
+		return resumeAll(cmd.Context(), downloadOrder(cmd))
 	}
 
 	id, err := strconv.ParseInt(arg, 10, 64)
@@ -61,102 +182,234 @@ func resumeOne(ctx context.Context, id int64) error {
 
 	fmt.Printf("Resuming: %s\n", download.Title)
 
-	mgr := downloader.NewManager()
-
-	// Use configurable timeout
-	timeout := config.Get().Downloads.Timeout
-	if timeout == 0 {
-		timeout = 30 * time.Minute
+	mgr, err := newDownloadManager()
+	if err != nil {
+		return err
 	}
-	dlCtx, cancel := context.WithTimeout(ctx, timeout)
+
+	dlCtx, cancel := withTimeout(ctx, downloadTimeout())
 	defer cancel()
 
-	if err := mgr.StartDownload(dlCtx, download); err != nil {
-		db.UpdateStatus(download.ID, db.StatusFailed, err.Error())
+	err = mgr.StartDownload(dlCtx, download)
+	if needsNewLink(err) && refreshDownloadURL(dlCtx, mgr, download, err, os.Stdout) == nil {
+		err = mgr.StartDownload(dlCtx, download)
+	}
+	if err != nil {
+		if err == downloader.ErrPaused {
+			fmt.Printf("Paused. Use 'bookdl resume %d' to continue.\n", download.ID)
+			return nil
+		}
+		db.MarkFailed(download.ID, err.Error())
+		notify.DownloadFailed(download.Title, err.Error())
 		return fmt.Errorf("download failed: %w", err)
 	}
 
+	if err := verifyCompleted(download); err != nil {
+		db.MarkFailed(download.ID, err.Error())
+		notify.DownloadFailed(download.Title, err.Error())
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
 	if err := db.MarkCompleted(download.ID, download.FilePath); err != nil {
 		return fmt.Errorf("failed to mark complete: %w", err)
 	}
+	writeMetadataSidecar(download, nil)
 
 	Successf("Downloaded: %s", download.FilePath)
+	notify.DownloadComplete(download.Title)
 	return nil
 }
 
-func resumeAll(ctx context.Context) error {
-	downloads, err := db.ListDownloads(db.StatusPaused, false)
+// countScheduled returns how many pending downloads aren't due until after now
+func countScheduled(now time.Time) int {
+	pending, err := db.ListDownloads(db.StatusPending, false)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, d := range pending {
+		if d.ScheduledAt != nil && d.ScheduledAt.After(now) {
+			count++
+		}
+	}
+	return count
+}
+
+func resumeAll(ctx context.Context, order string) error {
+	// With --progress json, stdout only carries progress events
+	var out io.Writer = os.Stdout
+	if progressFormat == progressJSON {
+		out = os.Stderr
+	}
+
+	// Paused, failed and pending downloads, except those scheduled for
+	// later, highest priority first
+	now := time.Now()
+	downloads, err := db.ListResumable(now)
 	if err != nil {
 		return fmt.Errorf("failed to list downloads: %w", err)
 	}
-
-	// Also get failed downloads
-	failed, err := db.ListDownloads(db.StatusFailed, false)
-	if err == nil {
-		downloads = append(downloads, failed...)
+	if scheduled := countScheduled(now); scheduled > 0 {
+		fmt.Fprintf(out, "%d download(s) scheduled for later are skipped.\n", scheduled)
 	}
 
-	// Also get pending downloads (from queue)
-	pending, err := db.ListDownloads(db.StatusPending, false)
-	if err == nil {
-		downloads = append(downloads, pending...)
+	downloads, gaveUp := skipRetries(downloads)
+	if gaveUp > 0 {
+		fmt.Fprintf(out, "%d download(s) failed %d times and are skipped; use 'bookdl restart --reset-retries all' to try them again.\n",
+			gaveUp, config.Get().Downloads.MaxDownloadRetries)
 	}
 
 	if len(downloads) == 0 {
-		fmt.Println("No downloads to resume.")
+		fmt.Fprintln(out, "No downloads to resume.")
 		return nil
 	}
 
-	mgr := downloader.NewManager()
+	return runBatch(ctx, downloads, order, "Resuming")
+}
+
+// retriesExhausted reports whether a download has failed as many times as
+// downloads.max_download_retries allows
+func retriesExhausted(d *db.Download) bool {
+	max := config.Get().Downloads.MaxDownloadRetries
+	return max > 0 && d.RetryCount >= max
+}
+
+// skipRetries drops failed downloads that have used up their retries and
+// returns how many were dropped
+func skipRetries(downloads []*db.Download) (kept []*db.Download, gaveUp int) {
+	for _, d := range downloads {
+		if d.Status == db.StatusFailed && retriesExhausted(d) {
+			gaveUp++
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept, gaveUp
+}
+
+// runBatch downloads several records concurrently, showing progress as set
+// by --progress, and prints a summary. action describes what is being done,
+// e.g. "Resuming".
+func runBatch(ctx context.Context, downloads []*db.Download, order, action string) error {
+	var out io.Writer = os.Stdout
+	if progressFormat == progressJSON {
+		out = os.Stderr
+	}
+
+	mgr, err := newDownloadManager()
+	if err != nil {
+		return err
+	}
+	if progressFormat == progressJSON {
+		downloader.SetProgressBars(false)
+		mgr.SetProgressListener(jsonProgressPrinter(os.Stdout))
+	}
 	maxConcurrent := mgr.GetMaxConcurrent()
 
-	fmt.Printf("Resuming %d download(s) (max %d concurrent)...\n\n", len(downloads), maxConcurrent)
+	orderBySize(ctx, mgr, downloads, order)
+
+	fmt.Fprintf(out, "%s %d download(s) (max %d concurrent)...\n\n", action, len(downloads), maxConcurrent)
 
-	// Track completed and failed
+	// Track completed, paused and failed
 	completed := 0
+	paused := 0
 	var errors []error
 
+	useDashboard := progressFormat == progressTUI || (progressFormat == progressAuto && interactive() && !quiet)
+
 	// Use concurrent downloads
-	results := mgr.StartConcurrent(ctx, downloads, func(id int64, status string, progress float64) {
-		// Progress callback - could be used for TUI in future
-		switch status {
-		case "starting":
-			// Find download title
-			for _, d := range downloads {
-				if d.ID == id {
-					fmt.Printf("⬇️  Starting: %s\n", d.Title)
-					break
+	startAll := func(batch []*db.Download) ([]downloader.DownloadResult, error) {
+		if useDashboard {
+			return runWithDashboard(ctx, mgr, batch)
+		}
+		return mgr.StartConcurrent(ctx, batch, func(id int64, status string, progress float64) {
+			// --quiet leaves just the summary
+			if quiet {
+				return
+			}
+			switch status {
+			case "starting":
+				// Find download title
+				for _, d := range batch {
+					if d.ID == id {
+						fmt.Fprintf(out, "%sStarting: %s\n", glyph("⬇️"), d.Title)
+						break
+					}
 				}
+			case "completed":
+				fmt.Fprintf(out, "%sCompleted: download #%d\n", glyph("✅"), id)
+			case "paused":
+				fmt.Fprintf(out, "%sPaused: download #%d\n", glyph("⏸️"), id)
+			case "failed":
+				fmt.Fprintf(out, "%sFailed: download #%d\n", glyph("❌"), id)
 			}
-		case "completed":
-			fmt.Printf("✅ Completed: download #%d\n", id)
-		case "failed":
-			fmt.Printf("❌ Failed: download #%d\n", id)
+		}), nil
+	}
+	results, err := startAll(downloads)
+	if err != nil {
+		return err
+	}
+
+	// Downloads whose stored links expired, or whose mirror is down, get a
+	// fresh link and one more try
+	var expired []*db.Download
+	retryIndex := make(map[int64]int)
+	for i, result := range results {
+		if needsNewLink(result.Error) && refreshDownloadURL(ctx, mgr, result.Download, result.Error, out) == nil {
+			expired = append(expired, result.Download)
+			retryIndex[result.Download.ID] = i
 		}
-	})
+	}
+	if len(expired) > 0 {
+		fmt.Fprintf(out, "\nRetrying %d download(s) with fresh links...\n\n", len(expired))
+		retried, err := startAll(expired)
+		if err != nil {
+			return err
+		}
+		for _, result := range retried {
+			results[retryIndex[result.Download.ID]] = result
+		}
+	}
 
 	// Process results
 	for _, result := range results {
+		// Paused from another process; leave it paused for a later resume
+		if result.Error == downloader.ErrPaused {
+			paused++
+			continue
+		}
+
+		if result.Error == nil {
+			if err := verifyCompleted(result.Download); err != nil {
+				result.Error = fmt.Errorf("verification failed: %w", err)
+			}
+		}
+
 		if result.Error != nil {
-			db.UpdateStatus(result.Download.ID, db.StatusFailed, result.Error.Error())
+			db.MarkFailed(result.Download.ID, result.Error.Error())
 			errors = append(errors, fmt.Errorf("download #%d (%s): %w",
 				result.Download.ID, result.Download.Title, result.Error))
 		} else {
 			if err := db.MarkCompleted(result.Download.ID, result.Download.FilePath); err != nil {
 				errors = append(errors, fmt.Errorf("failed to mark #%d complete: %w", result.Download.ID, err))
 			} else {
+				writeMetadataSidecar(result.Download, nil)
 				completed++
 			}
 		}
 	}
 
-	fmt.Println()
-	fmt.Printf("Summary: %d completed, %d failed\n", completed, len(errors))
+	fmt.Fprintln(out)
+	if paused > 0 {
+		fmt.Fprintf(out, "Summary: %d completed, %d paused, %d failed\n", completed, paused, len(errors))
+	} else {
+		fmt.Fprintf(out, "Summary: %d completed, %d failed\n", completed, len(errors))
+	}
 
 	if len(errors) > 0 {
-		fmt.Printf("\nFailed downloads:\n")
+		fmt.Fprintf(out, "\nFailed downloads:\n")
 		for _, err := range errors {
-			fmt.Printf("  - %s\n", err)
+			fmt.Fprintf(out, "  - %s\n", err)
 		}
 	}
 
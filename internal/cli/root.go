@@ -1,20 +1,35 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/billmal071/bookdl/internal/anna"
 	"github.com/billmal071/bookdl/internal/config"
 	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+	"github.com/billmal071/bookdl/internal/log"
+	"github.com/billmal071/bookdl/internal/notify"
 )
 
 var (
-	cfgFile string
-	verbose bool
+	cfgFile   string
+	verbose   bool
+	logLevel  string
+	logFile   string
+	quiet     bool
+	noBrowser bool
 )
 
+// cacheCleanInterval is the minimum time between automatic cache cleanups
+const cacheCleanInterval = time.Hour
+
 var rootCmd = &cobra.Command{
 	Use:   "bookdl",
 	Short: "Download books from Anna's Archive",
@@ -30,51 +45,147 @@ Examples:
   bookdl resume 1                         Resume download #1
   bookdl pause 1                          Pause download #1`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyColorMode(); err != nil {
+			return err
+		}
+		if quiet {
+			downloader.SetProgressBars(false)
+		}
+
 		// Initialize config
 		if err := config.Init(cfgFile); err != nil {
 			return fmt.Errorf("failed to initialize config: %w", err)
 		}
 
+		if err := setupLogging(); err != nil {
+			return err
+		}
+		if noBrowser {
+			anna.DisableBrowser()
+		}
+
 		// Initialize database
 		if err := db.Init(); err != nil {
 			return fmt.Errorf("failed to initialize database: %w", err)
 		}
 
+		// Downloads left 'downloading' by a crash can be resumed again
+		if n, err := db.RecoverStaleDownloads(downloader.StaleAfter); err != nil {
+			Printf("Failed to recover interrupted downloads: %v\n", err)
+		} else if n > 0 {
+			Printf("Marked %d interrupted download(s) as paused\n", n)
+		}
+
+		// Keep the search cache tidy without a DELETE on every search
+		if config.Get().Cache.Enabled {
+			if _, err := db.CleanExpiredCacheIfDue(cacheCleanInterval); err != nil {
+				Printf("Cache cleanup failed: %v\n", err)
+			}
+		}
+
+		autoArchive()
+
 		return nil
 	},
-	PersistentPostRun: func(cmd *cobra.Command, args []string) {
-		anna.CloseBrowser()
-		db.Close()
-	},
 }
 
-// Execute runs the root command
+// Execute runs the root command. The first Ctrl-C or SIGTERM cancels the
+// command's context with downloader.ErrInterrupted, so running downloads
+// save their progress and are paused; a second one exits immediately.
+// Shared resources are released however the command ends, including when
+// it fails.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	defer shutdown()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case <-sigs:
+			signal.Stop(sigs)
+			cancel(downloader.ErrInterrupted)
+		case <-ctx.Done():
+		}
+	}()
+
+	return rootCmd.ExecuteContext(ctx)
+}
+
+// shutdown waits for webhooks, each bounded by
+// notifications.webhook_timeout, and closes the browser, database and log
+func shutdown() {
+	notify.Wait()
+	anna.CloseBrowser()
+	db.Close()
+	log.Close()
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default $HOME/.config/bookdl/config.yaml)")
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output (also sets --log-level debug)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "diagnostic log level: debug, info, warn, error (default warn)")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "write diagnostic logs to this file instead of stderr")
+	rootCmd.PersistentFlags().BoolVar(&noInput, "no-input", false, "never prompt or start the interactive UI (default when stdin isn't a terminal)")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", "auto", "colorize output: auto, always, never (NO_COLOR is honored)")
+	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "no progress bars or per-download status lines; errors and summaries are still shown")
+	rootCmd.PersistentFlags().BoolVar(&noBrowser, "no-browser", false, "never fall back to headless Chrome; fail fast instead (also browser.enabled)")
+	rootCmd.PersistentFlags().DurationVar(&timeoutOverride, "timeout", 0, "time limit for searches and downloads, e.g. 2h (0 for none; default from config)")
 
 	// Add subcommands
 	rootCmd.AddCommand(searchCmd)
 	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(metaCmd)
+	rootCmd.AddCommand(openCmd)
+	rootCmd.AddCommand(moveCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(queueCmd)
 	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(daemonCmd)
 	rootCmd.AddCommand(pauseCmd)
 	rootCmd.AddCommand(restartCmd)
 	rootCmd.AddCommand(verifyCmd)
 	rootCmd.AddCommand(bookmarkCmd)
 	rootCmd.AddCommand(bookmarksCmd)
+	rootCmd.AddCommand(tagCmd)
 	rootCmd.AddCommand(historyCmd)
 	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(dedupeCmd)
+	rootCmd.AddCommand(cleanCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(completionCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
+// setupLogging configures the diagnostic log. An explicit --log-level wins;
+// otherwise --verbose or browser.verbose_logging turn on debug logging.
+func setupLogging() error {
+	level := log.DefaultLevel
+	if verbose || config.Get().Browser.VerboseLogging {
+		level = slog.LevelDebug
+	}
+	if logLevel != "" {
+		parsed, err := log.ParseLevel(logLevel)
+		if err != nil {
+			return err
+		}
+		level = parsed
+	}
+	if err := log.Setup(level, logFile); err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	return nil
+}
+
 // Verbose returns whether verbose mode is enabled
 func Verbose() bool {
 	return verbose
@@ -94,5 +205,5 @@ func Errorf(format string, args ...interface{}) {
 
 // Successf prints a success message
 func Successf(format string, args ...interface{}) {
-	fmt.Printf("✓ "+format+"\n", args...)
+	fmt.Printf(glyph("✓")+format+"\n", args...)
 }
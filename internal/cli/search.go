@@ -3,16 +3,19 @@ package cli
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/billmal071/bookdl/internal/anna"
 	"github.com/billmal071/bookdl/internal/config"
 	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/fuzzy"
 	"github.com/billmal071/bookdl/internal/tui"
 )
 
@@ -32,8 +35,18 @@ Examples:
   bookdl search -l english "machine learning"
   bookdl search --year 2020-2024 "python"
   bookdl search --max-size 10MB "algorithms"
+  bookdl search --author "martin fowler" "refactoring"
+  bookdl search --exact "the art of computer programming"
+  bookdl search --dedupe "dune"            # One entry per book, not per scan
+  bookdl search --new "distributed systems" # Only books not in your library
   bookdl search -d "pragmatic programmer"
+  bookdl search --page 3 "algorithms"      # Jump straight to page 3
+  bookdl search --sort year --sort-desc "rust"  # Newest first
   bookdl search -q "programming books"     # Multi-select to queue
+  bookdl search --json "sicp"              # Results as JSON
+  bookdl search --template '{{.Title}}\t{{.MD5Hash}}\t{{.Format}}' "sicp"  # TSV
+  bookdl search --no-cache "golang"        # Fresh results, bypassing the cache
+  bookdl search --cache-only "golang"      # Offline: cached results or nothing
   bookdl search --history                  # Show search history`,
 	Args: cobra.ArbitraryArgs,
 	RunE: runSearch,
@@ -41,22 +54,44 @@ Examples:
 
 // filterOptions holds all search filter settings
 type filterOptions struct {
-	format   string
-	language string
-	year     string
-	maxSize  string
+	format    string
+	language  string
+	year      string
+	maxSize   string
+	author    string
+	publisher string
+	prefer    bool   // keep one edition per title, in the most preferred format
+	exact     string // phrase that titles must contain (--exact)
+	dedupe    bool   // merge near-identical titles by the same author (--dedupe)
+	onlyNew   bool   // hide books already downloaded, queued or bookmarked (--new)
 }
 
 func init() {
 	searchCmd.Flags().IntP("limit", "n", 5, "number of results to show")
+	searchCmd.Flags().Int("page", 1, "fetch this page of results directly")
 	searchCmd.Flags().StringP("format", "f", "", "filter by format (epub, pdf, mobi, djvu)")
-	searchCmd.Flags().StringP("language", "l", "", "filter by language (english, spanish, etc.)")
+	searchCmd.Flags().StringP("language", "l", "", "filter by language name or ISO code (english, de, etc.)")
 	searchCmd.Flags().String("year", "", "filter by year (2020) or year range (2020-2024)")
 	searchCmd.Flags().String("max-size", "", "filter by maximum file size (e.g., 10MB, 1GB)")
+	searchCmd.Flags().String("author", "", "filter by author (case-insensitive substring; commas separate alternatives)")
+	searchCmd.Flags().String("publisher", "", "filter by publisher (case-insensitive substring)")
+	searchCmd.Flags().Bool("exact", false, "match the query as a phrase; titles must contain it")
+	searchCmd.Flags().Bool("prefer", false, "show each title once, in the best format from files.preferred_formats")
+	searchCmd.Flags().Bool("dedupe", false, "merge near-identical titles by the same author into one result (best format, then largest)")
+	searchCmd.Flags().Bool("new", false, "hide books already downloaded, queued or bookmarked")
+	searchCmd.Flags().String("sort", "", "sort results by size, year, title or format")
+	searchCmd.Flags().Bool("sort-desc", false, "sort in descending order")
 	searchCmd.Flags().BoolP("download", "d", false, "immediately download selected book")
 	searchCmd.Flags().BoolP("queue", "q", false, "multi-select mode: add multiple books to download queue")
 	searchCmd.Flags().Bool("no-interactive", false, "disable interactive mode, just print results")
+	searchCmd.Flags().String("template", "", "print each result with this Go template (implies --no-interactive)")
+	searchCmd.Flags().Bool("json", false, "print results as JSON (implies --no-interactive)")
 	searchCmd.Flags().Bool("history", false, "show search history")
+	searchCmd.Flags().Bool("no-cache", false, "don't read or write the search cache; always search online")
+	searchCmd.Flags().Bool("cache-only", false, "only show cached results; never search online")
+
+	// The selector's s/r keys sort with the same rules as --sort
+	tui.SetBookSorter(sortBooks)
 }
 
 func runSearch(cmd *cobra.Command, args []string) error {
@@ -77,13 +112,47 @@ func runSearch(cmd *cobra.Command, args []string) error {
 	autoDownload, _ := cmd.Flags().GetBool("download")
 	queueMode, _ := cmd.Flags().GetBool("queue")
 	noInteractive, _ := cmd.Flags().GetBool("no-interactive")
+	page, _ := cmd.Flags().GetInt("page")
+	if page < 1 {
+		return fmt.Errorf("--page must be 1 or greater")
+	}
+	order, err := resultOrder(cmd)
+	if err != nil {
+		return err
+	}
+	cache, err := searchCacheFlags(cmd)
+	if err != nil {
+		return err
+	}
+	asJSON, _ := cmd.Flags().GetBool("json")
+	templateText := getString(cmd, "template")
+	if asJSON && templateText != "" {
+		return fmt.Errorf("--json and --template cannot be used together")
+	}
+	bookTemplate, err := parseBookTemplate(templateText)
+	if err != nil {
+		return err
+	}
+	// Scripted output: only the results themselves go to stdout
+	scripted := asJSON || templateText != ""
+	if scripted {
+		noInteractive = true
+	}
 
 	// Collect filter options
 	filters := filterOptions{
-		format:   getString(cmd, "format"),
-		language: getString(cmd, "language"),
-		year:     getString(cmd, "year"),
-		maxSize:  getString(cmd, "max-size"),
+		format:    getString(cmd, "format"),
+		language:  getString(cmd, "language"),
+		year:      getString(cmd, "year"),
+		maxSize:   getString(cmd, "max-size"),
+		author:    getString(cmd, "author"),
+		publisher: getString(cmd, "publisher"),
+	}
+	filters.prefer, _ = cmd.Flags().GetBool("prefer")
+	filters.dedupe, _ = cmd.Flags().GetBool("dedupe")
+	filters.onlyNew, _ = cmd.Flags().GetBool("new")
+	if exact, _ := cmd.Flags().GetBool("exact"); exact {
+		filters.exact = query
 	}
 
 	// Show search info with active filters
@@ -95,7 +164,7 @@ func runSearch(cmd *cobra.Command, args []string) error {
 	// Create client and search
 	client := anna.NewClient()
 
-	ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
+	ctx, cancel := withTimeout(cmd.Context(), searchTimeout())
 	defer cancel()
 
 	// Get extra results for filtering (more if filters are active)
@@ -110,47 +179,40 @@ func runSearch(cmd *cobra.Command, args []string) error {
 	var books []*anna.Book
 
 	// Try to get from cache if enabled
-	cfg := config.Get()
-	if cfg.Cache.Enabled {
-		filterMap := filters.toMap()
-		cacheKey := db.GenerateCacheKey(query, filterMap)
-
-		cached, err := db.GetCachedSearch(cacheKey)
-		if err == nil && cached != nil {
-			// Cache hit
-			if err := json.Unmarshal([]byte(cached.ResultsJSON), &books); err == nil {
-				Printf("Using cached results (%d found)\n", len(books))
-			} else {
-				// Cache corrupted, fetch fresh
-				books = nil
-			}
-		}
-
-		// Clean expired cache entries periodically
-		go db.CleanExpiredCache()
+	filterMap := filters.toMap()
+	if page > 1 {
+		filterMap["page"] = strconv.Itoa(page)
 	}
+	books = cache.load(query, filterMap)
 
 	// If not in cache, fetch from API
 	if books == nil {
+		if cache.only {
+			if asJSON {
+				return printBooksJSON(nil)
+			}
+			fmt.Fprintln(os.Stderr, "Not cached; search without --cache-only to fetch results.")
+			return nil
+		}
+
 		var err error
-		books, err = client.Search(ctx, query, searchLimit)
+		if page > 1 {
+			books, err = client.SearchPage(ctx, filters.searchQuery(query), searchLimit, page)
+		} else {
+			books, err = client.Search(ctx, filters.searchQuery(query), searchLimit)
+		}
 		if err != nil {
 			return fmt.Errorf("search failed: %w", err)
 		}
-
-		// Save to cache if enabled
-		if cfg.Cache.Enabled {
-			filterMap := filters.toMap()
-			cacheKey := db.GenerateCacheKey(query, filterMap)
-			if resultsJSON, err := json.Marshal(books); err == nil {
-				filtersJSON, _ := json.Marshal(filterMap)
-				db.SaveCachedSearch(cacheKey, query, string(filtersJSON), string(resultsJSON), len(books), cfg.Cache.TTL)
-			}
-		}
+		cache.save(query, filterMap, books)
 	}
 
 	// Apply all filters
 	books = applyFilters(books, filters)
+	books, hidden := hideKnown(books, filters)
+	if hidden > 0 && !scripted {
+		fmt.Printf("Hid %d book(s) already in your library.\n", hidden)
+	}
 
 	// Limit results
 	if len(books) > limit {
@@ -158,10 +220,20 @@ func runSearch(cmd *cobra.Command, args []string) error {
 	}
 
 	if len(books) == 0 {
-		fmt.Println("No books found matching your query.")
+		if asJSON {
+			return printBooksJSON(books)
+		}
+		if !scripted {
+			fmt.Println("No books found matching your query.")
+		}
 		return nil
 	}
 
+	if order != nil {
+		order(books)
+		tui.SetResultOrder(order)
+	}
+
 	Printf("Found %d result(s)\n\n", len(books))
 
 	// Save search to history
@@ -169,24 +241,34 @@ func runSearch(cmd *cobra.Command, args []string) error {
 
 	// Non-interactive mode: just print results
 	if noInteractive {
-		printBooks(books)
-		return nil
+		if asJSON {
+			return printBooksJSON(books)
+		}
+		return writeBooks(os.Stdout, books, bookTemplate)
+	}
+
+	if !interactive() {
+		return selectWithoutInput(cmd.Context(), books, autoDownload, queueMode)
 	}
 
 	// Create load more function for pagination
-	currentPage := 1
+	currentPage := page
 	loadMore := func() ([]*anna.Book, error) {
+		if cache.only {
+			return nil, errCacheOnly
+		}
 		currentPage++
-		newCtx, newCancel := context.WithTimeout(cmd.Context(), 60*time.Second)
+		newCtx, newCancel := withTimeout(cmd.Context(), searchTimeout())
 		defer newCancel()
 
-		moreBooks, err := client.SearchPage(newCtx, query, searchLimit, currentPage)
+		moreBooks, err := client.SearchPage(newCtx, filters.searchQuery(query), searchLimit, currentPage)
 		if err != nil {
 			return nil, err
 		}
 
 		// Apply all filters
 		moreBooks = applyFilters(moreBooks, filters)
+		moreBooks, _ = hideKnown(moreBooks, filters)
 
 		// Limit results
 		if len(moreBooks) > limit {
@@ -252,6 +334,68 @@ func runSearch(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// searchCache is how one search uses the result cache: cache.enabled,
+// unless --no-cache or --cache-only say otherwise
+type searchCache struct {
+	enabled bool // read and write cached results
+	only    bool // --cache-only: never search online
+}
+
+// errCacheOnly is returned instead of loading more results with --cache-only
+var errCacheOnly = errors.New("more results aren't cached (--cache-only)")
+
+// searchCacheFlags resolves the cache mode from --no-cache/--cache-only
+// and cache.enabled
+func searchCacheFlags(cmd *cobra.Command) (searchCache, error) {
+	noCache, _ := cmd.Flags().GetBool("no-cache")
+	cacheOnly, _ := cmd.Flags().GetBool("cache-only")
+	switch {
+	case noCache && cacheOnly:
+		return searchCache{}, fmt.Errorf("--no-cache and --cache-only cannot be used together")
+	case noCache:
+		return searchCache{}, nil
+	case cacheOnly:
+		return searchCache{enabled: true, only: true}, nil
+	}
+	return searchCache{enabled: config.Get().Cache.Enabled}, nil
+}
+
+// load returns the cached results for query and filters, or nil if there
+// are none (or they can't be read)
+func (c searchCache) load(query string, filterMap map[string]string) []*anna.Book {
+	if !c.enabled {
+		return nil
+	}
+	cached, err := db.GetCachedSearch(db.GenerateCacheKey(query, filterMap))
+	if err != nil || cached == nil {
+		return nil
+	}
+	var books []*anna.Book
+	if err := json.Unmarshal([]byte(cached.ResultsJSON), &books); err != nil {
+		// Cache corrupted, fetch fresh
+		return nil
+	}
+	Printf("Using cached results (%d found)\n", len(books))
+	return books
+}
+
+// save caches the results of a search, evicting the oldest entries beyond
+// cache.max_entries
+func (c searchCache) save(query string, filterMap map[string]string, books []*anna.Book) {
+	if !c.enabled {
+		return
+	}
+	cfg := config.Get()
+	resultsJSON, err := json.Marshal(books)
+	if err != nil {
+		return
+	}
+	filtersJSON, _ := json.Marshal(filterMap)
+	if db.SaveCachedSearch(db.GenerateCacheKey(query, filterMap), query, string(filtersJSON), string(resultsJSON), len(books), cfg.Cache.TTL) == nil {
+		db.EvictOldestCache(cfg.Cache.MaxEntries)
+	}
+}
+
 // addToQueue adds a book to the download queue as a pending download
 func addToQueue(book *anna.Book) error {
 	// Check if already in queue
@@ -289,7 +433,17 @@ func getString(cmd *cobra.Command, name string) string {
 
 // hasAny returns true if any filter is set
 func (f filterOptions) hasAny() bool {
-	return f.format != "" || f.language != "" || f.year != "" || f.maxSize != ""
+	return f.format != "" || f.language != "" || f.year != "" || f.maxSize != "" ||
+		f.author != "" || f.publisher != "" || f.prefer || f.exact != "" || f.dedupe || f.onlyNew
+}
+
+// searchQuery returns the query to send to Anna's Archive: quoted as a
+// phrase with --exact, unchanged otherwise
+func (f filterOptions) searchQuery(query string) string {
+	if f.exact != "" {
+		return anna.PhraseQuery(query)
+	}
+	return query
 }
 
 // String returns a human-readable representation of active filters
@@ -307,6 +461,24 @@ func (f filterOptions) String() string {
 	if f.maxSize != "" {
 		parts = append(parts, fmt.Sprintf("max-size=%s", f.maxSize))
 	}
+	if f.author != "" {
+		parts = append(parts, fmt.Sprintf("author=%s", f.author))
+	}
+	if f.publisher != "" {
+		parts = append(parts, fmt.Sprintf("publisher=%s", f.publisher))
+	}
+	if f.prefer {
+		parts = append(parts, "prefer="+strings.Join(config.Get().Files.PreferredFormats, ","))
+	}
+	if f.exact != "" {
+		parts = append(parts, fmt.Sprintf("exact=%q", f.exact))
+	}
+	if f.dedupe {
+		parts = append(parts, "dedupe")
+	}
+	if f.onlyNew {
+		parts = append(parts, "new")
+	}
 	return strings.Join(parts, ", ")
 }
 
@@ -325,6 +497,15 @@ func (f filterOptions) toMap() map[string]string {
 	if f.maxSize != "" {
 		m["max-size"] = f.maxSize
 	}
+	if f.author != "" {
+		m["author"] = strings.ToLower(f.author)
+	}
+	if f.publisher != "" {
+		m["publisher"] = strings.ToLower(f.publisher)
+	}
+	if f.exact != "" {
+		m["exact"] = "true"
+	}
 	return m
 }
 
@@ -348,11 +529,146 @@ func applyFilters(books []*anna.Book, filters filterOptions) []*anna.Book {
 		if filters.maxSize != "" && !matchesMaxSize(book, filters.maxSize) {
 			continue
 		}
+		if filters.author != "" && !matchesAuthor(book, filters.author) {
+			continue
+		}
+		if filters.publisher != "" && !matchesPublisher(book, filters.publisher) {
+			continue
+		}
+		if filters.exact != "" && !matchesPhrase(book, filters.exact) {
+			continue
+		}
 		filtered = append(filtered, book)
 	}
+	if filters.prefer {
+		filtered = selectPreferredFormat(filtered, config.Get().Files.PreferredFormats)
+	}
+	if filters.dedupe {
+		filtered = collapseEditions(filtered, config.Get().Files.PreferredFormats)
+	}
 	return filtered
 }
 
+// hideKnown drops, with --new, the books already downloaded, queued or
+// bookmarked, and returns how many it dropped. It runs after applyFilters,
+// on the results rather than the cached search, since the library changes.
+func hideKnown(books []*anna.Book, filters filterOptions) ([]*anna.Book, int) {
+	if !filters.onlyNew || len(books) == 0 {
+		return books, 0
+	}
+
+	hashes := make([]string, len(books))
+	for i, book := range books {
+		hashes[i] = book.MD5Hash
+	}
+	known, err := db.ExistingHashes(hashes)
+	if err != nil {
+		Errorf("couldn't check your library for --new: %v", err)
+		return books, 0
+	}
+
+	var kept []*anna.Book
+	for _, book := range books {
+		if !known[book.MD5Hash] {
+			kept = append(kept, book)
+		}
+	}
+	return kept, len(books) - len(kept)
+}
+
+// Thresholds for collapseEditions: titles must be nearly identical, while
+// author lists only need most of the shorter one's words ("Tolkien, J.R.R."
+// vs "J. R. R. Tolkien, Christopher Tolkien")
+const (
+	editionTitleSimilarity = 0.9
+	editionAuthorOverlap   = 0.75
+)
+
+// collapseEditions merges results that look like the same book under
+// different MD5s (other scans, uploads or formats): near-identical titles,
+// without subtitles, by the same authors. Each group keeps its copy in the
+// format that comes first in prefs, the largest on a tie, with Editions
+// set to the group's size. Groups stay in the order they first appeared.
+func collapseEditions(books []*anna.Book, prefs []string) []*anna.Book {
+	better := func(a, b *anna.Book) bool {
+		if ra, rb := formatRank(a.Format, prefs), formatRank(b.Format, prefs); ra != rb {
+			return ra < rb
+		}
+		return a.SizeBytes > b.SizeBytes
+	}
+
+	type group struct {
+		title, author string
+		best          *anna.Book
+		count         int
+	}
+	var groups []*group
+	for _, book := range books {
+		title := fuzzy.NormalizeTitle(book.Title)
+		author := fuzzy.Normalize(book.Authors)
+
+		var match *group
+		for _, g := range groups {
+			if title == "" || fuzzy.Similarity(title, g.title) < editionTitleSimilarity {
+				continue
+			}
+			// An unknown author doesn't rule out a match
+			if author != "" && g.author != "" && fuzzy.Overlap(author, g.author) < editionAuthorOverlap {
+				continue
+			}
+			match = g
+			break
+		}
+		if match == nil {
+			groups = append(groups, &group{title: title, author: author, best: book, count: 1})
+			continue
+		}
+
+		match.count++
+		if match.author == "" {
+			match.author = author
+		}
+		if better(book, match.best) {
+			match.best = book
+		}
+	}
+
+	collapsed := make([]*anna.Book, 0, len(groups))
+	for _, g := range groups {
+		// Copy, so cached results keep their own counts
+		book := *g.best
+		book.Editions = g.count
+		collapsed = append(collapsed, &book)
+	}
+	return collapsed
+}
+
+// selectPreferredFormat keeps one book per title (and first author): the
+// one whose format comes first in prefs, or the first listed on a tie.
+// Groups stay in the order their first book appeared. With -f every book
+// already has the same format, so this only drops same-format duplicates.
+func selectPreferredFormat(books []*anna.Book, prefs []string) []*anna.Book {
+	best := make(map[string]int) // key -> index into picked
+	var picked []*anna.Book
+	for _, book := range books {
+		key := db.DuplicateKey(book.Title, book.Authors)
+		if key == "" {
+			picked = append(picked, book)
+			continue
+		}
+		i, seen := best[key]
+		if !seen {
+			best[key] = len(picked)
+			picked = append(picked, book)
+			continue
+		}
+		if formatRank(book.Format, prefs) < formatRank(picked[i].Format, prefs) {
+			picked[i] = book
+		}
+	}
+	return picked
+}
+
 // matchesFormat checks if a book matches the format filter
 func matchesFormat(book *anna.Book, format string) bool {
 	return strings.EqualFold(book.Format, format)
@@ -360,7 +676,35 @@ func matchesFormat(book *anna.Book, format string) bool {
 
 // matchesLanguage checks if a book matches the language filter
 func matchesLanguage(book *anna.Book, language string) bool {
-	return strings.EqualFold(book.Language, language)
+	return strings.EqualFold(anna.NormalizeLanguage(book.Language), anna.NormalizeLanguage(language))
+}
+
+// matchesAuthor checks if any of the comma-separated authors in the filter
+// appears in the book's authors, ignoring case
+func matchesAuthor(book *anna.Book, authorFilter string) bool {
+	authors := strings.ToLower(book.Authors)
+	for _, author := range strings.Split(authorFilter, ",") {
+		author = strings.ToLower(strings.TrimSpace(author))
+		if author != "" && strings.Contains(authors, author) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPublisher checks if the book's publisher contains the filter,
+// ignoring case
+func matchesPublisher(book *anna.Book, publisher string) bool {
+	return strings.Contains(strings.ToLower(book.Publisher), strings.ToLower(strings.TrimSpace(publisher)))
+}
+
+// matchesPhrase checks if a book's title contains the phrase, ignoring case,
+// quotes and runs of whitespace
+func matchesPhrase(book *anna.Book, phrase string) bool {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(s, `"`, ""))), " ")
+	}
+	return strings.Contains(normalize(book.Title), normalize(phrase))
 }
 
 // matchesYear checks if a book matches the year filter
@@ -409,13 +753,92 @@ func extractYear(s string) int {
 	return year
 }
 
+// Search result sort keys (--sort)
+var sortKeys = []string{"size", "year", "title", "format"}
+
+// resultOrder builds the sort from --sort/--sort-desc, or nil if unsorted
+func resultOrder(cmd *cobra.Command) (func([]*anna.Book), error) {
+	key := strings.ToLower(getString(cmd, "sort"))
+	if key == "" {
+		return nil, nil
+	}
+	valid := false
+	for _, k := range sortKeys {
+		if key == k {
+			valid = true
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid --sort %q (use %s)", key, strings.Join(sortKeys, ", "))
+	}
+	desc, _ := cmd.Flags().GetBool("sort-desc")
+	return func(books []*anna.Book) {
+		sortBooks(books, key, desc)
+	}, nil
+}
+
+// sortBooks sorts books in place by key (size, year, title or format).
+// Books with an unknown size or year go last; ties fall back to title.
+func sortBooks(books []*anna.Book, key string, desc bool) {
+	title := func(b *anna.Book) string { return strings.ToLower(b.Title) }
+	size := func(b *anna.Book) int64 {
+		if b.SizeBytes > 0 {
+			return b.SizeBytes
+		}
+		return anna.ParseSizeToBytes(b.Size)
+	}
+
+	sort.SliceStable(books, func(i, j int) bool {
+		a, b := books[i], books[j]
+
+		var cmp int
+		switch key {
+		case "size":
+			cmp = compareKnown(size(a), size(b), desc)
+		case "year":
+			cmp = compareKnown(int64(extractYear(a.Year)), int64(extractYear(b.Year)), desc)
+		case "format":
+			cmp = strings.Compare(strings.ToLower(a.Format), strings.ToLower(b.Format))
+			if desc {
+				cmp = -cmp
+			}
+		}
+		if cmp != 0 {
+			return cmp < 0
+		}
+
+		cmp = strings.Compare(title(a), title(b))
+		if key == "title" && desc {
+			cmp = -cmp
+		}
+		return cmp < 0
+	})
+}
+
+// compareKnown compares two values where 0 means unknown; unknown values
+// always sort after known ones
+func compareKnown(a, b int64, desc bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a == 0:
+		return 1
+	case b == 0:
+		return -1
+	case (a < b) != desc:
+		return -1
+	default:
+		return 1
+	}
+}
+
 // matchesMaxSize checks if a book is within the max size limit
 func matchesMaxSize(book *anna.Book, maxSize string) bool {
 	if book.Size == "" && book.SizeBytes == 0 {
 		return true // Allow books with unknown size
 	}
 
-	maxBytes := parseSize(maxSize)
+	maxBytes := anna.ParseSizeToBytes(maxSize)
 	if maxBytes == 0 {
 		return true // Invalid max size, don't filter
 	}
@@ -425,7 +848,7 @@ func matchesMaxSize(book *anna.Book, maxSize string) bool {
 	if book.SizeBytes > 0 {
 		bookBytes = book.SizeBytes
 	} else {
-		bookBytes = parseSize(book.Size)
+		bookBytes = anna.ParseSizeToBytes(book.Size)
 	}
 
 	if bookBytes == 0 {
@@ -435,58 +858,36 @@ func matchesMaxSize(book *anna.Book, maxSize string) bool {
 	return bookBytes <= maxBytes
 }
 
-// parseSize parses a size string like "10MB" or "1.5 GB" to bytes
-func parseSize(s string) int64 {
-	s = strings.TrimSpace(strings.ToUpper(s))
-	if s == "" {
-		return 0
-	}
-
-	re := regexp.MustCompile(`^(\d+\.?\d*)\s*(B|KB|MB|GB|TB)?$`)
-	match := re.FindStringSubmatch(s)
-	if len(match) < 2 {
-		return 0
-	}
-
-	value, err := strconv.ParseFloat(match[1], 64)
-	if err != nil {
-		return 0
-	}
-
-	unit := "B"
-	if len(match) >= 3 && match[2] != "" {
-		unit = match[2]
-	}
-
-	multipliers := map[string]float64{
-		"B":  1,
-		"KB": 1024,
-		"MB": 1024 * 1024,
-		"GB": 1024 * 1024 * 1024,
-		"TB": 1024 * 1024 * 1024 * 1024,
+// selectWithoutInput stands in for the selector when no TUI can be shown:
+// -d downloads the top result, -q queues every listed result, and
+// otherwise the results are printed
+func selectWithoutInput(ctx context.Context, books []*anna.Book, autoDownload, queueMode bool) error {
+	switch {
+	case autoDownload:
+		return startBookDownload(ctx, books[0])
+	case queueMode:
+		added := 0
+		for _, book := range books {
+			if err := addToQueue(book); err != nil {
+				Errorf("failed to queue %s: %v", book.Title, err)
+				continue
+			}
+			added++
+			fmt.Printf("Queued: %s\n", book.Title)
+		}
+		if added > 0 {
+			Successf("Added %d book(s) to the download queue.", added)
+		}
+		return nil
 	}
-
-	return int64(value * multipliers[unit])
+	printBooks(books)
+	return nil
 }
 
-// printBooks prints books in a simple format
+// printBooks prints books in the default --no-interactive format
 func printBooks(books []*anna.Book) {
-	for i, book := range books {
-		fmt.Printf("%d. %s\n", i+1, book.Title)
-		if book.Authors != "" {
-			fmt.Printf("   Author: %s\n", book.Authors)
-		}
-		fmt.Printf("   Format: %s", book.Format)
-		if book.Size != "" {
-			fmt.Printf(" | Size: %s", book.Size)
-		}
-		if book.Language != "" {
-			fmt.Printf(" | Language: %s", book.Language)
-		}
-		fmt.Println()
-		fmt.Printf("   MD5: %s\n", book.MD5Hash)
-		fmt.Println()
-	}
+	t, _ := parseBookTemplate("")
+	writeBooks(os.Stdout, books, t)
 }
 
 // startBookDownload initiates a download for the selected book
@@ -500,10 +901,13 @@ func startBookDownload(ctx context.Context, book *anna.Book) error {
 // saveSearchHistory saves a search to the history database
 func saveSearchHistory(query string, resultCount int, filters filterOptions) {
 	dbFilters := db.SearchFilters{
-		Format:   filters.format,
-		Language: filters.language,
-		Year:     filters.year,
-		MaxSize:  filters.maxSize,
+		Format:    filters.format,
+		Language:  filters.language,
+		Year:      filters.year,
+		MaxSize:   filters.maxSize,
+		Author:    filters.author,
+		Publisher: filters.publisher,
+		Exact:     filters.exact != "",
 	}
 	// Ignore errors - history is not critical
 	db.AddSearchHistory(query, resultCount, dbFilters)
@@ -522,6 +926,10 @@ func showSearchHistoryInteractive(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if !interactive() {
+		return showSearchHistory()
+	}
+
 	// Use interactive selector
 	selected, err := tui.RunHistorySelector(history)
 	if err != nil {
@@ -538,11 +946,12 @@ func showSearchHistoryInteractive(cmd *cobra.Command, args []string) error {
 	Printf("Running search: %s\n", selected.Query)
 
 	// Reconstruct the filter options from the selected history
-	filters := filterOptions{
-		format:   selected.Filters.Format,
-		language: selected.Filters.Language,
-		year:     selected.Filters.Year,
-		maxSize:  selected.Filters.MaxSize,
+	filters := historyFilters(selected)
+	filters.prefer, _ = cmd.Flags().GetBool("prefer")
+	filters.dedupe, _ = cmd.Flags().GetBool("dedupe")
+	filters.onlyNew, _ = cmd.Flags().GetBool("new")
+	if exact, _ := cmd.Flags().GetBool("exact"); exact {
+		filters.exact = selected.Query
 	}
 
 	if filters.hasAny() {
@@ -553,11 +962,19 @@ func showSearchHistoryInteractive(cmd *cobra.Command, args []string) error {
 	limit, _ := cmd.Flags().GetInt("limit")
 	autoDownload, _ := cmd.Flags().GetBool("download")
 	queueMode, _ := cmd.Flags().GetBool("queue")
+	order, err := resultOrder(cmd)
+	if err != nil {
+		return err
+	}
+	cache, err := searchCacheFlags(cmd)
+	if err != nil {
+		return err
+	}
 
 	// Create client and search
 	client := anna.NewClient()
 
-	ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
+	ctx, cancel := withTimeout(cmd.Context(), searchTimeout())
 	defer cancel()
 
 	// Get extra results for filtering
@@ -572,43 +989,28 @@ func showSearchHistoryInteractive(cmd *cobra.Command, args []string) error {
 	var books []*anna.Book
 
 	// Try to get from cache if enabled
-	cfg := config.Get()
-	if cfg.Cache.Enabled {
-		filterMap := filters.toMap()
-		cacheKey := db.GenerateCacheKey(selected.Query, filterMap)
-
-		cached, err := db.GetCachedSearch(cacheKey)
-		if err == nil && cached != nil {
-			if err := json.Unmarshal([]byte(cached.ResultsJSON), &books); err == nil {
-				Printf("Using cached results (%d found)\n", len(books))
-			} else {
-				books = nil
-			}
-		}
-
-		go db.CleanExpiredCache()
-	}
+	books = cache.load(selected.Query, filters.toMap())
 
 	// If not in cache, fetch from API
 	if books == nil {
-		books, err = client.Search(ctx, selected.Query, searchLimit)
-		if err != nil {
-			return fmt.Errorf("search failed: %w", err)
+		if cache.only {
+			fmt.Println("Not cached; search without --cache-only to fetch results.")
+			return nil
 		}
 
-		// Save to cache if enabled
-		if cfg.Cache.Enabled {
-			filterMap := filters.toMap()
-			cacheKey := db.GenerateCacheKey(selected.Query, filterMap)
-			if resultsJSON, err := json.Marshal(books); err == nil {
-				filtersJSON, _ := json.Marshal(filterMap)
-				db.SaveCachedSearch(cacheKey, selected.Query, string(filtersJSON), string(resultsJSON), len(books), cfg.Cache.TTL)
-			}
+		books, err = client.Search(ctx, filters.searchQuery(selected.Query), searchLimit)
+		if err != nil {
+			return fmt.Errorf("search failed: %w", err)
 		}
+		cache.save(selected.Query, filters.toMap(), books)
 	}
 
 	// Apply all filters
 	books = applyFilters(books, filters)
+	books, hidden := hideKnown(books, filters)
+	if hidden > 0 {
+		fmt.Printf("Hid %d book(s) already in your library.\n", hidden)
+	}
 
 	// Limit results
 	if len(books) > limit {
@@ -620,21 +1022,34 @@ func showSearchHistoryInteractive(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if order != nil {
+		order(books)
+		tui.SetResultOrder(order)
+	}
+
 	Printf("Found %d result(s)\n\n", len(books))
 
+	if !interactive() {
+		return selectWithoutInput(cmd.Context(), books, autoDownload, queueMode)
+	}
+
 	// Create load more function for pagination
 	currentPage := 1
 	loadMore := func() ([]*anna.Book, error) {
+		if cache.only {
+			return nil, errCacheOnly
+		}
 		currentPage++
-		newCtx, newCancel := context.WithTimeout(cmd.Context(), 60*time.Second)
+		newCtx, newCancel := withTimeout(cmd.Context(), searchTimeout())
 		defer newCancel()
 
-		moreBooks, err := client.SearchPage(newCtx, selected.Query, searchLimit, currentPage)
+		moreBooks, err := client.SearchPage(newCtx, filters.searchQuery(selected.Query), searchLimit, currentPage)
 		if err != nil {
 			return nil, err
 		}
 
 		moreBooks = applyFilters(moreBooks, filters)
+		moreBooks, _ = hideKnown(moreBooks, filters)
 
 		if len(moreBooks) > limit {
 			moreBooks = moreBooks[:limit]
@@ -697,6 +1112,22 @@ func showSearchHistoryInteractive(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// historyFilters rebuilds the filter options a past search used
+func historyFilters(h *db.SearchHistory) filterOptions {
+	filters := filterOptions{
+		format:    h.Filters.Format,
+		language:  h.Filters.Language,
+		year:      h.Filters.Year,
+		maxSize:   h.Filters.MaxSize,
+		author:    h.Filters.Author,
+		publisher: h.Filters.Publisher,
+	}
+	if h.Filters.Exact {
+		filters.exact = h.Query
+	}
+	return filters
+}
+
 // showSearchHistory displays recent search history (non-interactive)
 func showSearchHistory() error {
 	history, err := db.GetUniqueSearchHistory(20)
@@ -711,35 +1142,9 @@ func showSearchHistory() error {
 	}
 
 	fmt.Printf("Recent Searches (%d):\n\n", len(history))
+	printHistory(history)
 
-	for i, h := range history {
-		// Query and result count
-		fmt.Printf("  %d. \"%s\" (%d results)\n", i+1, h.Query, h.ResultCount)
-
-		// Show filters if any were used
-		var filterParts []string
-		if h.Filters.Format != "" {
-			filterParts = append(filterParts, "format="+h.Filters.Format)
-		}
-		if h.Filters.Language != "" {
-			filterParts = append(filterParts, "language="+h.Filters.Language)
-		}
-		if h.Filters.Year != "" {
-			filterParts = append(filterParts, "year="+h.Filters.Year)
-		}
-		if h.Filters.MaxSize != "" {
-			filterParts = append(filterParts, "max-size="+h.Filters.MaxSize)
-		}
-		if len(filterParts) > 0 {
-			fmt.Printf("     Filters: %s\n", strings.Join(filterParts, ", "))
-		}
-
-		// Date
-		fmt.Printf("     %s\n", h.CreatedAt.Format("2006-01-02 15:04"))
-		fmt.Println()
-	}
-
-	fmt.Println("To repeat a search, copy the query above.")
+	fmt.Println("To repeat a search: bookdl history select")
 	fmt.Println("To clear history: bookdl history clear")
 	return nil
 }
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/billmal071/bookdl/internal/anna"
+)
+
+// defaultBookTemplate is the --no-interactive output when --template isn't given
+const defaultBookTemplate = `{{.Index}}. {{.Title}}
+{{if .Authors}}   Author: {{.Authors}}
+{{end}}   Format: {{.Format}}{{if .Size}} | Size: {{.Size}}{{end}}{{if .Language}} | Language: {{.Language}}{{end}}{{if gt .Editions 1}} | ({{.Editions}} editions){{end}}
+   MD5: {{.MD5Hash}}
+
+`
+
+// bookRow is what a --template is executed with: the book's own fields
+// plus its 1-based position in the results
+type bookRow struct {
+	*anna.Book
+	Index int
+}
+
+// templateEscapes expands the escapes users type in shell-quoted templates,
+// so '{{.Title}}\t{{.MD5Hash}}' prints a real tab
+var templateEscapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n")
+
+// parseBookTemplate parses a --template value. Each result is printed on
+// its own line unless the template ends in a newline itself.
+func parseBookTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultBookTemplate
+	} else {
+		text = templateEscapes.Replace(text)
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+	}
+	t, err := template.New("book").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --template: %w", err)
+	}
+	return t, nil
+}
+
+// writeBooks executes t once per book
+func writeBooks(w io.Writer, books []*anna.Book, t *template.Template) error {
+	for i, book := range books {
+		if err := t.Execute(w, bookRow{Book: book, Index: i + 1}); err != nil {
+			return fmt.Errorf("invalid --template: %w", err)
+		}
+	}
+	return nil
+}
+
+// printBooksJSON prints the results as a JSON array, empty rather than
+// null when nothing matched
+func printBooksJSON(books []*anna.Book) error {
+	if books == nil {
+		books = []*anna.Book{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(books)
+}
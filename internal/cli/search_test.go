@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/billmal071/bookdl/internal/anna"
+)
+
+func TestSelectPreferredFormat(t *testing.T) {
+	books := []*anna.Book{
+		{MD5Hash: "a", Title: "Dune", Authors: "Frank Herbert", Format: "pdf"},
+		{MD5Hash: "b", Title: "Emma", Authors: "Jane Austen", Format: "mobi"},
+		{MD5Hash: "c", Title: "Dune", Authors: "Frank Herbert", Format: "EPUB"},
+		{MD5Hash: "d", Title: "Emma", Authors: "Jane Austen", Format: "djvu"},
+	}
+
+	got := selectPreferredFormat(books, []string{"epub", "pdf"})
+	if len(got) != 2 {
+		t.Fatalf("got %d books, want 2", len(got))
+	}
+	// Dune's epub replaces the pdf in its place; neither Emma is preferred,
+	// so the first one stays
+	if got[0].MD5Hash != "c" || got[1].MD5Hash != "b" {
+		t.Errorf("got %s, %s; want c, b", got[0].MD5Hash, got[1].MD5Hash)
+	}
+}
+
+func TestCollapseEditions(t *testing.T) {
+	books := []*anna.Book{
+		{MD5Hash: "a", Title: "Dune", Authors: "Frank Herbert", Format: "pdf", SizeBytes: 10},
+		{MD5Hash: "b", Title: "Dune: Deluxe Edition", Authors: "Herbert, Frank", Format: "epub", SizeBytes: 5},
+		{MD5Hash: "c", Title: "Dune", Authors: "Frank Herbert", Format: "epub", SizeBytes: 8},
+		{MD5Hash: "d", Title: "Emma", Authors: "Jane Austen", Format: "pdf"},
+	}
+
+	got := collapseEditions(books, []string{"epub", "pdf"})
+	if len(got) != 2 {
+		t.Fatalf("got %d books, want 2", len(got))
+	}
+	// The largest epub wins the Dune group
+	if got[0].MD5Hash != "c" || got[0].Editions != 3 {
+		t.Errorf("Dune: got %s with %d editions, want c with 3", got[0].MD5Hash, got[0].Editions)
+	}
+	if got[1].MD5Hash != "d" || got[1].Editions != 1 {
+		t.Errorf("Emma: got %s with %d editions, want d with 1", got[1].MD5Hash, got[1].Editions)
+	}
+	if books[2].Editions != 0 {
+		t.Error("collapseEditions changed the input books")
+	}
+}
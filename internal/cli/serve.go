@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/server"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Share your downloaded books over the network",
+	Long: `Serve completed downloads over HTTP so other devices can fetch them.
+
+By default a web page lists the books with a search box; PDFs and other
+formats the browser understands open directly, the rest are downloaded.
+
+With --opds the library is served as an OPDS catalog instead, which
+e-readers such as KOReader, Moon+ Reader or Thorium can browse: add
+http://<this computer's address>:<port>/opds as a catalog. Books are
+listed all together and by format.
+
+Stop the server with Ctrl+C.
+
+Examples:
+  bookdl serve
+  bookdl serve --port 9000
+  bookdl serve --bind 127.0.0.1      Only this computer
+  bookdl serve --opds`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().Bool("opds", false, "serve an OPDS catalog for e-readers instead of a web page")
+	serveCmd.Flags().Int("port", 8080, "port to listen on")
+	serveCmd.Flags().String("bind", "", "address to listen on (default all interfaces)")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	opds, _ := cmd.Flags().GetBool("opds")
+	port, _ := cmd.Flags().GetInt("port")
+	bind, _ := cmd.Flags().GetString("bind")
+
+	addr := net.JoinHostPort(bind, strconv.Itoa(port))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	var handler http.Handler
+	path := "/"
+	if opds {
+		handler = server.NewOPDSHandler()
+		path = "/opds"
+		fmt.Println("Serving the OPDS catalog at:")
+	} else {
+		handler = server.NewHTMLHandler()
+		fmt.Println("Serving your library at:")
+	}
+
+	hosts := []string{bind}
+	if bind == "" || net.ParseIP(bind).IsUnspecified() {
+		hosts = lanAddresses()
+	}
+	for _, host := range hosts {
+		fmt.Printf("  http://%s%s\n", net.JoinHostPort(host, strconv.Itoa(port)), path)
+	}
+	fmt.Println("Press Ctrl+C to stop.")
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := server.Serve(ctx, listener, handler); err != nil {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	fmt.Println("\nStopped.")
+	return nil
+}
+
+// lanAddresses returns this machine's IPv4 addresses other devices may
+// reach it at, starting with localhost
+func lanAddresses() []string {
+	hosts := []string{"localhost"}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return hosts
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		hosts = append(hosts, ipNet.IP.String())
+	}
+	return hosts
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/downloader"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show download statistics",
+	Long: `Summarize your download history: totals, status and format breakdowns,
+average size, most downloaded authors, and unverified files.
+
+Examples:
+  bookdl stats
+  bookdl stats --json`,
+	Args: cobra.NoArgs,
+	RunE: runStats,
+}
+
+func init() {
+	statsCmd.Flags().Bool("json", false, "output as JSON")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	stats, err := db.GetDownloadStats()
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	fmt.Println("Download Statistics")
+	fmt.Println()
+	fmt.Printf("  Files downloaded: %d\n", stats.TotalFiles)
+	fmt.Printf("  Total size:       %s\n", downloader.FormatBytes(stats.TotalBytes))
+	fmt.Printf("  Average size:     %s\n", downloader.FormatBytes(stats.AverageSize))
+	fmt.Printf("  Unverified:       %d\n", stats.Unverified)
+
+	if len(stats.ByStatus) > 0 {
+		fmt.Println()
+		fmt.Println("By status:")
+		printCounts(stats.ByStatus)
+	}
+
+	if len(stats.ByFormat) > 0 {
+		fmt.Println()
+		fmt.Println("By format:")
+		printCounts(stats.ByFormat)
+	}
+
+	if len(stats.TopAuthors) > 0 {
+		fmt.Println()
+		fmt.Println("Top authors:")
+		for i, a := range stats.TopAuthors {
+			author := a.Author
+			if len(author) > 40 {
+				author = author[:37] + "..."
+			}
+			fmt.Printf("  %d. %s (%d)\n", i+1, author, a.Count)
+		}
+	}
+
+	return nil
+}
+
+// printCounts prints counts largest first
+func printCounts(counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		fmt.Printf("  %-12s %d\n", k, counts[k])
+	}
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag [md5] [tag...]",
+	Short: "Tag books into collections",
+	Long: `Tag downloads and bookmarks to organize them into collections.
+
+Tags are attached to the book's MD5 hash, so they apply to both its
+download and its bookmark.
+
+Examples:
+  bookdl tag abc123... work study     Add tags
+  bookdl tag abc123... -r study       Remove a tag
+  bookdl tag abc123...                Show a book's tags
+  bookdl tag                          List all tags
+  bookdl list --tag work              List downloads tagged "work"`,
+	RunE: runTag,
+}
+
+func init() {
+	tagCmd.Flags().BoolP("remove", "r", false, "remove the given tags")
+}
+
+func runTag(cmd *cobra.Command, args []string) error {
+	remove, _ := cmd.Flags().GetBool("remove")
+
+	if len(args) == 0 {
+		return listAllTags()
+	}
+
+	md5Hash, err := anna.ExtractMD5(args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		tags, err := db.ListTags(md5Hash)
+		if err != nil {
+			return fmt.Errorf("failed to list tags: %w", err)
+		}
+		if len(tags) == 0 {
+			fmt.Println("No tags.")
+			return nil
+		}
+		fmt.Println(strings.Join(tags, ", "))
+		return nil
+	}
+
+	for _, tag := range args[1:] {
+		if strings.TrimSpace(tag) == "" {
+			continue
+		}
+		if remove {
+			if err := db.RemoveTag(md5Hash, tag); err != nil {
+				return fmt.Errorf("failed to remove tag %s: %w", tag, err)
+			}
+		} else {
+			if err := db.AddTag(md5Hash, tag); err != nil {
+				return fmt.Errorf("failed to add tag %s: %w", tag, err)
+			}
+		}
+	}
+
+	tags, err := db.ListTags(md5Hash)
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+	if len(tags) == 0 {
+		Successf("Removed all tags")
+	} else {
+		Successf("Tags: %s", strings.Join(tags, ", "))
+	}
+	return nil
+}
+
+func listAllTags() error {
+	counts, err := db.ListAllTags()
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+	if len(counts) == 0 {
+		fmt.Println("No tags yet.")
+		fmt.Println("\nTo tag a book:")
+		fmt.Println("  bookdl tag <md5-hash> <tag>")
+		return nil
+	}
+
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	fmt.Printf("Tags (%d):\n\n", len(tags))
+	for _, tag := range tags {
+		fmt.Printf("  %-20s %d\n", tag, counts[tag])
+	}
+	return nil
+}
+
+// formatTags returns a book's tags joined for display, or "" if it has none
+func formatTags(md5Hash string) string {
+	tags, err := db.ListTags(md5Hash)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(tags, ", ")
+}
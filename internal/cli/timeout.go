@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/config"
+)
+
+// timeoutOverride replaces the configured search and download time limits
+// for this run (--timeout); 0 means no limit
+var timeoutOverride time.Duration
+
+// downloadTimeout is how long a download may take: --timeout, or
+// downloads.timeout. 0 means no limit.
+func downloadTimeout() time.Duration {
+	if rootCmd.PersistentFlags().Changed("timeout") {
+		return timeoutOverride
+	}
+	return config.Get().Downloads.Timeout
+}
+
+// searchTimeout is how long a search or book lookup may take: --timeout,
+// or network.search_timeout. 0 means no limit.
+func searchTimeout() time.Duration {
+	if rootCmd.PersistentFlags().Changed("timeout") {
+		return timeoutOverride
+	}
+	return config.Get().Network.SearchTimeout
+}
+
+// withTimeout limits ctx to d, or only makes it cancellable when d is 0.
+// Downloads rely on this: their HTTP client has no timeout of its own.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/config"
+)
+
+// useTorrent hands magnet/torrent links to the torrent client even when
+// HTTP mirrors are available
+var useTorrent bool
+
+func init() {
+	downloadCmd.Flags().BoolVar(&useTorrent, "torrent", false, "prefer the torrent over HTTP mirrors when one is offered")
+}
+
+// handleTorrent hands a magnet link or .torrent URL off to the configured
+// torrent client (downloads.torrent_handler), or saves .torrent files to
+// downloads.torrent_watch_dir. {magnet} and {url} in the handler are
+// replaced with the link.
+func handleTorrent(ctx context.Context, torrentURL string, title string) error {
+	cfg := config.Get().Downloads
+
+	if cfg.TorrentHandler != "" {
+		fields := strings.Fields(cfg.TorrentHandler)
+		replaced := false
+		for i, f := range fields {
+			if strings.Contains(f, "{magnet}") || strings.Contains(f, "{url}") {
+				f = strings.ReplaceAll(f, "{magnet}", torrentURL)
+				fields[i] = strings.ReplaceAll(f, "{url}", torrentURL)
+				replaced = true
+			}
+		}
+		if !replaced {
+			fields = append(fields, torrentURL)
+		}
+
+		cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("torrent handler failed: %w", err)
+		}
+		Successf("Handed torrent for %s to %s", title, fields[0])
+		return nil
+	}
+
+	if cfg.TorrentWatchDir != "" && !strings.HasPrefix(torrentURL, "magnet:") {
+		dest, err := saveTorrentFile(ctx, torrentURL, cfg.TorrentWatchDir)
+		if err != nil {
+			return err
+		}
+		Successf("Saved torrent for %s to %s", title, dest)
+		return nil
+	}
+
+	fmt.Printf("Torrent: %s\n", torrentURL)
+	return fmt.Errorf("no torrent client configured (set downloads.torrent_handler or downloads.torrent_watch_dir)")
+}
+
+// saveTorrentFile downloads a .torrent file into dir
+func saveTorrentFile(ctx context.Context, torrentURL string, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create watch directory: %w", err)
+	}
+
+	name := "download.torrent"
+	if u, err := url.Parse(torrentURL); err == nil {
+		if base := sanitizeFilename(path.Base(u.Path)); strings.HasSuffix(base, ".torrent") {
+			name = base
+		}
+	}
+	dest := filepath.Join(dir, name)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", torrentURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", config.RandomUserAgent())
+
+	client := &http.Client{Timeout: 60 * time.Second, Transport: config.ProxyTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch torrent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch torrent: HTTP %d", resp.StatusCode)
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(dest)
+		return "", fmt.Errorf("failed to save torrent: %w", err)
+	}
+	return dest, f.Close()
+}
@@ -12,10 +12,14 @@ import (
 var verifyCmd = &cobra.Command{
 	Use:   "verify [download-id]",
 	Short: "Verify checksum of downloaded files",
-	Long: `Verify the MD5 checksum of downloaded files.
+	Long: `Verify the checksum of downloaded files.
+
+MD5 is used by default. With --algo sha1 or sha256 the hash published on
+the book page is used when known, falling back to MD5 otherwise.
 
 Examples:
   bookdl verify 1          # Verify specific download
+  bookdl verify 1 --algo sha256
   bookdl verify --all      # Verify all completed downloads
   bookdl verify --failed   # Re-verify failed downloads`,
 	RunE: runVerify,
@@ -25,12 +29,14 @@ func init() {
 	verifyCmd.Flags().Bool("all", false, "verify all completed downloads")
 	verifyCmd.Flags().Bool("failed", false, "re-verify downloads that failed verification")
 	verifyCmd.Flags().Bool("fix", false, "automatically re-download corrupted files")
+	verifyCmd.Flags().String("algo", "md5", "checksum algorithm (md5, sha1, sha256)")
 }
 
 func runVerify(cmd *cobra.Command, args []string) error {
 	verifyAll, _ := cmd.Flags().GetBool("all")
 	verifyFailed, _ := cmd.Flags().GetBool("failed")
 	autoFix, _ := cmd.Flags().GetBool("fix")
+	algo, _ := cmd.Flags().GetString("algo")
 
 	var downloads []*db.Download
 	var err error
@@ -87,37 +93,37 @@ func runVerify(cmd *cobra.Command, args []string) error {
 	for _, download := range downloads {
 		// Check if file exists
 		if _, err := os.Stat(download.FilePath); os.IsNotExist(err) {
-			fmt.Printf("❌ [%d] %s\n", download.ID, download.Title)
+			fmt.Printf("%s[%d] %s\n", glyph("❌"), download.ID, download.Title)
 			fmt.Printf("    File not found: %s\n\n", download.FilePath)
 			missing++
 			continue
 		}
 
-		fmt.Printf("🔍 [%d] %s\n", download.ID, download.Title)
+		fmt.Printf("%s[%d] %s\n", glyph("🔍"), download.ID, download.Title)
 		fmt.Printf("    Verifying: %s\n", download.FilePath)
 
-		err := downloader.VerifyAndMark(download)
+		err := downloader.VerifyAndMarkWith(download, algo)
 		if err != nil {
-			fmt.Printf("    ❌ Verification failed: %v\n", err)
+			fmt.Printf("    %sVerification failed: %v\n", glyph("❌"), err)
 			failed++
 
 			if autoFix {
-				fmt.Printf("    🔄 Re-downloading...\n")
+				fmt.Printf("    %sRe-downloading...\n", glyph("🔄"))
 				// Reset and re-download
 				if err := db.ResetDownload(download.ID); err != nil {
-					fmt.Printf("    ⚠️  Failed to reset download: %v\n", err)
+					fmt.Printf("    %sFailed to reset download: %v\n", glyph("⚠️"), err)
 				} else {
 					// Trigger re-download
 					if err := runDownloadByHash(cmd.Context(), download.MD5Hash, "", nil); err != nil {
-						fmt.Printf("    ⚠️  Re-download failed: %v\n", err)
+						fmt.Printf("    %sRe-download failed: %v\n", glyph("⚠️"), err)
 					} else {
-						fmt.Printf("    ✓ Re-download completed\n")
+						fmt.Printf("    %sRe-download completed\n", glyph("✓"))
 					}
 				}
 			}
 			fmt.Println()
 		} else {
-			fmt.Printf("    ✓ Checksum verified\n\n")
+			fmt.Printf("    %sChecksum verified\n\n", glyph("✓"))
 			verified++
 		}
 	}
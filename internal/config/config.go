@@ -1,6 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,61 +15,94 @@ import (
 
 // Config holds all application configuration
 type Config struct {
-	Anna      AnnaConfig     `mapstructure:"anna"`
-	Downloads DownloadConfig `mapstructure:"downloads"`
-	Files     FileConfig     `mapstructure:"files"`
-	Network   NetworkConfig  `mapstructure:"network"`
-	Browser   BrowserConfig  `mapstructure:"browser"`
-	Cache     CacheConfig    `mapstructure:"cache"`
+	Anna          AnnaConfig         `mapstructure:"anna"`
+	Downloads     DownloadConfig     `mapstructure:"downloads"`
+	Files         FileConfig         `mapstructure:"files"`
+	Network       NetworkConfig      `mapstructure:"network"`
+	Browser       BrowserConfig      `mapstructure:"browser"`
+	Cache         CacheConfig        `mapstructure:"cache"`
+	Notifications NotificationConfig `mapstructure:"notifications"`
 }
 
 // AnnaConfig holds Anna's Archive settings
 type AnnaConfig struct {
-	APIKey  string `mapstructure:"api_key"`
-	BaseURL string `mapstructure:"base_url"`
+	APIKey       string   `mapstructure:"api_key"`
+	MemberCookie string   `mapstructure:"member_cookie"` // logged-in session, for fast_download links
+	BaseURL      string   `mapstructure:"base_url"`
+	BaseURLs     []string `mapstructure:"base_urls"` // fallback mirror domains, tried in order after base_url
 }
 
 // DownloadConfig holds download settings
 type DownloadConfig struct {
-	Path             string        `mapstructure:"path"`
-	ChunkSize        int64         `mapstructure:"chunk_size"`
-	MaxConcurrent    int           `mapstructure:"max_concurrent"`
-	Timeout          time.Duration `mapstructure:"timeout"`
-	AutoResume       bool          `mapstructure:"auto_resume"`
-	Notifications    bool          `mapstructure:"notifications"`
-	SoundEnabled     bool          `mapstructure:"sound_enabled"`
+	Path                string        `mapstructure:"path"`
+	ChunkSize           int64         `mapstructure:"chunk_size"`
+	MaxConcurrent       int           `mapstructure:"max_concurrent"`
+	Timeout             time.Duration `mapstructure:"timeout"`
+	AutoResume          bool          `mapstructure:"auto_resume"`
+	Notifications       bool          `mapstructure:"notifications"`
+	SoundEnabled        bool          `mapstructure:"sound_enabled"`
+	VerifyAfter         bool          `mapstructure:"verify_after"`          // fail downloads whose checksum doesn't match
+	Order               string        `mapstructure:"order"`                 // queue order: priority, smallest_first, largest_first
+	TorrentHandler      string        `mapstructure:"torrent_handler"`       // command for magnet/torrent links, e.g. "transmission-remote -a {magnet}"
+	TorrentWatchDir     string        `mapstructure:"torrent_watch_dir"`     // directory .torrent files are saved to
+	MaxSpeed            string        `mapstructure:"max_speed"`             // total bandwidth cap across all downloads, e.g. "2MB"; empty for unlimited. Applies on top of max_rate
+	ChunkChecksums      bool          `mapstructure:"chunk_checksums"`       // hash completed chunks and re-check them on resume
+	MaxRate             string        `mapstructure:"max_rate"`              // per-download bandwidth cap, e.g. "500KB"; empty for unlimited. The lower of this and max_speed wins
+	ParallelChunks      int           `mapstructure:"parallel_chunks"`       // chunks of a single download fetched at once
+	MinFileSize         string        `mapstructure:"min_file_size"`         // reject files smaller than this, e.g. "10KB"; empty for no minimum
+	AllowedContentTypes []string      `mapstructure:"allowed_content_types"` // media types accepted from mirrors; empty accepts any but HTML
+	SniffMarkers        []string      `mapstructure:"sniff_markers"`         // text that marks the start of a generic-typed file as an error page
+	MinFreeSpace        string        `mapstructure:"min_free_space"`        // disk space to leave free, e.g. "500MB"; downloads that would eat into it don't start
+	MaxDownloadRetries  int           `mapstructure:"max_download_retries"`  // failed attempts before 'resume all' gives up on a download (0 = never)
 }
 
 // FileConfig holds file preferences
 type FileConfig struct {
-	PreferredFormats []string `mapstructure:"preferred_formats"`
-	OrganizeMode     string   `mapstructure:"organize_mode"`     // flat, author, format, year, custom
-	OrganizePattern  string   `mapstructure:"organize_pattern"`  // custom pattern like {author}/{year}/{title}
-	RenameFiles      bool     `mapstructure:"rename_files"`      // rename files based on metadata
+	PreferredFormats    []string          `mapstructure:"preferred_formats"`
+	OrganizeMode        string            `mapstructure:"organize_mode"`         // flat, author, format, year, custom
+	OrganizePattern     string            `mapstructure:"organize_pattern"`      // custom pattern like {author}/{year}/{title}
+	RenameFiles         bool              `mapstructure:"rename_files"`          // rename files based on metadata
+	WriteMetadata       bool              `mapstructure:"write_metadata"`        // write a .json metadata sidecar next to each book
+	ArchiveDir          string            `mapstructure:"archive_dir"`           // where 'bookdl archive' moves old downloads
+	ArchiveAfterDays    int               `mapstructure:"archive_after_days"`    // auto-archive downloads older than this (0 = off)
+	TrustServerFilename bool              `mapstructure:"trust_server_filename"` // use the filename the server sends instead of the guessed one
+	FormatDirs          map[string]string `mapstructure:"format_dirs"`           // per-format download directories, e.g. pdf: ~/Papers
 }
 
 // NetworkConfig holds network settings
 type NetworkConfig struct {
-	Timeout           time.Duration `mapstructure:"timeout"`
-	RetryAttempts     int           `mapstructure:"retry_attempts"`
-	RetryBaseDelay    time.Duration `mapstructure:"retry_base_delay"`
-	RetryMaxDelay     time.Duration `mapstructure:"retry_max_delay"`
-	RetryMultiplier   float64       `mapstructure:"retry_multiplier"`
-	UserAgent         string        `mapstructure:"user_agent"`
+	Timeout         time.Duration `mapstructure:"timeout"`
+	SearchTimeout   time.Duration `mapstructure:"search_timeout"` // how long a search or book lookup may take, 0 for no limit
+	RetryAttempts   int           `mapstructure:"retry_attempts"`
+	RetryBaseDelay  time.Duration `mapstructure:"retry_base_delay"`
+	RetryMaxDelay   time.Duration `mapstructure:"retry_max_delay"`
+	RetryMultiplier float64       `mapstructure:"retry_multiplier"`
+	UserAgent       string        `mapstructure:"user_agent"`  // send only this User-Agent; empty rotates through user_agents
+	UserAgents      []string      `mapstructure:"user_agents"` // User-Agents picked from at random
+	Proxy           string        `mapstructure:"proxy"`       // e.g. socks5://127.0.0.1:9050 or http://host:port
 }
 
 // BrowserConfig holds browser automation settings
 type BrowserConfig struct {
-	PageLoadTimeout     time.Duration `mapstructure:"page_load_timeout"`      // Timeout for initial page load
-	MaxCountdownWait    time.Duration `mapstructure:"max_countdown_wait"`     // Max time to wait for download countdown
-	PollInterval        time.Duration `mapstructure:"poll_interval"`          // How often to check for download link
-	VerboseLogging      bool          `mapstructure:"verbose_logging"`        // Enable detailed logging
+	Enabled          bool          `mapstructure:"enabled"`            // Fall back to headless Chrome when scraping fails
+	PageLoadTimeout  time.Duration `mapstructure:"page_load_timeout"`  // Timeout for initial page load
+	MaxCountdownWait time.Duration `mapstructure:"max_countdown_wait"` // Max time to wait for download countdown
+	PollInterval     time.Duration `mapstructure:"poll_interval"`      // How often to check for download link
+	VerboseLogging   bool          `mapstructure:"verbose_logging"`    // Enable detailed logging
 }
 
 // CacheConfig holds cache settings
 type CacheConfig struct {
-	Enabled bool          `mapstructure:"enabled"`  // Enable search result caching
-	TTL     time.Duration `mapstructure:"ttl"`      // Time-to-live for cached results
+	Enabled    bool          `mapstructure:"enabled"`     // Enable search result caching
+	TTL        time.Duration `mapstructure:"ttl"`         // Time-to-live for cached results
+	MaxEntries int           `mapstructure:"max_entries"` // Oldest entries beyond this are evicted (0 = unlimited)
+}
+
+// NotificationConfig holds webhook notification settings
+type NotificationConfig struct {
+	WebhookURL      string        `mapstructure:"webhook_url"`      // POST events here (Discord, Slack, ntfy, ...); empty to disable
+	WebhookTemplate string        `mapstructure:"webhook_template"` // Go template for the request body; empty for the default JSON
+	WebhookTimeout  time.Duration `mapstructure:"webhook_timeout"`  // how long a webhook request may take
 }
 
 var cfg *Config
@@ -90,6 +127,7 @@ func GetConfigPath() string {
 func Init(cfgFile string) error {
 	// Set defaults
 	viper.SetDefault("anna.base_url", "annas-archive.li")
+	viper.SetDefault("anna.base_urls", []string{"annas-archive.se", "annas-archive.org", "annas-archive.gs"})
 	viper.SetDefault("downloads.path", "~/Downloads/books")
 	viper.SetDefault("downloads.chunk_size", 5*1024*1024) // 5MB
 	viper.SetDefault("downloads.max_concurrent", 2)
@@ -97,22 +135,58 @@ func Init(cfgFile string) error {
 	viper.SetDefault("downloads.auto_resume", true)
 	viper.SetDefault("downloads.notifications", false)
 	viper.SetDefault("downloads.sound_enabled", false)
+	viper.SetDefault("downloads.verify_after", false)
+	viper.SetDefault("downloads.order", "priority")
+	viper.SetDefault("downloads.torrent_handler", "")
+	viper.SetDefault("downloads.torrent_watch_dir", "")
+	viper.SetDefault("downloads.max_speed", "")
+	viper.SetDefault("downloads.chunk_checksums", false)
+	viper.SetDefault("downloads.max_rate", "")
+	viper.SetDefault("downloads.parallel_chunks", 4)
+	viper.SetDefault("downloads.min_file_size", "")
+	viper.SetDefault("downloads.min_free_space", "100MB")
+	viper.SetDefault("downloads.max_download_retries", 5)
+	viper.SetDefault("downloads.allowed_content_types", []string{
+		"application/pdf", "application/epub+zip", "application/x-mobipocket-ebook",
+		"application/vnd.amazon.ebook", "application/vnd.amazon.mobi8-ebook",
+		"image/vnd.djvu", "image/x-djvu", "application/x-fictionbook+xml",
+		"application/vnd.comicbook+zip", "application/vnd.comicbook-rar",
+		"application/zip", "application/x-zip-compressed", "application/x-rar-compressed",
+		"application/vnd.rar", "text/plain", "application/rtf", "application/msword",
+	})
+	viper.SetDefault("downloads.sniff_markers", []string{
+		"<!doctype", "<html", "<head", "<body", "<title>", "<script",
+		"cloudflare", "captcha", "access denied", "error 403", "error 404",
+	})
 	viper.SetDefault("files.preferred_formats", []string{"epub", "pdf"})
 	viper.SetDefault("files.organize_mode", "flat")
 	viper.SetDefault("files.organize_pattern", "{author}/{title}")
 	viper.SetDefault("files.rename_files", false)
+	viper.SetDefault("files.write_metadata", false)
+	viper.SetDefault("files.archive_dir", "")
+	viper.SetDefault("files.archive_after_days", 0)
+	viper.SetDefault("files.trust_server_filename", true)
+	viper.SetDefault("files.format_dirs", map[string]string{})
 	viper.SetDefault("network.timeout", 30*time.Second)
+	viper.SetDefault("network.search_timeout", 60*time.Second)
 	viper.SetDefault("network.retry_attempts", 5)
 	viper.SetDefault("network.retry_base_delay", 1*time.Second)
 	viper.SetDefault("network.retry_max_delay", 30*time.Second)
 	viper.SetDefault("network.retry_multiplier", 2.0)
-	viper.SetDefault("network.user_agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	viper.SetDefault("network.proxy", "")
+	viper.SetDefault("network.user_agent", "")
+	viper.SetDefault("network.user_agents", defaultUserAgents)
+	viper.SetDefault("browser.enabled", true)
 	viper.SetDefault("browser.page_load_timeout", 60*time.Second)
 	viper.SetDefault("browser.max_countdown_wait", 90*time.Second)
 	viper.SetDefault("browser.poll_interval", 3*time.Second)
 	viper.SetDefault("browser.verbose_logging", false)
 	viper.SetDefault("cache.enabled", true)
 	viper.SetDefault("cache.ttl", 24*time.Hour)
+	viper.SetDefault("cache.max_entries", 500)
+	viper.SetDefault("notifications.webhook_url", "")
+	viper.SetDefault("notifications.webhook_template", "")
+	viper.SetDefault("notifications.webhook_timeout", 10*time.Second)
 
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
@@ -139,6 +213,9 @@ func Get() *Config {
 		cfg = &Config{}
 		viper.Unmarshal(cfg)
 		cfg.Downloads.Path = expandPath(cfg.Downloads.Path)
+		cfg.Downloads.TorrentWatchDir = expandPath(cfg.Downloads.TorrentWatchDir)
+		cfg.Files.ArchiveDir = expandPath(cfg.Files.ArchiveDir)
+		expandFormatDirs(cfg.Files.FormatDirs)
 	}
 	return cfg
 }
@@ -159,11 +236,147 @@ func Set(key, value string) error {
 	return viper.WriteConfigAs(GetConfigPath())
 }
 
+// ConfigFileUsed returns the path of the config file in use: --config if
+// given, otherwise the default path, whether or not the file exists
+func ConfigFileUsed() string {
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used
+	}
+	return GetConfigPath()
+}
+
+// EnsureConfigFile writes the current settings (including defaults) to the
+// config file if it does not exist yet, and returns its path
+func EnsureConfigFile() (string, error) {
+	path := ConfigFileUsed()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := viper.WriteConfigAs(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Reload re-reads the config file and decodes it, returning any parse errors
+func Reload() error {
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+
+	fresh := &Config{}
+	if err := viper.Unmarshal(fresh); err != nil {
+		return err
+	}
+	fresh.Downloads.Path = expandPath(fresh.Downloads.Path)
+	fresh.Downloads.TorrentWatchDir = expandPath(fresh.Downloads.TorrentWatchDir)
+	fresh.Files.ArchiveDir = expandPath(fresh.Files.ArchiveDir)
+	expandFormatDirs(fresh.Files.FormatDirs)
+	cfg = fresh
+	return nil
+}
+
 // GetValue retrieves a configuration value
 func GetValue(key string) interface{} {
 	return viper.Get(key)
 }
 
+// ProxyURL parses network.proxy. It returns nil when no proxy is configured.
+func ProxyURL() (*url.URL, error) {
+	raw := strings.TrimSpace(Get().Network.Proxy)
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid network.proxy %q", raw)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported network.proxy scheme %q (use http, https or socks5)", u.Scheme)
+	}
+	return u, nil
+}
+
+// MemberCookies returns the anna.member_cookie session as cookies, or nil
+// when none is set. The setting is either a Cookie header copied from a
+// logged-in browser ("name=value; name2=value2") or just the account
+// token, which is sent as aa_account_id2.
+func MemberCookies() []*http.Cookie {
+	raw := strings.TrimSpace(Get().Anna.MemberCookie)
+	if raw == "" {
+		return nil
+	}
+	if !strings.Contains(raw, "=") {
+		return []*http.Cookie{{Name: "aa_account_id2", Value: raw}}
+	}
+	req := http.Request{Header: http.Header{"Cookie": {raw}}}
+	return req.Cookies()
+}
+
+// MemberCookieHeader returns the member session as a Cookie header value,
+// or "" when none is set
+func MemberCookieHeader() string {
+	var pairs []string
+	for _, c := range MemberCookies() {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// defaultUserAgents are recent desktop browsers. They're all Chrome-based, so
+// they also match the headless browser that sends them.
+var defaultUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0",
+}
+
+// RandomUserAgent returns the User-Agent for a request or browser session:
+// network.user_agent if set, otherwise one of network.user_agents at random
+func RandomUserAgent() string {
+	network := Get().Network
+	if ua := strings.TrimSpace(network.UserAgent); ua != "" {
+		return ua
+	}
+	var agents []string
+	for _, ua := range network.UserAgents {
+		if ua = strings.TrimSpace(ua); ua != "" {
+			agents = append(agents, ua)
+		}
+	}
+	if len(agents) == 0 {
+		agents = defaultUserAgents
+	}
+	return agents[rand.Intn(len(agents))]
+}
+
+// ProxyTransport returns a new HTTP transport that routes through
+// network.proxy, so every client uses the same proxy. Without a proxy the
+// usual HTTP_PROXY/HTTPS_PROXY environment variables apply. An invalid proxy
+// makes every request fail rather than silently going direct.
+func ProxyTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	u, err := ProxyURL()
+	switch {
+	case err != nil:
+		t.Proxy = func(*http.Request) (*url.URL, error) { return nil, err }
+	case u != nil:
+		t.Proxy = http.ProxyURL(u)
+	}
+	return t
+}
+
 func expandPath(path string) string {
 	if strings.HasPrefix(path, "~/") {
 		home, _ := os.UserHomeDir()
@@ -171,3 +384,15 @@ func expandPath(path string) string {
 	}
 	return path
 }
+
+// expandFormatDirs expands ~ in the files.format_dirs directories and
+// normalizes the formats to lowercase without a leading dot
+func expandFormatDirs(dirs map[string]string) {
+	for format, dir := range dirs {
+		key := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(format), "."))
+		if key != format {
+			delete(dirs, format)
+		}
+		dirs[key] = expandPath(strings.TrimSpace(dir))
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCacheDefaultsWithoutConfigFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg = nil
+	defer func() { cfg = nil }()
+
+	if err := Init(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(GetConfigPath()); !os.IsNotExist(err) {
+		t.Fatalf("config file %s exists", GetConfigPath())
+	}
+
+	cache := Get().Cache
+	if !cache.Enabled {
+		t.Error("cache.enabled should default to true")
+	}
+	if cache.TTL != 24*time.Hour {
+		t.Errorf("cache.ttl = %v, want 24h", cache.TTL)
+	}
+	if cache.MaxEntries != 500 {
+		t.Errorf("cache.max_entries = %d, want 500", cache.MaxEntries)
+	}
+}
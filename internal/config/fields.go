@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Kinds of config values, as shown and validated by the config editor
+const (
+	KindString   = "string"
+	KindInt      = "int"
+	KindFloat    = "float"
+	KindBool     = "bool"
+	KindDuration = "duration"
+	KindList     = "list" // comma-separated strings
+	KindMap      = "map"  // not editable as a single value
+)
+
+// Field is one config setting with its current value
+type Field struct {
+	Section string // e.g. "downloads"
+	Key     string // dotted key for Set, e.g. "downloads.path"
+	Kind    string
+	Value   string // current value, formatted for editing
+}
+
+// Fields lists every config setting with its current value (as written in
+// the config file, before ~ is expanded), in the order the sections and
+// keys are declared
+func Fields() []Field {
+	var current Config
+	viper.Unmarshal(&current)
+
+	var fields []Field
+	root := reflect.ValueOf(current)
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i).Tag.Get("mapstructure")
+		group := root.Field(i)
+		for j := 0; j < group.NumField(); j++ {
+			name := group.Type().Field(j).Tag.Get("mapstructure")
+			kind, value := describe(group.Field(j))
+			fields = append(fields, Field{
+				Section: section,
+				Key:     section + "." + name,
+				Kind:    kind,
+				Value:   value,
+			})
+		}
+	}
+	return fields
+}
+
+// describe returns the kind of v and its value formatted for editing
+func describe(v reflect.Value) (kind, value string) {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return KindDuration, d.String()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return KindBool, strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int64:
+		return KindInt, strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64:
+		return KindFloat, strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return KindList, strings.Join(items, ",")
+	case reflect.Map:
+		var pairs []string
+		for _, k := range v.MapKeys() {
+			pairs = append(pairs, fmt.Sprintf("%v: %v", k.Interface(), v.MapIndex(k).Interface()))
+		}
+		sort.Strings(pairs)
+		return KindMap, strings.Join(pairs, ", ")
+	default:
+		return KindString, v.String()
+	}
+}
+
+// ValidateValue checks that value can be stored in a setting of kind
+func ValidateValue(kind, value string) error {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case KindInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+	case KindFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case KindBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+	case KindDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%q is not a duration (e.g. 30s, 5m, 2h)", value)
+		}
+	case KindMap:
+		return fmt.Errorf("maps can't be edited as one value; use 'bookdl config edit'")
+	}
+	return nil
+}
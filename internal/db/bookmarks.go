@@ -5,6 +5,31 @@ import (
 	"time"
 )
 
+// BookmarkStatus is where a bookmarked book is in the reading list
+type BookmarkStatus string
+
+const (
+	BookmarkToRead  BookmarkStatus = "to_read"
+	BookmarkReading BookmarkStatus = "reading"
+	BookmarkRead    BookmarkStatus = "read"
+)
+
+// BookmarkStatuses lists the valid bookmark statuses in reading order
+var BookmarkStatuses = []BookmarkStatus{BookmarkToRead, BookmarkReading, BookmarkRead}
+
+// Valid reports whether s is a known bookmark status
+func (s BookmarkStatus) Valid() bool {
+	for _, status := range BookmarkStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// bookmarkColumns are the columns scanned into a Bookmark, in order
+const bookmarkColumns = `id, md5_hash, title, authors, publisher, year, language, format, size, page_url, notes, status, created_at`
+
 // Bookmark represents a saved book for later
 type Bookmark struct {
 	ID        int64
@@ -18,16 +43,20 @@ type Bookmark struct {
 	Size      string
 	PageURL   string
 	Notes     string
+	Status    BookmarkStatus
 	CreatedAt time.Time
 }
 
 // CreateBookmark creates a new bookmark
 func CreateBookmark(b *Bookmark) error {
+	if b.Status == "" {
+		b.Status = BookmarkToRead
+	}
 	result, err := database.Exec(`
 		INSERT INTO bookmarks (
-			md5_hash, title, authors, publisher, year, language, format, size, page_url, notes
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		b.MD5Hash, b.Title, b.Authors, b.Publisher, b.Year, b.Language, b.Format, b.Size, b.PageURL, b.Notes,
+			md5_hash, title, authors, publisher, year, language, format, size, page_url, notes, status
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.MD5Hash, b.Title, b.Authors, b.Publisher, b.Year, b.Language, b.Format, b.Size, b.PageURL, b.Notes, b.Status,
 	)
 	if err != nil {
 		return err
@@ -45,9 +74,9 @@ func CreateBookmark(b *Bookmark) error {
 func GetBookmark(id int64) (*Bookmark, error) {
 	b := &Bookmark{}
 	err := database.QueryRow(`
-		SELECT id, md5_hash, title, authors, publisher, year, language, format, size, page_url, notes, created_at
+		SELECT `+bookmarkColumns+`
 		FROM bookmarks WHERE id = ?`, id).Scan(
-		&b.ID, &b.MD5Hash, &b.Title, &b.Authors, &b.Publisher, &b.Year, &b.Language, &b.Format, &b.Size, &b.PageURL, &b.Notes, &b.CreatedAt,
+		&b.ID, &b.MD5Hash, &b.Title, &b.Authors, &b.Publisher, &b.Year, &b.Language, &b.Format, &b.Size, &b.PageURL, &b.Notes, &b.Status, &b.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -59,9 +88,9 @@ func GetBookmark(id int64) (*Bookmark, error) {
 func GetBookmarkByHash(hash string) (*Bookmark, error) {
 	b := &Bookmark{}
 	err := database.QueryRow(`
-		SELECT id, md5_hash, title, authors, publisher, year, language, format, size, page_url, notes, created_at
+		SELECT `+bookmarkColumns+`
 		FROM bookmarks WHERE md5_hash = ?`, hash).Scan(
-		&b.ID, &b.MD5Hash, &b.Title, &b.Authors, &b.Publisher, &b.Year, &b.Language, &b.Format, &b.Size, &b.PageURL, &b.Notes, &b.CreatedAt,
+		&b.ID, &b.MD5Hash, &b.Title, &b.Authors, &b.Publisher, &b.Year, &b.Language, &b.Format, &b.Size, &b.PageURL, &b.Notes, &b.Status, &b.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -69,22 +98,33 @@ func GetBookmarkByHash(hash string) (*Bookmark, error) {
 	return b, nil
 }
 
-// ListBookmarks retrieves all bookmarks
-func ListBookmarks() ([]*Bookmark, error) {
-	rows, err := database.Query(`
-		SELECT id, md5_hash, title, authors, publisher, year, language, format, size, page_url, notes, created_at
-		FROM bookmarks
-		ORDER BY created_at DESC`)
+// ListBookmarks retrieves the bookmarks with a status, or all of them if
+// status is empty
+func ListBookmarks(status BookmarkStatus) ([]*Bookmark, error) {
+	query := `SELECT ` + bookmarkColumns + ` FROM bookmarks`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := database.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
+	return scanBookmarks(rows)
+}
+
+// scanBookmarks reads all bookmark rows and closes rows
+func scanBookmarks(rows *sql.Rows) ([]*Bookmark, error) {
 	defer rows.Close()
 
 	var bookmarks []*Bookmark
 	for rows.Next() {
 		b := &Bookmark{}
 		err := rows.Scan(
-			&b.ID, &b.MD5Hash, &b.Title, &b.Authors, &b.Publisher, &b.Year, &b.Language, &b.Format, &b.Size, &b.PageURL, &b.Notes, &b.CreatedAt,
+			&b.ID, &b.MD5Hash, &b.Title, &b.Authors, &b.Publisher, &b.Year, &b.Language, &b.Format, &b.Size, &b.PageURL, &b.Notes, &b.Status, &b.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -121,3 +161,15 @@ func UpdateBookmarkNotes(id int64, notes string) error {
 	_, err := database.Exec(`UPDATE bookmarks SET notes = ? WHERE id = ?`, notes, id)
 	return err
 }
+
+// UpdateBookmarkStatus sets the reading status of the bookmark for a hash
+func UpdateBookmarkStatus(hash string, status BookmarkStatus) error {
+	result, err := database.Exec(`UPDATE bookmarks SET status = ? WHERE md5_hash = ?`, status, hash)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -60,12 +60,45 @@ func SaveCachedSearch(cacheKey, query, filters string, resultsJSON string, resul
 	return err
 }
 
+// EvictOldestCache deletes the oldest cache entries beyond the newest keep,
+// returning how many were removed. keep <= 0 means no limit.
+func EvictOldestCache(keep int) (int64, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+	result, err := database.Exec(`
+		DELETE FROM search_cache WHERE id NOT IN (
+			SELECT id FROM search_cache ORDER BY created_at DESC, id DESC LIMIT ?
+		)`, keep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // CleanExpiredCache removes expired cache entries
 func CleanExpiredCache() error {
 	_, err := database.Exec(`DELETE FROM search_cache WHERE expires_at < CURRENT_TIMESTAMP`)
 	return err
 }
 
+// cacheCleanedKey is the meta row recording the last expired-cache cleanup
+const cacheCleanedKey = "cache_cleaned_at"
+
+// CleanExpiredCacheIfDue removes expired cache entries unless a cleanup
+// already ran within interval. Returns whether a cleanup was performed.
+func CleanExpiredCacheIfDue(interval time.Duration) (bool, error) {
+	due, err := IsDue(cacheCleanedKey, interval)
+	if err != nil || !due {
+		return false, err
+	}
+
+	if err := CleanExpiredCache(); err != nil {
+		return false, err
+	}
+	return true, MarkDone(cacheCleanedKey)
+}
+
 // ClearSearchCache clears all cached search results
 func ClearSearchCache() error {
 	_, err := database.Exec(`DELETE FROM search_cache`)
@@ -31,9 +31,15 @@ CREATE TABLE IF NOT EXISTS downloads (
     retry_count     INTEGER DEFAULT 0,
     verified        INTEGER DEFAULT 0,
     priority        INTEGER DEFAULT 0,
+    archived        INTEGER DEFAULT 0,
+    sha1            TEXT,
+    sha256          TEXT,
     created_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
-    completed_at    DATETIME
+    completed_at    DATETIME,
+    scheduled_at    DATETIME,
+    speed           REAL DEFAULT 0,
+    avg_speed       REAL DEFAULT 0
 );
 
 CREATE INDEX IF NOT EXISTS idx_downloads_status ON downloads(status);
@@ -47,6 +53,7 @@ CREATE TABLE IF NOT EXISTS chunks (
     end_byte        INTEGER NOT NULL,
     downloaded      INTEGER DEFAULT 0,
     status          TEXT DEFAULT 'pending',
+    checksum        TEXT,
     FOREIGN KEY (download_id) REFERENCES downloads(id) ON DELETE CASCADE,
     UNIQUE(download_id, chunk_index)
 );
@@ -65,6 +72,7 @@ CREATE TABLE IF NOT EXISTS bookmarks (
     size            TEXT,
     page_url        TEXT,
     notes           TEXT,
+    status          TEXT NOT NULL DEFAULT 'to_read',
     created_at      DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -94,6 +102,20 @@ CREATE TABLE IF NOT EXISTS search_cache (
 
 CREATE INDEX IF NOT EXISTS idx_search_cache_key ON search_cache(cache_key);
 CREATE INDEX IF NOT EXISTS idx_search_cache_expires ON search_cache(expires_at);
+
+CREATE TABLE IF NOT EXISTS tags (
+    md5_hash        TEXT NOT NULL,
+    tag             TEXT NOT NULL,
+    created_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (md5_hash, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tags_tag ON tags(tag);
+
+CREATE TABLE IF NOT EXISTS meta (
+    key             TEXT PRIMARY KEY,
+    value           TEXT
+);
 `
 
 // Init initializes the database connection and schema
@@ -166,6 +188,94 @@ func runMigrations(db *sql.DB) error {
 		}
 	}
 
+	// Migration 3: Add archived column if it doesn't exist
+	var archivedCount int
+	err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('downloads') WHERE name='archived'").Scan(&archivedCount)
+	if err != nil {
+		return err
+	}
+
+	if archivedCount == 0 {
+		_, err := db.Exec("ALTER TABLE downloads ADD COLUMN archived INTEGER DEFAULT 0")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Migration 4: Add checksum column to chunks if it doesn't exist
+	var checksumCount int
+	err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('chunks') WHERE name='checksum'").Scan(&checksumCount)
+	if err != nil {
+		return err
+	}
+
+	if checksumCount == 0 {
+		_, err := db.Exec("ALTER TABLE chunks ADD COLUMN checksum TEXT")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Migration 5: Add sha1/sha256 columns if they don't exist
+	for _, column := range []string{"sha1", "sha256"} {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('downloads') WHERE name=?", column).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			_, err := db.Exec("ALTER TABLE downloads ADD COLUMN " + column + " TEXT")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Migration 6: Add scheduled_at column if it doesn't exist
+	var scheduledCount int
+	err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('downloads') WHERE name='scheduled_at'").Scan(&scheduledCount)
+	if err != nil {
+		return err
+	}
+
+	if scheduledCount == 0 {
+		_, err := db.Exec("ALTER TABLE downloads ADD COLUMN scheduled_at DATETIME")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Migration 7: Add reading status to bookmarks if it doesn't exist
+	var bookmarkStatusCount int
+	err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('bookmarks') WHERE name='status'").Scan(&bookmarkStatusCount)
+	if err != nil {
+		return err
+	}
+
+	if bookmarkStatusCount == 0 {
+		_, err := db.Exec("ALTER TABLE bookmarks ADD COLUMN status TEXT NOT NULL DEFAULT 'to_read'")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Migration 8: Add speed columns if they don't exist
+	for _, column := range []string{"speed", "avg_speed"} {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('downloads') WHERE name=?", column).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			_, err := db.Exec("ALTER TABLE downloads ADD COLUMN " + column + " REAL DEFAULT 0")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
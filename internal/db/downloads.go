@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -16,6 +18,19 @@ const (
 	StatusFailed      DownloadStatus = "failed"
 )
 
+// DownloadStatuses lists the valid download statuses
+var DownloadStatuses = []DownloadStatus{StatusPending, StatusDownloading, StatusPaused, StatusCompleted, StatusFailed}
+
+// Valid reports whether s is a known download status
+func (s DownloadStatus) Valid() bool {
+	for _, status := range DownloadStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 // Download represents a download record
 type Download struct {
 	ID             int64
@@ -36,9 +51,15 @@ type Download struct {
 	RetryCount     int
 	Verified       bool
 	Priority       int
+	Archived       bool
+	SHA1           string // optional, from the book page
+	SHA256         string // optional, from the book page
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	CompletedAt    *time.Time
+	ScheduledAt    *time.Time // pending downloads don't start before this, if set
+	Speed          float64    // bytes per second, averaged over the last few seconds while downloading
+	AvgSpeed       float64    // bytes per second over the last run of a finished download
 }
 
 // Chunk represents a download chunk for resumable downloads
@@ -50,6 +71,7 @@ type Chunk struct {
 	EndByte     int64
 	Downloaded  int64
 	Status      string
+	Checksum    string // SHA-256 of the completed chunk, if chunk checksums are enabled
 }
 
 // CreateDownload creates a new download record
@@ -74,18 +96,45 @@ func CreateDownload(d *Download) error {
 	return nil
 }
 
+// ImportDownload records a book file that's already on disk as a completed,
+// verified download of d.FileSize bytes
+func ImportDownload(d *Download) error {
+	result, err := database.Exec(`
+		INSERT INTO downloads (
+			md5_hash, title, authors, publisher, language, format,
+			file_size, downloaded_size, source_url, download_url, file_path,
+			status, verified, completed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, CURRENT_TIMESTAMP)`,
+		d.MD5Hash, d.Title, d.Authors, d.Publisher, d.Language, d.Format,
+		d.FileSize, d.FileSize, d.SourceURL, d.DownloadURL, d.FilePath, StatusCompleted,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	d.ID = id
+	d.DownloadedSize = d.FileSize
+	d.Status = StatusCompleted
+	d.Verified = true
+	return nil
+}
+
 // GetDownload retrieves a download by ID
 func GetDownload(id int64) (*Download, error) {
 	d := &Download{}
-	var errMsg sql.NullString
+	var errMsg, tempPath sql.NullString
 	err := database.QueryRow(`
 		SELECT id, md5_hash, title, authors, publisher, language, format,
 			file_size, downloaded_size, source_url, download_url, file_path,
-			temp_path, status, error_message, retry_count, verified, priority, created_at, updated_at, completed_at
+			temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
 		FROM downloads WHERE id = ?`, id).Scan(
 		&d.ID, &d.MD5Hash, &d.Title, &d.Authors, &d.Publisher, &d.Language, &d.Format,
 		&d.FileSize, &d.DownloadedSize, &d.SourceURL, &d.DownloadURL, &d.FilePath,
-		&d.TempPath, &d.Status, &errMsg, &d.RetryCount, &d.Verified, &d.Priority, &d.CreatedAt, &d.UpdatedAt, &d.CompletedAt,
+		&tempPath, &d.Status, &errMsg, &d.RetryCount, &d.Verified, &d.Priority, &d.Archived, &d.SHA1, &d.SHA256, &d.CreatedAt, &d.UpdatedAt, &d.CompletedAt, &d.ScheduledAt, &d.Speed, &d.AvgSpeed,
 	)
 	if err != nil {
 		return nil, err
@@ -93,21 +142,22 @@ func GetDownload(id int64) (*Download, error) {
 	if errMsg.Valid {
 		d.ErrorMessage = errMsg.String
 	}
+	d.TempPath = tempPath.String
 	return d, nil
 }
 
 // GetDownloadByHash retrieves a download by MD5 hash
 func GetDownloadByHash(hash string) (*Download, error) {
 	d := &Download{}
-	var errMsg sql.NullString
+	var errMsg, tempPath sql.NullString
 	err := database.QueryRow(`
 		SELECT id, md5_hash, title, authors, publisher, language, format,
 			file_size, downloaded_size, source_url, download_url, file_path,
-			temp_path, status, error_message, retry_count, verified, priority, created_at, updated_at, completed_at
+			temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
 		FROM downloads WHERE md5_hash = ?`, hash).Scan(
 		&d.ID, &d.MD5Hash, &d.Title, &d.Authors, &d.Publisher, &d.Language, &d.Format,
 		&d.FileSize, &d.DownloadedSize, &d.SourceURL, &d.DownloadURL, &d.FilePath,
-		&d.TempPath, &d.Status, &errMsg, &d.RetryCount, &d.Verified, &d.Priority, &d.CreatedAt, &d.UpdatedAt, &d.CompletedAt,
+		&tempPath, &d.Status, &errMsg, &d.RetryCount, &d.Verified, &d.Priority, &d.Archived, &d.SHA1, &d.SHA256, &d.CreatedAt, &d.UpdatedAt, &d.CompletedAt, &d.ScheduledAt, &d.Speed, &d.AvgSpeed,
 	)
 	if err != nil {
 		return nil, err
@@ -115,11 +165,27 @@ func GetDownloadByHash(hash string) (*Download, error) {
 	if errMsg.Valid {
 		d.ErrorMessage = errMsg.String
 	}
+	d.TempPath = tempPath.String
 	return d, nil
 }
 
-// ListDownloads retrieves downloads filtered by status
+// ListDownloads retrieves downloads filtered by status, archived ones included
 func ListDownloads(status DownloadStatus, showAll bool) ([]*Download, error) {
+	return listDownloads(status, showAll, true)
+}
+
+// ListUnarchivedDownloads is ListDownloads without archived downloads, for
+// views of the library itself
+func ListUnarchivedDownloads(status DownloadStatus, showAll bool) ([]*Download, error) {
+	return listDownloads(status, showAll, false)
+}
+
+func listDownloads(status DownloadStatus, showAll, withArchived bool) ([]*Download, error) {
+	archived := ""
+	if !withArchived {
+		archived = " AND archived = 0"
+	}
+
 	var rows *sql.Rows
 	var err error
 
@@ -133,38 +199,127 @@ func ListDownloads(status DownloadStatus, showAll bool) ([]*Download, error) {
 		rows, err = database.Query(`
 			SELECT id, md5_hash, title, authors, publisher, language, format,
 				file_size, downloaded_size, source_url, download_url, file_path,
-				temp_path, status, error_message, retry_count, verified, priority, created_at, updated_at, completed_at
-			FROM downloads WHERE status = ?
+				temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
+			FROM downloads WHERE status = ?`+archived+`
 			`+orderClause, status)
 	} else if showAll {
 		rows, err = database.Query(`
 			SELECT id, md5_hash, title, authors, publisher, language, format,
 				file_size, downloaded_size, source_url, download_url, file_path,
-				temp_path, status, error_message, retry_count, verified, priority, created_at, updated_at, completed_at
-			FROM downloads
+				temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
+			FROM downloads WHERE 1 = 1` + archived + `
 			ORDER BY updated_at DESC`)
 	} else {
 		// By default, don't show completed downloads
 		rows, err = database.Query(`
 			SELECT id, md5_hash, title, authors, publisher, language, format,
 				file_size, downloaded_size, source_url, download_url, file_path,
-				temp_path, status, error_message, retry_count, verified, priority, created_at, updated_at, completed_at
-			FROM downloads WHERE status != 'completed'
+				temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
+			FROM downloads WHERE status != 'completed'` + archived + `
 			ORDER BY updated_at DESC`)
 	}
 	if err != nil {
 		return nil, err
 	}
+	return scanDownloads(rows)
+}
+
+// ListArchivedDownloads retrieves archived downloads, most recent first
+func ListArchivedDownloads() ([]*Download, error) {
+	rows, err := database.Query(`
+		SELECT id, md5_hash, title, authors, publisher, language, format,
+			file_size, downloaded_size, source_url, download_url, file_path,
+			temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
+		FROM downloads WHERE archived = 1
+		ORDER BY completed_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return scanDownloads(rows)
+}
+
+// ListArchivable retrieves completed, unarchived downloads finished before cutoff
+func ListArchivable(cutoff time.Time) ([]*Download, error) {
+	rows, err := database.Query(`
+		SELECT id, md5_hash, title, authors, publisher, language, format,
+			file_size, downloaded_size, source_url, download_url, file_path,
+			temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
+		FROM downloads WHERE status = 'completed' AND archived = 0 AND completed_at < ?
+		ORDER BY completed_at ASC`, cutoff.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	return scanDownloads(rows)
+}
+
+// ListDueDownloads retrieves pending downloads that may start at now:
+// unscheduled ones and those whose scheduled time has passed
+func ListDueDownloads(now time.Time) ([]*Download, error) {
+	rows, err := database.Query(`
+		SELECT id, md5_hash, title, authors, publisher, language, format,
+			file_size, downloaded_size, source_url, download_url, file_path,
+			temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
+		FROM downloads WHERE status = ? AND archived = 0
+			AND (scheduled_at IS NULL OR scheduled_at <= ?)
+		ORDER BY priority DESC, created_at ASC`, StatusPending, now.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	return scanDownloads(rows)
+}
+
+// ListResumable retrieves everything 'resume all' should start: paused and
+// failed downloads, and pending ones that are due at now, highest priority
+// first and oldest first within a priority
+func ListResumable(now time.Time) ([]*Download, error) {
+	rows, err := database.Query(`
+		SELECT id, md5_hash, title, authors, publisher, language, format,
+			file_size, downloaded_size, source_url, download_url, file_path,
+			temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
+		FROM downloads WHERE archived = 0 AND (
+			status IN (?, ?)
+			OR (status = ? AND (scheduled_at IS NULL OR scheduled_at <= ?))
+		)
+		ORDER BY priority DESC, created_at ASC`,
+		StatusPaused, StatusFailed, StatusPending, now.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	return scanDownloads(rows)
+}
+
+// SetScheduledAt sets when a pending download may start; nil clears it
+func SetScheduledAt(id int64, at *time.Time) error {
+	var value interface{}
+	if at != nil {
+		value = at.UTC().Format("2006-01-02 15:04:05")
+	}
+	_, err := database.Exec(`
+		UPDATE downloads SET scheduled_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, value, id)
+	return err
+}
+
+// MarkArchived records that a download was moved to the archive
+func MarkArchived(id int64, filePath string) error {
+	_, err := database.Exec(`
+		UPDATE downloads SET archived = 1, file_path = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, filePath, id)
+	return err
+}
+
+// scanDownloads reads all download rows and closes rows
+func scanDownloads(rows *sql.Rows) ([]*Download, error) {
 	defer rows.Close()
 
 	var downloads []*Download
 	for rows.Next() {
 		d := &Download{}
-		var errMsg sql.NullString
+		var errMsg, tempPath sql.NullString
 		err := rows.Scan(
 			&d.ID, &d.MD5Hash, &d.Title, &d.Authors, &d.Publisher, &d.Language, &d.Format,
 			&d.FileSize, &d.DownloadedSize, &d.SourceURL, &d.DownloadURL, &d.FilePath,
-			&d.TempPath, &d.Status, &errMsg, &d.RetryCount, &d.Verified, &d.Priority, &d.CreatedAt, &d.UpdatedAt, &d.CompletedAt,
+			&tempPath, &d.Status, &errMsg, &d.RetryCount, &d.Verified, &d.Priority, &d.Archived, &d.SHA1, &d.SHA256, &d.CreatedAt, &d.UpdatedAt, &d.CompletedAt, &d.ScheduledAt, &d.Speed, &d.AvgSpeed,
 		)
 		if err != nil {
 			return nil, err
@@ -172,11 +327,46 @@ func ListDownloads(status DownloadStatus, showAll bool) ([]*Download, error) {
 		if errMsg.Valid {
 			d.ErrorMessage = errMsg.String
 		}
+		d.TempPath = tempPath.String
 		downloads = append(downloads, d)
 	}
 	return downloads, rows.Err()
 }
 
+// ExistingHashes reports which of md5s are already in the library, as a
+// download in any state or a bookmark
+func ExistingHashes(md5s []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(md5s) == 0 {
+		return existing, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(md5s)), ",")
+	args := make([]interface{}, 0, 2*len(md5s))
+	for _, md5 := range md5s {
+		args = append(args, md5)
+	}
+	args = append(args, args...)
+
+	rows, err := database.Query(`
+		SELECT md5_hash FROM downloads WHERE md5_hash IN (`+placeholders+`)
+		UNION
+		SELECT md5_hash FROM bookmarks WHERE md5_hash IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var md5 string
+		if err := rows.Scan(&md5); err != nil {
+			return nil, err
+		}
+		existing[md5] = true
+	}
+	return existing, rows.Err()
+}
+
 // UpdateStatus updates the download status
 func UpdateStatus(id int64, status DownloadStatus, errMsg string) error {
 	_, err := database.Exec(`
@@ -185,6 +375,27 @@ func UpdateStatus(id int64, status DownloadStatus, errMsg string) error {
 	return err
 }
 
+// TouchDownload marks a download as still alive without changing anything else
+func TouchDownload(id int64) error {
+	_, err := database.Exec(`UPDATE downloads SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`, id)
+	return err
+}
+
+// RecoverStaleDownloads pauses downloads left 'downloading' by a process
+// that died, recognised by not having been touched within staleAfter.
+// Downloads running in a live process (or the daemon) are touched
+// regularly and are left alone. Returns how many were recovered.
+func RecoverStaleDownloads(staleAfter time.Duration) (int64, error) {
+	res, err := database.Exec(`
+		UPDATE downloads SET status = ?, speed = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE status = ? AND updated_at < datetime('now', ?)`,
+		StatusPaused, StatusDownloading, fmt.Sprintf("-%d seconds", int(staleAfter.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // UpdateProgress updates the download progress
 func UpdateProgress(id int64, downloadedSize int64) error {
 	_, err := database.Exec(`
@@ -193,6 +404,21 @@ func UpdateProgress(id int64, downloadedSize int64) error {
 	return err
 }
 
+// UpdateSpeed records the current download speed in bytes per second
+func UpdateSpeed(id int64, speed float64) error {
+	_, err := database.Exec(`
+		UPDATE downloads SET speed = ? WHERE id = ?`, speed, id)
+	return err
+}
+
+// SetAvgSpeed records the average speed of a finished download and clears
+// its current speed
+func SetAvgSpeed(id int64, avgSpeed float64) error {
+	_, err := database.Exec(`
+		UPDATE downloads SET avg_speed = ?, speed = 0 WHERE id = ?`, avgSpeed, id)
+	return err
+}
+
 // UpdateDownloadURL updates the download URL
 func UpdateDownloadURL(id int64, url string) error {
 	_, err := database.Exec(`
@@ -201,6 +427,22 @@ func UpdateDownloadURL(id int64, url string) error {
 	return err
 }
 
+// UpdatePaths updates a download's destination, temp file and format
+func UpdatePaths(id int64, filePath, tempPath, format string) error {
+	_, err := database.Exec(`
+		UPDATE downloads SET file_path = ?, temp_path = ?, format = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, filePath, tempPath, format, id)
+	return err
+}
+
+// UpdateFilePath records a completed download's file at a new location
+func UpdateFilePath(id int64, filePath string) error {
+	_, err := database.Exec(`
+		UPDATE downloads SET file_path = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, filePath, id)
+	return err
+}
+
 // MarkCompleted marks a download as completed
 func MarkCompleted(id int64, filePath string) error {
 	_, err := database.Exec(`
@@ -214,6 +456,17 @@ func MarkCompleted(id int64, filePath string) error {
 	return err
 }
 
+// SetHashes stores the SHA-1 and SHA-256 published for a download.
+// Empty values leave the existing hash unchanged.
+func SetHashes(id int64, sha1, sha256 string) error {
+	_, err := database.Exec(`
+		UPDATE downloads SET
+			sha1 = COALESCE(NULLIF(?, ''), sha1),
+			sha256 = COALESCE(NULLIF(?, ''), sha256)
+		WHERE id = ?`, sha1, sha256, id)
+	return err
+}
+
 // MarkVerified marks a download as verified
 func MarkVerified(id int64, verified bool) error {
 	_, err := database.Exec(`
@@ -222,6 +475,32 @@ func MarkVerified(id int64, verified bool) error {
 	return err
 }
 
+// MarkFailed records a failed attempt: the download is marked failed with
+// errMsg and its retry count goes up by one
+func MarkFailed(id int64, errMsg string) error {
+	if err := UpdateStatus(id, StatusFailed, errMsg); err != nil {
+		return err
+	}
+	return IncrementRetry(id)
+}
+
+// ResetRetries clears a download's retry count, so 'resume all' tries it
+// again after it gave up
+func ResetRetries(id int64) error {
+	_, err := database.Exec(`UPDATE downloads SET retry_count = 0 WHERE id = ?`, id)
+	return err
+}
+
+// ResetAllRetries clears the retry count of every failed download and
+// returns how many were reset
+func ResetAllRetries() (int64, error) {
+	res, err := database.Exec(`UPDATE downloads SET retry_count = 0 WHERE status = ? AND retry_count > 0`, StatusFailed)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // IncrementRetry increments the retry count
 func IncrementRetry(id int64) error {
 	_, err := database.Exec(`
@@ -249,10 +528,23 @@ func ResetDownload(id int64) error {
 	return err
 }
 
-// DeleteDownload deletes a download record
+// DeleteDownload deletes a download record and its chunks. The chunks are
+// deleted explicitly: PRAGMA foreign_keys only applies to the pooled
+// connection it ran on, so ON DELETE CASCADE can't be relied on.
 func DeleteDownload(id int64) error {
-	_, err := database.Exec(`DELETE FROM downloads WHERE id = ?`, id)
-	return err
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM chunks WHERE download_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM downloads WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // CreateChunks creates chunk records for a download
@@ -287,7 +579,7 @@ func CreateChunks(downloadID int64, chunks []*Chunk) error {
 // GetChunks retrieves chunks for a download
 func GetChunks(downloadID int64) ([]*Chunk, error) {
 	rows, err := database.Query(`
-		SELECT id, download_id, chunk_index, start_byte, end_byte, downloaded, status
+		SELECT id, download_id, chunk_index, start_byte, end_byte, downloaded, status, COALESCE(checksum, '')
 		FROM chunks WHERE download_id = ?
 		ORDER BY chunk_index`, downloadID)
 	if err != nil {
@@ -298,7 +590,7 @@ func GetChunks(downloadID int64) ([]*Chunk, error) {
 	var chunks []*Chunk
 	for rows.Next() {
 		c := &Chunk{}
-		err := rows.Scan(&c.ID, &c.DownloadID, &c.ChunkIndex, &c.StartByte, &c.EndByte, &c.Downloaded, &c.Status)
+		err := rows.Scan(&c.ID, &c.DownloadID, &c.ChunkIndex, &c.StartByte, &c.EndByte, &c.Downloaded, &c.Status, &c.Checksum)
 		if err != nil {
 			return nil, err
 		}
@@ -343,6 +635,20 @@ func MarkChunkCompleted(chunkID int64) error {
 	return err
 }
 
+// SetChunkChecksum stores the checksum of a completed chunk
+func SetChunkChecksum(chunkID int64, checksum string) error {
+	_, err := database.Exec(`
+		UPDATE chunks SET checksum = ? WHERE id = ?`, checksum, chunkID)
+	return err
+}
+
+// ResetChunk marks a chunk as not downloaded so it is fetched again
+func ResetChunk(chunkID int64) error {
+	_, err := database.Exec(`
+		UPDATE chunks SET status = 'pending', downloaded = 0, checksum = NULL WHERE id = ?`, chunkID)
+	return err
+}
+
 // DeleteChunks deletes all chunks for a download
 func DeleteChunks(downloadID int64) error {
 	_, err := database.Exec(`DELETE FROM chunks WHERE download_id = ?`, downloadID)
@@ -352,7 +658,7 @@ func DeleteChunks(downloadID int64) error {
 // GetIncompleteChunks retrieves incomplete chunks for a download
 func GetIncompleteChunks(downloadID int64) ([]*Chunk, error) {
 	rows, err := database.Query(`
-		SELECT id, download_id, chunk_index, start_byte, end_byte, downloaded, status
+		SELECT id, download_id, chunk_index, start_byte, end_byte, downloaded, status, COALESCE(checksum, '')
 		FROM chunks WHERE download_id = ? AND status != 'completed'
 		ORDER BY chunk_index`, downloadID)
 	if err != nil {
@@ -363,7 +669,33 @@ func GetIncompleteChunks(downloadID int64) ([]*Chunk, error) {
 	var chunks []*Chunk
 	for rows.Next() {
 		c := &Chunk{}
-		err := rows.Scan(&c.ID, &c.DownloadID, &c.ChunkIndex, &c.StartByte, &c.EndByte, &c.Downloaded, &c.Status)
+		err := rows.Scan(&c.ID, &c.DownloadID, &c.ChunkIndex, &c.StartByte, &c.EndByte, &c.Downloaded, &c.Status, &c.Checksum)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// FindOrphanedChunks returns chunks left behind by downloads that no longer
+// exist or have already completed
+func FindOrphanedChunks() ([]*Chunk, error) {
+	rows, err := database.Query(`
+		SELECT c.id, c.download_id, c.chunk_index, c.start_byte, c.end_byte, c.downloaded, c.status, COALESCE(c.checksum, '')
+		FROM chunks c
+		LEFT JOIN downloads d ON d.id = c.download_id
+		WHERE d.id IS NULL OR d.status = 'completed'
+		ORDER BY c.download_id, c.chunk_index`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []*Chunk
+	for rows.Next() {
+		c := &Chunk{}
+		err := rows.Scan(&c.ID, &c.DownloadID, &c.ChunkIndex, &c.StartByte, &c.EndByte, &c.Downloaded, &c.Status, &c.Checksum)
 		if err != nil {
 			return nil, err
 		}
@@ -401,3 +733,86 @@ func SetPriorityBottom(id int64) error {
 	}
 	return UpdatePriority(id, minPriority-1)
 }
+
+// AuthorCount is the number of downloads by one author
+type AuthorCount struct {
+	Author string `json:"author"`
+	Count  int    `json:"count"`
+}
+
+// DownloadStats summarizes the download history
+type DownloadStats struct {
+	TotalFiles  int            `json:"total_files"`  // completed downloads
+	TotalBytes  int64          `json:"total_bytes"`  // size of completed downloads
+	AverageSize int64          `json:"average_size"` // average size of completed downloads
+	Unverified  int            `json:"unverified"`   // completed downloads without a verified checksum
+	ByStatus    map[string]int `json:"by_status"`
+	ByFormat    map[string]int `json:"by_format"`
+	TopAuthors  []AuthorCount  `json:"top_authors"`
+}
+
+// GetDownloadStats aggregates the downloads table
+func GetDownloadStats() (*DownloadStats, error) {
+	stats := &DownloadStats{
+		ByStatus: make(map[string]int),
+		ByFormat: make(map[string]int),
+	}
+
+	err := database.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(file_size), 0), CAST(COALESCE(AVG(file_size), 0) AS INTEGER),
+			COALESCE(SUM(CASE WHEN verified = 0 THEN 1 ELSE 0 END), 0)
+		FROM downloads WHERE status = 'completed'`).Scan(
+		&stats.TotalFiles, &stats.TotalBytes, &stats.AverageSize, &stats.Unverified,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := countGrouped(`
+		SELECT status, COUNT(*) FROM downloads GROUP BY status`, stats.ByStatus); err != nil {
+		return nil, err
+	}
+
+	if err := countGrouped(`
+		SELECT UPPER(COALESCE(NULLIF(format, ''), 'unknown')), COUNT(*)
+		FROM downloads WHERE status = 'completed' GROUP BY 1`, stats.ByFormat); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.Query(`
+		SELECT authors, COUNT(*) AS n FROM downloads
+		WHERE status = 'completed' AND authors IS NOT NULL AND authors != ''
+		GROUP BY authors ORDER BY n DESC, authors LIMIT 5`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ac AuthorCount
+		if err := rows.Scan(&ac.Author, &ac.Count); err != nil {
+			return nil, err
+		}
+		stats.TopAuthors = append(stats.TopAuthors, ac)
+	}
+	return stats, rows.Err()
+}
+
+// countGrouped runs a "SELECT key, COUNT(*) ... GROUP BY" query into counts
+func countGrouped(query string, counts map[string]int) error {
+	rows, err := database.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var n int
+		if err := rows.Scan(&key, &n); err != nil {
+			return err
+		}
+		counts[key] = n
+	}
+	return rows.Err()
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// addDownload records a download with the given status, priority and
+// creation time, which is also when it was last updated
+func addDownload(t *testing.T, title string, status DownloadStatus, priority int, createdAt time.Time) *Download {
+	t.Helper()
+	d := &Download{
+		MD5Hash:   fmt.Sprintf("%032x", time.Now().UnixNano()),
+		Title:     title,
+		Format:    "epub",
+		SourceURL: "https://example.com/md5/" + title,
+		Status:    status,
+	}
+	if err := CreateDownload(d); err != nil {
+		t.Fatal(err)
+	}
+	_, err := database.Exec(`UPDATE downloads SET priority = ?, created_at = ?, updated_at = ? WHERE id = ?`,
+		priority, createdAt.UTC().Format("2006-01-02 15:04:05"), createdAt.UTC().Format("2006-01-02 15:04:05"), d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// clearDownloads removes every download, so a test starts from an empty table
+func clearDownloads(t *testing.T) {
+	t.Helper()
+	if _, err := database.Exec(`DELETE FROM downloads`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func titles(downloads []*Download) []string {
+	var out []string
+	for _, d := range downloads {
+		out = append(out, d.Title)
+	}
+	return out
+}
+
+func TestListResumableOrder(t *testing.T) {
+	clearDownloads(t)
+	base := time.Now().Add(-time.Hour)
+	addDownload(t, "low-old", StatusPaused, 0, base)
+	addDownload(t, "high-new", StatusFailed, 5, base.Add(2*time.Minute))
+	addDownload(t, "low-new", StatusPending, 0, base.Add(3*time.Minute))
+	addDownload(t, "high-old", StatusPending, 5, base.Add(time.Minute))
+	addDownload(t, "done", StatusCompleted, 9, base)
+
+	got, err := ListResumable(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"high-old", "high-new", "low-old", "low-new"}
+	if fmt.Sprint(titles(got)) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", titles(got), want)
+	}
+}
+
+func TestListResumableSkipsScheduled(t *testing.T) {
+	clearDownloads(t)
+	now := time.Now()
+	due := addDownload(t, "due", StatusPending, 0, now.Add(-time.Hour))
+	later := addDownload(t, "later", StatusPending, 0, now.Add(-time.Hour))
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	if err := SetScheduledAt(due.ID, &past); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetScheduledAt(later.ID, &future); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ListResumable(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(titles(got)) != "[due]" {
+		t.Errorf("got %v, want only the due download", titles(got))
+	}
+}
+
+func TestRecoverStaleDownloads(t *testing.T) {
+	clearDownloads(t)
+	now := time.Now()
+	stale := addDownload(t, "stale", StatusDownloading, 0, now.Add(-10*time.Minute))
+	live := addDownload(t, "live", StatusDownloading, 0, now.Add(-10*time.Second))
+	failed := addDownload(t, "failed", StatusFailed, 0, now.Add(-time.Hour))
+
+	n, err := RecoverStaleDownloads(2 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("recovered %d downloads, want 1", n)
+	}
+
+	for _, tt := range []struct {
+		d    *Download
+		want DownloadStatus
+	}{
+		{stale, StatusPaused},
+		{live, StatusDownloading},
+		{failed, StatusFailed},
+	} {
+		got, err := GetDownload(tt.d.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != tt.want {
+			t.Errorf("%s: status %s, want %s", tt.d.Title, got.Status, tt.want)
+		}
+	}
+}
+
+func TestMarkFailed(t *testing.T) {
+	clearDownloads(t)
+	d := addDownload(t, "book", StatusDownloading, 0, time.Now())
+
+	for i := 1; i <= 2; i++ {
+		if err := MarkFailed(d.ID, "mirror down"); err != nil {
+			t.Fatal(err)
+		}
+		got, err := GetDownload(d.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != StatusFailed || got.ErrorMessage != "mirror down" {
+			t.Errorf("status %s, error %q", got.Status, got.ErrorMessage)
+		}
+		if got.RetryCount != i {
+			t.Errorf("retry count %d after %d failures", got.RetryCount, i)
+		}
+	}
+}
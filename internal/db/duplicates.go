@@ -0,0 +1,54 @@
+package db
+
+import "github.com/billmal071/bookdl/internal/fuzzy"
+
+// FindDuplicateDownloads groups completed, unarchived downloads that look
+// like the same book (same normalized title and first author), e.g. one
+// book downloaded as both EPUB and PDF. Only groups of two or more are
+// returned, oldest download first within each group.
+func FindDuplicateDownloads() ([][]*Download, error) {
+	rows, err := database.Query(`
+		SELECT id, md5_hash, title, authors, publisher, language, format,
+			file_size, downloaded_size, source_url, download_url, file_path,
+			temp_path, status, error_message, retry_count, verified, priority, archived, COALESCE(sha1, ''), COALESCE(sha256, ''), created_at, updated_at, completed_at, scheduled_at, speed, avg_speed
+		FROM downloads WHERE status = 'completed' AND archived = 0
+		ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	downloads, err := scanDownloads(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]*Download)
+	var keys []string
+	for _, d := range downloads {
+		key := DuplicateKey(d.Title, d.Authors)
+		if key == "" {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], d)
+	}
+
+	var clusters [][]*Download
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			clusters = append(clusters, groups[key])
+		}
+	}
+	return clusters, nil
+}
+
+// DuplicateKey returns the key two copies of the same book share: the
+// normalized title without its subtitle, plus the normalized first author
+func DuplicateKey(title, authors string) string {
+	t := fuzzy.NormalizeTitle(title)
+	if t == "" {
+		return ""
+	}
+	return t + "|" + fuzzy.NormalizeAuthor(authors)
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestMain runs the tests against a fresh database in a throwaway home
+// directory
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	home, err := os.MkdirTemp("", "bookdl-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(home)
+	os.Setenv("HOME", home)
+
+	if err := Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer Close()
+
+	return m.Run()
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GetMeta returns a value from the meta table, or "" if unset
+func GetMeta(key string) (string, error) {
+	var value string
+	err := database.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+// SetMeta stores a value in the meta table
+func SetMeta(key, value string) error {
+	_, err := database.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, key, value)
+	return err
+}
+
+// IsDue reports whether more than interval has passed since the time
+// recorded under key with MarkDone (or if it was never recorded)
+func IsDue(key string, interval time.Duration) (bool, error) {
+	last, err := GetMeta(key)
+	if err != nil {
+		return false, err
+	}
+	t, err := time.Parse(time.RFC3339, last)
+	if err != nil {
+		return true, nil
+	}
+	return time.Since(t) >= interval, nil
+}
+
+// MarkDone records the current time under key
+func MarkDone(key string) error {
+	return SetMeta(key, time.Now().UTC().Format(time.RFC3339))
+}
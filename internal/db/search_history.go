@@ -1,6 +1,7 @@
 package db
 
 import (
+	"database/sql"
 	"encoding/json"
 	"time"
 )
@@ -16,10 +17,13 @@ type SearchHistory struct {
 
 // SearchFilters stores the filters used in a search
 type SearchFilters struct {
-	Format   string `json:"format,omitempty"`
-	Language string `json:"language,omitempty"`
-	Year     string `json:"year,omitempty"`
-	MaxSize  string `json:"max_size,omitempty"`
+	Format    string `json:"format,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Year      string `json:"year,omitempty"`
+	MaxSize   string `json:"max_size,omitempty"`
+	Author    string `json:"author,omitempty"`
+	Publisher string `json:"publisher,omitempty"`
+	Exact     bool   `json:"exact,omitempty"` // query was searched as a phrase
 }
 
 // AddSearchHistory adds a search to history
@@ -51,25 +55,7 @@ func GetSearchHistory(limit int) ([]*SearchHistory, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var history []*SearchHistory
-	for rows.Next() {
-		h := &SearchHistory{}
-		var filtersJSON string
-		err := rows.Scan(&h.ID, &h.Query, &h.ResultCount, &filtersJSON, &h.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-
-		// Parse filters JSON
-		if filtersJSON != "" {
-			json.Unmarshal([]byte(filtersJSON), &h.Filters)
-		}
-
-		history = append(history, h)
-	}
-	return history, rows.Err()
+	return scanSearchHistory(rows)
 }
 
 // GetUniqueSearchHistory retrieves unique recent searches (no duplicates)
@@ -89,6 +75,29 @@ func GetUniqueSearchHistory(limit int) ([]*SearchHistory, error) {
 	if err != nil {
 		return nil, err
 	}
+	return scanSearchHistory(rows)
+}
+
+// SearchHistoryByQuery returns past searches whose query contains term,
+// ignoring case: the latest of each distinct query, newest first
+func SearchHistoryByQuery(term string) ([]*SearchHistory, error) {
+	rows, err := database.Query(`
+		SELECT id, query, result_count, filters, created_at
+		FROM search_history
+		WHERE id IN (
+			SELECT MAX(id) FROM search_history
+			WHERE instr(lower(query), lower(?)) > 0
+			GROUP BY query
+		)
+		ORDER BY created_at DESC`, term)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchHistory(rows)
+}
+
+// scanSearchHistory reads search history rows and closes them
+func scanSearchHistory(rows *sql.Rows) ([]*SearchHistory, error) {
 	defer rows.Close()
 
 	var history []*SearchHistory
@@ -100,6 +109,7 @@ func GetUniqueSearchHistory(limit int) ([]*SearchHistory, error) {
 			return nil, err
 		}
 
+		// Parse filters JSON
 		if filtersJSON != "" {
 			json.Unmarshal([]byte(filtersJSON), &h.Filters)
 		}
@@ -0,0 +1,98 @@
+package db
+
+import "strings"
+
+// normalizeTag lowercases and trims a tag so "Work" and "work " match
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// AddTag tags a book (download or bookmark) by MD5 hash
+func AddTag(md5Hash, tag string) error {
+	_, err := database.Exec(`INSERT OR IGNORE INTO tags (md5_hash, tag) VALUES (?, ?)`,
+		md5Hash, normalizeTag(tag))
+	return err
+}
+
+// RemoveTag removes a tag from a book
+func RemoveTag(md5Hash, tag string) error {
+	_, err := database.Exec(`DELETE FROM tags WHERE md5_hash = ? AND tag = ?`,
+		md5Hash, normalizeTag(tag))
+	return err
+}
+
+// ListTags returns a book's tags in alphabetical order
+func ListTags(md5Hash string) ([]string, error) {
+	return queryStrings(`SELECT tag FROM tags WHERE md5_hash = ? ORDER BY tag`, md5Hash)
+}
+
+// ListByTag returns the MD5 hashes of all books with a tag
+func ListByTag(tag string) ([]string, error) {
+	return queryStrings(`SELECT md5_hash FROM tags WHERE tag = ? ORDER BY created_at`, normalizeTag(tag))
+}
+
+// ListDownloadsByTag returns non-archived downloads with a tag
+func ListDownloadsByTag(tag string) ([]*Download, error) {
+	rows, err := database.Query(`
+		SELECT d.id, d.md5_hash, d.title, d.authors, d.publisher, d.language, d.format,
+			d.file_size, d.downloaded_size, d.source_url, d.download_url, d.file_path,
+			d.temp_path, d.status, d.error_message, d.retry_count, d.verified, d.priority, d.archived, COALESCE(d.sha1, ''), COALESCE(d.sha256, ''), d.created_at, d.updated_at, d.completed_at, d.scheduled_at, d.speed, d.avg_speed
+		FROM downloads d JOIN tags t ON t.md5_hash = d.md5_hash
+		WHERE t.tag = ? AND d.archived = 0
+		ORDER BY d.updated_at DESC`, normalizeTag(tag))
+	if err != nil {
+		return nil, err
+	}
+	return scanDownloads(rows)
+}
+
+// ListBookmarksByTag returns bookmarks with a tag
+func ListBookmarksByTag(tag string) ([]*Bookmark, error) {
+	rows, err := database.Query(`
+		SELECT b.id, b.md5_hash, b.title, b.authors, b.publisher, b.year, b.language, b.format, b.size, b.page_url, b.notes, b.status, b.created_at
+		FROM bookmarks b JOIN tags t ON t.md5_hash = b.md5_hash
+		WHERE t.tag = ?
+		ORDER BY b.created_at DESC`, normalizeTag(tag))
+	if err != nil {
+		return nil, err
+	}
+	return scanBookmarks(rows)
+}
+
+// ListAllTags returns every tag in use with the number of books it's on
+func ListAllTags() (map[string]int, error) {
+	rows, err := database.Query(`SELECT tag, COUNT(*) FROM tags GROUP BY tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var tag string
+		var n int
+		if err := rows.Scan(&tag, &n); err != nil {
+			return nil, err
+		}
+		counts[tag] = n
+	}
+	return counts, rows.Err()
+}
+
+func queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,140 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/log"
+)
+
+const (
+	// HostFailureThreshold is how many requests in a row must fail before a
+	// host is skipped. It matches the default retry budget, so one request
+	// retried to exhaustion is enough.
+	HostFailureThreshold = 5
+
+	// HostCooldown is how long a failing host is skipped before it's tried
+	// again
+	HostCooldown = 2 * time.Minute
+)
+
+// ErrHostDown is returned instead of contacting a host that kept failing,
+// until its cooldown ends. The download should move on to another mirror.
+var ErrHostDown = errors.New("host is down")
+
+// hostBreaker counts consecutive failures per host and, once a host reaches
+// the threshold, fails requests to it straight away for the cooldown. A
+// success resets the count. Downloads running at the same time share it,
+// so a dead gateway costs a batch one retry budget rather than one each.
+type hostBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	hosts     map[string]*hostState
+}
+
+type hostState struct {
+	failures  int
+	openUntil time.Time
+}
+
+func newHostBreaker(threshold int, cooldown time.Duration) *hostBreaker {
+	return &hostBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     make(map[string]*hostState),
+	}
+}
+
+// allow returns ErrHostDown if host is cooling down
+func (b *hostBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.hosts[host]
+	if state == nil || state.openUntil.IsZero() {
+		return nil
+	}
+	if wait := state.openUntil.Sub(time.Now()); wait > 0 {
+		return fmt.Errorf("%w: %s failed %d times in a row, skipping it for %v", ErrHostDown, host, state.failures, wait.Round(time.Second))
+	}
+
+	// Cooldown over: let requests through again. One more failure reopens
+	// the breaker.
+	state.openUntil = time.Time{}
+	state.failures = b.threshold - 1
+	return nil
+}
+
+// success resets host's failure count
+func (b *hostBreaker) success(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.hosts, host)
+}
+
+// failure counts a failed request to host, opening the breaker once the
+// threshold is reached
+func (b *hostBreaker) failure(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.hosts[host]
+	if state == nil {
+		state = &hostState{}
+		b.hosts[host] = state
+	}
+	state.failures++
+	if state.failures >= b.threshold && state.openUntil.IsZero() {
+		state.openUntil = time.Now().Add(b.cooldown)
+		log.Warn("host keeps failing, skipping it", "host", host, "failures", state.failures, "cooldown", b.cooldown)
+	}
+}
+
+// down reports whether host is cooling down
+func (b *hostBreaker) down(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.hosts[host]
+	return state != nil && time.Now().Before(state.openUntil)
+}
+
+// breakerTransport sends requests through next unless the host's breaker is
+// open, and records the outcome. Connection errors and 5xx responses count
+// as failures; other responses mean the host is up.
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *hostBreaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.breaker.allow(host); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// Cancelled or paused; says nothing about the host
+	case err != nil || resp.StatusCode >= 500:
+		t.breaker.failure(host)
+	default:
+		t.breaker.success(host)
+	}
+	return resp, err
+}
+
+// HostDown reports whether rawURL's host failed repeatedly and is being
+// skipped, so callers can pick another mirror without trying it
+func (m *Manager) HostDown(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || m.breaker == nil {
+		return false
+	}
+	return m.breaker.down(u.Host)
+}
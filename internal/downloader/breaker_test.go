@@ -0,0 +1,147 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostBreakerTripsAtThreshold(t *testing.T) {
+	b := newHostBreaker(3, time.Minute)
+	for i := 0; i < 2; i++ {
+		b.failure("a.example")
+	}
+	if err := b.allow("a.example"); err != nil {
+		t.Fatalf("open after 2 of 3 failures: %v", err)
+	}
+
+	b.failure("a.example")
+	if err := b.allow("a.example"); !errors.Is(err, ErrHostDown) {
+		t.Fatalf("err = %v, want ErrHostDown after 3 failures", err)
+	}
+	if !b.down("a.example") {
+		t.Error("down = false for a tripped host")
+	}
+	if err := b.allow("b.example"); err != nil {
+		t.Errorf("another host is affected: %v", err)
+	}
+}
+
+func TestHostBreakerCooldown(t *testing.T) {
+	b := newHostBreaker(2, 20*time.Millisecond)
+	b.failure("a.example")
+	b.failure("a.example")
+	if err := b.allow("a.example"); !errors.Is(err, ErrHostDown) {
+		t.Fatalf("err = %v, want ErrHostDown", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := b.allow("a.example"); err != nil {
+		t.Fatalf("still open after the cooldown: %v", err)
+	}
+
+	// One more failure after the cooldown reopens it straight away
+	b.failure("a.example")
+	if err := b.allow("a.example"); !errors.Is(err, ErrHostDown) {
+		t.Errorf("err = %v, want ErrHostDown after a failure following the cooldown", err)
+	}
+}
+
+func TestHostBreakerResetOnSuccess(t *testing.T) {
+	b := newHostBreaker(3, time.Minute)
+	b.failure("a.example")
+	b.failure("a.example")
+	b.success("a.example")
+	b.failure("a.example")
+	b.failure("a.example")
+	if err := b.allow("a.example"); err != nil {
+		t.Errorf("the count wasn't reset by a success: %v", err)
+	}
+}
+
+// breakerServer returns a server answering with status, counting requests,
+// and a client going through a breakerTransport
+func breakerServer(t *testing.T, status int) (*httptest.Server, *http.Client, *hostBreaker, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	breaker := newHostBreaker(2, time.Minute)
+	client := &http.Client{Transport: &breakerTransport{next: srv.Client().Transport, breaker: breaker}}
+	return srv, client, breaker, &requests
+}
+
+func TestBreakerTransportSkipsFailingHost(t *testing.T) {
+	srv, client, _, requests := breakerServer(t, http.StatusBadGateway)
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrHostDown) {
+		t.Fatalf("err = %v, want ErrHostDown", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("server got %d requests, want 2", n)
+	}
+
+	m := &Manager{breaker: client.Transport.(*breakerTransport).breaker}
+	if !m.HostDown(srv.URL + "/some/book.epub") {
+		t.Error("HostDown = false for the failing server")
+	}
+}
+
+func TestBreakerTransportClientErrorsMeanUp(t *testing.T) {
+	srv, client, breaker, _ := breakerServer(t, http.StatusNotFound)
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	u, _ := url.Parse(srv.URL)
+	if breaker.down(u.Host) {
+		t.Error("404s tripped the breaker")
+	}
+}
+
+func TestBreakerTransportIgnoresCancellation(t *testing.T) {
+	// Never answers until the request is cancelled
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-block:
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	breaker := newHostBreaker(1, time.Minute)
+	client := &http.Client{Transport: &breakerTransport{next: srv.Client().Transport, breaker: breaker}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("the cancelled request succeeded")
+	}
+
+	u, _ := url.Parse(srv.URL)
+	if err := breaker.allow(u.Host); err != nil {
+		t.Errorf("a cancelled request counted as a failure: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+const testFileSize = 1000
+
+// chunkFixture is a partial file of 'a's and a download and middle chunk
+// pointing at a test server
+func chunkFixture(t *testing.T, handler http.HandlerFunc) (*Manager, *db.Download, *db.Chunk, *os.File) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "book.epub.part")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("a"), testFileSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	download := &db.Download{DownloadURL: srv.URL, FileSize: testFileSize, TempPath: path}
+	chunk := &db.Chunk{ChunkIndex: 1, StartByte: 400, EndByte: 599}
+	m := &Manager{httpClient: srv.Client()}
+	return m, download, chunk, file
+}
+
+func newTestChunkProgress() *chunkProgress {
+	return &chunkProgress{count: 3, maxAttempts: 3, bar: createProgressBar(testFileSize, "test")}
+}
+
+func TestDownloadChunkRejectsIgnoredRange(t *testing.T) {
+	m, download, chunk, file := chunkFixture(t, func(w http.ResponseWriter, r *http.Request) {
+		// Ignores Range and sends the whole file
+		w.Write(bytes.Repeat([]byte("b"), testFileSize))
+	})
+
+	err := m.downloadChunk(context.Background(), download, chunk, file, newTestChunkProgress())
+	if !errors.Is(err, ErrRangeIgnored) {
+		t.Fatalf("err = %v, want ErrRangeIgnored", err)
+	}
+
+	data, _ := os.ReadFile(download.TempPath)
+	if !bytes.Equal(data, bytes.Repeat([]byte("a"), testFileSize)) {
+		t.Error("the partial file was written to")
+	}
+}
+
+func TestDownloadChunkStopsAtEndByte(t *testing.T) {
+	m, download, chunk, file := chunkFixture(t, func(w http.ResponseWriter, r *http.Request) {
+		// Claims the range but sends everything from its start
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 400-599/%d", testFileSize))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(bytes.Repeat([]byte("b"), testFileSize-400))
+	})
+
+	if err := m.downloadChunk(context.Background(), download, chunk, file, newTestChunkProgress()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(download.TempPath)
+	want := append(append(bytes.Repeat([]byte("a"), 400), bytes.Repeat([]byte("b"), 200)...), bytes.Repeat([]byte("a"), 400)...)
+	if !bytes.Equal(data, want) {
+		t.Error("the chunk was written past its end byte")
+	}
+	if chunk.Downloaded != 200 {
+		t.Errorf("chunk.Downloaded = %d, want 200", chunk.Downloaded)
+	}
+}
+
+func TestChunkProgressShowsAttempts(t *testing.T) {
+	p := newTestChunkProgress()
+	p.setRetrying(1, 2)
+	p.setRetrying(0, 3)
+	want := "Chunks 0/3 (chunk 1 retrying 3/3) (chunk 2 retrying 2/3)"
+	if got := p.label(); got != want {
+		t.Errorf("description = %q, want %q", got, want)
+	}
+	p.setRetrying(0, 0)
+	p.setRetrying(1, 0)
+	if got := p.label(); got != "Chunks 0/3" {
+		t.Errorf("description after retries = %q, want %q", got, "Chunks 0/3")
+	}
+}
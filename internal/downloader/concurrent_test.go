@@ -0,0 +1,53 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+func TestStartConcurrentKeepsOrder(t *testing.T) {
+	// Every download fails at once; only the order they start in matters
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	dir := t.TempDir()
+	var downloads []*db.Download
+	for i := 0; i < 8; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("book%d.epub", i))
+		downloads = append(downloads, &db.Download{
+			ID:          int64(1000 + i),
+			DownloadURL: srv.URL,
+			FilePath:    path,
+			TempPath:    path + ".part",
+		})
+	}
+
+	m := NewManager()
+	m.maxConcurrent = 1
+
+	var mu sync.Mutex
+	var started []int64
+	m.StartConcurrent(context.Background(), downloads, func(id int64, status string, _ float64) {
+		if status == "starting" {
+			mu.Lock()
+			started = append(started, id)
+			mu.Unlock()
+		}
+	})
+
+	var want []int64
+	for _, d := range downloads {
+		want = append(want, d.ID)
+	}
+	if !reflect.DeepEqual(started, want) {
+		t.Errorf("started %v, want %v", started, want)
+	}
+}
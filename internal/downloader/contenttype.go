@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// ErrContentType indicates the server sent a media type that isn't in
+// downloads.allowed_content_types
+var ErrContentType = errors.New("content type not allowed")
+
+// genericContentTypes say nothing about what a file is, so the start of the
+// file is checked instead
+var genericContentTypes = map[string]bool{
+	"":                           true,
+	"application/octet-stream":   true,
+	"binary/octet-stream":        true,
+	"application/download":       true,
+	"application/x-download":     true,
+	"application/force-download": true,
+	"application/unknown":        true,
+}
+
+// checkContentType validates a response's Content-Type header. HTML is
+// always rejected; generic types are accepted but need sniffing, and other
+// types must be allowed by downloads.allowed_content_types (if set).
+func (m *Manager) checkContentType(header string) (sniff bool, err error) {
+	mediaType, _, parseErr := mime.ParseMediaType(header)
+	if parseErr != nil {
+		mediaType = strings.TrimSpace(strings.SplitN(header, ";", 2)[0])
+	}
+	mediaType = strings.ToLower(mediaType)
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return false, ErrHTMLContent
+	case genericContentTypes[mediaType]:
+		return true, nil
+	case len(m.allowedTypes) == 0:
+		// Nothing configured: accept the type but keep checking the file
+		return true, nil
+	}
+
+	for _, allowed := range m.allowedTypes {
+		if strings.EqualFold(strings.TrimSpace(allowed), mediaType) {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%w: %s", ErrContentType, mediaType)
+}
+
+// sniffErrorPage returns the first downloads.sniff_markers entry found in
+// the start of a file, or "" if it doesn't look like an error page
+func (m *Manager) sniffErrorPage(start []byte) string {
+	text := strings.ToLower(string(start))
+	for _, marker := range m.sniffMarkers {
+		if marker != "" && strings.Contains(text, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// lowerAll returns the strings in lowercase
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, v := range values {
+		lowered[i] = strings.ToLower(v)
+	}
+	return lowered
+}
@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// contentTypeManager accepts EPUB and PDF and sniffs for HTML and captchas
+func contentTypeManager() *Manager {
+	return &Manager{
+		allowedTypes: []string{"application/epub+zip", " Application/PDF "},
+		sniffMarkers: lowerAll([]string{"<html", "Captcha"}),
+	}
+}
+
+func TestCheckContentType(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantSniff bool
+		wantErr   error
+	}{
+		{"application/epub+zip", false, nil},
+		{"application/pdf; name=book.pdf", false, nil},
+		{"APPLICATION/PDF", false, nil},
+		{"", true, nil},
+		{"application/octet-stream", true, nil},
+		{"application/force-download; charset=binary", true, nil},
+		{"text/html; charset=utf-8", false, ErrHTMLContent},
+		{"application/xhtml+xml", false, ErrHTMLContent},
+		{"image/png", false, ErrContentType},
+		{"application/pdf;;broken", false, nil},
+	}
+	m := contentTypeManager()
+	for _, tt := range tests {
+		sniff, err := m.checkContentType(tt.header)
+		if sniff != tt.wantSniff || !errors.Is(err, tt.wantErr) {
+			t.Errorf("checkContentType(%q) = %v, %v, want %v, %v", tt.header, sniff, err, tt.wantSniff, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckContentTypeWithoutAllowList(t *testing.T) {
+	m := &Manager{}
+	if sniff, err := m.checkContentType("image/png"); !sniff || err != nil {
+		t.Errorf("got %v, %v; want any type accepted but sniffed", sniff, err)
+	}
+	if _, err := m.checkContentType("text/html"); !errors.Is(err, ErrHTMLContent) {
+		t.Errorf("got %v, want HTML rejected without an allow list too", err)
+	}
+}
+
+func TestSniffErrorPage(t *testing.T) {
+	m := contentTypeManager()
+	tests := []struct {
+		start string
+		want  string
+	}{
+		{"PK\x03\x04mimetypeapplication/epub+zip", ""},
+		{"%PDF-1.7", ""},
+		{"<!DOCTYPE html><HTML><body>", "<html"},
+		{"Please complete the CAPTCHA to continue", "captcha"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := m.sniffErrorPage([]byte(tt.start)); got != tt.want {
+			t.Errorf("sniffErrorPage(%q) = %q, want %q", tt.start, got, tt.want)
+		}
+	}
+}
+
+// TestDownloadSimpleContentChecks covers both paths through a download: an
+// explicitly allowed type is trusted as is, while a generic one has the
+// start of the file sniffed
+func TestDownloadSimpleContentChecks(t *testing.T) {
+	page := []byte("<html><body>Please complete the captcha</body></html>")
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantErr     error
+	}{
+		{"allowed type is not sniffed", "application/epub+zip", page, nil},
+		{"generic type with a file", "application/octet-stream", simpleContent, nil},
+		{"generic type with an error page", "application/octet-stream", page, ErrHTMLContent},
+		{"html type", "text/html", simpleContent, ErrHTMLContent},
+		{"disallowed type", "image/png", simpleContent, ErrContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, download := simpleFixture(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write(tt.body)
+			})
+			m.allowedTypes = contentTypeManager().allowedTypes
+			m.sniffMarkers = contentTypeManager().sniffMarkers
+
+			err := m.downloadSimple(context.Background(), download, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if _, statErr := os.Stat(download.FilePath); (statErr == nil) != (tt.wantErr == nil) {
+				t.Errorf("file exists = %v, want %v", statErr == nil, tt.wantErr == nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+// Downloads run inside whichever bookdl process started them, so a `bookdl pause`
+// in another terminal can't reach the cancel func in Manager.active. Instead,
+// pause requests are written as control files (~/.config/bookdl/control/pause-<id>)
+// that every running download polls for.
+
+// ErrPaused indicates the download was stopped by a pause request
+var ErrPaused = errors.New("download paused")
+
+// ErrInterrupted is the cancel cause for a context cancelled by Ctrl-C or
+// SIGTERM. Downloads cancelled with it are paused rather than failed.
+var ErrInterrupted = errors.New("interrupted")
+
+// Interrupted reports whether ctx was cancelled with ErrInterrupted
+func Interrupted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrInterrupted)
+}
+
+// controlPollInterval is how often a running download checks for pause requests
+const controlPollInterval = time.Second
+
+// heartbeatInterval is how often a running download touches its database
+// row, so other processes can tell it from one left behind by a crash
+const heartbeatInterval = 30 * time.Second
+
+// StaleAfter is how long a 'downloading' row can go untouched before it's
+// taken to belong to a process that died. It's a few missed heartbeats.
+const StaleAfter = 4 * heartbeatInterval
+
+// controlDir returns the directory holding inter-process control files
+func controlDir() string {
+	return filepath.Join(config.GetConfigDir(), "control")
+}
+
+// pauseRequestPath returns the control file path for a download's pause request
+func pauseRequestPath(downloadID int64) string {
+	return filepath.Join(controlDir(), fmt.Sprintf("pause-%d", downloadID))
+}
+
+// RequestPause asks the process running the download to pause it
+func RequestPause(downloadID int64) error {
+	if err := os.MkdirAll(controlDir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(pauseRequestPath(downloadID), []byte(time.Now().Format(time.RFC3339)), 0644)
+}
+
+// clearPauseRequest removes a pending pause request for a download
+func clearPauseRequest(downloadID int64) {
+	os.Remove(pauseRequestPath(downloadID))
+}
+
+// pauseRequested reports whether a pause request exists for a download
+func pauseRequested(downloadID int64) bool {
+	_, err := os.Stat(pauseRequestPath(downloadID))
+	return err == nil
+}
+
+// watchPauseRequests cancels the download when a pause request appears,
+// and keeps its heartbeat going meanwhile. It returns when ctx is done.
+func watchPauseRequests(ctx context.Context, downloadID int64, onPause func()) {
+	ticker := time.NewTicker(controlPollInterval)
+	defer ticker.Stop()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			db.TouchDownload(downloadID)
+		case <-ticker.C:
+			if pauseRequested(downloadID) {
+				clearPauseRequest(downloadID)
+				onPause()
+				return
+			}
+		}
+	}
+}
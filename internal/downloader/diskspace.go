@@ -0,0 +1,104 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+// ErrInsufficientSpace indicates a download wouldn't fit on its disk while
+// leaving downloads.min_free_space free
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// errSpaceUnknown is returned where free space can't be measured; the
+// check is skipped then
+var errSpaceUnknown = errors.New("free disk space is unknown on this platform")
+
+// remainingBytes returns how many more bytes download needs on disk, 0 if
+// its size isn't known
+func remainingBytes(download *db.Download) int64 {
+	if download.FileSize <= 0 || download.DownloadedSize >= download.FileSize {
+		return 0
+	}
+	return download.FileSize - download.DownloadedSize
+}
+
+// existingDir returns the closest directory at or above path that exists,
+// so free space can be measured before the download's directory is created
+func existingDir(path string) string {
+	dir := filepath.Dir(path)
+	for {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// checkDiskSpace fails fast if need more bytes wouldn't fit next to path
+// while keeping m.minFreeSpace free
+func (m *Manager) checkDiskSpace(path string, need int64) error {
+	if need <= 0 {
+		return nil
+	}
+	free, _, err := diskSpace(existingDir(path))
+	if err != nil {
+		return nil
+	}
+	return m.compareSpace(need, free)
+}
+
+// compareSpace returns ErrInsufficientSpace if need bytes plus the
+// configured buffer don't fit in free
+func (m *Manager) compareSpace(need int64, free uint64) error {
+	if uint64(need+m.minFreeSpace) <= free {
+		return nil
+	}
+	have := int64(free) - m.minFreeSpace
+	if have < 0 {
+		have = 0
+	}
+	return fmt.Errorf("%w: need %s, have %s (keeping %s free)", ErrInsufficientSpace,
+		FormatBytes(need), FormatBytes(have), FormatBytes(m.minFreeSpace))
+}
+
+// preflightDiskSpace checks that a batch of downloads fits, adding up what
+// they need on each filesystem. Downloads are admitted in order; the
+// returned map holds an error for each one that doesn't fit.
+func (m *Manager) preflightDiskSpace(downloads []*db.Download) map[int]error {
+	type volume struct {
+		free     uint64
+		reserved int64
+	}
+	volumes := make(map[uint64]*volume)
+	rejected := make(map[int]error)
+
+	for i, d := range downloads {
+		need := remainingBytes(d)
+		if need <= 0 || d.TempPath == "" {
+			continue
+		}
+		free, device, err := diskSpace(existingDir(d.TempPath))
+		if err != nil {
+			continue
+		}
+		v, ok := volumes[device]
+		if !ok {
+			v = &volume{free: free}
+			volumes[device] = v
+		}
+		if err := m.compareSpace(v.reserved+need, v.free); err != nil {
+			rejected[i] = err
+			continue
+		}
+		v.reserved += need
+	}
+	return rejected
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package downloader
+
+// diskSpace can't measure free space on this platform, so disk space
+// checks are skipped
+func diskSpace(dir string) (free uint64, device uint64, err error) {
+	return 0, 0, errSpaceUnknown
+}
@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd
+
+package downloader
+
+import (
+	"os"
+	"syscall"
+)
+
+// diskSpace returns the bytes available to this user on the filesystem
+// holding dir, and an ID for that filesystem
+func diskSpace(dir string) (free uint64, device uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	if sys, ok := fi.Sys().(*syscall.Stat_t); ok {
+		device = uint64(sys.Dev)
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), device, nil
+}
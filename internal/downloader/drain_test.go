@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// trackingBody records how much of it was read and whether it was closed
+type trackingBody struct {
+	r      io.Reader
+	read   int64
+	closed bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestDrainAndCloseReadsSmallRemainder(t *testing.T) {
+	body := &trackingBody{r: strings.NewReader(strings.Repeat("x", 1000))}
+	drainAndClose(body)
+	if body.read != 1000 {
+		t.Errorf("drained %d bytes, want 1000", body.read)
+	}
+	if !body.closed {
+		t.Error("body not closed")
+	}
+}
+
+func TestDrainAndCloseAbandonsLargeRemainder(t *testing.T) {
+	body := &trackingBody{r: zeroReader{}}
+	drainAndClose(body)
+	if body.read != maxDrainBytes {
+		t.Errorf("drained %d bytes, want %d", body.read, maxDrainBytes)
+	}
+	if !body.closed {
+		t.Error("body not closed")
+	}
+}
+
+func TestRangeCheckReusesConnection(t *testing.T) {
+	// The server ignores Range and sends the whole (small) file, leaving
+	// most of the body unread by the range check
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/epub+zip")
+		io.WriteString(w, strings.Repeat("x", 16*1024))
+	}))
+	var conns atomic.Int32
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	m := &Manager{httpClient: srv.Client()}
+	for i := 0; i < 3; i++ {
+		if _, err := m.checkRangeSupportWithGet(context.Background(), srv.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("3 requests opened %d connections, want 1", n)
+	}
+}
@@ -0,0 +1,95 @@
+package downloader
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+// bookExtensions are file extensions worth taking from a download URL
+var bookExtensions = map[string]bool{
+	".epub": true, ".pdf": true, ".mobi": true, ".azw": true, ".azw3": true,
+	".djvu": true, ".fb2": true, ".cbr": true, ".cbz": true, ".txt": true,
+	".rtf": true, ".doc": true, ".docx": true, ".lit": true, ".chm": true,
+	".zip": true, ".rar": true, ".7z": true,
+}
+
+// TempPath returns where a download to filePath is written until it
+// completes
+func TempPath(filePath string) string {
+	return filePath + ".part"
+}
+
+// IsTempPath reports whether path looks like a TempPath of a book: a file
+// with a book extension followed by .part, such as "Dune.epub.part"
+func IsTempPath(path string) bool {
+	stem, ok := strings.CutSuffix(path, ".part")
+	return ok && bookExtensions[strings.ToLower(filepath.Ext(stem))]
+}
+
+// serverFilename returns the filename the server gives for a response: the
+// Content-Disposition filename, or else the last path element of the final
+// (post-redirect) URL when it has a book extension. Returns "" if neither.
+func serverFilename(resp *http.Response) string {
+	if cd := resp.Header.Get("Content-Disposition"); cd != "" {
+		if _, params, err := mime.ParseMediaType(cd); err == nil && params["filename"] != "" {
+			return params["filename"]
+		}
+	}
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		name := path.Base(resp.Request.URL.Path)
+		if bookExtensions[strings.ToLower(path.Ext(name))] {
+			return name
+		}
+	}
+	return ""
+}
+
+// useServerFilename renames a download that hasn't started yet to the name
+// the server gave, keeping its directory (so organize mode still applies).
+// Downloads that already have a partial file are left alone.
+func (m *Manager) useServerFilename(download *db.Download, name string) error {
+	if _, err := os.Stat(download.TempPath); err == nil {
+		return nil
+	}
+
+	// Strip any path the server tried to smuggle in, then clean the name
+	// without losing its extension to length limits
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	ext := strings.ToLower(filepath.Ext(name))
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if m.sanitize != nil {
+		stem = m.sanitize(stem)
+	}
+	if stem == "" || strings.Trim(stem, ".") == "" || !bookExtensions[ext] {
+		return nil
+	}
+
+	filePath := filepath.Join(filepath.Dir(download.FilePath), stem+ext)
+	if filePath == download.FilePath {
+		return nil
+	}
+
+	// Don't clobber an unrelated file that already has the server's name
+	if _, err := os.Stat(filePath); err == nil {
+		return nil
+	}
+
+	format := strings.ToUpper(strings.TrimPrefix(ext, "."))
+	tempPath := TempPath(filePath)
+	if err := db.UpdatePaths(download.ID, filePath, tempPath, format); err != nil {
+		return fmt.Errorf("failed to update file name: %w", err)
+	}
+
+	download.FilePath = filePath
+	download.TempPath = tempPath
+	download.Format = format
+	return nil
+}
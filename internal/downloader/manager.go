@@ -2,24 +2,72 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/mattn/go-isatty"
 	"github.com/schollz/progressbar/v3"
 	"github.com/billmal071/bookdl/internal/config"
 	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/log"
 )
 
 const (
 	// DefaultChunkSize is 5MB
 	DefaultChunkSize = 5 * 1024 * 1024
+
+	// MinChunkSize and MaxChunkSize bound downloads.chunk_size and --chunk-size
+	MinChunkSize = 256 * 1024
+	MaxChunkSize = 256 * 1024 * 1024
 )
 
+// colorEnabled controls whether progress bars use ANSI colors
+var colorEnabled = true
+
+// barsEnabled controls whether progress bars are drawn at all. They're
+// off when stdout isn't a terminal, where their control codes only clutter
+// logs.
+var barsEnabled = isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+
+// SetProgressBars turns progress bars on or off, e.g. when progress is
+// reported some other way
+func SetProgressBars(enabled bool) {
+	barsEnabled = enabled
+}
+
+// SetColorEnabled turns progress bar colors on or off
+func SetColorEnabled(enabled bool) {
+	colorEnabled = enabled
+}
+
+// barTheme returns the progress bar theme in the given color, or a plain
+// theme without color tags when colors are disabled
+func barTheme(color string) progressbar.Theme {
+	if !colorEnabled {
+		return progressbar.Theme{
+			Saucer:        "█",
+			SaucerHead:    "▓",
+			SaucerPadding: "░",
+			BarStart:      "│",
+			BarEnd:        "│",
+		}
+	}
+	return progressbar.Theme{
+		Saucer:        "[" + color + "]█[reset]",
+		SaucerHead:    "[" + color + "]▓[reset]",
+		SaucerPadding: "[dark_gray]░[reset]",
+		BarStart:      "[dark_gray]│[reset]",
+		BarEnd:        "[dark_gray]│[reset]",
+	}
+}
+
 // createProgressBar creates a styled progress bar with speed, ETA, and colors
 func createProgressBar(total int64, description string) *progressbar.ProgressBar {
 	return progressbar.NewOptions64(
@@ -31,16 +79,13 @@ func createProgressBar(total int64, description string) *progressbar.ProgressBar
 		progressbar.OptionSetPredictTime(true),
 		progressbar.OptionShowElapsedTimeOnFinish(),
 		progressbar.OptionSetRenderBlankState(true),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[green]█[reset]",
-			SaucerHead:    "[green]▓[reset]",
-			SaucerPadding: "[dark_gray]░[reset]",
-			BarStart:      "[dark_gray]│[reset]",
-			BarEnd:        "[dark_gray]│[reset]",
-		}),
+		progressbar.OptionEnableColorCodes(colorEnabled),
+		progressbar.OptionSetVisibility(barsEnabled),
+		progressbar.OptionSetTheme(barTheme("green")),
 		progressbar.OptionOnCompletion(func() {
-			fmt.Println()
+			if barsEnabled {
+				fmt.Println()
+			}
 		}),
 	)
 }
@@ -56,14 +101,9 @@ func createChunkProgressBar(total int64, description string, currentChunk, total
 		progressbar.OptionShowCount(),
 		progressbar.OptionSetPredictTime(true),
 		progressbar.OptionSetRenderBlankState(true),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[cyan]█[reset]",
-			SaucerHead:    "[cyan]▓[reset]",
-			SaucerPadding: "[dark_gray]░[reset]",
-			BarStart:      "[dark_gray]│[reset]",
-			BarEnd:        "[dark_gray]│[reset]",
-		}),
+		progressbar.OptionEnableColorCodes(colorEnabled),
+		progressbar.OptionSetVisibility(barsEnabled),
+		progressbar.OptionSetTheme(barTheme("cyan")),
 	)
 }
 
@@ -75,11 +115,22 @@ type DownloadResult struct {
 
 // Manager handles download operations
 type Manager struct {
-	httpClient    *http.Client
-	chunkSize     int64
-	maxConcurrent int
-	mu            sync.RWMutex
-	active        map[int64]context.CancelFunc
+	httpClient       *http.Client
+	chunkSize        int64
+	maxConcurrent    int
+	parallelChunks   int // chunks of one download fetched at once
+	mu               sync.RWMutex
+	active           map[int64]context.CancelFunc
+	paused           map[int64]bool
+	limits           rateLimits           // bandwidth caps, total and per download
+	verifyResume     bool                 // hash chunks and re-check them on resume, restarting if the ETag changed
+	sanitize         func(string) string  // cleans server-supplied filenames, if set
+	progressListener func(ProgressUpdate) // receives every download's progress, if set
+	minFileSize      int64                // files smaller than this are rejected, 0 for no minimum
+	allowedTypes     []string             // media types accepted from servers, empty for any but HTML
+	sniffMarkers     []string             // lowercase text marking a generic-typed file as an error page
+	minFreeSpace     int64                // disk space downloads must leave free
+	breaker          *hostBreaker         // skips hosts that keep failing, shared by all downloads
 }
 
 // NewManager creates a new download manager
@@ -88,6 +139,9 @@ func NewManager() *Manager {
 	chunkSize := cfg.Downloads.ChunkSize
 	if chunkSize == 0 {
 		chunkSize = DefaultChunkSize
+	} else if err := ValidateChunkSize(chunkSize); err != nil {
+		log.Warn("ignoring downloads.chunk_size", "err", err)
+		chunkSize = DefaultChunkSize
 	}
 
 	maxConcurrent := cfg.Downloads.MaxConcurrent
@@ -95,22 +149,106 @@ func NewManager() *Manager {
 		maxConcurrent = 2
 	}
 
+	parallelChunks := cfg.Downloads.ParallelChunks
+	if parallelChunks <= 0 {
+		parallelChunks = 1
+	}
+
+	minFileSize, err := ParseSize(cfg.Downloads.MinFileSize)
+	if err != nil {
+		log.Warn("ignoring downloads.min_file_size", "err", err)
+		minFileSize = 0
+	}
+
+	minFreeSpace, err := ParseSize(cfg.Downloads.MinFreeSpace)
+	if err != nil {
+		log.Warn("ignoring downloads.min_free_space", "err", err)
+		minFreeSpace = 0
+	}
+
+	transport := config.ProxyTransport()
+	transport.MaxIdleConns = 32
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.DisableCompression = true
+	// Chunks and concurrent downloads mostly hit the same host
+	transport.MaxIdleConnsPerHost = 16
+
+	breaker := newHostBreaker(HostFailureThreshold, HostCooldown)
+
 	return &Manager{
 		httpClient: &http.Client{
-			Timeout: 0, // No timeout for downloads
-			Transport: &http.Transport{
-				MaxIdleConns:        10,
-				IdleConnTimeout:     30 * time.Second,
-				DisableCompression:  true,
-				MaxIdleConnsPerHost: 5,
-			},
+			Timeout:   0, // No timeout for downloads
+			Transport: &breakerTransport{next: transport, breaker: breaker},
 		},
-		chunkSize:     chunkSize,
-		maxConcurrent: maxConcurrent,
-		active:        make(map[int64]context.CancelFunc),
+		chunkSize:      chunkSize,
+		maxConcurrent:  maxConcurrent,
+		parallelChunks: parallelChunks,
+		active:         make(map[int64]context.CancelFunc),
+		paused:         make(map[int64]bool),
+		limits:         newRateLimits(cfg.Downloads.MaxSpeed, cfg.Downloads.MaxRate),
+		minFileSize:    minFileSize,
+		allowedTypes:   cfg.Downloads.AllowedContentTypes,
+		sniffMarkers:   lowerAll(cfg.Downloads.SniffMarkers),
+		minFreeSpace:   minFreeSpace,
+		breaker:        breaker,
 	}
 }
 
+// SetVerifyResume makes downloads hash chunks as they complete and re-check
+// them when resumed, restarting if the server's ETag changed. Chunks from
+// a session without it or downloads.chunk_checksums have no hash to check.
+func (m *Manager) SetVerifyResume(enabled bool) {
+	m.verifyResume = enabled
+}
+
+// chunkChecksums reports whether completed chunks are hashed, so a later
+// resume can re-check them
+func (m *Manager) chunkChecksums() bool {
+	return config.Get().Downloads.ChunkChecksums || m.verifyResume
+}
+
+// ValidateChunkSize returns an error if size is outside MinChunkSize to
+// MaxChunkSize
+func ValidateChunkSize(size int64) error {
+	if size < MinChunkSize || size > MaxChunkSize {
+		return fmt.Errorf("chunk size must be between 256KB and 256MB, got %d bytes", size)
+	}
+	return nil
+}
+
+// SetChunkSize overrides the chunk size for new chunked downloads. Downloads
+// being resumed keep the chunks they were started with.
+func (m *Manager) SetChunkSize(size int64) error {
+	if err := ValidateChunkSize(size); err != nil {
+		return err
+	}
+	m.chunkSize = size
+	return nil
+}
+
+// SetFilenameSanitizer sets how filenames supplied by the server are cleaned
+// before use
+func (m *Manager) SetFilenameSanitizer(sanitize func(string) string) {
+	m.sanitize = sanitize
+}
+
+// SetProgressListener sets a callback that receives byte-level progress
+// for every download this manager runs
+func (m *Manager) SetProgressListener(fn func(ProgressUpdate)) {
+	m.progressListener = fn
+}
+
+// SetMaxRate overrides the per-download rate limit (bytes per second, 0 for
+// none). The total limit, downloads.max_speed, still applies.
+func (m *Manager) SetMaxRate(bytesPerSec int64) {
+	m.limits.perDownload = bytesPerSec
+}
+
+// MaxRate returns the per-download rate limit in bytes per second
+func (m *Manager) MaxRate() int64 {
+	return m.limits.perDownload
+}
+
 // GetMaxConcurrent returns the maximum concurrent downloads setting
 func (m *Manager) GetMaxConcurrent() int {
 	return m.maxConcurrent
@@ -125,13 +263,26 @@ func (m *Manager) StartConcurrent(ctx context.Context, downloads []*db.Download,
 	var wg sync.WaitGroup
 	var resultMu sync.Mutex
 
+	// Don't start downloads that can't all fit on disk together
+	rejected := m.preflightDiskSpace(downloads)
+
 	for i, download := range downloads {
+		if err, ok := rejected[i]; ok {
+			log.Warn("not starting download", "id", download.ID, "reason", err)
+			results[i] = DownloadResult{Download: download, Error: err}
+			if progressFn != nil {
+				progressFn(download.ID, "failed", 0)
+			}
+			continue
+		}
+
+		// Take a slot before starting the goroutine, so downloads start in
+		// the order given (e.g. by priority) rather than whichever
+		// goroutine the scheduler runs first
+		sem <- struct{}{}
 		wg.Add(1)
 		go func(idx int, dl *db.Download) {
 			defer wg.Done()
-
-			// Acquire semaphore
-			sem <- struct{}{}
 			defer func() { <-sem }()
 
 			// Notify start
@@ -139,8 +290,14 @@ func (m *Manager) StartConcurrent(ctx context.Context, downloads []*db.Download,
 				progressFn(dl.ID, "starting", 0)
 			}
 
-			// Perform download
-			err := m.StartDownload(ctx, dl)
+			// Perform download, reporting byte-level progress as it goes
+			dlCtx := ctx
+			if progressFn != nil {
+				dlCtx = withProgressFunc(ctx, func(u ProgressUpdate) {
+					progressFn(u.ID, "downloading", u.Percent())
+				})
+			}
+			err := m.StartDownload(dlCtx, dl)
 
 			// Store result
 			resultMu.Lock()
@@ -149,7 +306,9 @@ func (m *Manager) StartConcurrent(ctx context.Context, downloads []*db.Download,
 
 			// Notify completion
 			if progressFn != nil {
-				if err != nil {
+				if err == ErrPaused {
+					progressFn(dl.ID, "paused", 0)
+				} else if err != nil {
 					progressFn(dl.ID, "failed", 0)
 				} else {
 					progressFn(dl.ID, "completed", 100)
@@ -163,180 +322,422 @@ func (m *Manager) StartConcurrent(ctx context.Context, downloads []*db.Download,
 }
 
 // StartDownload starts or resumes a download
-func (m *Manager) StartDownload(ctx context.Context, download *db.Download) error {
-	// Create cancellable context
-	dlCtx, cancel := context.WithCancel(ctx)
+// It returns ErrPaused if the download was paused, from this or another process,
+// or if ctx was interrupted; an interrupted download is marked paused.
+func (m *Manager) StartDownload(ctx context.Context, download *db.Download) (err error) {
+	// Create cancellable context, carrying this download's own rate limit
+	dlCtx, cancel := context.WithCancel(m.limits.forDownload(ctx))
 	m.mu.Lock()
 	m.active[download.ID] = cancel
+	delete(m.paused, download.ID)
 	m.mu.Unlock()
 
 	defer func() {
+		cancel()
 		m.mu.Lock()
+		wasPaused := m.paused[download.ID]
 		delete(m.active, download.ID)
+		delete(m.paused, download.ID)
 		m.mu.Unlock()
+
+		if err != nil && wasPaused {
+			err = ErrPaused
+		}
+		// Progress was saved as the transfer stopped; keep it resumable
+		if err != nil && Interrupted(ctx) {
+			db.UpdateStatus(download.ID, db.StatusPaused, "")
+			err = ErrPaused
+		}
 	}()
 
+	// Watch for pause requests from other bookdl processes, ignoring stale
+	// requests left over from before this download started
+	clearPauseRequest(download.ID)
+	go watchPauseRequests(dlCtx, download.ID, func() {
+		m.mu.Lock()
+		m.paused[download.ID] = true
+		m.mu.Unlock()
+		cancel()
+	})
+
 	// Update status to downloading
 	if err := db.UpdateStatus(download.ID, db.StatusDownloading, ""); err != nil {
 		return err
 	}
 
 	// Check if server supports range requests
-	supportsRange, totalSize, err := m.checkRangeSupport(dlCtx, download.DownloadURL)
+	info, err := m.checkRangeSupport(dlCtx, download.DownloadURL)
 	if err != nil {
 		return fmt.Errorf("failed to check server capabilities: %w", err)
 	}
 
-	download.FileSize = totalSize
+	download.FileSize = info.size
+	log.Debug("server info", "url", download.DownloadURL, "size", info.size, "ranges", info.supportsRange, "etag", info.etag)
+
+	// Don't bother downloading a file the server already says is too small.
+	// A zero length is skipped too: some servers send it for HEAD requests.
+	if info.size > 0 {
+		if err := m.checkMinSize(info.size); err != nil {
+			log.Warn("rejecting mirror", "url", download.DownloadURL, "reason", err)
+			return err
+		}
+	}
+
+	// Fail fast rather than filling the disk part way through
+	if err := m.checkDiskSpace(download.TempPath, remainingBytes(download)); err != nil {
+		return err
+	}
+
+	if info.filename != "" && config.Get().Files.TrustServerFilename {
+		if err := m.useServerFilename(download, info.filename); err != nil {
+			return err
+		}
+	}
+
+	sink := m.newProgressSink(ctx, download.ID)
+
+	if info.supportsRange && info.size > m.chunkSize {
+		err = m.downloadChunked(dlCtx, download, info.etag, sink)
+	} else {
+		err = m.downloadSimple(dlCtx, download, sink)
+	}
+	if err != nil {
+		db.UpdateSpeed(download.ID, 0)
+		return err
+	}
+	db.SetAvgSpeed(download.ID, sink.averageSpeed())
+	return m.rejectIfTooSmall(download)
+}
+
+// ErrFileTooSmall indicates a mirror served a file below downloads.min_file_size,
+// usually a placeholder rather than the book
+var ErrFileTooSmall = errors.New("file is smaller than downloads.min_file_size")
 
-	if supportsRange && totalSize > m.chunkSize {
-		return m.downloadChunked(dlCtx, download)
+// checkMinSize returns ErrFileTooSmall if size is known and below the
+// configured minimum
+func (m *Manager) checkMinSize(size int64) error {
+	if m.minFileSize <= 0 || size < 0 || size >= m.minFileSize {
+		return nil
 	}
+	return fmt.Errorf("%w: %d bytes, minimum %d", ErrFileTooSmall, size, m.minFileSize)
+}
 
-	return m.downloadSimple(dlCtx, download)
+// rejectIfTooSmall removes a finished download whose file is below the
+// configured minimum, so the next mirror can be tried
+func (m *Manager) rejectIfTooSmall(download *db.Download) error {
+	fi, err := os.Stat(download.FilePath)
+	if err != nil {
+		return nil
+	}
+	if err := m.checkMinSize(fi.Size()); err != nil {
+		log.Warn("rejecting downloaded file", "id", download.ID, "url", download.DownloadURL, "reason", err)
+		os.Remove(download.FilePath)
+		db.DeleteChunks(download.ID)
+		db.UpdateProgress(download.ID, 0)
+		return err
+	}
+	return nil
 }
 
 // PauseDownload pauses an active download
+// Downloads running in this process are cancelled directly; for downloads
+// running in another bookdl process a pause request is left for it to pick up.
 func (m *Manager) PauseDownload(downloadID int64) error {
-	m.mu.RLock()
+	m.mu.Lock()
 	cancel, ok := m.active[downloadID]
-	m.mu.RUnlock()
+	if ok {
+		m.paused[downloadID] = true
+	}
+	m.mu.Unlock()
 
 	if ok {
 		cancel()
+	} else if err := RequestPause(downloadID); err != nil {
+		return fmt.Errorf("failed to send pause request: %w", err)
 	}
 
 	return db.UpdateStatus(downloadID, db.StatusPaused, "")
 }
 
-// checkRangeSupport checks if the server supports range requests
-func (m *Manager) checkRangeSupport(ctx context.Context, url string) (bool, int64, error) {
+// ProbeSize returns the file size reported by the server for url, or 0 if unknown
+func (m *Manager) ProbeSize(ctx context.Context, url string) int64 {
+	if url == "" {
+		return 0
+	}
+	info, err := m.checkRangeSupport(ctx, url)
+	if err != nil || info.size < 0 {
+		return 0
+	}
+	return info.size
+}
+
+// serverInfo is what a probe request learned about a download URL
+type serverInfo struct {
+	supportsRange bool
+	size          int64
+	etag          string
+	filename      string // from Content-Disposition or the final URL, if any
+}
+
+// setRequestHeaders sets the headers every download request carries. The
+// anna.member_cookie session is only sent to fast_download links.
+func setRequestHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", config.RandomUserAgent())
+	if strings.Contains(req.URL.Path, "/fast_download/") {
+		if cookie := config.MemberCookieHeader(); cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+	}
+}
+
+// checkRangeSupport checks if the server supports range requests, and
+// returns the file size, ETag and filename it reports
+func (m *Manager) checkRangeSupport(ctx context.Context, url string) (*serverInfo, error) {
 	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
-		return false, 0, err
+		return nil, err
 	}
 
-	req.Header.Set("User-Agent", config.Get().Network.UserAgent)
+	setRequestHeaders(req)
 
 	resp, err := m.httpClient.Do(req)
 	if err != nil {
 		// HEAD might not be supported, try GET with Range
 		return m.checkRangeSupportWithGet(ctx, url)
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
 
-	acceptRanges := resp.Header.Get("Accept-Ranges")
-	contentLength := resp.ContentLength
+	// Some servers refuse HEAD; only trust a GET about an expired link
+	if errors.Is(statusError(resp), ErrLinkExpired) {
+		return m.checkRangeSupportWithGet(ctx, url)
+	}
 
-	return acceptRanges == "bytes", contentLength, nil
+	return &serverInfo{
+		supportsRange: resp.Header.Get("Accept-Ranges") == "bytes",
+		size:          resp.ContentLength,
+		etag:          resp.Header.Get("ETag"),
+		filename:      serverFilename(resp),
+	}, nil
 }
 
-func (m *Manager) checkRangeSupportWithGet(ctx context.Context, url string) (bool, int64, error) {
+func (m *Manager) checkRangeSupportWithGet(ctx context.Context, url string) (*serverInfo, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return false, 0, err
+		return nil, err
 	}
 
-	req.Header.Set("User-Agent", config.Get().Network.UserAgent)
+	setRequestHeaders(req)
 	req.Header.Set("Range", "bytes=0-0")
 
 	resp, err := m.httpClient.Do(req)
 	if err != nil {
-		return false, 0, err
+		return nil, err
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
 
+	if err := statusError(resp); errors.Is(err, ErrLinkExpired) {
+		return nil, err
+	}
+
+	info := &serverInfo{
+		size:     resp.ContentLength,
+		etag:     resp.Header.Get("ETag"),
+		filename: serverFilename(resp),
+	}
 	if resp.StatusCode == http.StatusPartialContent {
 		// Parse Content-Range header
 		contentRange := resp.Header.Get("Content-Range")
 		var total int64
 		fmt.Sscanf(contentRange, "bytes 0-0/%d", &total)
-		return true, total, nil
+		info.supportsRange = true
+		info.size = total
 	}
 
-	return false, resp.ContentLength, nil
+	return info, nil
+}
+
+// maxDrainBytes caps how much of an unread body is discarded before closing.
+// Smaller remainders are drained so the connection can be reused; a larger
+// remainder (e.g. a cancelled chunk) is cheaper to abandon than to download.
+const maxDrainBytes = 64 * 1024
+
+// drainAndClose discards what is left of a response body and closes it,
+// returning the connection to the transport's idle pool
+func drainAndClose(body io.ReadCloser) {
+	io.CopyN(io.Discard, body, maxDrainBytes)
+	body.Close()
 }
 
 // ErrHTMLContent indicates the download returned HTML instead of a file
 var ErrHTMLContent = fmt.Errorf("received HTML content instead of file")
 
-// downloadSimple downloads without chunking
-func (m *Manager) downloadSimple(ctx context.Context, download *db.Download) error {
+// ErrRangeIgnored indicates a server answered a request for part of a file
+// with the whole file, so chunks can't be downloaded from it
+var ErrRangeIgnored = errors.New("server ignored the range request")
+
+// ErrLinkExpired indicates the server refused or no longer has the file
+// (403, 404 or 410), as happens when a mirror's download link expires
+var ErrLinkExpired = errors.New("download link expired")
+
+// statusError describes an unexpected response status, wrapping
+// ErrLinkExpired for the statuses expired links get
+func statusError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return fmt.Errorf("%w: server returned %s", ErrLinkExpired, resp.Status)
+	}
+	return fmt.Errorf("server returned %s", resp.Status)
+}
+
+// progressSaveInterval is how often a simple download records its
+// progress, so an interrupted one knows how far it got
+const progressSaveInterval = 2 * time.Second
+
+// progressSaver records a simple download's size in the database as bytes
+// are written, at most every progressSaveInterval
+type progressSaver struct {
+	id         int64
+	downloaded int64
+	lastSave   time.Time
+}
+
+func (p *progressSaver) Write(b []byte) (int, error) {
+	p.downloaded += int64(len(b))
+	if time.Since(p.lastSave) >= progressSaveInterval {
+		p.lastSave = time.Now()
+		db.UpdateProgress(p.id, p.downloaded)
+	}
+	return len(b), nil
+}
+
+// downloadSimple downloads without chunking, reporting progress to sink.
+// A .part file left by an interrupted run is continued with a range request,
+// even if the server didn't advertise range support: many accept Range
+// anyway. Only a full-length 200 response starts the file over.
+func (m *Manager) downloadSimple(ctx context.Context, download *db.Download, sink *progressSink) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", download.DownloadURL, nil)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("User-Agent", config.Get().Network.UserAgent)
+	setRequestHeaders(req)
+
+	var offset int64
+	if fi, err := os.Stat(download.TempPath); err == nil && fi.Size() > 0 {
+		offset = fi.Size()
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
 
 	resp, err := m.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned %s", resp.Status)
+	defer drainAndClose(resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		// The server continues where the partial file ends
+		log.Info("resuming partial download", "id", download.ID, "offset", offset)
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file doesn't fit the file on the server; start over
+		log.Info("partial file doesn't match the server's, starting over", "id", download.ID)
+		drainAndClose(resp.Body)
+		if err := os.Remove(download.TempPath); err != nil {
+			return err
+		}
+		return m.downloadSimple(ctx, download, sink)
+	case resp.StatusCode == http.StatusOK && offset > 0 && download.FileSize > 0 &&
+		resp.ContentLength == download.FileSize-offset:
+		// Some servers honor the range but answer 200; the length gives it away
+		log.Info("resuming partial download (server sent the range with status 200)", "id", download.ID, "offset", offset)
+	case resp.StatusCode == http.StatusOK:
+		// Full file, either as asked or because ranges aren't supported
+		if offset > 0 {
+			log.Info("server ignored the range request, starting over", "id", download.ID, "offset", offset)
+		}
+		offset = 0
+	default:
+		return statusError(resp)
 	}
 
-	// Check content type - if it's HTML, this is likely an error page
-	contentType := resp.Header.Get("Content-Type")
-	if strings.Contains(contentType, "text/html") {
-		return ErrHTMLContent
+	// A specific, allowed content type is trusted; a generic one means the
+	// start of the file has to be checked
+	sniff, err := m.checkContentType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		log.Warn("rejecting response", "url", download.DownloadURL, "content_type", resp.Header.Get("Content-Type"), "reason", err)
+		return err
 	}
 
-	// Create temp file
-	file, err := os.Create(download.TempPath)
+	// Create the temp file, or append to it when resuming
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(download.TempPath, flags, 0644)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
+	total := resp.ContentLength
+	if total >= 0 && offset > 0 {
+		total += offset
+	}
+
 	// Create styled progress bar with speed and ETA
-	bar := createProgressBar(resp.ContentLength, "Downloading")
-
-	// Read the first 2KB to validate content (larger buffer catches more HTML errors)
-	header := make([]byte, 2048)
-	n, _ := io.ReadFull(resp.Body, header)
-	if n > 0 {
-		// Check for HTML content by looking at the beginning
-		headerStr := strings.ToLower(string(header[:n]))
-		if strings.Contains(headerStr, "<!doctype html") ||
-			strings.Contains(headerStr, "<html") ||
-			strings.Contains(headerStr, "<head") ||
-			strings.Contains(headerStr, "<body") ||
-			strings.Contains(headerStr, "<title>") ||
-			strings.Contains(headerStr, "<!doctype") ||
-			strings.Contains(headerStr, "<script") ||
-			strings.Contains(headerStr, "cloudflare") ||
-			strings.Contains(headerStr, "captcha") ||
-			strings.Contains(headerStr, "access denied") ||
-			strings.Contains(headerStr, "error 403") ||
-			strings.Contains(headerStr, "error 404") {
-			return ErrHTMLContent
-		}
-
-		// Write header to file
-		if _, err := file.Write(header[:n]); err != nil {
-			return err
+	bar := createProgressBar(total, "Downloading")
+	if offset > 0 {
+		bar.Set64(offset)
+	}
+	sink.start(total, offset)
+	saver := &progressSaver{id: download.ID, downloaded: offset, lastSave: time.Now()}
+
+	body := m.limitReader(ctx, resp.Body)
+
+	// The start of the file was validated when it was first downloaded
+	if offset == 0 && sniff {
+		// Read the first 2KB to validate content (larger buffer catches more HTML errors)
+		header := make([]byte, 2048)
+		n, _ := io.ReadFull(body, header)
+		if n > 0 {
+			// Check for HTML content by looking at the beginning
+			if marker := m.sniffErrorPage(header[:n]); marker != "" {
+				log.Warn("file starts like an HTML or error page", "url", download.DownloadURL, "marker", marker)
+				return ErrHTMLContent
+			}
+
+			// Write header to file
+			if _, err := file.Write(header[:n]); err != nil {
+				return err
+			}
+			bar.Add(n)
+			sink.add(n)
+			saver.Write(header[:n])
 		}
-		bar.Add(n)
 	}
 
 	// Copy the rest with progress
-	writer := io.MultiWriter(file, bar)
-	_, err = io.Copy(writer, resp.Body)
+	writer := io.MultiWriter(file, bar, sink, saver)
+	_, err = io.Copy(writer, body)
+	db.UpdateProgress(download.ID, saver.downloaded)
 	if err != nil {
 		return err
 	}
+	sink.finish()
 
-	// Move temp file to final location
-	file.Close()
+	// Flush to disk before the rename, so the final file is never half-written
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
 	return os.Rename(download.TempPath, download.FilePath)
 }
 
-// downloadChunked downloads with chunking for resumability
-func (m *Manager) downloadChunked(ctx context.Context, download *db.Download) error {
+// downloadChunked downloads with chunking for resumability. etag is the
+// server's ETag for the file, if it sent one. Progress goes to sink.
+func (m *Manager) downloadChunked(ctx context.Context, download *db.Download, etag string, sink *progressSink) error {
 	// Get or create chunks
 	chunks, err := db.GetChunks(download.ID)
 	if err != nil || len(chunks) == 0 {
@@ -344,6 +745,42 @@ func (m *Manager) downloadChunked(ctx context.Context, download *db.Download) er
 		if err := db.CreateChunks(download.ID, chunks); err != nil {
 			return fmt.Errorf("failed to create chunks: %w", err)
 		}
+	} else {
+		// Resuming keeps the stored chunk offsets, whatever the chunk size is now
+		if size := chunks[0].EndByte - chunks[0].StartByte + 1; len(chunks) > 1 && size != m.chunkSize {
+			log.Info("keeping the chunk size this download was started with",
+				"id", download.ID, "chunk_size", FormatBytes(size), "configured", FormatBytes(m.chunkSize))
+		}
+
+		// Resuming: make sure the .part file still holds what the chunks claim
+		fixed, err := validatePartialFile(download, chunks)
+		if err != nil {
+			return fmt.Errorf("failed to check partial file: %w", err)
+		}
+		if fixed > 0 {
+			log.Warn("chunks were ahead of the partial file and will be downloaded again", "id", download.ID, "chunks", fixed)
+		}
+
+		if m.verifyResume && etag != "" {
+			changed, err := etagChanged(download, etag)
+			if err != nil {
+				return err
+			}
+			if changed {
+				log.Warn("file changed on the server since the download started, restarting it", "id", download.ID)
+				if err := db.DeleteChunks(download.ID); err != nil {
+					return err
+				}
+				os.Remove(download.TempPath)
+				chunks = m.createChunks(download)
+				if err := db.CreateChunks(download.ID, chunks); err != nil {
+					return fmt.Errorf("failed to create chunks: %w", err)
+				}
+			}
+		}
+	}
+	if etag != "" {
+		db.SetMeta(etagKey(download.ID), etag)
 	}
 
 	// Open or create temp file
@@ -358,6 +795,17 @@ func (m *Manager) downloadChunked(ctx context.Context, download *db.Download) er
 		return err
 	}
 
+	// Make sure chunks completed in an earlier session weren't corrupted
+	if m.chunkChecksums() {
+		reset, err := verifyChunks(file, chunks)
+		if err != nil {
+			return fmt.Errorf("failed to verify chunks: %w", err)
+		}
+		if reset > 0 {
+			log.Warn("chunks failed verification and will be downloaded again", "id", download.ID, "chunks", reset)
+		}
+	}
+
 	// Calculate already downloaded and count incomplete chunks
 	var downloaded int64
 	var incompleteChunks int
@@ -375,28 +823,78 @@ func (m *Manager) downloadChunked(ctx context.Context, download *db.Download) er
 
 	// Set initial progress
 	bar.Set64(downloaded)
+	sink.start(download.FileSize, downloaded)
 
-	// Download each incomplete chunk
-	chunkNum := 0
+	// Download incomplete chunks in parallel; the first failure stops the rest
+	var pending []*db.Chunk
 	for _, chunk := range chunks {
-		if chunk.Status == "completed" {
-			continue
+		if chunk.Status != "completed" {
+			pending = append(pending, chunk)
 		}
-		chunkNum++
+	}
+
+	workers := m.parallelChunks
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	chunkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress := &chunkProgress{
+		total:       downloaded,
+		completed:   len(chunks) - incompleteChunks,
+		count:       len(chunks),
+		maxAttempts: DefaultRetryConfig().MaxAttempts,
+		bar:         bar,
+		sink:        sink,
+	}
+	progress.describe()
 
+	work := make(chan *db.Chunk)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range work {
+				if err := m.downloadChunk(chunkCtx, download, chunk, file, progress); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+				progress.chunkDone()
+			}
+		}()
+	}
+
+feed:
+	for _, chunk := range pending {
 		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		case work <- chunk:
+		case <-chunkCtx.Done():
+			break feed
 		}
+	}
+	close(work)
+	wg.Wait()
+	close(errs)
 
-		// Update description to show current chunk
-		bar.Describe(fmt.Sprintf("Chunk %d/%d", len(chunks)-incompleteChunks+chunkNum, len(chunks)))
-
-		if err := m.downloadChunk(ctx, download, chunk, file, bar); err != nil {
-			return err
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// Prefer the error that stopped the workers over the cancellations it caused
+	var firstErr error
+	for err := range errs {
+		if firstErr == nil || (errors.Is(firstErr, context.Canceled) && !errors.Is(err, context.Canceled)) {
+			firstErr = err
 		}
 	}
+	if firstErr != nil {
+		return firstErr
+	}
+	sink.finish()
 
 	// Move temp file to final location
 	file.Close()
@@ -426,48 +924,127 @@ func (m *Manager) createChunks(download *db.Download) []*db.Chunk {
 	return chunks
 }
 
-// downloadChunk downloads a single chunk
-func (m *Manager) downloadChunk(ctx context.Context, download *db.Download, chunk *db.Chunk, file *os.File, bar *progressbar.ProgressBar) error {
+// chunkProgress tracks combined progress across chunks downloading in parallel
+type chunkProgress struct {
+	mu          sync.Mutex
+	total       int64 // bytes downloaded across all chunks
+	completed   int
+	count       int
+	retries     map[int]int // attempt number of each chunk waiting to retry, by chunk index
+	maxAttempts int
+	bar         *progressbar.ProgressBar
+	sink        *progressSink
+}
+
+// add records n newly written bytes and returns the new total
+func (p *chunkProgress) add(n int) int64 {
+	p.bar.Add(n)
+	p.sink.add(n)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += int64(n)
+	return p.total
+}
+
+func (p *chunkProgress) chunkDone() {
+	p.mu.Lock()
+	p.completed++
+	p.mu.Unlock()
+	p.describe()
+}
+
+// setRetrying records that a chunk is on its attempt'th try, or done
+// retrying if attempt is 0
+func (p *chunkProgress) setRetrying(index, attempt int) {
+	p.mu.Lock()
+	if attempt == 0 {
+		delete(p.retries, index)
+	} else {
+		if p.retries == nil {
+			p.retries = make(map[int]int)
+		}
+		p.retries[index] = attempt
+	}
+	p.mu.Unlock()
+	p.describe()
+}
+
+// describe updates the bar with the chunk count and any retries in progress
+func (p *chunkProgress) describe() {
+	p.bar.Describe(p.label())
+}
+
+// label is the bar's description, e.g. "Chunks 3/10 (chunk 4 retrying 2/5)"
+func (p *chunkProgress) label() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	label := fmt.Sprintf("Chunks %d/%d", p.completed, p.count)
+	indexes := make([]int, 0, len(p.retries))
+	for index := range p.retries {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		label += fmt.Sprintf(" (chunk %d retrying %d/%d)", index+1, p.retries[index], p.maxAttempts)
+	}
+	return label
+}
+
+// downloadChunk downloads a single chunk. Chunks write at their own offsets,
+// so several can share file concurrently.
+func (m *Manager) downloadChunk(ctx context.Context, download *db.Download, chunk *db.Chunk, file *os.File, progress *chunkProgress) error {
 	// Calculate resume position
 	startPos := chunk.StartByte + chunk.Downloaded
 
 	var resp *http.Response
 	retryCfg := DefaultRetryConfig()
+	retried := false
+	retryCfg.OnRetry = func(attempt int, err error) {
+		retried = true
+		progress.setRetrying(chunk.ChunkIndex, attempt)
+	}
 
 	// Retry with exponential backoff
-	err := RetryOperation(ctx, retryCfg, func() (int, error) {
+	err := RetryOperation(ctx, retryCfg, func() (*http.Response, error) {
 		req, err := http.NewRequestWithContext(ctx, "GET", download.DownloadURL, nil)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 
-		req.Header.Set("User-Agent", config.Get().Network.UserAgent)
+		setRequestHeaders(req)
 		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", startPos, chunk.EndByte))
 
 		var reqErr error
 		resp, reqErr = m.httpClient.Do(req)
 		if reqErr != nil {
-			return 0, reqErr
+			return nil, reqErr
 		}
 
-		if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
-			statusCode := resp.StatusCode
-			resp.Body.Close()
-			return statusCode, fmt.Errorf("server returned %d", statusCode)
+		switch {
+		case resp.StatusCode == http.StatusOK && (startPos > 0 || chunk.EndByte < download.FileSize-1):
+			// The whole file instead of this range; writing it here would
+			// overwrite the other chunks
+			drainAndClose(resp.Body)
+			return resp, ErrRangeIgnored
+		case resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK:
+			drainAndClose(resp.Body)
+			return resp, statusError(resp)
 		}
 
-		return resp.StatusCode, nil
+		return resp, nil
 	})
 
+	if retried {
+		progress.setRetrying(chunk.ChunkIndex, 0)
+	}
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
 
-	// Seek to correct position in file
-	if _, err := file.Seek(startPos, io.SeekStart); err != nil {
-		return err
-	}
+	// Never write past the chunk, whatever the server sends
+	offset := startPos
+	body := m.limitReader(ctx, io.LimitReader(resp.Body, chunk.EndByte-startPos+1))
 
 	// Read and write in small buffers for better progress tracking
 	buf := make([]byte, 32*1024) // 32KB buffer
@@ -480,17 +1057,19 @@ func (m *Manager) downloadChunk(ctx context.Context, download *db.Download, chun
 		default:
 		}
 
-		n, err := resp.Body.Read(buf)
+		n, err := body.Read(buf)
 		if n > 0 {
-			if _, writeErr := file.Write(buf[:n]); writeErr != nil {
+			if _, writeErr := file.WriteAt(buf[:n], offset); writeErr != nil {
+				db.UpdateChunkProgress(chunk.ID, chunk.Downloaded)
 				return writeErr
 			}
+			offset += int64(n)
 			chunk.Downloaded += int64(n)
-			bar.Add(n)
+			total := progress.add(n)
 
 			// Periodically save progress (every 256KB to minimize data loss on crash)
 			if chunk.Downloaded%(256*1024) == 0 {
-				db.UpdateProgressAtomic(download.ID, chunk.ID, chunk.Downloaded, download.DownloadedSize+chunk.Downloaded)
+				db.UpdateProgressAtomic(download.ID, chunk.ID, chunk.Downloaded, total)
 			}
 		}
 
@@ -503,6 +1082,80 @@ func (m *Manager) downloadChunk(ctx context.Context, download *db.Download, chun
 		}
 	}
 
+	// Record the chunk's checksum so a later resume can detect corruption
+	if m.chunkChecksums() {
+		sum, err := chunkChecksum(file, chunk)
+		if err != nil {
+			return err
+		}
+		if err := db.SetChunkChecksum(chunk.ID, sum); err != nil {
+			return err
+		}
+	}
+
 	// Mark chunk completed
 	return db.MarkChunkCompleted(chunk.ID)
 }
+
+// mirrorProbeTimeout bounds how long PickFastestMirror waits for responses
+const mirrorProbeTimeout = 10 * time.Second
+
+// PickFastestMirror probes all urls at once with a one-byte range request
+// and returns the first to answer with part of a file. urls should be
+// direct file links; pages that lead to the file can't win. Only the winner is used, so
+// slow mirrors cost nothing but an abandoned request.
+func (m *Manager) PickFastestMirror(ctx context.Context, urls []string) (string, error) {
+	if len(urls) == 0 {
+		return "", fmt.Errorf("no mirrors to try")
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, mirrorProbeTimeout)
+	defer cancel()
+
+	type result struct {
+		url string
+		err error
+	}
+	results := make(chan result, len(urls))
+	for _, u := range urls {
+		go func(u string) {
+			results <- result{u, m.probeMirror(probeCtx, u)}
+		}(u)
+	}
+
+	var lastErr error
+	for range urls {
+		r := <-results
+		if r.err == nil {
+			return r.url, nil
+		}
+		lastErr = r.err
+	}
+	return "", fmt.Errorf("no mirror responded: %w", lastErr)
+}
+
+// probeMirror requests the first byte of url. Only a 206 with the file
+// counts: a page that answers 200 (a slow_download page, an error page)
+// would still need resolving before the file starts.
+func (m *Manager) probeMirror(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	setRequestHeaders(req)
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("server returned %s, not partial content", resp.Status)
+	}
+	if _, err := m.checkContentType(resp.Header.Get("Content-Type")); err != nil {
+		return err
+	}
+	return nil
+}
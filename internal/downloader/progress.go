@@ -0,0 +1,176 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+const (
+	// progressInterval is the minimum time between progress reports
+	progressInterval = 500 * time.Millisecond
+	// speedWindow is how far back the reported speed looks
+	speedWindow = 5 * time.Second
+)
+
+// ProgressUpdate is a snapshot of one download's byte-level progress
+type ProgressUpdate struct {
+	ID         int64   `json:"id"`
+	Downloaded int64   `json:"downloaded"`
+	Total      int64   `json:"total"` // 0 if the server didn't say
+	Speed      float64 `json:"speed"` // bytes per second over the last few seconds
+}
+
+// Percent returns the progress in percent, or 0 if the size is unknown
+func (u ProgressUpdate) Percent() float64 {
+	if u.Total <= 0 {
+		return 0
+	}
+	return float64(u.Downloaded) / float64(u.Total) * 100
+}
+
+// progressFuncKey carries a per-download progress callback in a context
+type progressFuncKey struct{}
+
+// withProgressFunc attaches fn to ctx so StartDownload reports to it
+func withProgressFunc(ctx context.Context, fn func(ProgressUpdate)) context.Context {
+	return context.WithValue(ctx, progressFuncKey{}, fn)
+}
+
+// progressSample is the downloaded byte count at a point in time
+type progressSample struct {
+	at         time.Time
+	downloaded int64
+}
+
+// progressSink turns written bytes into throttled ProgressUpdates, and
+// records the speed in the database for 'bookdl list'. A nil sink ignores
+// everything, so callers don't need to check.
+type progressSink struct {
+	mu         sync.Mutex
+	id         int64
+	total      int64
+	downloaded int64
+	samples    []progressSample // within speedWindow, oldest first
+	first      progressSample   // where this session started
+	lastReport time.Time
+	lastSave   time.Time
+	report     func(ProgressUpdate) // nil if nobody is listening
+}
+
+// newProgressSink returns a sink for a download that reports to the
+// manager's listener and to any callback in ctx
+func (m *Manager) newProgressSink(ctx context.Context, id int64) *progressSink {
+	ctxFn, _ := ctx.Value(progressFuncKey{}).(func(ProgressUpdate))
+	listener := m.progressListener
+	sink := &progressSink{id: id}
+	if ctxFn != nil || listener != nil {
+		sink.report = func(u ProgressUpdate) {
+			if ctxFn != nil {
+				ctxFn(u)
+			}
+			if listener != nil {
+				listener(u)
+			}
+		}
+	}
+	return sink
+}
+
+// start sets the total size and what was already downloaded before this
+// session, e.g. when resuming
+func (s *progressSink) start(total, downloaded int64) {
+	if s == nil {
+		return
+	}
+	if total < 0 {
+		total = 0
+	}
+	s.mu.Lock()
+	s.total = total
+	s.downloaded = downloaded
+	s.first = progressSample{at: time.Now(), downloaded: downloaded}
+	s.samples = []progressSample{s.first}
+	s.lastSave = s.first.at
+	s.mu.Unlock()
+	s.update(true)
+}
+
+// add records n newly written bytes
+func (s *progressSink) add(n int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.downloaded += int64(n)
+	s.mu.Unlock()
+	s.update(false)
+}
+
+// Write counts p as written, so a sink can sit in an io.MultiWriter
+func (s *progressSink) Write(p []byte) (int, error) {
+	s.add(len(p))
+	return len(p), nil
+}
+
+// finish sends a final update regardless of throttling
+func (s *progressSink) finish() {
+	if s == nil {
+		return
+	}
+	s.update(true)
+}
+
+// averageSpeed returns the bytes per second since start
+func (s *progressSink) averageSpeed() float64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := time.Since(s.first.at).Seconds()
+	if s.first.at.IsZero() || elapsed <= 0 {
+		return 0
+	}
+	return float64(s.downloaded-s.first.downloaded) / elapsed
+}
+
+// update reports the current progress if progressInterval has passed since
+// the last report, or always when force is set. The speed is saved every
+// progressSaveInterval.
+func (s *progressSink) update(force bool) {
+	now := time.Now()
+	s.mu.Lock()
+	if !force && now.Sub(s.lastReport) < progressInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastReport = now
+
+	s.samples = append(s.samples, progressSample{at: now, downloaded: s.downloaded})
+	for len(s.samples) > 2 && now.Sub(s.samples[1].at) >= speedWindow {
+		s.samples = s.samples[1:]
+	}
+
+	var speed float64
+	oldest := s.samples[0]
+	if elapsed := now.Sub(oldest.at).Seconds(); elapsed > 0 {
+		speed = float64(s.downloaded-oldest.downloaded) / elapsed
+	}
+
+	u := ProgressUpdate{ID: s.id, Downloaded: s.downloaded, Total: s.total, Speed: speed}
+	save := now.Sub(s.lastSave) >= progressSaveInterval
+	if save {
+		s.lastSave = now
+	}
+	s.mu.Unlock()
+
+	if save {
+		db.UpdateSpeed(s.id, speed)
+	}
+	if s.report != nil {
+		s.report(u)
+	}
+}
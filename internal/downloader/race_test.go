@@ -0,0 +1,58 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPickFastestMirrorNeedsPartialContent(t *testing.T) {
+	// Answers at once, but with a page instead of the file
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html>Please wait...</html>"))
+	}))
+	defer page.Close()
+
+	// Answers at once with 200 and the whole file, ignoring the range
+	whole := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/epub+zip")
+		w.Write([]byte("PK..."))
+	}))
+	defer whole.Close()
+
+	// Slower, but sends the requested byte
+	file := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/epub+zip")
+		w.Header().Set("Content-Range", "bytes 0-0/100")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("P"))
+	}))
+	defer file.Close()
+
+	m := &Manager{httpClient: http.DefaultClient}
+	got, err := m.PickFastestMirror(context.Background(), []string{page.URL, whole.URL, file.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != file.URL {
+		t.Errorf("picked %s, want the server that sent partial content (%s)", got, file.URL)
+	}
+}
+
+func TestPickFastestMirrorRejectsHTMLPartialContent(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("<"))
+	}))
+	defer page.Close()
+
+	m := &Manager{httpClient: http.DefaultClient}
+	if got, err := m.PickFastestMirror(context.Background(), []string{page.URL}); err == nil {
+		t.Errorf("picked %s, want an error for an HTML-only race", got)
+	}
+}
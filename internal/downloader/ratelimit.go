@@ -0,0 +1,168 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/log"
+)
+
+// minBurst is the smallest burst a limiter allows, one read buffer
+const minBurst = 32 * 1024
+
+// rateLimiter is a token bucket limiting bytes per second.
+// A single limiter is shared by every read on a Manager, so concurrent
+// downloads split the configured bandwidth instead of each getting all of it.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter for bytesPerSec, or nil for no limit
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	rate := float64(bytesPerSec)
+	burst := rate / 10
+	if burst < minBurst {
+		burst = minBurst
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until n bytes may be consumed. Callers that overdraw the
+// bucket wait for the deficit to refill, so the long-run rate holds even
+// when reads are larger than the burst.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// limitedReader throttles reads through a rateLimiter
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > minBurst {
+		p = p[:minBurst]
+	}
+	n, err := lr.r.Read(p)
+	if waitErr := lr.limiter.wait(lr.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+// downloadLimiterKey carries a download's own limiter in its context
+type downloadLimiterKey struct{}
+
+// withDownloadLimiter attaches a per-download limiter to ctx, shared by all
+// of that download's chunks
+func withDownloadLimiter(ctx context.Context, l *rateLimiter) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, downloadLimiterKey{}, l)
+}
+
+// rateLimits are a Manager's bandwidth caps: downloads.max_speed for all
+// downloads together and downloads.max_rate (or --limit-rate) for each one.
+// Both apply at once, so a download never goes faster than the lower of the
+// two, and concurrent downloads split the total between them.
+type rateLimits struct {
+	total       *rateLimiter // shared by all downloads, nil if unlimited
+	perDownload int64        // cap for each download in bytes per second, 0 if unlimited
+}
+
+// newRateLimits parses the configured caps; an invalid one is ignored
+// with a warning
+func newRateLimits(maxSpeed, maxRate string) rateLimits {
+	total, err := ParseRate(maxSpeed)
+	if err != nil {
+		log.Warn("ignoring downloads.max_speed", "error", err)
+		total = 0
+	}
+	perDownload, err := ParseRate(maxRate)
+	if err != nil {
+		log.Warn("ignoring downloads.max_rate", "error", err)
+		perDownload = 0
+	}
+	return rateLimits{total: newRateLimiter(total), perDownload: perDownload}
+}
+
+// forDownload attaches a fresh per-download limiter to ctx, shared by all
+// of that download's chunks
+func (l rateLimits) forDownload(ctx context.Context) context.Context {
+	return withDownloadLimiter(ctx, newRateLimiter(l.perDownload))
+}
+
+// limitReader wraps r with the manager's shared rate limit and the
+// download's own limit from ctx, whichever are set
+func (m *Manager) limitReader(ctx context.Context, r io.Reader) io.Reader {
+	if m.limits.total != nil {
+		r = &limitedReader{ctx: ctx, r: r, limiter: m.limits.total}
+	}
+	if l, ok := ctx.Value(downloadLimiterKey{}).(*rateLimiter); ok {
+		r = &limitedReader{ctx: ctx, r: r, limiter: l}
+	}
+	return r
+}
+
+// ParseRate parses a transfer rate such as "500KB", "1.5MB/s" or "2M" into
+// bytes per second, using ParseSize with an optional "/s". "" and "0" mean
+// unlimited.
+func ParseRate(s string) (int64, error) {
+	size := strings.TrimSpace(s)
+	if strings.HasSuffix(strings.ToLower(size), "/s") {
+		size = size[:len(size)-2]
+	}
+	rate, err := ParseSize(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q (use e.g. 500KB or 2MB)", strings.TrimSpace(s))
+	}
+	return rate, nil
+}
@@ -0,0 +1,100 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// zeroReader is an endless stream of zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// readLimited reads n bytes through m's limits as one download
+func readLimited(t *testing.T, m *Manager, n int64) {
+	t.Helper()
+	ctx := m.limits.forDownload(context.Background())
+	if _, err := io.CopyN(io.Discard, m.limitReader(ctx, zeroReader{}), n); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSharedLimitCapsAggregateThroughput(t *testing.T) {
+	const (
+		rate      = 1024 * 1024 // 1MB/s
+		downloads = 3
+		each      = 512 * 1024
+	)
+	m := &Manager{limits: rateLimits{total: newRateLimiter(rate)}}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < downloads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readLimited(t, m, each)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// 1.5MB at 1MB/s, less the initial burst
+	minimum := time.Duration(float64(downloads*each-int(m.limits.total.burst)) / rate * float64(time.Second))
+	if elapsed < minimum*9/10 {
+		t.Errorf("3 downloads took %v, want at least %v at 1MB/s total", elapsed, minimum)
+	}
+	if elapsed > 3*time.Second {
+		t.Errorf("3 downloads took %v, far slower than 1MB/s total", elapsed)
+	}
+}
+
+func TestLowerLimitWins(t *testing.T) {
+	const each = 256 * 1024
+	// A 512KB/s per-download cap under a 4MB/s total: the per-download cap
+	// sets the speed
+	m := &Manager{limits: rateLimits{total: newRateLimiter(4 * 1024 * 1024), perDownload: 512 * 1024}}
+
+	start := time.Now()
+	readLimited(t, m, each)
+	elapsed := time.Since(start)
+
+	burst := newRateLimiter(m.limits.perDownload).burst
+	minimum := time.Duration(float64(each-int(burst)) / float64(m.limits.perDownload) * float64(time.Second))
+	if elapsed < minimum*9/10 {
+		t.Errorf("download took %v, want at least %v at 512KB/s", elapsed, minimum)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"500", 500},
+		{"500KB", 500 * 1024},
+		{"1.5MB", 1536 * 1024},
+		{"2M", 2 * 1024 * 1024},
+		{"1MB/s", 1024 * 1024},
+		{"1g", 1024 * 1024 * 1024},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRate(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"fast", "-1MB", "1TB"} {
+		if _, err := ParseRate(bad); err == nil {
+			t.Errorf("ParseRate(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/log"
 )
 
 // RetryConfig holds retry settings
@@ -18,6 +20,9 @@ type RetryConfig struct {
 	BaseDelay   time.Duration
 	MaxDelay    time.Duration
 	Multiplier  float64
+	// OnRetry, if set, is called before waiting for the next attempt.
+	// attempt is the 1-based number of the upcoming attempt.
+	OnRetry func(attempt int, err error)
 }
 
 // DefaultRetryConfig returns retry config from app settings
@@ -45,6 +50,15 @@ const (
 
 // CategorizeError determines how an error should be handled
 func CategorizeError(err error, statusCode int) ErrorCategory {
+	// The host already used up its retries; move on to another mirror
+	if errors.Is(err, ErrHostDown) {
+		return ErrorNonRetryable
+	}
+	// Asking the same server again gets the whole file again
+	if errors.Is(err, ErrRangeIgnored) {
+		return ErrorNonRetryable
+	}
+
 	// Check status code first
 	switch statusCode {
 	case http.StatusTooManyRequests: // 429
@@ -123,10 +137,41 @@ func CalculateBackoff(attempt int, cfg RetryConfig) time.Duration {
 	return time.Duration(delay)
 }
 
-// RetryOperation executes an operation with exponential backoff
-func RetryOperation(ctx context.Context, cfg RetryConfig, operation func() (int, error)) error {
+// maxRetryAfter caps how long a server's Retry-After can make us wait
+const maxRetryAfter = 5 * time.Minute
+
+// parseRetryAfter reads a Retry-After header given either as seconds or as
+// an HTTP date. It returns 0 if the header is missing or invalid, and never
+// more than maxRetryAfter.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if secs, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(secs) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		delay = at.Sub(now)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return delay
+}
+
+// RetryOperation executes an operation with exponential backoff. The
+// operation returns the response it got, if any, so rate limit responses
+// can be retried after the server's Retry-After delay; its body may
+// already be closed.
+func RetryOperation(ctx context.Context, cfg RetryConfig, operation func() (*http.Response, error)) error {
 	var lastErr error
-	var statusCode int
+	var resp *http.Response
 
 	for attempt := 0; attempt < cfg.MaxAttempts; attempt++ {
 		select {
@@ -135,7 +180,7 @@ func RetryOperation(ctx context.Context, cfg RetryConfig, operation func() (int,
 		default:
 		}
 
-		statusCode, lastErr = operation()
+		resp, lastErr = operation()
 
 		// Success
 		if lastErr == nil {
@@ -143,24 +188,41 @@ func RetryOperation(ctx context.Context, cfg RetryConfig, operation func() (int,
 		}
 
 		// Check if we should retry
+		statusCode := 0
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
 		category := CategorizeError(lastErr, statusCode)
 
 		switch category {
 		case ErrorNonRetryable:
+			log.Debug("request failed, not retrying", "err", lastErr)
 			return lastErr // Don't retry
 		case ErrorRateLimited:
-			// Wait longer for rate limiting (use max delay)
+			// Wait as long as the server asks, or the max delay if it doesn't say
 			if attempt < cfg.MaxAttempts-1 {
+				wait := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+				if wait <= 0 {
+					wait = cfg.MaxDelay
+				}
+				log.Warn("rate limited, waiting before retrying", "wait", wait, "attempt", attempt+2)
+				if cfg.OnRetry != nil {
+					cfg.OnRetry(attempt+2, lastErr)
+				}
 				select {
 				case <-ctx.Done():
 					return ctx.Err()
-				case <-time.After(cfg.MaxDelay):
+				case <-time.After(wait):
 				}
 			}
 		case ErrorRetryable:
 			// Normal exponential backoff
 			if attempt < cfg.MaxAttempts-1 {
 				backoff := CalculateBackoff(attempt, cfg)
+				log.Info("request failed, retrying", "err", lastErr, "wait", backoff.Round(time.Millisecond), "attempt", attempt+2)
+				if cfg.OnRetry != nil {
+					cfg.OnRetry(attempt+2, lastErr)
+				}
 				select {
 				case <-ctx.Done():
 					return ctx.Err()
@@ -0,0 +1,34 @@
+package downloader
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"missing", "", 0},
+		{"seconds", "120", 2 * time.Minute},
+		{"seconds with spaces", " 7 ", 7 * time.Second},
+		{"negative seconds", "-5", 0},
+		{"seconds over the cap", "86400", maxRetryAfter},
+		{"http date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"http date in the past", now.Add(-time.Hour).Format(http.TimeFormat), 0},
+		{"http date over the cap", now.Add(time.Hour).Format(http.TimeFormat), maxRetryAfter},
+		{"rfc850 date", now.Add(time.Minute).Format(time.RFC850), time.Minute},
+		{"garbage", "soon", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+// simpleContent is the file the test servers serve
+var simpleContent = bytes.Repeat([]byte("0123456789"), 1000)
+
+// simpleFixture returns a manager and a download of simpleContent from a
+// server running handler
+func simpleFixture(t *testing.T, handler http.HandlerFunc) (*Manager, *db.Download) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	download := &db.Download{
+		DownloadURL: srv.URL,
+		FileSize:    int64(len(simpleContent)),
+		FilePath:    filepath.Join(dir, "book.epub"),
+		TempPath:    filepath.Join(dir, "book.epub.part"),
+	}
+	return &Manager{httpClient: srv.Client()}, download
+}
+
+// serveRanges serves simpleContent, honoring Range requests
+func serveRanges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/epub+zip")
+	http.ServeContent(w, r, "book.epub", time.Time{}, bytes.NewReader(simpleContent))
+}
+
+// checkCompleted fails unless the download ended up whole at FilePath
+func checkCompleted(t *testing.T, download *db.Download) {
+	t.Helper()
+	data, err := os.ReadFile(download.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, simpleContent) {
+		t.Errorf("got %d bytes that don't match the served file", len(data))
+	}
+	if _, err := os.Stat(download.TempPath); !os.IsNotExist(err) {
+		t.Error("the .part file was left behind")
+	}
+}
+
+func TestDownloadSimpleResumesInterruptedCopy(t *testing.T) {
+	const cut = 4000
+	var ranges []string
+	m, download := simpleFixture(t, func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.Header.Get("Range") == "" {
+			// The connection drops partway through the file
+			w.Header().Set("Content-Type", "application/epub+zip")
+			w.Header().Set("Content-Length", strconv.Itoa(len(simpleContent)))
+			w.Write(simpleContent[:cut])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		serveRanges(w, r)
+	})
+
+	if err := m.downloadSimple(context.Background(), download, nil); err == nil {
+		t.Fatal("the interrupted copy succeeded")
+	}
+	part, err := os.ReadFile(download.TempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(part, simpleContent[:cut]) {
+		t.Fatalf("the .part file has %d bytes, want the %d received", len(part), cut)
+	}
+
+	if err := m.downloadSimple(context.Background(), download, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkCompleted(t, download)
+	if want := "bytes=" + strconv.Itoa(cut) + "-"; len(ranges) != 2 || ranges[1] != want {
+		t.Errorf("requests asked for ranges %q, want a second one for %q", ranges, want)
+	}
+}
+
+func TestDownloadSimpleStartsOverWhenRangeIgnored(t *testing.T) {
+	m, download := simpleFixture(t, func(w http.ResponseWriter, r *http.Request) {
+		// Always the whole file with 200
+		w.Header().Set("Content-Type", "application/epub+zip")
+		w.Write(simpleContent)
+	})
+	if err := os.WriteFile(download.TempPath, simpleContent[:3000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.downloadSimple(context.Background(), download, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkCompleted(t, download)
+}
+
+func TestDownloadSimpleStartsOverWhenRangeUnsatisfiable(t *testing.T) {
+	m, download := simpleFixture(t, serveRanges)
+	// Longer than the file on the server, so the range can't be served
+	if err := os.WriteFile(download.TempPath, bytes.Repeat([]byte("x"), len(simpleContent)+10), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.downloadSimple(context.Background(), download, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkCompleted(t, download)
+}
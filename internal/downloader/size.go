@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseSize parses a size setting or flag such as "500KB", "1.5MB" or "2M"
+// into bytes. Plain numbers are bytes and "" is 0.
+func ParseSize(s string) (int64, error) {
+	trimmed := strings.ToUpper(strings.TrimSpace(s))
+	if trimmed == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{
+		{"GB", 1024 * 1024 * 1024}, {"MB", 1024 * 1024}, {"KB", 1024},
+		{"G", 1024 * 1024 * 1024}, {"M", 1024 * 1024}, {"K", 1024}, {"B", 1},
+	} {
+		if strings.HasSuffix(trimmed, unit.suffix) {
+			multiplier = unit.mult
+			trimmed = strings.TrimSpace(strings.TrimSuffix(trimmed, unit.suffix))
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 500KB or 2MB)", strings.TrimSpace(s))
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// FormatBytes formats a byte count for display, e.g. "1.5 MB"
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
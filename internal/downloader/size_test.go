@@ -0,0 +1,30 @@
+package downloader
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"10240", 10240},
+		{"10KB", 10 * 1024},
+		{"10 kb", 10 * 1024},
+		{"8M", 8 * 1024 * 1024},
+		{"1.5GB", 1536 * 1024 * 1024},
+		{"100B", 100},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"big", "-10KB", "1TB", "1MB/s"} {
+		if _, err := ParseSize(bad); err == nil {
+			t.Errorf("ParseSize(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package downloader
 
 import (
 	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"strings"
@@ -12,10 +15,34 @@ import (
 
 // VerifyChecksum verifies the MD5 checksum of a downloaded file
 func VerifyChecksum(download *db.Download) error {
+	return VerifyWith(download, "md5")
+}
+
+// VerifyWith verifies a downloaded file with the given algorithm: md5, sha1
+// or sha256. If the download has no hash recorded for algo, it falls back
+// to MD5, which is always known.
+func VerifyWith(download *db.Download, algo string) error {
 	if download.FilePath == "" {
 		return fmt.Errorf("file path is empty")
 	}
 
+	algo = strings.ToLower(strings.TrimSpace(algo))
+	var h hash.Hash
+	var expectedHash string
+	switch algo {
+	case "sha1":
+		h, expectedHash = sha1.New(), download.SHA1
+	case "sha256":
+		h, expectedHash = sha256.New(), download.SHA256
+	case "", "md5":
+		h, expectedHash = md5.New(), download.MD5Hash
+	default:
+		return fmt.Errorf("unsupported checksum algorithm %q (use md5, sha1, or sha256)", algo)
+	}
+	if expectedHash == "" {
+		h, expectedHash = md5.New(), download.MD5Hash
+	}
+
 	// Open the file
 	file, err := os.Open(download.FilePath)
 	if err != nil {
@@ -23,17 +50,16 @@ func VerifyChecksum(download *db.Download) error {
 	}
 	defer file.Close()
 
-	// Calculate MD5 hash
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
+	// Calculate the hash
+	if _, err := io.Copy(h, file); err != nil {
 		return fmt.Errorf("failed to calculate checksum: %w", err)
 	}
 
 	// Get checksum as hex string
-	checksum := fmt.Sprintf("%x", hash.Sum(nil))
+	checksum := fmt.Sprintf("%x", h.Sum(nil))
 
 	// Compare with expected hash
-	expectedHash := strings.ToLower(strings.TrimSpace(download.MD5Hash))
+	expectedHash = strings.ToLower(strings.TrimSpace(expectedHash))
 	if checksum != expectedHash {
 		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedHash, checksum)
 	}
@@ -41,9 +67,14 @@ func VerifyChecksum(download *db.Download) error {
 	return nil
 }
 
-// VerifyAndMark verifies a download and updates its verified status
+// VerifyAndMark verifies a download's MD5 and updates its verified status
 func VerifyAndMark(download *db.Download) error {
-	err := VerifyChecksum(download)
+	return VerifyAndMarkWith(download, "md5")
+}
+
+// VerifyAndMarkWith verifies a download with algo and updates its verified status
+func VerifyAndMarkWith(download *db.Download, algo string) error {
+	err := VerifyWith(download, algo)
 	if err != nil {
 		// Mark as not verified
 		if markErr := db.MarkVerified(download.ID, false); markErr != nil {
@@ -59,3 +90,99 @@ func VerifyAndMark(download *db.Download) error {
 
 	return nil
 }
+
+// chunkChecksum returns the SHA-256 of a chunk's bytes in file
+func chunkChecksum(file *os.File, chunk *db.Chunk) (string, error) {
+	hash := sha256.New()
+	section := io.NewSectionReader(file, chunk.StartByte, chunk.EndByte-chunk.StartByte+1)
+	if _, err := io.Copy(hash, section); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// validatePartialFile compares the chunks' recorded progress with the size
+// of the .part file. A chunk claiming bytes beyond the end of the file (after
+// a crash, truncation or a full disk) is wound back to what is actually on
+// disk. Returns the number of chunks adjusted.
+func validatePartialFile(download *db.Download, chunks []*db.Chunk) (int, error) {
+	var size int64
+	info, err := os.Stat(download.TempPath)
+	if err == nil {
+		size = info.Size()
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	fixed := 0
+	for _, chunk := range chunks {
+		end := chunk.StartByte + chunk.Downloaded
+		if chunk.Status == "completed" {
+			end = chunk.EndByte + 1
+		}
+		if end <= size {
+			continue
+		}
+
+		// Keep what is on disk for this chunk, if anything
+		onDisk := size - chunk.StartByte
+		if onDisk < 0 {
+			onDisk = 0
+		}
+		if err := db.ResetChunk(chunk.ID); err != nil {
+			return fixed, err
+		}
+		if onDisk > 0 {
+			if err := db.UpdateChunkProgress(chunk.ID, onDisk); err != nil {
+				return fixed, err
+			}
+		}
+		chunk.Status = "pending"
+		chunk.Downloaded = onDisk
+		chunk.Checksum = ""
+		fixed++
+	}
+	return fixed, nil
+}
+
+// etagKey is the meta row holding the ETag a download was started with
+func etagKey(downloadID int64) string {
+	return fmt.Sprintf("etag:%d", downloadID)
+}
+
+// etagChanged reports whether the server's ETag differs from the one
+// recorded when the download started, i.e. the remote file has changed
+func etagChanged(download *db.Download, etag string) (bool, error) {
+	previous, err := db.GetMeta(etagKey(download.ID))
+	if err != nil {
+		return false, err
+	}
+	return previous != "" && previous != etag, nil
+}
+
+// verifyChunks re-hashes completed chunks that have a stored checksum and
+// resets any whose bytes on disk no longer match, so they are downloaded
+// again. Returns the number of chunks reset.
+func verifyChunks(file *os.File, chunks []*db.Chunk) (int, error) {
+	reset := 0
+	for _, chunk := range chunks {
+		if chunk.Status != "completed" || chunk.Checksum == "" {
+			continue
+		}
+		sum, err := chunkChecksum(file, chunk)
+		if err != nil {
+			return reset, err
+		}
+		if sum == chunk.Checksum {
+			continue
+		}
+		if err := db.ResetChunk(chunk.ID); err != nil {
+			return reset, err
+		}
+		chunk.Status = "pending"
+		chunk.Downloaded = 0
+		chunk.Checksum = ""
+		reset++
+	}
+	return reset, nil
+}
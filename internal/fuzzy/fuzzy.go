@@ -0,0 +1,125 @@
+// Package fuzzy normalizes book titles and authors and scores how similar
+// two strings are, for spotting the same book listed more than once
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalize lowercases s and reduces it to letters and digits separated by
+// single spaces
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// NormalizeTitle normalizes a title without its subtitle: "Title:
+// Subtitle", "Title - Subtitle" and "Title (2nd Edition)" are the same book
+func NormalizeTitle(title string) string {
+	for _, sep := range []string{":", " - ", " — ", "(", "["} {
+		if idx := strings.Index(title, sep); idx > 0 {
+			title = title[:idx]
+		}
+	}
+	return Normalize(title)
+}
+
+// NormalizeAuthor normalizes the first author of an author list
+func NormalizeAuthor(authors string) string {
+	for _, sep := range []string{",", ";", "&", " and "} {
+		if idx := strings.Index(authors, sep); idx > 0 {
+			authors = authors[:idx]
+		}
+	}
+	return Normalize(authors)
+}
+
+// Levenshtein returns the number of single-rune insertions, deletions and
+// substitutions that turn a into b
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// Similarity scores a and b from 0 (nothing alike) to 1 (the same once
+// normalized). It takes the better of the edit distance, which forgives
+// typos, and the share of words in common, which forgives word order
+// ("Fowler Martin" and "Martin Fowler").
+func Similarity(a, b string) float64 {
+	a, b = Normalize(a), Normalize(b)
+	if a == b {
+		return 1
+	}
+	if a == "" || b == "" {
+		return 0
+	}
+
+	longest := max(len([]rune(a)), len([]rune(b)))
+	edit := 1 - float64(Levenshtein(a, b))/float64(longest)
+	return max(edit, tokenSetSimilarity(a, b))
+}
+
+// Overlap returns the words a and b share as a fraction of the distinct
+// words in the shorter one, so a name matches a fuller form of itself
+// ("Tolkien" and "J. R. R. Tolkien")
+func Overlap(a, b string) float64 {
+	shared, inA, inB := wordCounts(Normalize(a), Normalize(b))
+	if inA == 0 || inB == 0 {
+		return 0
+	}
+	return float64(shared) / float64(min(inA, inB))
+}
+
+// tokenSetSimilarity returns the words a and b share as a fraction of all
+// the distinct words in either
+func tokenSetSimilarity(a, b string) float64 {
+	shared, inA, inB := wordCounts(a, b)
+	return float64(shared) / float64(inA+inB-shared)
+}
+
+// wordCounts counts the distinct words of a and b, and those in both
+func wordCounts(a, b string) (shared, inA, inB int) {
+	words := make(map[string]int) // bit 1: in a, bit 2: in b
+	for _, w := range strings.Fields(a) {
+		words[w] |= 1
+	}
+	for _, w := range strings.Fields(b) {
+		words[w] |= 2
+	}
+	for _, in := range words {
+		if in&1 != 0 {
+			inA++
+		}
+		if in&2 != 0 {
+			inB++
+		}
+		if in == 3 {
+			shared++
+		}
+	}
+	return shared, inA, inB
+}
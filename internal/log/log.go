@@ -0,0 +1,106 @@
+// Package log is bookdl's leveled diagnostic log. Messages for the user go
+// through the cli print helpers instead; these explain what happened behind
+// the scenes, such as which mirror was used or why a request was retried.
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultLevel is the level used when none is configured
+const DefaultLevel = slog.LevelWarn
+
+var (
+	logger = newLogger(os.Stderr, DefaultLevel)
+	output *os.File // the log file, if logging to one
+)
+
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", s)
+}
+
+// Setup logs messages at level and above to path, or to stderr if path is
+// empty. A log file is appended to.
+func Setup(level slog.Level, path string) error {
+	Close()
+
+	if path == "" {
+		logger = newLogger(os.Stderr, level)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	output = f
+	logger = newLogger(f, level)
+	return nil
+}
+
+// Close closes the log file, if any, and goes back to logging to stderr
+func Close() {
+	if output == nil {
+		return
+	}
+	output.Close()
+	output = nil
+	logger = newLogger(os.Stderr, DefaultLevel)
+}
+
+// Enabled reports whether messages at level are logged
+func Enabled(level slog.Level) bool {
+	return logger.Enabled(context.Background(), level)
+}
+
+// Debug logs details that are only useful when digging into a problem
+func Debug(msg string, args ...any) {
+	logger.Debug(msg, args...)
+}
+
+// Info logs notable events, such as falling back to another mirror
+func Info(msg string, args ...any) {
+	logger.Info(msg, args...)
+}
+
+// Warn logs problems bookdl worked around, such as a Cloudflare challenge
+func Warn(msg string, args ...any) {
+	logger.Warn(msg, args...)
+}
+
+// Error logs failures
+func Error(msg string, args ...any) {
+	logger.Error(msg, args...)
+}
+
+// Debugf logs a printf-style message at debug level, for libraries that
+// take a printf-like logger
+func Debugf(format string, args ...any) {
+	if Enabled(slog.LevelDebug) {
+		logger.Debug(strings.TrimSpace(fmt.Sprintf(format, args...)))
+	}
+}
@@ -0,0 +1,452 @@
+// Package meta reads the metadata embedded in downloaded books
+package meta
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrUnsupported is returned for file types metadata can't be read from
+var ErrUnsupported = errors.New("unsupported file type")
+
+// Metadata is what a book file says about itself. Fields the file doesn't
+// set are empty.
+type Metadata struct {
+	Title     string `json:"title,omitempty"`
+	Authors   string `json:"authors,omitempty"`
+	Year      string `json:"year,omitempty"`
+	Publisher string `json:"publisher,omitempty"`
+	Language  string `json:"language,omitempty"`
+}
+
+// Empty reports whether no title or author was found
+func (m *Metadata) Empty() bool {
+	return m.Title == "" && m.Authors == ""
+}
+
+// Extract reads metadata from an EPUB or PDF file, based on its extension
+func Extract(path string) (*Metadata, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".epub":
+		return ExtractEPUB(path)
+	case ".pdf":
+		return ExtractPDF(path)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, filepath.Ext(path))
+}
+
+// yearPattern finds a four digit year in a date
+var yearPattern = regexp.MustCompile(`\b(1[5-9]|20)\d{2}\b`)
+
+// ExtractEPUB reads the Dublin Core metadata from an EPUB's package
+// document (content.opf)
+func ExtractEPUB(path string) (*Metadata, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid EPUB: %w", err)
+	}
+	defer r.Close()
+
+	opfPath, err := epubPackagePath(&r.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readZipFile(&r.Reader, opfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg struct {
+		Metadata struct {
+			Titles     []string `xml:"title"`
+			Creators   []string `xml:"creator"`
+			Dates      []string `xml:"date"`
+			Publishers []string `xml:"publisher"`
+			Languages  []string `xml:"language"`
+		} `xml:"metadata"`
+	}
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("invalid package document: %w", err)
+	}
+
+	md := pkg.Metadata
+	m := &Metadata{
+		Title:     first(md.Titles),
+		Authors:   strings.Join(trimAll(md.Creators), ", "),
+		Publisher: first(md.Publishers),
+		Language:  first(md.Languages),
+	}
+	for _, date := range md.Dates {
+		if year := yearPattern.FindString(date); year != "" {
+			m.Year = year
+			break
+		}
+	}
+	return m, nil
+}
+
+// epubPackagePath finds the package document via META-INF/container.xml,
+// falling back to the first .opf file in the archive
+func epubPackagePath(r *zip.Reader) (string, error) {
+	if data, err := readZipFile(r, "META-INF/container.xml"); err == nil {
+		var container struct {
+			Rootfiles []struct {
+				FullPath string `xml:"full-path,attr"`
+			} `xml:"rootfiles>rootfile"`
+		}
+		if xml.Unmarshal(data, &container) == nil {
+			for _, rf := range container.Rootfiles {
+				if rf.FullPath != "" {
+					return rf.FullPath, nil
+				}
+			}
+		}
+	}
+	for _, f := range r.File {
+		if strings.EqualFold(path.Ext(f.Name), ".opf") {
+			return f.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no package document (content.opf) found")
+}
+
+// maxZipEntry bounds how much of one archive entry is read
+const maxZipEntry = 4 << 20
+
+// readZipFile returns the contents of the named archive entry
+func readZipFile(r *zip.Reader, name string) ([]byte, error) {
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxZipEntry))
+	}
+	return nil, fmt.Errorf("%s not found in archive", name)
+}
+
+// first returns the first non-empty value, trimmed
+func first(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// trimAll returns the non-empty values, trimmed
+func trimAll(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+var (
+	// pdfInfoRef finds the trailer's reference to the Info dictionary
+	pdfInfoRef = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	// pdfDateYear reads the year from a PDF date such as D:20190304...
+	pdfDateYear = regexp.MustCompile(`^D?:?(\d{4})`)
+	// pdfObjectHeader finds "num gen obj <<", the start of an object's dictionary
+	pdfObjectHeader = regexp.MustCompile(`(?:^|\s)(\d+)\s+(\d+)\s+obj\s*<<`)
+	// pdfDictKey finds a key with a string value in a dictionary
+	pdfDictKey = regexp.MustCompile(`/([A-Za-z]+)\s*([(<])`)
+	// pdfStartXref finds the offset of the last cross-reference section
+	pdfStartXref = regexp.MustCompile(`startxref\s+(\d+)`)
+	// pdfXrefSubsection reads a cross-reference subsection header: first
+	// object number and count
+	pdfXrefSubsection = regexp.MustCompile(`^\s*(\d+) (\d+)[ \t]*\r?\n`)
+	// pdfPrevXref finds a trailer's link to the previous cross-reference section
+	pdfPrevXref = regexp.MustCompile(`/Prev\s+(\d+)`)
+)
+
+const (
+	// pdfTailSize is how much of the end of a PDF is read for the trailer
+	pdfTailSize = 64 * 1024
+	// pdfObjectMax bounds how much is read for the Info object
+	pdfObjectMax = 64 * 1024
+	// pdfMaxXrefSections bounds how many incremental updates are followed
+	pdfMaxXrefSections = 16
+	// pdfScanBlock is how much is read at a time when an object has to be
+	// searched for
+	pdfScanBlock = 1024 * 1024
+)
+
+// ExtractPDF reads the title, author and creation year from a PDF's
+// Info dictionary. Only the trailer at the end of the file and the Info
+// object are read, found through the cross-reference table; files with
+// cross-reference streams are searched block by block instead. Info
+// dictionaries inside compressed object streams aren't found; those files
+// return empty metadata.
+func ExtractPDF(path string) (*Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+
+	if !bytes.HasPrefix(readAtMost(f, 0, 8), []byte("%PDF")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+
+	m := &Metadata{}
+
+	// The last trailer wins, as later incremental updates override earlier
+	// ones. Linearized files may only have it in the first page's trailer.
+	tailStart := size - pdfTailSize
+	if tailStart < 0 {
+		tailStart = 0
+	}
+	tail := readAtMost(f, tailStart, pdfTailSize)
+	refs := pdfInfoRef.FindAllSubmatch(tail, -1)
+	if len(refs) == 0 {
+		refs = pdfInfoRef.FindAllSubmatch(readAtMost(f, 0, pdfTailSize), -1)
+	}
+	if len(refs) == 0 {
+		return m, nil
+	}
+	ref := refs[len(refs)-1]
+	num, _ := strconv.Atoi(string(ref[1]))
+	gen, _ := strconv.Atoi(string(ref[2]))
+
+	dict := pdfFindObject(f, size, tail, num, gen)
+	if dict == nil {
+		return m, nil
+	}
+
+	m.Title = pdfDictString(dict, "Title")
+	m.Authors = pdfDictString(dict, "Author")
+	if match := pdfDateYear.FindStringSubmatch(pdfDictString(dict, "CreationDate")); match != nil {
+		m.Year = match[1]
+	}
+	return m, nil
+}
+
+// readAtMost reads up to n bytes at off, returning what could be read
+func readAtMost(r io.ReaderAt, off int64, n int) []byte {
+	buf := make([]byte, n)
+	read, _ := r.ReadAt(buf, off)
+	return buf[:read]
+}
+
+// pdfFindObject returns the dictionary of object num gen, looking it up in
+// the cross-reference table and falling back to searching the file
+func pdfFindObject(f io.ReaderAt, size int64, tail []byte, num, gen int) []byte {
+	if starts := pdfStartXref.FindAllSubmatch(tail, -1); len(starts) > 0 {
+		xref, _ := strconv.ParseInt(string(starts[len(starts)-1][1]), 10, 64)
+		if off, ok := pdfXrefLookup(f, xref, num); ok {
+			if dict := pdfObjectDict(readAtMost(f, off, pdfObjectMax), num, gen); dict != nil {
+				return dict
+			}
+		}
+	}
+
+	// Blocks overlap by pdfObjectMax so an object isn't missed for
+	// straddling two of them
+	for off := int64(0); off < size; off += pdfScanBlock - pdfObjectMax {
+		if dict := pdfObjectDict(readAtMost(f, off, pdfScanBlock), num, gen); dict != nil {
+			return dict
+		}
+	}
+	return nil
+}
+
+// pdfXrefLookup finds the file offset of object num in the cross-reference
+// table at xref, following /Prev links to earlier sections. It fails for
+// cross-reference streams, which aren't read.
+func pdfXrefLookup(f io.ReaderAt, xref int64, num int) (int64, bool) {
+	for section := 0; section < pdfMaxXrefSections; section++ {
+		if !bytes.HasPrefix(readAtMost(f, xref, 4), []byte("xref")) {
+			return 0, false
+		}
+		pos := xref + 4
+		for {
+			header := readAtMost(f, pos, 64)
+			loc := pdfXrefSubsection.FindSubmatchIndex(header)
+			if loc == nil {
+				break // the trailer
+			}
+			first, _ := strconv.Atoi(string(header[loc[2]:loc[3]]))
+			count, _ := strconv.Atoi(string(header[loc[4]:loc[5]]))
+			pos += int64(loc[1])
+			if num >= first && num < first+count {
+				// Entries are exactly 20 bytes: "0000012345 00000 n\r\n"
+				entry := readAtMost(f, pos+int64(num-first)*20, 20)
+				if len(entry) < 18 || entry[17] != 'n' {
+					return 0, false
+				}
+				off, err := strconv.ParseInt(string(entry[:10]), 10, 64)
+				return off, err == nil
+			}
+			pos += int64(count) * 20
+		}
+
+		prev := pdfPrevXref.FindSubmatch(readAtMost(f, pos, 4096))
+		if prev == nil {
+			return 0, false
+		}
+		xref, _ = strconv.ParseInt(string(prev[1]), 10, 64)
+	}
+	return 0, false
+}
+
+// pdfObjectDict returns the dictionary of object "num gen obj" in data, or nil
+func pdfObjectDict(data []byte, num, gen int) []byte {
+	for _, loc := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		n, _ := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		g, _ := strconv.Atoi(string(data[loc[4]:loc[5]]))
+		if n != num || g != gen {
+			continue
+		}
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endobj"))
+		if end < 0 {
+			return nil
+		}
+		return data[start : start+end]
+	}
+	return nil
+}
+
+// pdfDictString returns the string value of /key in a dictionary, decoding
+// literal "(...)" and hex "<...>" strings
+func pdfDictString(dict []byte, key string) string {
+	var rest []byte
+	for _, loc := range pdfDictKey.FindAllSubmatchIndex(dict, -1) {
+		if string(dict[loc[2]:loc[3]]) == key {
+			rest = dict[loc[4]:]
+			break
+		}
+	}
+	if rest == nil {
+		return ""
+	}
+
+	var raw []byte
+	if rest[0] == '<' {
+		end := bytes.IndexByte(rest, '>')
+		if end < 0 {
+			return ""
+		}
+		raw = decodePDFHex(rest[1:end])
+	} else {
+		raw = decodePDFLiteral(rest)
+	}
+	return strings.TrimSpace(decodePDFText(raw))
+}
+
+// decodePDFLiteral decodes a literal string starting at its opening paren
+func decodePDFLiteral(s []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch e := s[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					j := i
+					for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+						j++
+					}
+					v, _ := strconv.ParseUint(string(s[i:j]), 8, 8)
+					out = append(out, byte(v))
+					i = j - 1
+				} else {
+					out = append(out, e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				out = append(out, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// decodePDFHex decodes a hex string body, ignoring whitespace
+func decodePDFHex(s []byte) []byte {
+	var digits []byte
+	for _, c := range s {
+		if strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+// decodePDFText converts a PDF text string (UTF-16BE or UTF-8 with a byte
+// order mark, or PDFDocEncoding, treated here as Latin-1) to UTF-8
+func decodePDFText(b []byte) string {
+	if bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}) {
+		return string(b[3:])
+	}
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		units := make([]uint16, 0, (len(b)-2)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
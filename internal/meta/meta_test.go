@@ -0,0 +1,111 @@
+package meta
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildPDF writes a minimal PDF whose objects are listed in a cross-reference
+// table, with padding before the Info object so it isn't in the first or
+// last pdfTailSize bytes
+func buildPDF(t *testing.T, info string, withXref bool) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, 3)
+
+	offsets[1] = buf.Len()
+	buf.WriteString("1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+	buf.WriteString("% " + string(bytes.Repeat([]byte("x"), 2*pdfTailSize)) + "\n")
+
+	offsets[2] = buf.Len()
+	buf.WriteString("2 0 obj\n<< " + info + " >>\nendobj\n")
+	buf.WriteString("% " + string(bytes.Repeat([]byte("y"), 2*pdfTailSize)) + "\n")
+
+	xref := buf.Len()
+	if withXref {
+		buf.WriteString("xref\n0 3\n0000000000 65535 f\r\n")
+		for _, off := range offsets[1:] {
+			fmt.Fprintf(&buf, "%010d 00000 n\r\n", off)
+		}
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size 3 /Root 1 0 R /Info 2 0 R >>\nstartxref\n%d\n%%%%EOF\n", xref)
+
+	path := filepath.Join(t.TempDir(), "book.pdf")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractPDF(t *testing.T) {
+	info := "/Title (Dune \\(Deluxe\\)) /Author <FEFF004600720061006E006B> /CreationDate (D:19650801000000Z)"
+
+	for _, withXref := range []bool{true, false} {
+		t.Run(fmt.Sprintf("xref=%v", withXref), func(t *testing.T) {
+			m, err := ExtractPDF(buildPDF(t, info, withXref))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Title != "Dune (Deluxe)" {
+				t.Errorf("Title = %q", m.Title)
+			}
+			if m.Authors != "Frank" {
+				t.Errorf("Authors = %q", m.Authors)
+			}
+			if m.Year != "1965" {
+				t.Errorf("Year = %q", m.Year)
+			}
+		})
+	}
+}
+
+func TestExtractPDFNotPDF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.pdf")
+	if err := os.WriteFile(path, []byte("<html>captcha</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExtractPDF(path); err == nil {
+		t.Error("expected an error for a non-PDF file")
+	}
+}
+
+func TestPdfDictString(t *testing.T) {
+	dict := []byte("/Subtitle (wrong) /Title (Right) /Author()")
+	if got := pdfDictString(dict, "Title"); got != "Right" {
+		t.Errorf("Title = %q", got)
+	}
+	if got := pdfDictString(dict, "Author"); got != "" {
+		t.Errorf("Author = %q", got)
+	}
+	if got := pdfDictString(dict, "Creator"); got != "" {
+		t.Errorf("Creator = %q", got)
+	}
+}
+
+func TestPdfXrefLookup(t *testing.T) {
+	// An incremental update whose section only lists object 3; object 1 is
+	// found through /Prev in the original section
+	head := "%PDF-1.4\n1 0 obj\n<< >>\nendobj\n"
+	prev := len(head)
+	pdf := head +
+		"xref\n0 2\n0000000000 65535 f\r\n0000000009 00000 n\r\n" +
+		"trailer\n<< /Size 2 >>\n"
+	update := fmt.Sprintf("xref\n3 1\n0000000123 00000 n\r\ntrailer\n<< /Size 4 /Prev %d >>\n", prev)
+	data := []byte(pdf + update)
+	r := bytes.NewReader(data)
+
+	if off, ok := pdfXrefLookup(r, int64(len(pdf)), 3); !ok || off != 123 {
+		t.Errorf("object 3: got %d, %v", off, ok)
+	}
+	if off, ok := pdfXrefLookup(r, int64(len(pdf)), 1); !ok || off != 9 {
+		t.Errorf("object 1: got %d, %v", off, ok)
+	}
+	if _, ok := pdfXrefLookup(r, int64(len(pdf)), 7); ok {
+		t.Error("object 7 isn't listed")
+	}
+}
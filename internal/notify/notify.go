@@ -1,6 +1,7 @@
 package notify
 
 import (
+	"fmt"
 	"os/exec"
 	"runtime"
 
@@ -33,6 +34,7 @@ func Send(title, message, notifyType string) {
 // DownloadComplete sends a download complete notification
 func DownloadComplete(filename string) {
 	Send("Download Complete", filename, TypeSuccess)
+	SendWebhook(EventDownloadComplete, Payload{Title: "Download Complete", Message: filename})
 }
 
 // DownloadFailed sends a download failed notification
@@ -42,6 +44,7 @@ func DownloadFailed(filename, reason string) {
 		msg += ": " + reason
 	}
 	Send("Download Failed", msg, TypeError)
+	SendWebhook(EventDownloadFailed, Payload{Title: "Download Failed", Message: msg})
 }
 
 // QueueComplete sends a queue completion notification
@@ -53,6 +56,12 @@ func QueueComplete(completed, failed int) {
 		msg = "Completed with some failures"
 	}
 	Send("Queue Complete", msg, TypeInfo)
+	SendWebhook(EventQueueComplete, Payload{
+		Title:     "Queue Complete",
+		Message:   fmt.Sprintf("%s (%d completed, %d failed)", msg, completed, failed),
+		Completed: completed,
+		Failed:    failed,
+	})
 }
 
 func sendNotification(title, message, notifyType string) {
@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/config"
+	"github.com/billmal071/bookdl/internal/log"
+)
+
+// Webhook events
+const (
+	EventDownloadComplete = "download_complete"
+	EventDownloadFailed   = "download_failed"
+	EventQueueComplete    = "queue_complete"
+	EventTest             = "test"
+)
+
+// Payload is what a webhook receives. Text and Content repeat the message
+// under the names Slack and Discord read, so the default body works with
+// both; webhook_template can shape it for anything else.
+type Payload struct {
+	Event     string    `json:"event"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Text      string    `json:"text"`
+	Content   string    `json:"content"`
+	Completed int       `json:"completed,omitempty"`
+	Failed    int       `json:"failed,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// pending tracks webhooks still in flight, so the process can wait for
+// them before exiting
+var pending sync.WaitGroup
+
+// SendWebhook posts the event to notifications.webhook_url in the
+// background, if one is set. Failures are only logged.
+func SendWebhook(event string, payload Payload) {
+	if config.Get().Notifications.WebhookURL == "" {
+		return
+	}
+
+	pending.Add(1)
+	go func() {
+		defer pending.Done()
+		if err := PostWebhook(context.Background(), event, payload); err != nil {
+			log.Warn("webhook failed", "event", event, "err", err)
+		}
+	}()
+}
+
+// Wait blocks until webhooks sent so far have finished or timed out
+func Wait() {
+	pending.Wait()
+}
+
+// PostWebhook posts the event to notifications.webhook_url and waits for
+// the response
+func PostWebhook(ctx context.Context, event string, payload Payload) error {
+	cfg := config.Get().Notifications
+	if cfg.WebhookURL == "" {
+		return fmt.Errorf("notifications.webhook_url is not set")
+	}
+
+	payload.Event = event
+	payload.Text = payload.Title + ": " + payload.Message
+	payload.Content = payload.Text
+	if payload.Time.IsZero() {
+		payload.Time = time.Now()
+	}
+
+	body, err := webhookBody(cfg.WebhookTemplate, payload)
+	if err != nil {
+		return err
+	}
+
+	if cfg.WebhookTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.WebhookTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bookdl")
+
+	client := &http.Client{Transport: config.ProxyTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookBody renders the request body: payload as JSON, or tmpl executed
+// with it. Templates can use {{json .Message}} to insert a quoted string.
+func webhookBody(tmpl string, payload Payload) ([]byte, error) {
+	if tmpl == "" {
+		return json.Marshal(payload)
+	}
+
+	t, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notifications.webhook_template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("invalid notifications.webhook_template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,25 @@
+// Package open opens files and URLs with the system's default application
+package open
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// Start opens target (a file path or URL) with the default application
+// for it, without waiting for that application to exit
+func Start(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("xdg-open", target)
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		return fmt.Errorf("unsupported platform")
+	}
+	return cmd.Start()
+}
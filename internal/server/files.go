@@ -0,0 +1,114 @@
+// Package server shares the downloaded library over HTTP
+package server
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/log"
+)
+
+// mimeTypes maps book formats to their media types
+var mimeTypes = map[string]string{
+	"epub": "application/epub+zip",
+	"pdf":  "application/pdf",
+	"mobi": "application/x-mobipocket-ebook",
+	"azw3": "application/vnd.amazon.ebook",
+	"djvu": "image/vnd.djvu",
+	"fb2":  "application/x-fictionbook+xml",
+	"cbz":  "application/vnd.comicbook+zip",
+	"cbr":  "application/vnd.comicbook-rar",
+	"txt":  "text/plain; charset=utf-8",
+}
+
+// mimeType returns the media type for a book format, falling back to the
+// system's table for formats not listed above
+func mimeType(format string) string {
+	format = strings.ToLower(format)
+	if t, ok := mimeTypes[format]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension("." + format); t != "" && format != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// library returns the completed, unarchived downloads whose files are still
+// on disk: the ones serveFile will serve
+func library() ([]*db.Download, error) {
+	downloads, err := db.ListUnarchivedDownloads(db.StatusCompleted, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var books []*db.Download
+	for _, d := range downloads {
+		if d.FilePath == "" {
+			continue
+		}
+		if _, err := os.Stat(d.FilePath); err != nil {
+			continue
+		}
+		books = append(books, d)
+	}
+	return books, nil
+}
+
+// fileURL is where a download's file is served
+func fileURL(d *db.Download) string {
+	return fmt.Sprintf("/files/%d/%s", d.ID, url.PathEscape(filepath.Base(d.FilePath)))
+}
+
+// fileHandler serves the file of the completed download in the {id} path
+// value. The filename after the ID is only there for clients that name
+// the file after the URL. Inline files may be shown by the browser rather
+// than saved.
+func fileHandler(inline bool) http.HandlerFunc {
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveFile(w, r, disposition)
+	}
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, disposition string) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	d, err := db.GetDownload(id)
+	if err != nil || d.Status != db.StatusCompleted || d.Archived || d.FilePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(d.FilePath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("serving file", "id", d.ID, "file", d.FilePath, "client", r.RemoteAddr)
+	w.Header().Set("Content-Type", mimeType(d.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, filepath.Base(d.FilePath)))
+	http.ServeContent(w, r, filepath.Base(d.FilePath), info.ModTime(), f)
+}
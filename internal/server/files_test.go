@@ -0,0 +1,75 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+// addBook records a completed epub download whose file exists on disk
+func addBook(t *testing.T, md5Hash, title string) *db.Download {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), title+".epub")
+	if err := os.WriteFile(path, []byte("epub"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := &db.Download{MD5Hash: md5Hash, Title: title, Format: "epub", Status: db.StatusPending}
+	if err := db.CreateDownload(d); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DeleteDownload(d.ID) })
+	if err := db.MarkCompleted(d.ID, path); err != nil {
+		t.Fatal(err)
+	}
+	d.FilePath = path
+	return d
+}
+
+// get returns the body h answers path with
+func get(t *testing.T, h http.Handler, path string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d", path, rec.Code)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestArchivedBooksNotListed(t *testing.T) {
+	addBook(t, "0123456789abcdef0123456789abcdef", "Shelved")
+	archived := addBook(t, "fedcba9876543210fedcba9876543210", "Archived")
+	// The file is still on disk, only the row says it's archived
+	if err := db.MarkArchived(archived.ID, archived.FilePath); err != nil {
+		t.Fatal(err)
+	}
+
+	opds, html := NewOPDSHandler(), NewHTMLHandler()
+	for _, tt := range []struct {
+		h    http.Handler
+		path string
+	}{
+		{opds, "/opds"},
+		{opds, "/opds/all"},
+		{opds, "/opds/format/epub"},
+		{html, "/"},
+	} {
+		body := get(t, tt.h, tt.path)
+		if tt.path != "/opds" && !strings.Contains(body, "Shelved") {
+			t.Errorf("GET %s doesn't list the unarchived book", tt.path)
+		}
+		if strings.Contains(body, "Archived") {
+			t.Errorf("GET %s lists the archived book", tt.path)
+		}
+		if tt.path == "/opds" && !strings.Contains(body, "1 books") {
+			t.Errorf("GET /opds doesn't count only the unarchived book:\n%s", body)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/log"
+	"github.com/billmal071/bookdl/internal/tui"
+)
+
+// shutdownTimeout is how long in-flight requests get to finish on shutdown
+const shutdownTimeout = 5 * time.Second
+
+// Serve serves handler on listener until ctx is cancelled, then shuts down,
+// letting in-flight requests finish
+func Serve(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NewHTMLHandler returns a handler serving a searchable HTML index of the
+// completed downloads at /, with the files under /files. Files are sent
+// inline so browsers can open PDFs themselves.
+func NewHTMLHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", htmlIndex)
+	mux.HandleFunc("GET /files/{id}/{name}", fileHandler(true))
+	return mux
+}
+
+// indexRow is one book in the HTML index
+type indexRow struct {
+	Title   string
+	Authors string
+	Format  string
+	Size    string
+	URL     string
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>bookdl library</title>
+<style>
+body { font-family: sans-serif; margin: 1em auto; max-width: 60em; padding: 0 1em; }
+form { margin-bottom: 1em; }
+input[type=search] { width: 70%; padding: .4em; font-size: 1em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .4em; border-bottom: 1px solid #ddd; }
+td.format { text-transform: uppercase; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>bookdl library</h1>
+<form method="get" action="/">
+<input type="search" name="q" value="{{.Query}}" placeholder="Search by title or author" autofocus>
+<button type="submit">Search</button>
+{{if .Query}}<a href="/">Clear</a>{{end}}
+</form>
+<p class="muted">{{len .Rows}} of {{.Total}} books</p>
+{{if .Rows}}
+<table>
+<tr><th>Title</th><th>Author</th><th>Format</th><th>Size</th></tr>
+{{range .Rows}}<tr><td><a href="{{.URL}}">{{.Title}}</a></td><td>{{.Authors}}</td><td class="format">{{.Format}}</td><td>{{.Size}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>No books found.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// htmlIndex lists the books matching the ?q= search, most recent first
+func htmlIndex(w http.ResponseWriter, r *http.Request) {
+	books, err := library()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	var rows []indexRow
+	for _, d := range books {
+		if query != "" && !matchesQuery(d, query) {
+			continue
+		}
+		rows = append(rows, indexRow{
+			Title:   d.Title,
+			Authors: d.Authors,
+			Format:  d.Format,
+			Size:    tui.FormatSize(d.FileSize),
+			URL:     fileURL(d),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = indexTemplate.Execute(w, struct {
+		Query string
+		Total int
+		Rows  []indexRow
+	}{query, len(books), rows})
+	if err != nil {
+		log.Error("failed to render library index", "err", err)
+	}
+}
+
+// matchesQuery reports whether every word of query appears in the book's
+// title or authors, ignoring case
+func matchesQuery(d *db.Download, query string) bool {
+	text := strings.ToLower(d.Title + " " + d.Authors)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
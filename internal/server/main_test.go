@@ -0,0 +1,33 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/billmal071/bookdl/internal/db"
+)
+
+// TestMain runs the tests against a fresh database in a throwaway home
+// directory
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	home, err := os.MkdirTemp("", "bookdl-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(home)
+	os.Setenv("HOME", home)
+
+	if err := db.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	return m.Run()
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/billmal071/bookdl/internal/db"
+	"github.com/billmal071/bookdl/internal/log"
+)
+
+// OPDS 1.2 link types and relations
+const (
+	opdsNavigation  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	opdsAcquisition = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	relAcquisition  = "http://opds-spec.org/acquisition"
+	relSubsection   = "subsection"
+)
+
+// atomFeed is an OPDS catalog feed
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"feed"`
+	Xmlns     string      `xml:"xmlns,attr"`
+	XmlnsDC   string      `xml:"xmlns:dc,attr"`
+	XmlnsOPDS string      `xml:"xmlns:opds,attr"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Links     []atomLink  `xml:"link"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Authors   []atomAuthor `xml:"author"`
+	Language  string       `xml:"dc:language,omitempty"`
+	Publisher string       `xml:"dc:publisher,omitempty"`
+	Format    string       `xml:"dc:format,omitempty"`
+	Content   *atomContent `xml:"content,omitempty"`
+	Links     []atomLink   `xml:"link"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// NewOPDSHandler returns a handler serving the completed downloads as an
+// OPDS 1.2 catalog under /opds, with the files under /files
+func NewOPDSHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /opds", opdsRoot)
+	mux.HandleFunc("GET /opds/all", opdsAll)
+	mux.HandleFunc("GET /opds/format/{format}", opdsFormat)
+	mux.HandleFunc("GET /files/{id}/{name}", fileHandler(false))
+	mux.Handle("GET /{$}", http.RedirectHandler("/opds", http.StatusFound))
+	return mux
+}
+
+// newFeed creates an empty feed with the OPDS namespaces
+func newFeed(id, title, self, kind string) *atomFeed {
+	return &atomFeed{
+		Xmlns:     "http://www.w3.org/2005/Atom",
+		XmlnsDC:   "http://purl.org/dc/terms/",
+		XmlnsOPDS: "http://opds-spec.org/2010/catalog",
+		ID:        "urn:bookdl:" + id,
+		Title:     title,
+		Updated:   atomTime(time.Now()),
+		Author:    &atomAuthor{Name: "bookdl"},
+		Links: []atomLink{
+			{Rel: "self", Href: self, Type: kind},
+			{Rel: "start", Href: "/opds", Type: opdsNavigation},
+		},
+	}
+}
+
+// opdsRoot is the navigation feed: all books, then one subfeed per format
+func opdsRoot(w http.ResponseWriter, r *http.Request) {
+	books, err := library()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, d := range books {
+		counts[strings.ToLower(d.Format)]++
+	}
+	formats := make([]string, 0, len(counts))
+	for format := range counts {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+
+	feed := newFeed("root", "bookdl library", "/opds", opdsNavigation)
+	feed.Entries = append(feed.Entries, navEntry("all", "All books", fmt.Sprintf("%d books", len(books)), "/opds/all"))
+	for _, format := range formats {
+		if format == "" {
+			continue
+		}
+		feed.Entries = append(feed.Entries, navEntry("format:"+format, strings.ToUpper(format),
+			fmt.Sprintf("%d books", counts[format]), "/opds/format/"+format))
+	}
+	writeFeed(w, feed, opdsNavigation)
+}
+
+// navEntry links to a subfeed from the navigation feed
+func navEntry(id, title, summary, href string) atomEntry {
+	return atomEntry{
+		ID:      "urn:bookdl:" + id,
+		Title:   title,
+		Updated: atomTime(time.Now()),
+		Content: &atomContent{Type: "text", Text: summary},
+		Links:   []atomLink{{Rel: relSubsection, Href: href, Type: opdsAcquisition}},
+	}
+}
+
+// opdsAll is the acquisition feed of every book
+func opdsAll(w http.ResponseWriter, r *http.Request) {
+	books, err := library()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	feed := newFeed("all", "All books", "/opds/all", opdsAcquisition)
+	feed.Entries = bookEntries(books)
+	writeFeed(w, feed, opdsAcquisition)
+}
+
+// opdsFormat is the acquisition feed of the books in one format
+func opdsFormat(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.PathValue("format"))
+	books, err := library()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var matching []*db.Download
+	for _, d := range books {
+		if strings.EqualFold(d.Format, format) {
+			matching = append(matching, d)
+		}
+	}
+	feed := newFeed("format:"+format, strings.ToUpper(format)+" books", "/opds/format/"+format, opdsAcquisition)
+	feed.Entries = bookEntries(matching)
+	writeFeed(w, feed, opdsAcquisition)
+}
+
+// bookEntries turns downloads into acquisition entries
+func bookEntries(books []*db.Download) []atomEntry {
+	entries := make([]atomEntry, 0, len(books))
+	for _, d := range books {
+		updated := d.UpdatedAt
+		if d.CompletedAt != nil {
+			updated = *d.CompletedAt
+		}
+
+		entry := atomEntry{
+			ID:        "urn:md5:" + d.MD5Hash,
+			Title:     d.Title,
+			Updated:   atomTime(updated),
+			Language:  d.Language,
+			Publisher: d.Publisher,
+			Format:    mimeType(d.Format),
+			Links: []atomLink{{
+				Rel:   relAcquisition,
+				Href:  fileURL(d),
+				Type:  mimeType(d.Format),
+				Title: strings.ToUpper(d.Format),
+			}},
+		}
+		for _, author := range strings.Split(d.Authors, ",") {
+			if author = strings.TrimSpace(author); author != "" {
+				entry.Authors = append(entry.Authors, atomAuthor{Name: author})
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// writeFeed writes feed as XML with the given OPDS content type
+func writeFeed(w http.ResponseWriter, feed *atomFeed, kind string) {
+	w.Header().Set("Content-Type", kind+";charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		log.Error("failed to write OPDS feed", "err", err)
+	}
+}
+
+// atomTime formats t as an Atom date
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,240 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/billmal071/bookdl/internal/config"
+)
+
+// ConfigEditorModel is the Bubble Tea model for editing config settings
+type ConfigEditorModel struct {
+	fields   []config.Field
+	changed  map[string]bool
+	cursor   int
+	height   int
+	input    textinput.Model
+	editing  bool
+	err      string
+	saved    bool
+	quitting bool
+}
+
+// NewConfigEditor creates a config editor for fields
+func NewConfigEditor(fields []config.Field) ConfigEditorModel {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.CharLimit = 1024
+	input.Width = 60
+
+	return ConfigEditorModel{
+		fields:  fields,
+		changed: make(map[string]bool),
+		height:  24,
+		input:   input,
+	}
+}
+
+func (m ConfigEditorModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m ConfigEditorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.input.Width = msg.Width - 10
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.editing {
+			return m.updateEditing(msg)
+		}
+
+		m.err = ""
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.quitting = true
+			return m, tea.Quit
+		case "s":
+			m.saved = true
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.fields)-1 {
+				m.cursor++
+			}
+		case "enter", "e":
+			field := m.fields[m.cursor]
+			if field.Kind == config.KindMap {
+				m.err = fmt.Sprintf("%s is a map; edit it in the config file with 'bookdl config edit'", field.Key)
+				return m, nil
+			}
+			m.editing = true
+			m.input.SetValue(field.Value)
+			m.input.CursorEnd()
+			return m, m.input.Focus()
+		}
+		return m, nil
+	}
+
+	if m.editing {
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// updateEditing handles keys while a value is being edited
+func (m ConfigEditorModel) updateEditing(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+	case "esc":
+		m.editing = false
+		m.err = ""
+		m.input.Blur()
+		return m, nil
+	case "enter":
+		field := &m.fields[m.cursor]
+		value := strings.TrimSpace(m.input.Value())
+		if err := config.ValidateValue(field.Kind, value); err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		if value != field.Value {
+			field.Value = value
+			m.changed[field.Key] = true
+		}
+		m.editing = false
+		m.err = ""
+		m.input.Blur()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m ConfigEditorModel) View() string {
+	if m.saved {
+		return ""
+	}
+	if m.quitting {
+		return DimStyle.Render("\n  No changes saved.\n")
+	}
+
+	// One line per field plus a header per section; remember where the
+	// cursor's line is so the view can scroll to it
+	var lines []string
+	cursorLine := 0
+	section := ""
+	for i, field := range m.fields {
+		if field.Section != section {
+			section = field.Section
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, LabelStyle.Render("  "+section))
+		}
+		if i == m.cursor {
+			cursorLine = len(lines)
+		}
+		lines = append(lines, m.renderField(i, field))
+	}
+
+	// Keep the cursor visible, leaving room for the title, input and help
+	visible := m.height - 8
+	if visible < 5 {
+		visible = 5
+	}
+	start := 0
+	if cursorLine >= visible {
+		start = cursorLine - visible + 1
+	}
+	end := start + visible
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	var view strings.Builder
+	view.WriteString("\n")
+	view.WriteString(TitleStyle.Render("  Edit Configuration"))
+	view.WriteString("\n")
+	view.WriteString(strings.Join(lines[start:end], "\n"))
+	view.WriteString("\n\n")
+
+	if m.editing {
+		field := m.fields[m.cursor]
+		view.WriteString(fmt.Sprintf("  %s (%s)\n  %s\n", field.Key, field.Kind, m.input.View()))
+	}
+	if m.err != "" {
+		view.WriteString(ErrorStyle.Render("  "+m.err) + "\n")
+	}
+
+	help := "  ↑/↓: navigate • enter: edit • s: save and quit • q: quit without saving"
+	if m.editing {
+		help = "  enter: keep value • esc: cancel"
+	}
+	if n := len(m.changed); n > 0 {
+		help += fmt.Sprintf(" • %d unsaved change(s)", n)
+	}
+	view.WriteString(HelpStyle.Render(help))
+
+	return view.String()
+}
+
+// renderField renders one setting: its name, value and kind
+func (m ConfigEditorModel) renderField(i int, field config.Field) string {
+	name := field.Key[len(field.Section)+1:]
+	value := field.Value
+	if value == "" {
+		value = `""`
+	}
+	if len(value) > 50 {
+		value = value[:47] + "..."
+	}
+	if m.changed[field.Key] {
+		name += "*"
+	}
+
+	line := fmt.Sprintf("%-24s %s", name, value)
+	if i == m.cursor {
+		return SelectedStyle.Render("  ➤ "+line) + DimStyle.Render("  "+field.Kind)
+	}
+	return NormalStyle.Render("    " + line)
+}
+
+// RunConfigEditor shows the editor and returns the settings the user
+// changed, or nil if they quit without saving
+func RunConfigEditor(fields []config.Field) ([]config.Field, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no settings to edit")
+	}
+
+	p := tea.NewProgram(NewConfigEditor(fields))
+	finalModel, err := p.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	final := finalModel.(ConfigEditorModel)
+	if !final.saved {
+		return nil, nil
+	}
+	var changed []config.Field
+	for _, field := range final.fields {
+		if final.changed[field.Key] {
+			changed = append(changed, field)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,284 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/billmal071/bookdl/internal/downloader"
+)
+
+// Download states shown in the dashboard
+const (
+	DownloadQueued    = "queued"
+	DownloadRunning   = "downloading"
+	DownloadPaused    = "paused"
+	DownloadCompleted = "completed"
+	DownloadFailed    = "failed"
+)
+
+// DashboardRow is one download shown in the dashboard
+type DashboardRow struct {
+	ID         int64
+	Title      string
+	Status     string
+	Downloaded int64
+	Total      int64
+	Speed      float64
+}
+
+// DashboardActions pause and resume downloads from the dashboard. They run
+// outside the UI loop, so they may block.
+type DashboardActions struct {
+	Pause  func(id int64) error
+	Resume func(id int64) error
+}
+
+// progressMsg carries a progress update into the dashboard
+type progressMsg downloader.ProgressUpdate
+
+// statusMsg changes a download's state
+type statusMsg struct {
+	id     int64
+	status string
+}
+
+// actionMsg reports the outcome of a pause or resume
+type actionMsg struct {
+	err error
+}
+
+// dashboardDoneMsg closes the dashboard once every download has finished
+type dashboardDoneMsg struct{}
+
+// DashboardModel is the Bubble Tea model for the download dashboard
+type DashboardModel struct {
+	rows     []*DashboardRow
+	actions  DashboardActions
+	message  string
+	detached bool
+	done     bool
+}
+
+// NewDashboardModel creates a dashboard showing downloads in the given order
+func NewDashboardModel(rows []DashboardRow, actions DashboardActions) DashboardModel {
+	m := DashboardModel{actions: actions}
+	for i := range rows {
+		row := rows[i]
+		if row.Status == "" {
+			row.Status = DownloadQueued
+		}
+		m.rows = append(m.rows, &row)
+	}
+	return m
+}
+
+func (m DashboardModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m DashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch key := msg.String(); key {
+		case "q", "esc", "ctrl+c":
+			m.detached = true
+			return m, tea.Quit
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			return m, m.toggle(int(key[0] - '1'))
+		}
+	case progressMsg:
+		if row := m.row(msg.ID); row != nil {
+			row.Downloaded = msg.Downloaded
+			row.Total = msg.Total
+			row.Speed = msg.Speed
+			if row.Status == DownloadQueued {
+				row.Status = DownloadRunning
+			}
+		}
+	case statusMsg:
+		if row := m.row(msg.id); row != nil {
+			row.Status = msg.status
+			if msg.status != DownloadRunning {
+				row.Speed = 0
+			}
+		}
+	case actionMsg:
+		m.message = ""
+		if msg.err != nil {
+			m.message = ErrorStyle.Render(msg.err.Error())
+		}
+	case dashboardDoneMsg:
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// row returns the row for a download ID, or nil
+func (m DashboardModel) row(id int64) *DashboardRow {
+	for _, row := range m.rows {
+		if row.ID == id {
+			return row
+		}
+	}
+	return nil
+}
+
+// toggle pauses the running download in row i, or resumes it if paused
+func (m *DashboardModel) toggle(i int) tea.Cmd {
+	if i < 0 || i >= len(m.rows) {
+		return nil
+	}
+	row := m.rows[i]
+
+	var action func(id int64) error
+	switch row.Status {
+	case DownloadRunning:
+		action = m.actions.Pause
+		m.message = DimStyle.Render(fmt.Sprintf("Pausing #%d...", row.ID))
+	case DownloadPaused:
+		action = m.actions.Resume
+		row.Status = DownloadRunning
+		m.message = DimStyle.Render(fmt.Sprintf("Resuming #%d...", row.ID))
+	}
+	if action == nil {
+		return nil
+	}
+
+	id := row.ID
+	return func() tea.Msg {
+		return actionMsg{err: action(id)}
+	}
+}
+
+// progressBar renders a bar width cells wide for the given fraction
+func progressBar(fraction float64, width int) string {
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction * float64(width))
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+	if fraction >= 1 {
+		return ProgressCompleteStyle.Render(bar)
+	}
+	return ProgressStyle.Render(bar)
+}
+
+// eta estimates the remaining time for a row, or "" if unknown
+func eta(row *DashboardRow) string {
+	if row.Status != DownloadRunning || row.Speed <= 0 || row.Total <= 0 {
+		return ""
+	}
+	remaining := time.Duration(float64(row.Total-row.Downloaded) / row.Speed * float64(time.Second))
+	return "ETA " + remaining.Round(time.Second).String()
+}
+
+// statusStyle renders a download state in its color
+func statusStyle(status string) string {
+	switch status {
+	case DownloadCompleted:
+		return SuccessStyle.Render(status)
+	case DownloadFailed:
+		return ErrorStyle.Render(status)
+	case DownloadPaused:
+		return WarningStyle.Render(status)
+	case DownloadRunning:
+		return ProgressStyle.Render(status)
+	default:
+		return DimStyle.Render(status)
+	}
+}
+
+func (m DashboardModel) View() string {
+	if m.detached {
+		return DimStyle.Render("\n  Detached, downloads continue in the background.\n")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n" + TitleStyle.Render("Downloads") + "\n")
+
+	for i, row := range m.rows {
+		title := row.Title
+		if len(title) > 40 {
+			title = title[:37] + "..."
+		}
+
+		var fraction float64
+		size := FormatSize(row.Downloaded)
+		if row.Total > 0 {
+			fraction = float64(row.Downloaded) / float64(row.Total)
+			size += " / " + FormatSize(row.Total)
+		}
+		if row.Status == DownloadCompleted {
+			fraction = 1
+		}
+
+		line := fmt.Sprintf("  %d. %-40s %s %3.0f%%  %s",
+			i+1, title, progressBar(fraction, 20), fraction*100, statusStyle(row.Status))
+		sb.WriteString(NormalStyle.Render(line) + "\n")
+
+		details := []string{size}
+		if row.Status == DownloadRunning && row.Speed > 0 {
+			details = append(details, FormatSize(int64(row.Speed))+"/s")
+		}
+		if e := eta(row); e != "" {
+			details = append(details, e)
+		}
+		sb.WriteString(DimStyle.Render("     "+strings.Join(details, " | ")) + "\n")
+	}
+
+	if m.message != "" {
+		sb.WriteString("\n  " + m.message + "\n")
+	}
+
+	if !m.done {
+		help := "1-9: pause/resume • q: detach"
+		if len(m.rows) > 9 {
+			help = "1-9: pause/resume the first nine • q: detach"
+		}
+		sb.WriteString(HelpStyle.Render("  " + help))
+	}
+	return sb.String()
+}
+
+// Dashboard runs a DashboardModel and feeds it updates from download
+// goroutines
+type Dashboard struct {
+	program *tea.Program
+}
+
+// NewDashboard creates a dashboard for the given downloads
+func NewDashboard(rows []DashboardRow, actions DashboardActions) *Dashboard {
+	return &Dashboard{program: tea.NewProgram(NewDashboardModel(rows, actions))}
+}
+
+// Progress updates a download's progress; use it as the manager's
+// progress listener
+func (d *Dashboard) Progress(u downloader.ProgressUpdate) {
+	d.program.Send(progressMsg(u))
+}
+
+// SetStatus changes a download's state
+func (d *Dashboard) SetStatus(id int64, status string) {
+	d.program.Send(statusMsg{id: id, status: status})
+}
+
+// Done closes the dashboard once all downloads have finished
+func (d *Dashboard) Done() {
+	d.program.Send(dashboardDoneMsg{})
+}
+
+// Run shows the dashboard until Done is called or the user detaches, and
+// reports whether they detached. Updates sent after that are dropped.
+func (d *Dashboard) Run() (detached bool, err error) {
+	final, err := d.program.Run()
+	if err != nil {
+		return false, err
+	}
+	return final.(DashboardModel).detached, nil
+}
@@ -34,6 +34,12 @@ func (h HistoryItem) Description() string {
 	if h.History.Filters.MaxSize != "" {
 		filterParts = append(filterParts, "max-size="+h.History.Filters.MaxSize)
 	}
+	if h.History.Filters.Author != "" {
+		filterParts = append(filterParts, "author="+h.History.Filters.Author)
+	}
+	if h.History.Filters.Publisher != "" {
+		filterParts = append(filterParts, "publisher="+h.History.Filters.Publisher)
+	}
 	if len(filterParts) > 0 {
 		parts = append(parts, strings.Join(filterParts, ", "))
 	}
@@ -0,0 +1,146 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// MirrorOption is one download link offered in the mirror selector
+type MirrorOption struct {
+	URL   string
+	Host  string
+	Label string // what kind of mirror it is, e.g. "IPFS gateway"
+}
+
+// MirrorItem wraps a MirrorOption for the list component
+type MirrorItem struct {
+	Option MirrorOption
+}
+
+func (m MirrorItem) Title() string       { return m.Option.Host }
+func (m MirrorItem) Description() string { return m.Option.Label }
+func (m MirrorItem) FilterValue() string { return m.Option.Host + " " + m.Option.Label }
+
+// MirrorDelegate handles rendering of mirror items
+type MirrorDelegate struct{}
+
+func (d MirrorDelegate) Height() int                             { return 2 }
+func (d MirrorDelegate) Spacing() int                            { return 0 }
+func (d MirrorDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+
+func (d MirrorDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	mirror, ok := item.(MirrorItem)
+	if !ok {
+		return
+	}
+
+	line := fmt.Sprintf("%d. %s", index+1, mirror.Option.Host)
+	var str string
+	if index == m.Index() {
+		str = SelectedStyle.Render("  ➤ " + line)
+	} else {
+		str = NormalStyle.Render("    " + line)
+	}
+
+	link := mirror.Option.URL
+	if len(link) > 70 {
+		link = link[:67] + "..."
+	}
+	str += "\n" + DimStyle.Render(fmt.Sprintf("      %s | %s", mirror.Option.Label, link))
+
+	fmt.Fprint(w, str)
+}
+
+// MirrorSelectorModel is the Bubble Tea model for choosing a mirror
+type MirrorSelectorModel struct {
+	list     list.Model
+	options  []MirrorOption
+	selected int
+	quitting bool
+}
+
+// NewMirrorSelector creates a new mirror selector TUI
+func NewMirrorSelector(options []MirrorOption) MirrorSelectorModel {
+	items := make([]list.Item, len(options))
+	for i, o := range options {
+		items[i] = MirrorItem{Option: o}
+	}
+
+	l := list.New(items, MirrorDelegate{}, 80, len(options)*2+4)
+	l.Title = "Choose a Mirror"
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(false)
+	l.SetShowHelp(false)
+	l.Styles.Title = TitleStyle
+
+	return MirrorSelectorModel{
+		list:     l,
+		options:  options,
+		selected: -1,
+	}
+}
+
+func (m MirrorSelectorModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m MirrorSelectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			m.quitting = true
+			return m, tea.Quit
+		case "enter":
+			m.selected = m.list.Index()
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.list.SetWidth(msg.Width)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m MirrorSelectorModel) View() string {
+	if m.selected >= 0 {
+		return SuccessStyle.Render(fmt.Sprintf("\n  ✓ Using %s\n", m.options[m.selected].Host))
+	}
+
+	if m.quitting {
+		return DimStyle.Render("\n  Keeping the default mirror order.\n")
+	}
+
+	help := HelpStyle.Render("  ↑/↓: navigate • enter: select • q: use default order")
+
+	var view strings.Builder
+	view.WriteString("\n")
+	view.WriteString(m.list.View())
+	view.WriteString("\n")
+	view.WriteString(help)
+
+	return view.String()
+}
+
+// RunMirrorSelector displays the TUI and returns the index of the chosen
+// mirror, or -1 if the user cancelled
+func RunMirrorSelector(options []MirrorOption) (int, error) {
+	if len(options) == 0 {
+		return -1, fmt.Errorf("no mirrors available")
+	}
+
+	p := tea.NewProgram(NewMirrorSelector(options))
+	finalModel, err := p.Run()
+	if err != nil {
+		return -1, err
+	}
+
+	return finalModel.(MirrorSelectorModel).selected, nil
+}
@@ -3,18 +3,38 @@ package tui
 import (
 	"fmt"
 	"io"
-	"os/exec"
-	"runtime"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/billmal071/bookdl/internal/anna"
+	"github.com/billmal071/bookdl/internal/open"
 )
 
 // LoadMoreFunc is a callback to load more search results
 type LoadMoreFunc func() ([]*anna.Book, error)
 
+// resultOrder, when set, re-sorts the list after more results are loaded
+var resultOrder func([]*anna.Book)
+
+// SetResultOrder sets how the selector orders results once more are loaded,
+// so merged pages stay sorted. nil keeps them in load order.
+func SetResultOrder(order func([]*anna.Book)) {
+	resultOrder = order
+}
+
+// bookSorter sorts books in place by key; it backs the s/r keys
+var bookSorter func(books []*anna.Book, key string, desc bool)
+
+// SetBookSorter sets the sort used when cycling sort keys in the selector.
+// Without one, the s and r keys do nothing.
+func SetBookSorter(sorter func(books []*anna.Book, key string, desc bool)) {
+	bookSorter = sorter
+}
+
+// selectorSortKeys are cycled through with the s key
+var selectorSortKeys = []string{"title", "size", "year"}
+
 // loadMoreMsg is sent when more results are loaded
 type loadMoreMsg struct {
 	books []*anna.Book
@@ -46,6 +66,9 @@ func (b BookItem) Description() string {
 	if b.Book.Language != "" {
 		parts = append(parts, b.Book.Language)
 	}
+	if b.Book.Editions > 1 {
+		parts = append(parts, fmt.Sprintf("(%d editions)", b.Book.Editions))
+	}
 
 	if len(parts) == 0 {
 		return DimStyle.Render("No metadata available")
@@ -53,7 +76,7 @@ func (b BookItem) Description() string {
 	return DimStyle.Render(strings.Join(parts, " | "))
 }
 
-func (b BookItem) FilterValue() string { return b.Book.Title }
+func (b BookItem) FilterValue() string { return b.Book.Title + " " + b.Book.Authors }
 
 // BookDelegate handles rendering of book items
 type BookDelegate struct {
@@ -130,6 +153,8 @@ type SelectorModel struct {
 	browserMsg    string
 	multiSelect   bool
 	checkedMD5s   map[string]bool
+	sortKey       string // set once the user sorts with s
+	sortDesc      bool
 }
 
 // NewSelector creates a new book selector TUI
@@ -168,7 +193,7 @@ func newSelector(books []*anna.Book, title string, loadMore LoadMoreFunc, multiS
 	l := list.New(items, delegate, 80, 20)
 	l.Title = title
 	l.SetShowStatusBar(true)
-	l.SetFilteringEnabled(false)
+	l.SetFilteringEnabled(true)
 	l.SetShowHelp(false) // We show our own help
 	l.Styles.Title = TitleStyle
 
@@ -192,6 +217,14 @@ func (m SelectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.loading {
 			return m, nil
 		}
+		// While typing a filter, every key goes to the list
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		// Esc clears an applied filter before it cancels
+		if m.list.FilterState() == list.FilterApplied && msg.String() == "esc" {
+			break
+		}
 		switch msg.String() {
 		case "q", "ctrl+c", "esc":
 			m.quitting = true
@@ -252,6 +285,21 @@ func (m SelectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.loading = true
 				return m, m.doLoadMore()
 			}
+		case "s":
+			// Cycle the sort key
+			if bookSorter != nil {
+				m.sortKey = nextSortKey(m.sortKey)
+				return m, m.resort()
+			}
+		case "r":
+			// Reverse the current sort
+			if bookSorter != nil {
+				if m.sortKey == "" {
+					m.sortKey = selectorSortKeys[0]
+				}
+				m.sortDesc = !m.sortDesc
+				return m, m.resort()
+			}
 		case "i", "I":
 			// Toggle details view
 			m.showDetails = !m.showDetails
@@ -261,7 +309,7 @@ func (m SelectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Open book page in browser
 			if item, ok := m.list.SelectedItem().(BookItem); ok {
 				if item.Book.PageURL != "" {
-					if err := openBrowser(item.Book.PageURL); err != nil {
+					if err := open.Start(item.Book.PageURL); err != nil {
 						m.browserMsg = ErrorStyle.Render("Failed to open browser")
 					} else {
 						m.browserMsg = SuccessStyle.Render("Opened in browser")
@@ -297,9 +345,11 @@ func (m SelectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Append new items to the list
 		currentItems := m.list.Items()
 		allItems := append(currentItems, newItems...)
-		m.list.SetItems(allItems)
+		if order := m.order(); order != nil {
+			return m, m.setSorted(allItems, order)
+		}
 		// Don't change height - let the list handle scrolling
-		return m, nil
+		return m, m.list.SetItems(allItems)
 	case tea.WindowSizeMsg:
 		m.list.SetWidth(msg.Width)
 		return m, nil
@@ -310,6 +360,75 @@ func (m SelectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// order returns the sort to apply to the list: the user's s/r choice if
+// any, otherwise the one set with SetResultOrder
+func (m SelectorModel) order() func([]*anna.Book) {
+	if m.sortKey != "" && bookSorter != nil {
+		key, desc := m.sortKey, m.sortDesc
+		return func(books []*anna.Book) {
+			bookSorter(books, key, desc)
+		}
+	}
+	return resultOrder
+}
+
+// resort re-sorts the loaded books after the sort key or direction changed
+func (m *SelectorModel) resort() tea.Cmd {
+	m.browserMsg = ""
+	return m.setSorted(m.list.Items(), m.order())
+}
+
+// setSorted replaces the list with a sorted copy of items, keeping the
+// cursor on the same book. Checked books are tracked by MD5, so they stay
+// checked.
+func (m *SelectorModel) setSorted(items []list.Item, order func([]*anna.Book)) tea.Cmd {
+	sorted, cursor := m.reorder(items, order)
+	cmd := m.list.SetItems(sorted)
+	// With a filter applied the visible items are refiltered, so the
+	// cursor index no longer matches
+	if m.list.FilterState() == list.Unfiltered {
+		m.list.Select(cursor)
+	}
+	return cmd
+}
+
+// nextSortKey returns the sort key after key, wrapping around
+func nextSortKey(key string) string {
+	for i, k := range selectorSortKeys {
+		if k == key {
+			return selectorSortKeys[(i+1)%len(selectorSortKeys)]
+		}
+	}
+	return selectorSortKeys[0]
+}
+
+// reorder sorts a copy of items with order and returns the new index of the
+// selected book, so the cursor stays on it
+func (m SelectorModel) reorder(items []list.Item, order func([]*anna.Book)) ([]list.Item, int) {
+	var current string
+	if item, ok := m.list.SelectedItem().(BookItem); ok {
+		current = item.Book.MD5Hash
+	}
+
+	books := make([]*anna.Book, 0, len(items))
+	for _, item := range items {
+		if bi, ok := item.(BookItem); ok {
+			books = append(books, bi.Book)
+		}
+	}
+	order(books)
+
+	sorted := make([]list.Item, len(books))
+	cursor := 0
+	for i, book := range books {
+		sorted[i] = BookItem{Book: book}
+		if book.MD5Hash == current {
+			cursor = i
+		}
+	}
+	return sorted, cursor
+}
+
 // doLoadMore returns a command that loads more results
 func (m SelectorModel) doLoadMore() tea.Cmd {
 	return func() tea.Msg {
@@ -337,22 +456,6 @@ func (m *SelectorModel) updateDelegate() {
 	m.list.SetDelegate(delegate)
 }
 
-// openBrowser opens a URL in the default browser
-func openBrowser(url string) error {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "linux":
-		cmd = exec.Command("xdg-open", url)
-	case "darwin":
-		cmd = exec.Command("open", url)
-	case "windows":
-		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
-	default:
-		return fmt.Errorf("unsupported platform")
-	}
-	return cmd.Start()
-}
-
 // renderDetailsView renders the book details panel
 func (m SelectorModel) renderDetailsView() string {
 	item, ok := m.list.SelectedItem().(BookItem)
@@ -457,6 +560,18 @@ func (m SelectorModel) View() string {
 	} else {
 		helpParts = []string{"↑/↓: navigate", "enter: select", "i: details"}
 	}
+	helpParts = append(helpParts, "/: filter")
+	if bookSorter != nil {
+		sortHelp := "s: sort"
+		if m.sortKey != "" {
+			dir := "asc"
+			if m.sortDesc {
+				dir = "desc"
+			}
+			sortHelp = fmt.Sprintf("s: sort (%s %s)", m.sortKey, dir)
+		}
+		helpParts = append(helpParts, sortHelp, "r: reverse")
+	}
 	if m.showDetails {
 		helpParts = append(helpParts, "o: open in browser")
 	}
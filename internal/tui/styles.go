@@ -4,8 +4,19 @@ import (
 	"fmt"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
+// SetColorEnabled forces colored output on or off for all styles,
+// overriding lipgloss' own terminal detection
+func SetColorEnabled(enabled bool) {
+	if enabled {
+		lipgloss.SetColorProfile(termenv.ANSI256)
+	} else {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
 var (
 	// Colors
 	primaryColor   = lipgloss.Color("170") // Purple